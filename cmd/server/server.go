@@ -31,8 +31,12 @@ func main() {
 	mux := http.NewServeMux()
 	mux.Handle("GET /api/cubes", server.CubesHandler(reg))
 
+	// Cross-cube comparisons name their cubes in the cubes query param.
+	mux.Handle("GET /api/compare/cards", server.WithValidMatch(stats.CompareCardsHandler(reg)))
+	mux.Handle("GET /api/compare/metagame", server.WithValidMatch(stats.CompareMetagameHandler(reg)))
+
 	cubeRoute := func(pattern string, h http.Handler) {
-		mux.Handle(pattern, server.WithCube(reg, h))
+		mux.Handle(pattern, server.WithCube(reg, server.WithValidMatch(server.WithValidBucketBy(h))))
 	}
 	cubeRoute("GET /api/{cube}/cube", server.CubeContentHandler())
 	cubeRoute("GET /api/{cube}/index", server.CubeIndexHandler())
@@ -41,23 +45,57 @@ func main() {
 	deckStore := storage.NewFileDeckStore()
 	cubeRoute("GET /api/{cube}/decks", decks.DeckHandler(deckStore))
 	cubeRoute("POST /api/{cube}/decks/update", decks.UpdateDeckHandler(deckStore))
+	cubeRoute("GET /api/{cube}/decks/{draft_id}/{player}/similar", decks.SimilarDecksHandler(deckStore))
 	cubeRoute("GET /api/{cube}/archetypes", server.ArchetypesHandler())
 	cubeRoute("GET /api/{cube}/stats/cards", stats.CardStatsHandler())
 	cubeRoute("GET /api/{cube}/stats/colors", stats.ColorStatsHandler())
 	cubeRoute("GET /api/{cube}/stats/synergy", stats.SynergyStatsHandler())
+	cubeRoute("GET /api/{cube}/stats/synergy/itemsets", stats.SynergyItemsetsHandler())
 	cubeRoute("GET /api/{cube}/stats/archetypes", stats.ArchetypeStatsHandler())
+	cubeRoute("GET /api/{cube}/stats/archetype-clusters", stats.ArchetypeClustersHandler())
+	cubeRoute("POST /api/{cube}/stats/archetype-clusters/accept", stats.AcceptClusterSuggestionsHandler(deckStore))
 	cubeRoute("GET /api/{cube}/stats/players", stats.PlayerStatsHandler())
+	cubeRoute("GET /api/{cube}/stats/players/h2h", stats.HeadToHeadHandler())
+	cubeRoute("GET /api/{cube}/stats/players/{player}/h2h", stats.PlayerRivalriesHandler())
+	cubeRoute("GET /api/{cube}/stats/players/{player}/profile", stats.PlayerProfileHandler())
 	cubeRoute("GET /api/{cube}/stats/color-matchups", stats.ColorMatchupHandler())
 	cubeRoute("POST /api/{cube}/stats/pivot", stats.PivotHandler())
 	cubeRoute("GET /api/{cube}/stats/removal", stats.RemovalHandler())
+	cubeRoute("GET /api/{cube}/stats/removal/coverage", stats.RemovalCoverageHandler())
+	cubeRoute("GET /api/{cube}/stats/optimal-ranges", stats.OptimalRangesHandler())
+	cubeRoute("GET /api/{cube}/stats/sideboard", stats.SideboardHandler())
+	cubeRoute("GET /api/{cube}/stats/manabase", stats.FixingReportHandler())
+	cubeRoute("GET /api/{cube}/stats/manabase/{draft_id}/{player}", stats.DeckManaBaseHandler())
 	cubeRoute("GET /api/{cube}/stats/health", stats.HealthStatsHandler())
+	cubeRoute("GET /api/{cube}/stats/forecast", stats.MetagameForecastHandler())
 	cubeRoute("GET /api/{cube}/stats/design-graph", stats.DesignGraphHandler())
 	cubeRoute("POST /api/{cube}/stats/design-graph/match", stats.DesignGraphMatchHandler())
+	cubeRoute("GET /api/{cube}/stats/design-graph/validation", stats.DesignValidationHandler())
 	cubeRoute("GET /api/{cube}/stats/group-distributions", stats.GroupDistributionsHandler())
-	cubeRoute("POST /api/{cube}/save-design-rules", stats.SaveDesignRulesHandler())
+	cubeRoute("GET /api/{cube}/stats/group-balance", stats.GroupBalanceHandler())
+
+	// Design rules keep a revision per save; the handlers share one store so
+	// saves and restores are serialized.
+	rules := stats.NewDesignRules()
+	cubeRoute("POST /api/{cube}/save-design-rules", rules.SaveHandler())
+	cubeRoute("GET /api/{cube}/design-rules/history", rules.HistoryHandler())
+	cubeRoute("GET /api/{cube}/design-rules/history/{id}", rules.RevisionHandler())
+	cubeRoute("POST /api/{cube}/design-rules/history/{id}/restore", rules.RestoreHandler())
+	cubeRoute("GET /api/{cube}/design-rules/diff", rules.DiffHandler())
+
+	cubeRoute("GET /api/{cube}/removal-overrides", stats.RemovalOverridesHandler())
+	cubeRoute("POST /api/{cube}/removal-overrides", stats.SaveRemovalOverridesHandler())
 	cubeRoute("POST /api/{cube}/save-notes", server.SaveNotesHandler())
 	cubeRoute("POST /api/{cube}/refresh", server.RefreshHandler(reg))
 
+	// Named, persisted stats queries that re-run against current data.
+	saved := stats.NewSavedQueries()
+	cubeRoute("GET /api/{cube}/saved", saved.ListHandler())
+	cubeRoute("GET /api/{cube}/saved/{name}", saved.GetHandler())
+	cubeRoute("POST /api/{cube}/saved/{name}", saved.SaveHandler())
+	cubeRoute("DELETE /api/{cube}/saved/{name}", saved.DeleteHandler())
+	cubeRoute("GET /api/{cube}/saved/{name}/run", saved.RunHandler())
+
 	// OCR draft-import endpoints. The detector is shared across requests; built
 	// without `-tags ocr_cv` its calls return an error explaining the rebuild.
 	det := ocrhttp.NewDetector()
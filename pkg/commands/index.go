@@ -277,7 +277,9 @@ func getSubDirectories(directory string) ([]string, error) {
 
 	// Iterate over the directory content
 	for _, file := range files {
-		if file.IsDir() {
+		// Hidden directories hold working state, like the design rules
+		// history, rather than drafts.
+		if file.IsDir() && !strings.HasPrefix(file.Name(), ".") {
 			subDirs = append(subDirs, file.Name())
 		}
 	}
@@ -11,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/caseydavenport/cube-tools/pkg/cubes"
 	"github.com/caseydavenport/cube-tools/pkg/flag"
 	"github.com/caseydavenport/cube-tools/pkg/types"
 	"github.com/sirupsen/logrus"
@@ -120,20 +121,15 @@ func parseDeck(deckFiles []string, who, labels, date, draftID string) (*types.De
 		return nil, fmt.Errorf("Too many card sets (%d) found in deck files: %v", len(cardSets), deckFiles)
 	}
 
-	// Build the deck struct. Macro archetype values (aggro/midrange/control)
-	// passed through --labels are auto-promoted to the dedicated field so callers
-	// don't need to keep them in sync.
+	// Build the deck struct. Macro archetype values (the cube's configured
+	// macros) passed through --labels are auto-promoted to the dedicated field
+	// so callers don't need to keep them in sync.
 	d := types.NewDeck()
 	if len(labels) > 0 {
 		for _, l := range strings.Split(labels, ",") {
-			l = strings.TrimSpace(l)
-			switch strings.ToLower(l) {
-			case "aggro", "midrange", "control":
-				d.MacroArchetype = strings.ToLower(l)
-			default:
-				d.Labels = append(d.Labels, l)
-			}
+			d.Labels = append(d.Labels, strings.TrimSpace(l))
 		}
+		cubes.Lookup("data", cubeFlag).PromoteMacro(d)
 	}
 	d.Player = who
 	d.Date = date
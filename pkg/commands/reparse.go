@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
 
+	"github.com/caseydavenport/cube-tools/pkg/cubes"
 	"github.com/caseydavenport/cube-tools/pkg/types"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -39,6 +41,8 @@ func reparse(cube string) error {
 		return fmt.Errorf("failed to unmarshal index file: %w", err)
 	}
 
+	meta := cubes.Lookup("data", cube)
+
 	// Iterate over the drafts and reparse each one.
 	for _, draft := range index.Drafts {
 		for _, deckIndex := range draft.Decks {
@@ -48,6 +52,27 @@ func reparse(cube string) error {
 				return fmt.Errorf("failed to load deck file %s: %w", deckIndex.Path, err)
 			}
 
+			// Migrate the deck's tags to the cube's macro taxonomy: a label
+			// naming one of the cube's macros moves into the macro field, so
+			// reparsing after adding a macro to the registry brings old decks
+			// along. Save in place first so decks without source files are
+			// migrated too; writeDeck preserves the tags below.
+			if meta.PromoteMacro(deck) {
+				logrus.WithFields(logrus.Fields{
+					"deck":  deckIndex.Path,
+					"macro": deck.MacroArchetype,
+				}).Info("Migrated macro archetype")
+				if err := deck.Save(deckIndex.Path); err != nil {
+					return fmt.Errorf("failed to save deck file %s: %w", deckIndex.Path, err)
+				}
+			}
+			if m := deck.MacroArchetype; m != "" && !slices.Contains(meta.MacroArchetypes(), m) {
+				logrus.WithFields(logrus.Fields{
+					"deck":  deckIndex.Path,
+					"macro": m,
+				}).Warn("Deck macro archetype isn't in the cube's taxonomy")
+			}
+
 			// Build path to the .txt or csv file.
 			srcFiles := deck.Metadata.GetSourceFiles()
 
@@ -4,6 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/caseydavenport/cube-tools/pkg/types"
 )
 
 type Cube struct {
@@ -18,6 +23,69 @@ type Cube struct {
 	// SleeveColor names the sleeve color the OCR photo scanner keys on for this
 	// cube (e.g. "orange", "purple"). Empty means the default (orange).
 	SleeveColor string `json:"sleeve_color,omitempty"`
+
+	// Macros lists the macro archetypes the cube's decks are classified into,
+	// in display order (e.g. adding "combo" or "ramp"). Empty means
+	// types.DefaultMacros.
+	Macros []string `json:"macros,omitempty"`
+
+	// Labels is the vocabulary deck labels are drawn from. Empty allows any
+	// label.
+	Labels []string `json:"labels,omitempty"`
+}
+
+// MacroArchetypes returns the cube's configured macro archetypes, or the
+// defaults if it doesn't configure any.
+func (c Cube) MacroArchetypes() []string {
+	if len(c.Macros) == 0 {
+		return types.DefaultMacros
+	}
+	return c.Macros
+}
+
+// ValidateDeckMeta checks a deck's macro archetype and labels against the
+// cube's taxonomy. An empty macro is allowed, since not every deck has been
+// classified.
+func (c Cube) ValidateDeckMeta(macro string, labels []string) error {
+	macros := c.MacroArchetypes()
+	if macro != "" && !slices.Contains(macros, strings.ToLower(macro)) {
+		return fmt.Errorf("unknown macro archetype %q; cube %s uses %s", macro, c.ID, strings.Join(macros, ", "))
+	}
+	for _, l := range labels {
+		if slices.Contains(macros, strings.ToLower(l)) {
+			return fmt.Errorf("label %q is a macro archetype; set it as the macro instead", l)
+		}
+		if len(c.Labels) > 0 && !slices.Contains(c.Labels, l) {
+			return fmt.Errorf("unknown label %q; cube %s allows %s", l, c.ID, strings.Join(c.Labels, ", "))
+		}
+	}
+	return nil
+}
+
+// PromoteMacro moves a label naming one of the cube's macro archetypes into
+// the deck's MacroArchetype field, for decks tagged before the field existed
+// or before the cube added the macro. An existing macro wins over a label, and
+// the macro is lowercased either way. It reports whether the deck changed.
+func (c Cube) PromoteMacro(d *types.Deck) bool {
+	macros := c.MacroArchetypes()
+	changed := false
+	if m := strings.ToLower(d.MacroArchetype); m != d.MacroArchetype {
+		d.MacroArchetype, changed = m, true
+	}
+	labels := make([]string, 0, len(d.Labels))
+	for _, l := range d.Labels {
+		m := strings.ToLower(l)
+		if !slices.Contains(macros, m) {
+			labels = append(labels, l)
+			continue
+		}
+		if d.MacroArchetype == "" {
+			d.MacroArchetype = m
+		}
+		changed = true
+	}
+	d.Labels = labels
+	return changed
 }
 
 type Registry struct {
@@ -46,6 +114,14 @@ func Load(path string) (*Registry, error) {
 		if _, dup := idx[c.ID]; dup {
 			return nil, fmt.Errorf("duplicate cube id %q in registry", c.ID)
 		}
+		for _, m := range c.Macros {
+			if m == "" || m != strings.ToLower(m) {
+				return nil, fmt.Errorf("cube %q: macro archetypes must be non-empty and lowercase, got %q", c.ID, m)
+			}
+			if slices.Contains(c.Labels, m) {
+				return nil, fmt.Errorf("cube %q: %q is both a macro archetype and a label", c.ID, m)
+			}
+		}
 		idx[c.ID] = c
 	}
 	return &Registry{cubes: ff.Cubes, index: idx}, nil
@@ -56,3 +132,17 @@ func (r *Registry) List() []Cube { return append([]Cube(nil), r.cubes...) }
 func (r *Registry) Has(id string) bool { _, ok := r.index[id]; return ok }
 
 func (r *Registry) Get(id string) (Cube, bool) { c, ok := r.index[id]; return c, ok }
+
+// Lookup returns the registry entry for id from the registry under dataRoot.
+// A missing registry or entry gives a Cube with only the ID set, which uses
+// the default taxonomy.
+func Lookup(dataRoot, id string) Cube {
+	reg, err := Load(filepath.Join(dataRoot, "cubes.json"))
+	if err != nil {
+		return Cube{ID: id}
+	}
+	if c, ok := reg.Get(id); ok {
+		return c
+	}
+	return Cube{ID: id}
+}
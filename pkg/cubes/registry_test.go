@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/caseydavenport/cube-tools/pkg/types"
 	"github.com/stretchr/testify/require"
 )
 
@@ -48,3 +49,46 @@ func TestLoad_RejectsDuplicateID(t *testing.T) {
 	_, err := Load(path)
 	require.Error(t, err)
 }
+
+func TestLoad_RejectsBadMacros(t *testing.T) {
+	for _, body := range []string{
+		`{"cubes":[{"id":"a","name":"A","macros":["Combo"]}]}`,
+		`{"cubes":[{"id":"a","name":"A","macros":[""]}]}`,
+		`{"cubes":[{"id":"a","name":"A","macros":["ramp"],"labels":["ramp"]}]}`,
+	} {
+		_, err := Load(writeRegistry(t, body))
+		require.Error(t, err, body)
+	}
+}
+
+func TestValidateDeckMeta(t *testing.T) {
+	def := Cube{ID: "a"}
+	require.Equal(t, types.DefaultMacros, def.MacroArchetypes())
+	require.NoError(t, def.ValidateDeckMeta("Aggro", []string{"anything"}))
+	require.Error(t, def.ValidateDeckMeta("combo", nil))
+	require.Error(t, def.ValidateDeckMeta("", []string{"control"}))
+
+	c := Cube{ID: "b", Macros: []string{"aggro", "combo"}, Labels: []string{"tokens"}}
+	require.NoError(t, c.ValidateDeckMeta("combo", []string{"tokens"}))
+	require.NoError(t, c.ValidateDeckMeta("", nil))
+	require.Error(t, c.ValidateDeckMeta("midrange", nil))
+	require.Error(t, c.ValidateDeckMeta("aggro", []string{"sacrifice"}))
+}
+
+func TestPromoteMacro(t *testing.T) {
+	c := Cube{ID: "b", Macros: []string{"aggro", "combo"}}
+
+	d := &types.Deck{Labels: []string{"tokens", "Combo"}}
+	require.True(t, c.PromoteMacro(d))
+	require.Equal(t, "combo", d.MacroArchetype)
+	require.Equal(t, []string{"tokens"}, d.Labels)
+
+	// An existing macro wins, but the label is still dropped.
+	d = &types.Deck{MacroArchetype: "Aggro", Labels: []string{"combo"}}
+	require.True(t, c.PromoteMacro(d))
+	require.Equal(t, "aggro", d.MacroArchetype)
+	require.Empty(t, d.Labels)
+
+	d = &types.Deck{MacroArchetype: "aggro", Labels: []string{"tokens"}}
+	require.False(t, c.PromoteMacro(d))
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/caseydavenport/cube-tools/pkg/cubes"
 	"github.com/caseydavenport/cube-tools/pkg/server/decks"
 	"github.com/caseydavenport/cube-tools/pkg/storage"
 	"github.com/sirupsen/logrus"
@@ -41,10 +42,12 @@ func (d *archetypesHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 
 	// Get all of the decks from the store.
 	resp := ArchetypesResponse{}
-	decks, err := d.store.List(CubeFromRequest(r), dr)
+	cubeID := CubeFromRequest(r)
+	decks, err := d.store.List(cubeID, dr)
 	if err != nil {
 		panic(err)
 	}
+	macros := cubes.Lookup("data", cubeID).MacroArchetypes()
 
 	// Go through each deck, and add up the win/loss counts for each archetype it faced.
 	for _, deck := range decks {
@@ -54,7 +57,7 @@ func (d *archetypesHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		}
 
 		// Get the archetype of the current deck.
-		arch := deck.Macro()
+		arch := deck.MacroIn(macros)
 		if arch == "" {
 			continue // No archetype found, skip this deck.
 		}
@@ -74,7 +77,7 @@ func (d *archetypesHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 
 		// Go through each game in the deck and count wins/losses against opponents.
 		for _, game := range deck.Games {
-			opponentArch := LookupOpponentMacro(decks, deck, game.Opponent)
+			opponentArch := LookupOpponentMacro(decks, deck, game.Opponent, macros)
 			if opponentArch == "" {
 				continue // No opponent deck found.
 			}
@@ -110,7 +113,9 @@ func (d *archetypesHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func LookupOpponentMacro(decks []*storage.Deck, deck *storage.Deck, opponent string) string {
+// LookupOpponentMacro returns the macro archetype, out of macros, of the deck
+// opponent played in deck's draft, or "" if there isn't one.
+func LookupOpponentMacro(decks []*storage.Deck, deck *storage.Deck, opponent string, macros []string) string {
 	for _, d := range decks {
 		if d.Player != opponent {
 			// Not the right player.
@@ -122,7 +127,7 @@ func LookupOpponentMacro(decks []*storage.Deck, deck *storage.Deck, opponent str
 		}
 
 		// Return the archetype of the opponent deck, and the win/loss counts of the input deck.
-		return d.Macro()
+		return d.MacroIn(macros)
 	}
 	return ""
 }
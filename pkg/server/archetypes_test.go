@@ -41,7 +41,7 @@ func TestLookupOpponentMacro_Found(t *testing.T) {
 		makeStorageDeck("Bob", "draft1", []string{"control"}, nil, nil),
 	}
 
-	result := LookupOpponentMacro(decks, decks[0], "Bob")
+	result := LookupOpponentMacro(decks, decks[0], "Bob", types.DefaultMacros)
 	assert.Equal(t, "control", result)
 }
 
@@ -50,7 +50,7 @@ func TestLookupOpponentMacro_NotFound(t *testing.T) {
 		makeStorageDeck("Alice", "draft1", []string{"aggro"}, nil, nil),
 	}
 
-	result := LookupOpponentMacro(decks, decks[0], "Unknown")
+	result := LookupOpponentMacro(decks, decks[0], "Unknown", types.DefaultMacros)
 	assert.Equal(t, "", result)
 }
 
@@ -60,10 +60,21 @@ func TestLookupOpponentMacro_WrongDraft(t *testing.T) {
 		makeStorageDeck("Bob", "draft2", []string{"control"}, nil, nil), // different draft
 	}
 
-	result := LookupOpponentMacro(decks, decks[0], "Bob")
+	result := LookupOpponentMacro(decks, decks[0], "Bob", types.DefaultMacros)
 	assert.Equal(t, "", result)
 }
 
+func TestLookupOpponentMacro_CubeMacros(t *testing.T) {
+	decks := []*storage.Deck{
+		makeStorageDeck("Alice", "draft1", []string{"aggro"}, nil, nil),
+		makeStorageDeck("Bob", "draft1", []string{"ramp"}, nil, nil),
+	}
+
+	// A label is only a macro if the cube says so.
+	assert.Equal(t, "", LookupOpponentMacro(decks, decks[0], "Bob", types.DefaultMacros))
+	assert.Equal(t, "ramp", LookupOpponentMacro(decks, decks[0], "Bob", []string{"aggro", "ramp"}))
+}
+
 // --- Archetypes handler: draws excluded ---
 
 func TestArchetypesHandler_DrawsExcluded(t *testing.T) {
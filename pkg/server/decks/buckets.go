@@ -1,14 +1,78 @@
 package decks
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
 	"sort"
+	"time"
 
 	"github.com/caseydavenport/cube-tools/pkg/storage"
 )
 
+// Bucketing modes. By default a bucket holds a number of drafts; the other
+// modes group drafts into calendar periods or cube versions first, and a
+// bucket holds a number of those.
+const (
+	BucketByDrafts      = "drafts"
+	BucketByMonth       = "month"
+	BucketByQuarter     = "quarter"
+	BucketBySeason      = "season"
+	BucketByCubeVersion = "cube_version"
+)
+
+// ValidBucketBy returns an error naming the modes if by isn't one of them.
+// Empty is the drafts mode.
+func ValidBucketBy(by string) error {
+	switch by {
+	case "", BucketByDrafts, BucketByMonth, BucketByQuarter, BucketBySeason, BucketByCubeVersion:
+		return nil
+	}
+	return fmt.Errorf("unknown bucket_by %q; use %s, %s, %s, %s or %s",
+		by, BucketByDrafts, BucketByMonth, BucketByQuarter, BucketBySeason, BucketByCubeVersion)
+}
+
+// BucketOptions says how DeckBucketsBy splits decks.
+type BucketOptions struct {
+	// Size is the number of drafts, periods or versions per bucket.
+	Size int
+
+	// Discrete picks non-overlapping buckets; otherwise they slide by one.
+	Discrete bool
+
+	// By is the bucketing mode. Empty means BucketByDrafts.
+	By string
+
+	// Versions maps draft IDs to cube versions, for BucketByCubeVersion. See
+	// CubeVersions.
+	Versions map[string]string
+}
+
+// NewBucketOptions returns the options for a request's bucketing, loading the
+// drafts' cube versions from the cube's snapshots when bucketing by them.
+func NewBucketOptions(cube string, decks []*storage.Deck, size int, discrete bool, by string) BucketOptions {
+	opts := BucketOptions{Size: size, Discrete: discrete, By: by}
+	if by == BucketByCubeVersion {
+		opts.Versions = CubeVersions(cube, decks)
+	}
+	return opts
+}
+
+// Bucketed reports whether a request asked for buckets at all: a size, or a
+// mode that implies one period or version per bucket.
+func Bucketed(size int, by string) bool {
+	return size > 0 || (by != "" && by != BucketByDrafts)
+}
+
 // A bucket is a collection of drafts.
 type Bucket struct {
 	Drafts []*Draft
+
+	// Label names the bucket's period or cube version, when bucketed by one.
+	Label string
 }
 
 func (b *Bucket) AllDecks() []*storage.Deck {
@@ -33,6 +97,9 @@ func (b *Bucket) TotalGames() int {
 }
 
 func (b *Bucket) Name() string {
+	if b.Label != "" {
+		return b.Label
+	}
 	if len(b.Drafts) == 0 {
 		return "Empty Bucket"
 	}
@@ -71,6 +138,188 @@ func DeckBuckets(decks []*storage.Deck, bucketSize int, discrete bool) []Bucket
 	return deckBucketsSliding(decks, bucketSize)
 }
 
+// DeckBucketsBy splits the given decks into buckets as opts says. In the
+// period and version modes a size under one means one period or version per
+// bucket.
+func DeckBucketsBy(decks []*storage.Deck, opts BucketOptions) []Bucket {
+	if opts.By == "" || opts.By == BucketByDrafts {
+		return DeckBuckets(decks, opts.Size, opts.Discrete)
+	}
+	units := groupDrafts(decks, opts)
+	size := max(opts.Size, 1)
+	if size >= len(units) {
+		return []Bucket{mergeUnits(units)}
+	}
+
+	buckets := []Bucket{}
+	if opts.Discrete {
+		// As with drafts, work back from the newest so a partial bucket is
+		// the oldest, and drop it.
+		for i := len(units); i >= size; i -= size {
+			buckets = append(buckets, mergeUnits(units[i-size:i]))
+		}
+		for i, j := 0, len(buckets)-1; i < j; i, j = i+1, j-1 {
+			buckets[i], buckets[j] = buckets[j], buckets[i]
+		}
+		return buckets
+	}
+	for i := 0; i <= len(units)-size; i++ {
+		buckets = append(buckets, mergeUnits(units[i:i+size]))
+	}
+	return buckets
+}
+
+// mergeUnits joins consecutive single-period or single-version buckets into
+// one, labeled with the range they span.
+func mergeUnits(units []Bucket) Bucket {
+	b := Bucket{Drafts: []*Draft{}}
+	for _, u := range units {
+		b.Drafts = append(b.Drafts, u.Drafts...)
+	}
+	switch len(units) {
+	case 0:
+	case 1:
+		b.Label = units[0].Label
+	default:
+		b.Label = units[0].Label + " - " + units[len(units)-1].Label
+	}
+	return b
+}
+
+// groupDrafts groups the decks' drafts into one bucket per calendar period or
+// cube version, in chronological order.
+func groupDrafts(decks []*storage.Deck, opts BucketOptions) []Bucket {
+	draftMap := make(map[string]*Draft)
+	for _, deck := range decks {
+		draftID := deck.Metadata.DraftID
+		if _, ok := draftMap[draftID]; !ok {
+			draftMap[draftID] = &Draft{
+				Name:  draftID,
+				Decks: []*storage.Deck{},
+			}
+		}
+		draftMap[draftID].Decks = append(draftMap[draftID].Decks, deck)
+	}
+	drafts := make([]*Draft, 0, len(draftMap))
+	for _, draft := range draftMap {
+		drafts = append(drafts, draft)
+	}
+	sort.Slice(drafts, func(i, j int) bool {
+		return drafts[i].Name < drafts[j].Name
+	})
+
+	// Each draft gets a sort key and a label for its unit. Periods sort by
+	// their key; versions by their first draft, which is the order drafts
+	// first hit them in.
+	type unit struct {
+		key   string
+		label string
+	}
+	var units []unit
+	byKey := map[string]*Bucket{}
+	versionNum := 0
+	for _, draft := range drafts {
+		var u unit
+		if opts.By == BucketByCubeVersion {
+			hash := opts.Versions[draft.Name]
+			u.key = hash
+			if hash == "" {
+				u.label = "no snapshot"
+			} else if _, ok := byKey[hash]; !ok {
+				versionNum++
+				u.label = fmt.Sprintf("v%d (%s)", versionNum, hash[:min(7, len(hash))])
+			}
+		} else {
+			u.key, u.label = periodOf(draftDate(draft), opts.By)
+		}
+		if _, ok := byKey[u.key]; !ok {
+			byKey[u.key] = &Bucket{Label: u.label, Drafts: []*Draft{}}
+			units = append(units, u)
+		}
+		byKey[u.key].Drafts = append(byKey[u.key].Drafts, draft)
+	}
+	if opts.By != BucketByCubeVersion {
+		sort.Slice(units, func(i, j int) bool { return units[i].key < units[j].key })
+	}
+
+	out := make([]Bucket, 0, len(units))
+	for _, u := range units {
+		out = append(out, *byKey[u.key])
+	}
+	return out
+}
+
+// draftDate is the date a draft was held: its decks' date, or failing that
+// the date its ID starts with. The zero time means it couldn't be told.
+func draftDate(draft *Draft) time.Time {
+	for _, d := range draft.Decks {
+		if t, err := time.Parse(time.DateOnly, d.Date); err == nil {
+			return t
+		}
+	}
+	if len(draft.Name) >= len(time.DateOnly) {
+		if t, err := time.Parse(time.DateOnly, draft.Name[:len(time.DateOnly)]); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// periodOf returns the sort key and label of the calendar period t falls in.
+// Seasons are meteorological, with December counted in the next year's
+// winter so a winter doesn't straddle two labels.
+func periodOf(t time.Time, by string) (string, string) {
+	if t.IsZero() {
+		return "", "undated"
+	}
+	year, month := t.Year(), int(t.Month())
+	switch by {
+	case BucketByMonth:
+		key := t.Format("2006-01")
+		return key, key
+	case BucketByQuarter:
+		key := fmt.Sprintf("%d-Q%d", year, (month-1)/3+1)
+		return key, key
+	}
+	if month == 12 {
+		year++
+	}
+	season := (month % 12) / 3
+	names := []string{"Winter", "Spring", "Summer", "Fall"}
+	return fmt.Sprintf("%d-%d", year, season), fmt.Sprintf("%d %s", year, names[season])
+}
+
+// CubeVersions maps each draft among the decks to a hash of its
+// cube-snapshot.json, so drafts run on the same list share a version. Drafts
+// without a snapshot, or with one that lists no cards, map to "".
+func CubeVersions(cube string, decks []*storage.Deck) map[string]string {
+	return cubeVersions("data", cube, decks)
+}
+
+func cubeVersions(root, cube string, decks []*storage.Deck) map[string]string {
+	out := map[string]string{}
+	for _, d := range decks {
+		id := d.Metadata.DraftID
+		if _, ok := out[id]; ok {
+			continue
+		}
+		out[id] = ""
+		b, err := os.ReadFile(filepath.Join(root, cube, id, "cube-snapshot.json"))
+		if err != nil {
+			continue
+		}
+		var snap struct {
+			Cards []json.RawMessage `json:"cards"`
+		}
+		if err := json.Unmarshal(b, &snap); err != nil || len(snap.Cards) == 0 {
+			continue
+		}
+		sum := sha256.Sum256(b)
+		out[id] = hex.EncodeToString(sum[:])
+	}
+	return out
+}
+
 func deckBucketsDiscrete(decks []*storage.Deck, bucketSize int) []Bucket {
 	// If the bucket size is larger than the number of decks, return a single bucket.
 	if bucketSize >= len(decks) {
@@ -1,11 +1,14 @@
 package decks
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/caseydavenport/cube-tools/pkg/storage"
 	"github.com/caseydavenport/cube-tools/pkg/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func makeDeck(player, draftID string, games []types.Game) *storage.Deck {
@@ -181,3 +184,94 @@ func TestDeckBucketsSliding_GroupsMultipleDecksPerDraft(t *testing.T) {
 	assert.Equal(t, 4, len(buckets[0].AllDecks()))
 	assert.Equal(t, 4, len(buckets[1].AllDecks()))
 }
+
+// --- DeckBucketsBy ---
+
+func datedDeck(draftID, date string) *storage.Deck {
+	d := makeDeck("p", draftID, nil)
+	d.Date = date
+	return d
+}
+
+func bucketNames(buckets []Bucket) []string {
+	var names []string
+	for _, b := range buckets {
+		names = append(names, b.Name())
+	}
+	return names
+}
+
+func TestDeckBucketsBy_Periods(t *testing.T) {
+	all := []*storage.Deck{
+		datedDeck("2024-11-30", "2024-11-30"),
+		datedDeck("2024-12-14", "2024-12-14"),
+		datedDeck("2025-01-05", "2025-01-05"),
+		datedDeck("2025-01-20", "2025-01-20"),
+		datedDeck("2025-04-02", "2025-04-02"),
+	}
+
+	months := DeckBucketsBy(all, BucketOptions{Discrete: true, By: BucketByMonth})
+	assert.Equal(t, []string{"2024-11", "2024-12", "2025-01", "2025-04"}, bucketNames(months))
+	assert.Len(t, months[2].Drafts, 2)
+	assert.Equal(t, "2025-01-05", months[2].Start())
+
+	quarters := DeckBucketsBy(all, BucketOptions{Discrete: true, By: BucketByQuarter})
+	assert.Equal(t, []string{"2024-Q4", "2025-Q1", "2025-Q2"}, bucketNames(quarters))
+
+	// December opens the next year's winter.
+	seasons := DeckBucketsBy(all, BucketOptions{Discrete: true, By: BucketBySeason})
+	assert.Equal(t, []string{"2024 Fall", "2025 Winter", "2025 Spring"}, bucketNames(seasons))
+	assert.Len(t, seasons[1].Drafts, 3)
+
+	// Sizes count periods: two-month windows sliding by one month.
+	sliding := DeckBucketsBy(all, BucketOptions{Size: 2, By: BucketByMonth})
+	assert.Equal(t, []string{"2024-11 - 2024-12", "2024-12 - 2025-01", "2025-01 - 2025-04"}, bucketNames(sliding))
+
+	// Discrete buckets drop the oldest partial one, as with drafts.
+	discrete := DeckBucketsBy(all, BucketOptions{Size: 3, Discrete: true, By: BucketByMonth})
+	assert.Equal(t, []string{"2024-12 - 2025-04"}, bucketNames(discrete))
+}
+
+func TestDeckBucketsBy_DraftIDDate(t *testing.T) {
+	// A deck without a date falls back to the one in its draft ID.
+	buckets := DeckBucketsBy([]*storage.Deck{
+		makeDeck("p", "2025-03-01_local_1", nil),
+		makeDeck("p", "misc", nil),
+	}, BucketOptions{Discrete: true, By: BucketByMonth})
+	assert.Equal(t, []string{"undated", "2025-03"}, bucketNames(buckets))
+}
+
+func TestDeckBucketsBy_CubeVersion(t *testing.T) {
+	root := t.TempDir()
+	for id, body := range map[string]string{
+		"2025-01-01": `{"cards":["A"]}`,
+		"2025-02-01": `{"cards":["A"]}`,
+		"2025-03-01": `{"cards":["A","B"]}`,
+		"2025-05-01": `{"cards":[]}`,
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, "c", id), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "c", id, "cube-snapshot.json"), []byte(body), 0o644))
+	}
+	all := []*storage.Deck{
+		makeDeck("p", "2025-01-01", nil),
+		makeDeck("q", "2025-01-01", nil),
+		makeDeck("p", "2025-02-01", nil),
+		makeDeck("p", "2025-03-01", nil),
+		makeDeck("p", "2025-04-01", nil),
+		makeDeck("p", "2025-05-01", nil),
+	}
+	versions := cubeVersions(root, "c", all)
+	require.Len(t, versions, 5)
+	assert.Equal(t, versions["2025-01-01"], versions["2025-02-01"])
+	assert.NotEqual(t, versions["2025-01-01"], versions["2025-03-01"])
+	assert.Equal(t, "", versions["2025-04-01"])
+	assert.Equal(t, "", versions["2025-05-01"], "an empty snapshot is no snapshot")
+
+	buckets := DeckBucketsBy(all, BucketOptions{Discrete: true, By: BucketByCubeVersion, Versions: versions})
+	require.Len(t, buckets, 3)
+	assert.Len(t, buckets[0].Drafts, 2)
+	assert.Equal(t, "v1 ("+versions["2025-01-01"][:7]+")", buckets[0].Name())
+	assert.Equal(t, "v2 ("+versions["2025-03-01"][:7]+")", buckets[1].Name())
+	assert.Equal(t, "no snapshot", buckets[2].Name())
+	assert.Len(t, buckets[2].Drafts, 2)
+}
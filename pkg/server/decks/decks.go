@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/caseydavenport/cube-tools/pkg/cubes"
 	"github.com/caseydavenport/cube-tools/pkg/server/query"
 	"github.com/caseydavenport/cube-tools/pkg/storage"
 	"github.com/sirupsen/logrus"
@@ -80,6 +82,15 @@ func (h *updateDeckHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The macro and labels have to come from the cube's taxonomy, so a typo
+	// doesn't quietly start a new archetype.
+	cube := cubes.Lookup("data", r.PathValue("cube"))
+	if err := cube.ValidateDeckMeta(req.MacroArchetype, req.Labels); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.MacroArchetype = strings.ToLower(req.MacroArchetype)
+
 	updated, err := h.store.UpdateDeckMeta(r.PathValue("cube"), req.DraftID, req.Player, req.MacroArchetype, req.Labels, req.Colors)
 	if errors.Is(err, storage.ErrDeckNotFound) {
 		http.Error(rw, "Deck not found", http.StatusNotFound)
@@ -0,0 +1,230 @@
+package decks
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/caseydavenport/cube-tools/pkg/server/query"
+	"github.com/caseydavenport/cube-tools/pkg/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// SimilarDecksResponse is the API response for
+// /api/{cube}/decks/{draft_id}/{player}/similar.
+type SimilarDecksResponse struct {
+	DraftID string         `json:"draft_id"`
+	Player  string         `json:"player"`
+	Similar []*SimilarDeck `json:"similar"`
+
+	// Neighbours pools the games of every deck in Similar: a rough read on how
+	// builds like this one tend to do.
+	NeighbourGameWins   int     `json:"neighbour_game_wins"`
+	NeighbourGameLosses int     `json:"neighbour_game_losses"`
+	NeighbourWinPercent float64 `json:"neighbour_win_percent"`
+}
+
+// SimilarDeck is one historical deck and how close its mainboard is to the
+// requested deck, from 0 (no cards in common) to 1 (the same list).
+type SimilarDeck struct {
+	DraftID        string        `json:"draft_id"`
+	Player         string        `json:"player"`
+	Date           string        `json:"date"`
+	MacroArchetype string        `json:"macro_archetype,omitempty"`
+	Colors         []string      `json:"colors"`
+	Similarity     float64       `json:"similarity"`
+	SharedCards    []string      `json:"shared_cards"`
+	Stats          storage.Stats `json:"stats"`
+}
+
+// Embedding is a deck's mainboard as a unit-length sparse vector keyed by card
+// name.
+type Embedding map[string]float64
+
+// DeckEmbedder turns decks into embeddings. Each card is weighted by its
+// inverse document frequency across the cube's mainboards, so two decks
+// sharing a card that shows up everywhere say little about their similarity,
+// while sharing a card that rarely makes the cut says a lot.
+type DeckEmbedder struct {
+	idf map[string]float64
+
+	// unseen is the weight of a card no indexed mainboard plays.
+	unseen float64
+}
+
+// NewDeckEmbedder weights cards by how rarely they appear across the given
+// decks' mainboards.
+func NewDeckEmbedder(decks []*storage.Deck) *DeckEmbedder {
+	df := make(map[string]int)
+	for _, d := range decks {
+		seen := make(map[string]bool)
+		for _, c := range d.Mainboard {
+			if c.IsBasicLand() || seen[c.Name] {
+				continue
+			}
+			seen[c.Name] = true
+			df[c.Name]++
+		}
+	}
+
+	// Smoothed so a card in every deck still carries a little weight.
+	n := float64(len(decks))
+	idf := make(map[string]float64, len(df))
+	for name, count := range df {
+		idf[name] = math.Log((1+n)/(1+float64(count))) + 1
+	}
+	return &DeckEmbedder{idf: idf, unseen: math.Log(1+n) + 1}
+}
+
+// Embed returns the deck's mainboard embedding. Basic lands are ignored.
+func (e *DeckEmbedder) Embed(d *storage.Deck) Embedding {
+	v := make(Embedding)
+	for _, c := range d.Mainboard {
+		if c.IsBasicLand() {
+			continue
+		}
+		w, ok := e.idf[c.Name]
+		if !ok {
+			w = e.unseen
+		}
+		v[c.Name] += w
+	}
+	var norm float64
+	for _, w := range v {
+		norm += w * w
+	}
+	norm = math.Sqrt(norm)
+	for k := range v {
+		v[k] /= norm
+	}
+	return v
+}
+
+// Similarity is the cosine similarity of two embeddings, along with the cards
+// they share ordered by how much each contributed.
+func (a Embedding) Similarity(b Embedding) (float64, []string) {
+	var dot float64
+	var shared []string
+	for k, x := range a {
+		if y, ok := b[k]; ok {
+			dot += x * y
+			shared = append(shared, k)
+		}
+	}
+	sort.Slice(shared, func(i, j int) bool {
+		wi, wj := a[shared[i]]*b[shared[i]], a[shared[j]]*b[shared[j]]
+		if wi != wj {
+			return wi > wj
+		}
+		return shared[i] < shared[j]
+	})
+	return dot, shared
+}
+
+func SimilarDecksHandler(store storage.DeckStorage) http.Handler {
+	return &similarDecksHandler{store: store}
+}
+
+type similarDecksHandler struct {
+	store storage.DeckStorage
+}
+
+func (h *similarDecksHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	draftID := r.PathValue("draft_id")
+	player := r.PathValue("player")
+	dr := ParseDecksRequest(r)
+	logrus.WithFields(logrus.Fields{"draft_id": draftID, "player": player, "params": dr}).Info("/api/decks/similar")
+
+	// Player filters would hide every other player's decks, which are the
+	// candidates.
+	dr.Player = ""
+	all, err := h.store.List(r.PathValue("cube"), dr)
+	if err != nil {
+		http.Error(rw, "could not load decks", http.StatusInternalServerError)
+		return
+	}
+
+	var target *storage.Deck
+	for _, d := range all {
+		if d.Metadata.DraftID == draftID && strings.EqualFold(d.Player, player) {
+			target = d
+			break
+		}
+	}
+	if target == nil {
+		http.Error(rw, "Deck not found", http.StatusNotFound)
+		return
+	}
+
+	limit := query.GetInt(r, "limit")
+	if limit <= 0 {
+		limit = 10
+	}
+	resp := similarDecks(target, all, limit)
+
+	b, err := json.Marshal(resp)
+	if err != nil {
+		http.Error(rw, "could not marshal response", http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	if _, err := rw.Write(b); err != nil {
+		logrus.WithError(err).Error("Failed to write similar decks response")
+	}
+}
+
+// similarDecks returns up to limit decks from all most similar to target,
+// excluding target itself and decks with nothing in common.
+func similarDecks(target *storage.Deck, all []*storage.Deck, limit int) SimilarDecksResponse {
+	e := NewDeckEmbedder(all)
+	tv := e.Embed(target)
+
+	resp := SimilarDecksResponse{
+		DraftID: target.Metadata.DraftID,
+		Player:  target.Player,
+		Similar: []*SimilarDeck{},
+	}
+	for _, d := range all {
+		if d == target {
+			continue
+		}
+		sim, shared := tv.Similarity(e.Embed(d))
+		if sim <= 0 {
+			continue
+		}
+		colors := d.GetColors()
+		sort.Slice(colors, func(i, j int) bool {
+			return strings.Index("WUBRG", colors[i]) < strings.Index("WUBRG", colors[j])
+		})
+		resp.Similar = append(resp.Similar, &SimilarDeck{
+			DraftID:        d.Metadata.DraftID,
+			Player:         d.Player,
+			Date:           d.Date,
+			MacroArchetype: d.MacroArchetype,
+			Colors:         colors,
+			Similarity:     math.Round(1000*sim) / 1000,
+			SharedCards:    shared,
+			Stats:          d.Stats,
+		})
+	}
+	sort.Slice(resp.Similar, func(i, j int) bool {
+		if resp.Similar[i].Similarity != resp.Similar[j].Similarity {
+			return resp.Similar[i].Similarity > resp.Similar[j].Similarity
+		}
+		return resp.Similar[i].Date > resp.Similar[j].Date
+	})
+	if len(resp.Similar) > limit {
+		resp.Similar = resp.Similar[:limit]
+	}
+
+	for _, s := range resp.Similar {
+		resp.NeighbourGameWins += s.Stats.GameWins
+		resp.NeighbourGameLosses += s.Stats.GameLosses
+	}
+	if games := resp.NeighbourGameWins + resp.NeighbourGameLosses; games > 0 {
+		resp.NeighbourWinPercent = math.Round(1000*float64(resp.NeighbourGameWins)/float64(games)) / 10
+	}
+	return resp
+}
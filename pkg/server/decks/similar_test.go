@@ -0,0 +1,55 @@
+package decks
+
+import (
+	"testing"
+
+	"github.com/caseydavenport/cube-tools/pkg/storage"
+	"github.com/caseydavenport/cube-tools/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func similarDeck(player, draftID string, wins, losses int, cards ...string) *storage.Deck {
+	d := &storage.Deck{}
+	d.Player = player
+	d.Metadata.DraftID = draftID
+	d.Stats.GameWins = wins
+	d.Stats.GameLosses = losses
+	d.Mainboard = append(d.Mainboard, types.Card{Name: "Island", Types: []string{"Basic", "Land"}})
+	for _, c := range cards {
+		d.Mainboard = append(d.Mainboard, types.Card{Name: c})
+	}
+	return d
+}
+
+func TestSimilarDecks_RareSharedCardsWeighMore(t *testing.T) {
+	target := similarDeck("alice", "d3", 0, 0, "common", "rare")
+	// Shares only the card every deck plays.
+	a := similarDeck("bob", "d1", 2, 1, "common", "x")
+	// Shares the card hardly anyone plays.
+	b := similarDeck("carol", "d2", 1, 2, "rare", "y")
+	c := similarDeck("dave", "d2", 0, 0, "common", "z")
+	// Nothing but basics in common.
+	e := similarDeck("erin", "d1", 3, 0, "w")
+
+	resp := similarDecks(target, []*storage.Deck{target, a, b, c, e}, 10)
+	require.Len(t, resp.Similar, 3)
+	assert.Equal(t, "carol", resp.Similar[0].Player)
+	assert.Equal(t, []string{"rare"}, resp.Similar[0].SharedCards)
+	assert.Greater(t, resp.Similar[0].Similarity, resp.Similar[1].Similarity)
+	assert.Equal(t, 3, resp.NeighbourGameWins)
+	assert.Equal(t, 3, resp.NeighbourGameLosses)
+	assert.Equal(t, 50.0, resp.NeighbourWinPercent)
+
+	resp = similarDecks(target, []*storage.Deck{target, a, b, c, e}, 1)
+	require.Len(t, resp.Similar, 1)
+	assert.Equal(t, 1, resp.NeighbourGameWins)
+}
+
+func TestSimilarDecks_IdenticalListIsOne(t *testing.T) {
+	target := similarDeck("alice", "d1", 0, 0, "a", "b", "c")
+	twin := similarDeck("bob", "d2", 0, 0, "c", "b", "a")
+	resp := similarDecks(target, []*storage.Deck{target, twin}, 10)
+	require.Len(t, resp.Similar, 1)
+	assert.Equal(t, 1.0, resp.Similar[0].Similarity)
+}
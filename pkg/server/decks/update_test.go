@@ -101,3 +101,36 @@ func TestUpdateDeckHandler_MalformedJSON(t *testing.T) {
 	UpdateDeckHandler(store).ServeHTTP(rec, req)
 	require.Equal(t, http.StatusBadRequest, rec.Code)
 }
+
+func TestUpdateDeckHandler_Taxonomy(t *testing.T) {
+	cwd, _ := os.Getwd()
+	t.Cleanup(func() { _ = os.Chdir(cwd) })
+	require.NoError(t, os.Chdir(t.TempDir()))
+	deckPath := seedCube(t, "testcube", "d1", "p1")
+	require.NoError(t, os.WriteFile(filepath.Join("data", "cubes.json"),
+		[]byte(`{"cubes":[{"id":"testcube","name":"Test","macros":["aggro","combo"],"labels":["removal","tokens"]}]}`), 0o644))
+
+	store := storage.NewFileDeckStoreWithCache()
+	for _, tc := range []struct {
+		macro  string
+		labels []string
+		code   int
+	}{
+		{"Combo", []string{"tokens"}, http.StatusOK},
+		{"control", nil, http.StatusBadRequest},
+		{"aggro", []string{"sacrifice"}, http.StatusBadRequest},
+		{"", []string{"combo"}, http.StatusBadRequest},
+	} {
+		rec := httptest.NewRecorder()
+		UpdateDeckHandler(store).ServeHTTP(rec, updateReq(t, "testcube", UpdateDeckMetaRequest{
+			DraftID: "d1", Player: "p1", MacroArchetype: tc.macro, Labels: tc.labels,
+		}))
+		require.Equal(t, tc.code, rec.Code, "macro %q labels %v", tc.macro, tc.labels)
+	}
+
+	// Only the valid update landed, with the macro lowercased.
+	reloaded, err := types.LoadDeck(deckPath)
+	require.NoError(t, err)
+	require.Equal(t, "combo", reloaded.MacroArchetype)
+	require.Equal(t, []string{"tokens"}, reloaded.Labels)
+}
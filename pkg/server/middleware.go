@@ -2,9 +2,12 @@ package server
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 
 	"github.com/caseydavenport/cube-tools/pkg/cubes"
+	"github.com/caseydavenport/cube-tools/pkg/server/decks"
+	"github.com/caseydavenport/cube-tools/pkg/server/query"
 )
 
 type ctxKey int
@@ -31,6 +34,31 @@ func WithCube(reg *cubes.Registry, h http.Handler) http.Handler {
 	})
 }
 
+// WithValidMatch rejects a request whose match query doesn't parse, so a typo
+// comes back as a 400 naming the problem instead of an empty result or a
+// generic load failure from whichever handler hits it first.
+func WithValidMatch(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if _, err := query.Parse(r.URL.Query().Get("match")); err != nil {
+			http.Error(rw, fmt.Sprintf("invalid match query: %v", err), http.StatusBadRequest)
+			return
+		}
+		h.ServeHTTP(rw, r)
+	})
+}
+
+// WithValidBucketBy rejects a request whose bucket_by names no bucketing mode,
+// rather than letting it fall back to draft-count buckets unnoticed.
+func WithValidBucketBy(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if err := decks.ValidBucketBy(r.URL.Query().Get("bucket_by")); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.ServeHTTP(rw, r)
+	})
+}
+
 // CubeFromRequest returns the validated cube ID for this request.
 func CubeFromRequest(r *http.Request) string {
 	v, _ := r.Context().Value(cubeKey).(string)
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithValidMatch(t *testing.T) {
+	ok := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	})
+
+	for match, code := range map[string]int{
+		"":                         http.StatusNoContent,
+		"t:creature OR arch:aggro": http.StatusNoContent,
+		"(t:creature":              http.StatusBadRequest,
+		"foo:bar":                  http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/polyverse/stats/cards?match="+url.QueryEscape(match), nil)
+		WithValidMatch(ok).ServeHTTP(rec, req)
+		require.Equal(t, code, rec.Code, match)
+	}
+}
+
+func TestWithValidBucketBy(t *testing.T) {
+	ok := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	})
+
+	for by, code := range map[string]int{
+		"":             http.StatusNoContent,
+		"quarter":      http.StatusNoContent,
+		"cube_version": http.StatusNoContent,
+		"fortnight":    http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/polyverse/stats/cards?bucket_by="+by, nil)
+		WithValidBucketBy(ok).ServeHTTP(rec, req)
+		require.Equal(t, code, rec.Code, by)
+	}
+}
@@ -0,0 +1,583 @@
+package query
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/caseydavenport/cube-tools/pkg/types"
+)
+
+// node is one element of a parsed query. Every node can be evaluated against
+// a single card, with its play stats when the caller has them; evaluation
+// against a deck is handled by matchDeck, since it depends on how card terms
+// are grouped.
+type node interface {
+	matchCard(c types.Card, s *CardStats) bool
+}
+
+// CardStats are the aggregate play statistics stat terms compare against.
+type CardStats struct {
+	// Games is the number of games played by decks that mainboarded the card.
+	Games int
+
+	// Mainboard and Sideboard count the decks that ran or sided the card.
+	Mainboard int
+	Sideboard int
+
+	// Players is the number of distinct players who mainboarded the card, and
+	// Drafts the number of drafts it appeared in.
+	Players int
+	Drafts  int
+
+	// WinPercent is the game win rate of decks that mainboarded the card.
+	WinPercent float64
+}
+
+type andNode struct {
+	children []node
+}
+
+func (n *andNode) matchCard(c types.Card, s *CardStats) bool {
+	for _, child := range n.children {
+		if !child.matchCard(c, s) {
+			return false
+		}
+	}
+	return true
+}
+
+type orNode struct {
+	children []node
+}
+
+func (n *orNode) matchCard(c types.Card, s *CardStats) bool {
+	for _, child := range n.children {
+		if child.matchCard(c, s) {
+			return true
+		}
+	}
+	return false
+}
+
+type notNode struct {
+	child node
+}
+
+func (n *notNode) matchCard(c types.Card, s *CardStats) bool {
+	return !n.child.matchCard(c, s)
+}
+
+// fieldKind says what a field's value is and what it is evaluated against.
+type fieldKind int
+
+const (
+	// Card fields describe a single card.
+	cardText fieldKind = iota
+	cardColor
+	cardNumber
+	cardKeyword
+
+	// Deck fields describe the deck as a whole.
+	deckText
+	deckColor
+	deckNumber
+
+	// Stat fields describe a card's aggregate play statistics. Neither a bare
+	// card nor a deck carries those, so they only restrict results where the
+	// caller supplies CardStats.
+	statNumber
+)
+
+// fields maps every accepted field name, including aliases, to its canonical
+// name and kind.
+var fields = map[string]struct {
+	name string
+	kind fieldKind
+}{
+	"name":      {"name", cardText},
+	"n":         {"name", cardText},
+	"o":         {"oracle", cardText},
+	"oracle":    {"oracle", cardText},
+	"t":         {"type", cardText},
+	"type":      {"type", cardText},
+	"st":        {"subtype", cardText},
+	"subtype":   {"subtype", cardText},
+	"m":         {"mana", cardText},
+	"mana":      {"mana", cardText},
+	"tag":       {"tag", cardText},
+	"c":         {"color", cardColor},
+	"color":     {"color", cardColor},
+	"cmc":       {"mv", cardNumber},
+	"mv":        {"mv", cardNumber},
+	"pow":       {"pow", cardNumber},
+	"power":     {"pow", cardNumber},
+	"tou":       {"tou", cardNumber},
+	"toughness": {"tou", cardNumber},
+	"is":        {"is", cardKeyword},
+	"arch":      {"arch", deckText},
+	"player":    {"player", deckText},
+	"event":     {"event", deckText},
+	"dcolor":    {"dcolor", deckColor},
+	"draftsize": {"draftsize", deckNumber},
+	"mincards":  {"mincards", deckNumber},
+	"games":     {"games", statNumber},
+	"mb":        {"mb", statNumber},
+	"sb":        {"sb", statNumber},
+	"players":   {"players", statNumber},
+	"drafts":    {"drafts", statNumber},
+	"winpct":    {"winpct", statNumber},
+}
+
+// keywords are the type checks and interaction heuristics accepted by is:.
+// Any trait name from the types package (see isKeyword) is accepted too.
+var keywords = map[string]func(types.Card) bool{
+	"creature":     types.Card.IsCreature,
+	"land":         types.Card.IsLand,
+	"removal":      types.Card.IsRemoval,
+	"counterspell": types.Card.IsCounterspell,
+	"interaction":  types.Card.IsInteraction,
+	"handhate":     types.Card.IsHandHate,
+}
+
+// isKeyword reports whether is: accepts name.
+func isKeyword(name string) bool {
+	_, ok := keywords[name]
+	return ok || types.IsTrait(name)
+}
+
+// termNode is a single comparison. A bare word has no field and matches
+// against names and text.
+type termNode struct {
+	field string
+	kind  fieldKind
+	op    string
+	value string
+
+	text      pattern
+	num       float64
+	colors    []string
+	colorless bool
+}
+
+// isCard reports whether the term is about a single card.
+func (t *termNode) isCard() bool {
+	return t.field != "" && t.kind <= cardKeyword
+}
+
+func (t *termNode) matchCard(c types.Card, s *CardStats) bool {
+	if t.field == "" {
+		return t.text.in(c.Name) || t.text.in(c.OracleText)
+	}
+	switch t.field {
+	case "name":
+		return t.matchText(c.Name)
+	case "oracle":
+		return t.matchText(c.OracleText)
+	case "type":
+		return t.matchText(append(slices.Clone(c.Types), c.SubTypes...)...)
+	case "subtype":
+		return t.matchText(c.SubTypes...)
+	case "mana":
+		return t.matchText(c.ManaCost)
+	case "tag":
+		return t.matchText(c.Tags...)
+	case "color":
+		return t.matchColors(c.Colors)
+	case "mv":
+		return t.matchNumber(float64(c.CMC))
+	case "pow":
+		return t.matchStat(c.Power)
+	case "tou":
+		return t.matchStat(c.Toughness)
+	case "is":
+		var is bool
+		if f, ok := keywords[t.value]; ok {
+			is = f(c)
+		} else {
+			is = c.HasTrait(t.value)
+		}
+		if t.op == "!=" {
+			return !is
+		}
+		return is
+	}
+	if t.kind == statNumber && s != nil {
+		return t.matchNumber(s.value(t.field))
+	}
+	// Deck fields, and stat fields without stats, don't constrain a card.
+	return true
+}
+
+// value returns the stat a stat field names.
+func (s *CardStats) value(field string) float64 {
+	switch field {
+	case "games":
+		return float64(s.Games)
+	case "mb":
+		return float64(s.Mainboard)
+	case "sb":
+		return float64(s.Sideboard)
+	case "players":
+		return float64(s.Players)
+	case "drafts":
+		return float64(s.Drafts)
+	case "winpct":
+		return s.WinPercent
+	}
+	return 0
+}
+
+// matchDeckTerm evaluates a deck-level term, or a bare word, which matches the
+// deck's player, event or labels before falling back to its cards.
+func (t *termNode) matchDeckTerm(d Deck, cards []types.Card) bool {
+	switch t.field {
+	case "":
+		if t.text.in(d.GetPlayer()) || t.text.in(d.GetEventID()) {
+			return true
+		}
+		for _, l := range d.GetLabels() {
+			if t.text.in(l) {
+				return true
+			}
+		}
+		return anyCard(t, cards)
+	case "arch":
+		return t.matchText(d.GetLabels()...)
+	case "player":
+		return t.matchText(d.GetPlayer())
+	case "event":
+		return t.matchText(d.GetEventID())
+	case "dcolor":
+		return t.matchColors(d.GetColors())
+	case "draftsize":
+		return t.matchNumber(float64(d.GetDraftSize()))
+	}
+	// mincards is applied by the conjunction it belongs to; stat fields need
+	// per-card stats a deck doesn't have.
+	return true
+}
+
+// matchText applies a text operator across vals: ":" matches when any value
+// contains the pattern, "=" when any equals it, and "!=" when none does.
+func (t *termNode) matchText(vals ...string) bool {
+	switch t.op {
+	case ":":
+		return slices.ContainsFunc(vals, t.text.in)
+	case "=":
+		return slices.ContainsFunc(vals, t.text.is)
+	case "!=":
+		return !slices.ContainsFunc(vals, t.text.is)
+	}
+	return false
+}
+
+// matchColors compares a color set with the term's. ":" and ">=" mean "at
+// least these colors" (or, for c:c, exactly colorless), "<=" means "within
+// these colors", and "<" and ">" are the strict versions.
+func (t *termNode) matchColors(have []string) bool {
+	set := make(map[string]bool, len(have))
+	for _, c := range have {
+		set[strings.ToUpper(c)] = true
+	}
+	superset := true
+	for _, c := range t.colors {
+		if !set[c] {
+			superset = false
+		}
+	}
+	subset := true
+	for c := range set {
+		if !slices.Contains(t.colors, c) {
+			subset = false
+		}
+	}
+	equal := superset && subset
+
+	switch t.op {
+	case ":":
+		if t.colorless {
+			return len(set) == 0
+		}
+		return superset
+	case ">=":
+		return superset
+	case "=":
+		return equal
+	case "!=":
+		return !equal
+	case "<=":
+		return subset
+	case "<":
+		return subset && !equal
+	case ">":
+		return superset && !equal
+	}
+	return false
+}
+
+func (t *termNode) matchNumber(v float64) bool {
+	switch t.op {
+	case ":", "=":
+		return v == t.num
+	case "!=":
+		return v != t.num
+	case "<":
+		return v < t.num
+	case "<=":
+		return v <= t.num
+	case ">":
+		return v > t.num
+	case ">=":
+		return v >= t.num
+	}
+	return false
+}
+
+// matchStat compares a printed power or toughness. Non-numeric values like "*"
+// never match.
+func (t *termNode) matchStat(s string) bool {
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return false
+	}
+	return t.matchNumber(float64(v))
+}
+
+// pattern is a case-insensitive text value in which * matches any run of
+// characters.
+type pattern struct {
+	lower    string
+	contains *regexp.Regexp
+	exact    *regexp.Regexp
+}
+
+func newPattern(v string) pattern {
+	p := pattern{lower: strings.ToLower(v)}
+	if strings.Contains(p.lower, "*") {
+		re := strings.ReplaceAll(regexp.QuoteMeta(p.lower), `\*`, ".*")
+		p.contains = regexp.MustCompile(re)
+		p.exact = regexp.MustCompile("^" + re + "$")
+	}
+	return p
+}
+
+// in reports whether s contains the pattern.
+func (p pattern) in(s string) bool {
+	if p.contains != nil {
+		return p.contains.MatchString(strings.ToLower(s))
+	}
+	return strings.Contains(strings.ToLower(s), p.lower)
+}
+
+// is reports whether s is the pattern in its entirety.
+func (p pattern) is(s string) bool {
+	if p.exact != nil {
+		return p.exact.MatchString(strings.ToLower(s))
+	}
+	return strings.ToLower(s) == p.lower
+}
+
+// cardOnly reports whether n only involves card terms, and so describes a
+// single card.
+func cardOnly(n node) bool {
+	switch n := n.(type) {
+	case *termNode:
+		return n.isCard()
+	case *notNode:
+		return cardOnly(n.child)
+	case *andNode:
+		return !slices.ContainsFunc(n.children, func(c node) bool { return !cardOnly(c) })
+	case *orNode:
+		return !slices.ContainsFunc(n.children, func(c node) bool { return !cardOnly(c) })
+	}
+	return false
+}
+
+// anyCard reports whether any of the cards satisfies n.
+func anyCard(n node, cards []types.Card) bool {
+	for _, c := range cards {
+		if n.matchCard(c, nil) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasStats reports whether n contains a stat term.
+func hasStats(n node) bool {
+	switch n := n.(type) {
+	case *termNode:
+		return n.kind == statNumber
+	case *notNode:
+		return hasStats(n.child)
+	case *andNode:
+		return slices.ContainsFunc(n.children, hasStats)
+	case *orNode:
+		return slices.ContainsFunc(n.children, hasStats)
+	}
+	return false
+}
+
+// A caller can't evaluate every term: a lone card has no deck, and neither a
+// card nor a deck has play stats until they've been computed. Such a term must
+// not filter either way, which leaving it in as "always matches" gets wrong as
+// soon as it's negated: -winpct>55 would then match nothing. So before
+// matching, the terms a caller can't evaluate are pruned out in a way that
+// only ever widens the result. widen returns a node matched by everything n
+// is, and narrow a node matching only what n does; under NOT one turns into
+// the other.
+
+// widen returns n with the terms keep rejects taken out so that the result
+// matches at least what n does, or nil if it matches everything.
+func widen(n node, keep func(*termNode) bool) node {
+	switch n := n.(type) {
+	case *termNode:
+		if !keep(n) {
+			return nil
+		}
+		return n
+	case *notNode:
+		child := narrow(n.child, keep)
+		if child == nil {
+			return nil
+		}
+		return &notNode{child: child}
+	case *andNode:
+		var children []node
+		for _, c := range n.children {
+			if w := widen(c, keep); w != nil {
+				children = append(children, w)
+			}
+		}
+		if len(children) == 0 {
+			return nil
+		}
+		return &andNode{children: children}
+	case *orNode:
+		children := make([]node, 0, len(n.children))
+		for _, c := range n.children {
+			w := widen(c, keep)
+			if w == nil {
+				return nil
+			}
+			children = append(children, w)
+		}
+		return &orNode{children: children}
+	}
+	return n
+}
+
+// narrow returns n with the terms keep rejects taken out so that the result
+// matches at most what n does, or nil if it matches nothing.
+func narrow(n node, keep func(*termNode) bool) node {
+	switch n := n.(type) {
+	case *termNode:
+		if !keep(n) {
+			return nil
+		}
+		return n
+	case *notNode:
+		child := widen(n.child, keep)
+		if child == nil {
+			return nil
+		}
+		return &notNode{child: child}
+	case *andNode:
+		children := make([]node, 0, len(n.children))
+		for _, c := range n.children {
+			w := narrow(c, keep)
+			if w == nil {
+				return nil
+			}
+			children = append(children, w)
+		}
+		return &andNode{children: children}
+	case *orNode:
+		var children []node
+		for _, c := range n.children {
+			if w := narrow(c, keep); w != nil {
+				children = append(children, w)
+			}
+		}
+		if len(children) == 0 {
+			return nil
+		}
+		return &orNode{children: children}
+	}
+	return n
+}
+
+// cardTerm, cardStatTerm and deckTerm say which terms can be evaluated
+// against a lone card, a card with its stats, and a deck.
+func cardTerm(t *termNode) bool     { return t.field == "" || t.isCard() }
+func cardStatTerm(t *termNode) bool { return cardTerm(t) || t.kind == statNumber }
+func deckTerm(t *termNode) bool     { return t.kind != statNumber }
+
+// matchDeck evaluates n against a deck whose relevant board is cards.
+//
+// Card terms that are ANDed together describe one card, so `t:creature mv<=2`
+// matches decks running a cheap creature rather than decks with any creature
+// and any cheap card. A conjunction's mincards term raises how many cards must
+// satisfy it. A negated card term with no positive card term alongside it
+// excludes decks running any matching card: `-n:"Lightning Bolt"` is "no
+// Bolt", not "some card other than Bolt".
+func matchDeck(n node, d Deck, cards []types.Card) bool {
+	switch n := n.(type) {
+	case *notNode:
+		return !matchDeck(n.child, d, cards)
+	case *orNode:
+		if cardOnly(n) {
+			return anyCard(n, cards)
+		}
+		for _, child := range n.children {
+			if matchDeck(child, d, cards) {
+				return true
+			}
+		}
+		return false
+	case *andNode:
+		need := 1
+		var cardTerms []node
+		positive := false
+		for _, child := range n.children {
+			if t, ok := child.(*termNode); ok && t.field == "mincards" {
+				need = max(need, int(t.num))
+				continue
+			}
+			if cardOnly(child) {
+				cardTerms = append(cardTerms, child)
+				if _, neg := child.(*notNode); !neg {
+					positive = true
+				}
+				continue
+			}
+			if !matchDeck(child, d, cards) {
+				return false
+			}
+		}
+		if !positive {
+			for _, child := range cardTerms {
+				if !matchDeck(child, d, cards) {
+					return false
+				}
+			}
+			return true
+		}
+		card := &andNode{children: cardTerms}
+		count := 0
+		for _, c := range cards {
+			if card.matchCard(c, nil) {
+				count++
+			}
+		}
+		return count >= need
+	case *termNode:
+		if n.isCard() {
+			return anyCard(n, cards)
+		}
+		return n.matchDeckTerm(d, cards)
+	}
+	return true
+}
@@ -30,168 +30,246 @@ func (m *mockDeck) GetColors() []string        { return m.colors }
 func (m *mockDeck) GetEventID() string         { return m.eventID }
 
 // =====================
-// parseTerms
+// lex
 // =====================
 
-func TestParseTerms_Simple(t *testing.T) {
-	result := parseTerms("color:R cmc>3")
-	assert.Equal(t, []string{"color:R", "cmc>3"}, result)
+func termTokens(t *testing.T, s string) []token {
+	t.Helper()
+	toks, err := lex(s)
+	assert.NoError(t, err)
+	var out []token
+	for _, tok := range toks {
+		if tok.kind == tokTerm {
+			tok.pos = 0
+			out = append(out, tok)
+		}
+	}
+	return out
 }
 
-func TestParseTerms_QuotedValues(t *testing.T) {
-	result := parseTerms(`name:"Lightning Bolt" color:R`)
-	assert.Equal(t, []string{`name:"Lightning Bolt"`, "color:R"}, result)
+func TestLex_Simple(t *testing.T) {
+	assert.Equal(t, []token{
+		{kind: tokTerm, field: "color", op: ":", value: "R"},
+		{kind: tokTerm, field: "cmc", op: ">", value: "3"},
+	}, termTokens(t, "color:R cmc>3"))
 }
 
-func TestParseTerms_SingleTerm(t *testing.T) {
-	result := parseTerms("aggro")
-	assert.Equal(t, []string{"aggro"}, result)
+func TestLex_QuotedValues(t *testing.T) {
+	assert.Equal(t, []token{
+		{kind: tokTerm, field: "name", op: ":", value: "Lightning Bolt"},
+		{kind: tokTerm, field: "color", op: ":", value: "R"},
+	}, termTokens(t, `name:"Lightning Bolt" color:R`))
 }
 
-func TestParseTerms_Empty(t *testing.T) {
-	result := parseTerms("")
-	assert.Empty(t, result)
+func TestLex_SingleTerm(t *testing.T) {
+	assert.Equal(t, []token{{kind: tokTerm, value: "aggro"}}, termTokens(t, "aggro"))
 }
 
-func TestParseTerms_ExtraSpaces(t *testing.T) {
-	result := parseTerms("  color:R   cmc>3  ")
-	assert.Equal(t, []string{"color:R", "cmc>3"}, result)
+func TestLex_Empty(t *testing.T) {
+	assert.Empty(t, termTokens(t, ""))
 }
 
-// =====================
-// isTermQuery
-// =====================
+func TestLex_ExtraSpaces(t *testing.T) {
+	assert.Len(t, termTokens(t, "  color:R   cmc>3  "), 2)
+}
 
-func TestIsTermQuery(t *testing.T) {
-	assert.True(t, isTermQuery("color:R"))
-	assert.True(t, isTermQuery("cmc>3"))
-	assert.True(t, isTermQuery("cmc<5"))
-	assert.True(t, isTermQuery("color=RG"))
-	assert.True(t, isTermQuery("color!=R"))
-	assert.True(t, isTermQuery("arch:aggro"))
-	assert.True(t, isTermQuery("t:Creature"))
-	assert.True(t, isTermQuery("name:bolt o:damage"))
-	assert.True(t, isTermQuery("player:Alice"))
-	assert.True(t, isTermQuery("draftSize>4"))
-	assert.False(t, isTermQuery("lightning bolt"))
-	assert.False(t, isTermQuery("aggro"))
-	assert.False(t, isTermQuery(""))
+func TestLex_Operators(t *testing.T) {
+	for _, op := range []string{":", "=", "!=", "<", "<=", ">", ">="} {
+		toks := termTokens(t, "cmc"+op+"3")
+		assert.Equal(t, []token{{kind: tokTerm, field: "cmc", op: op, value: "3"}}, toks, op)
+	}
+}
+
+func TestLex_Keywords(t *testing.T) {
+	toks, err := lex(`(a OR b) and NOT -c "or"`)
+	assert.NoError(t, err)
+	var kinds []tokenKind
+	for _, tok := range toks {
+		kinds = append(kinds, tok.kind)
+	}
+	assert.Equal(t, []tokenKind{tokLParen, tokTerm, tokOr, tokTerm, tokRParen, tokAnd, tokNot, tokNot, tokTerm, tokTerm, tokEOF}, kinds)
 }
 
 // =====================
-// isDeckOnly
+// Parse
 // =====================
 
-func TestIsDeckOnly(t *testing.T) {
-	assert.True(t, isDeckOnly("arch:aggro"))
-	assert.True(t, isDeckOnly("arch!=control"))
-	assert.True(t, isDeckOnly("player:Alice"))
-	assert.True(t, isDeckOnly("dcolor:RG"))
-	assert.True(t, isDeckOnly("draftSize>4"))
-	assert.False(t, isDeckOnly("color:R"))
-	assert.False(t, isDeckOnly("cmc>3"))
-	assert.False(t, isDeckOnly("t:Creature"))
-	assert.False(t, isDeckOnly("name:bolt"))
+func TestParse_Errors(t *testing.T) {
+	for _, q := range []string{
+		"(t:creature",
+		"t:creature)",
+		"()",
+		"t:creature OR",
+		"AND t:creature",
+		`o:"unterminated`,
+		"o:",
+		"foo:bar",
+		"cmc>three",
+		"c:RX",
+		"is:shiny",
+		"t>creature",
+		"minCards>2",
+	} {
+		_, err := Parse(q)
+		var perr *ParseError
+		assert.ErrorAs(t, err, &perr, q)
+	}
+}
+
+func TestParse_ErrorPosition(t *testing.T) {
+	_, err := Parse("t:creature foo:bar")
+	assert.EqualError(t, err, `unknown field "foo" (at position 12)`)
+}
+
+func TestParse_Valid(t *testing.T) {
+	for _, q := range []string{
+		"",
+		"   ",
+		"lightning bolt",
+		"(t:artifact OR t:enchantment) cmc<2 !t:creature -t:land",
+		"t!=creature",
+		"NOT (c:R OR c:G)",
+		"winpct>55 games>=20 c:R",
+		"draftSize>4 minCards:3 arch:aggro",
+		`o:"add {*} or {*}" mv<=2.5`,
+	} {
+		_, err := Parse(q)
+		assert.NoError(t, err, q)
+	}
 }
 
 // =====================
-// combineColors
+// cardOnly
 // =====================
 
-func TestCombineColors(t *testing.T) {
-	assert.Equal(t, "WU", combineColors([]string{"U", "W"}))
-	assert.Equal(t, "WUBRG", combineColors([]string{"G", "R", "B", "U", "W"}))
-	assert.Equal(t, "R", combineColors([]string{"R"}))
-	assert.Equal(t, "BR", combineColors([]string{"r", "b"})) // lowercase input
-	assert.Equal(t, "", combineColors([]string{}))
+func TestCardOnly(t *testing.T) {
+	for q, want := range map[string]bool{
+		"arch:aggro":               false,
+		"arch!=control":            false,
+		"player:Alice":             false,
+		"dcolor:RG":                false,
+		"draftSize>4":              false,
+		"winpct>50":                false,
+		"bolt":                     false,
+		"color:R":                  true,
+		"cmc>3":                    true,
+		"t:Creature":               true,
+		"name:bolt":                true,
+		"-t:creature (c:R OR c:G)": true,
+		"t:creature arch:aggro":    false,
+	} {
+		p, err := Parse(q)
+		assert.NoError(t, err)
+		assert.Equal(t, want, cardOnly(p.root), q)
+	}
 }
 
 // =====================
-// colorMatches
+// Colors
 // =====================
 
 func TestColorMatches_Contains(t *testing.T) {
 	card := types.Card{Colors: []string{"R", "W"}}
-	assert.True(t, colorMatches("color:R", card))
-	assert.True(t, colorMatches("color:W", card))
-	assert.True(t, colorMatches("color:RW", card))
-	assert.False(t, colorMatches("color:U", card))
+	assert.True(t, CardMatches(card, "color:R"))
+	assert.True(t, CardMatches(card, "color:W"))
+	assert.True(t, CardMatches(card, "color:RW"))
+	assert.False(t, CardMatches(card, "color:U"))
 }
 
 func TestColorMatches_Exact(t *testing.T) {
 	card := types.Card{Colors: []string{"R", "W"}}
-	assert.True(t, colorMatches("color=RW", card))
-	assert.True(t, colorMatches("color=WR", card)) // order doesn't matter
-	assert.False(t, colorMatches("color=R", card))
+	assert.True(t, CardMatches(card, "color=RW"))
+	assert.True(t, CardMatches(card, "color=WR")) // order doesn't matter
+	assert.False(t, CardMatches(card, "color=R"))
 }
 
 func TestColorMatches_NotEqual(t *testing.T) {
 	card := types.Card{Colors: []string{"R"}}
-	assert.True(t, colorMatches("color!=U", card))
-	assert.False(t, colorMatches("color!=R", card))
+	assert.True(t, CardMatches(card, "color!=U"))
+	assert.False(t, CardMatches(card, "color!=R"))
+}
+
+func TestColorMatches_Subset(t *testing.T) {
+	card := types.Card{Colors: []string{"R", "W"}}
+	assert.True(t, CardMatches(card, "c<=RWG"))
+	assert.True(t, CardMatches(card, "c<RWG"))
+	assert.False(t, CardMatches(card, "c<RW"))
+	assert.False(t, CardMatches(card, "c<=R"))
+	assert.True(t, CardMatches(card, "c>R"))
+	assert.True(t, CardMatches(card, "c>=rw"))
+}
+
+func TestColorMatches_Colorless(t *testing.T) {
+	assert.True(t, CardMatches(types.Card{}, "c:c"))
+	assert.True(t, CardMatches(types.Card{}, "c=colorless"))
+	assert.False(t, CardMatches(types.Card{Colors: []string{"G"}}, "c:c"))
 }
 
 // =====================
-// cmcMatches
+// Numbers
 // =====================
 
 func TestCmcMatches(t *testing.T) {
 	card := types.Card{CMC: 3}
-	assert.True(t, cmcMatches("cmc<5", card))
-	assert.False(t, cmcMatches("cmc<3", card))
-	assert.True(t, cmcMatches("cmc>2", card))
-	assert.False(t, cmcMatches("cmc>3", card))
-	assert.True(t, cmcMatches("cmc=3", card))
-	assert.False(t, cmcMatches("cmc=4", card))
+	assert.True(t, CardMatches(card, "cmc<5"))
+	assert.False(t, CardMatches(card, "cmc<3"))
+	assert.True(t, CardMatches(card, "cmc>2"))
+	assert.False(t, CardMatches(card, "cmc>3"))
+	assert.True(t, CardMatches(card, "cmc=3"))
+	assert.False(t, CardMatches(card, "cmc=4"))
+	assert.True(t, CardMatches(card, "mv:3"))
+	assert.True(t, CardMatches(card, "mv>=3"))
+	assert.True(t, CardMatches(card, "mv!=2"))
 }
 
-// =====================
-// powMatches
-// =====================
-
 func TestPowMatches(t *testing.T) {
-	card := types.Card{Power: "4"}
-	assert.True(t, powMatches("pow>3", card))
-	assert.False(t, powMatches("pow>4", card))
-	assert.True(t, powMatches("pow<5", card))
-	assert.True(t, powMatches("pow=4", card))
+	card := types.Card{Power: "4", Toughness: "5"}
+	assert.True(t, CardMatches(card, "pow>3"))
+	assert.False(t, CardMatches(card, "pow>4"))
+	assert.True(t, CardMatches(card, "pow<5"))
+	assert.True(t, CardMatches(card, "pow=4"))
+	assert.True(t, CardMatches(card, "tou>=5"))
+	assert.False(t, CardMatches(card, "tou<5"))
 }
 
 func TestPowMatches_NonNumeric(t *testing.T) {
 	// Cards with "*" power should not match
 	card := types.Card{Power: "*"}
-	assert.False(t, powMatches("pow>0", card))
+	assert.False(t, CardMatches(card, "pow>0"))
+	assert.False(t, CardMatches(card, "pow!=0"))
 }
 
 func TestPowMatches_Empty(t *testing.T) {
 	card := types.Card{Power: ""}
-	assert.False(t, powMatches("pow>0", card))
+	assert.False(t, CardMatches(card, "pow>0"))
 }
 
 // =====================
-// deckTypeMatches
+// arch
 // =====================
 
 func TestDeckTypeMatches_Found(t *testing.T) {
 	d := &mockDeck{labels: []string{"aggro", "RDW"}}
-	assert.True(t, deckTypeMatches("arch:aggro", d))
-	assert.True(t, deckTypeMatches("arch:RDW", d))
+	assert.True(t, DeckMatches(d, "arch:aggro"))
+	assert.True(t, DeckMatches(d, "arch:RDW"))
 }
 
 func TestDeckTypeMatches_CaseInsensitive(t *testing.T) {
 	d := &mockDeck{labels: []string{"Aggro"}}
-	assert.True(t, deckTypeMatches("arch:aggro", d))
+	assert.True(t, DeckMatches(d, "arch:aggro"))
 }
 
 func TestDeckTypeMatches_NotFound(t *testing.T) {
 	d := &mockDeck{labels: []string{"aggro"}}
-	assert.False(t, deckTypeMatches("arch:control", d))
+	assert.False(t, DeckMatches(d, "arch:control"))
+	// Labels match whole, not as substrings.
+	assert.False(t, DeckMatches(d, "arch:agg"))
 }
 
 func TestDeckTypeMatches_NotEqual(t *testing.T) {
 	d := &mockDeck{labels: []string{"aggro"}}
-	assert.True(t, deckTypeMatches("arch!=control", d))
-	assert.False(t, deckTypeMatches("arch!=aggro", d))
+	assert.True(t, DeckMatches(d, "arch!=control"))
+	assert.False(t, DeckMatches(d, "arch!=aggro"))
 }
 
 // =====================
@@ -246,7 +324,6 @@ func TestCardMatches_ColorShorthand(t *testing.T) {
 	c := types.Card{Name: "Bolt", Colors: []string{"R"}}
 	assert.True(t, CardMatches(c, "c:R"))
 	assert.False(t, CardMatches(c, "c:U"))
-	assert.True(t, isTermQuery("c:R"))
 }
 
 func TestCardMatches_CmcTerm(t *testing.T) {
@@ -382,3 +459,188 @@ func TestDeckMatches_CardInPool(t *testing.T) {
 	assert.False(t, DeckMatches(d, "t:Land"))
 	assert.True(t, DeckMatchesBoard(d, "t:Land", "Pool"))
 }
+
+// =====================
+// Card fields
+// =====================
+
+func TestCardMatches_SubtypesAndTags(t *testing.T) {
+	c := types.Card{
+		Name: "Goblin Guide", Types: []string{"Creature"}, SubTypes: []string{"Goblin", "Scout"},
+		ManaCost: "{R}", Tags: []string{"Aggro 1-drop"},
+	}
+	assert.True(t, CardMatches(c, "t:goblin"))
+	assert.True(t, CardMatches(c, "st:scout"))
+	assert.False(t, CardMatches(c, "st:creature"))
+	assert.True(t, CardMatches(c, "m:{R}"))
+	assert.True(t, CardMatches(c, `tag:"1-drop"`))
+	assert.True(t, CardMatches(c, "t=creature"))
+	assert.False(t, CardMatches(c, "t=creat"))
+	assert.True(t, CardMatches(c, "t!=instant"))
+}
+
+func TestCardMatches_Is(t *testing.T) {
+	c := types.Card{Name: "Bear", Types: []string{"Creature"}}
+	assert.True(t, CardMatches(c, "is:creature"))
+	assert.False(t, CardMatches(c, "is:land"))
+	assert.True(t, CardMatches(c, "is!=land"))
+}
+
+func TestCardMatches_IsTrait(t *testing.T) {
+	c := types.Card{
+		Name: "Mulldrifter", Types: []string{"Creature"},
+		OracleText: "Flying\nWhen this creature enters, draw two cards.",
+	}
+	assert.True(t, CardMatches(c, "is:flying"))
+	assert.True(t, CardMatches(c, "is:etb is:card_draw"))
+	assert.True(t, CardMatches(c, "is:evasive"))
+	assert.False(t, CardMatches(c, "is:token_maker"))
+	assert.False(t, CardMatches(c, `is:"first strike"`))
+	assert.True(t, CardMatches(c, "-is:ramp"))
+
+	_, err := Parse(`is:"first strike"`)
+	assert.NoError(t, err)
+	_, err = Parse("is:landfall")
+	assert.Error(t, err)
+}
+
+func TestCardMatches_Wildcard(t *testing.T) {
+	c := types.Card{Name: "Lightning Bolt", OracleText: "Lightning Bolt deals 3 damage to any target."}
+	assert.True(t, CardMatches(c, `o:"deals * damage"`))
+	assert.True(t, CardMatches(c, "n:light*bolt"))
+	assert.False(t, CardMatches(c, "n:bolt*light"))
+}
+
+func TestCardMatches_Boolean(t *testing.T) {
+	c := types.Card{Name: "Lightning Bolt", Colors: []string{"R"}, CMC: 1, Types: []string{"Instant"}}
+	assert.True(t, CardMatches(c, "t:creature OR t:instant"))
+	assert.True(t, CardMatches(c, "(c:U OR c:R) cmc<2"))
+	assert.False(t, CardMatches(c, "(c:U OR c:G) cmc<2"))
+	assert.True(t, CardMatches(c, "-t:creature"))
+	assert.True(t, CardMatches(c, "NOT (t:creature OR c:U)"))
+	assert.False(t, CardMatches(c, "!t:instant"))
+	assert.True(t, CardMatches(c, "t:instant AND c:R"))
+}
+
+func TestCardMatches_DeckAndStatTermsIgnored(t *testing.T) {
+	c := types.Card{Name: "Lightning Bolt", Colors: []string{"R"}}
+	assert.True(t, CardMatches(c, "arch:aggro c:R"))
+	assert.True(t, CardMatches(c, "winpct>55 games>=20 c:R"))
+}
+
+func TestCardMatches_InvalidQuery(t *testing.T) {
+	c := types.Card{Name: "Lightning Bolt"}
+	assert.False(t, CardMatches(c, "foo:bar"))
+}
+
+// =====================
+// Deck boolean logic
+// =====================
+
+func TestDeckMatches_CardTermsDescribeOneCard(t *testing.T) {
+	d := &mockDeck{
+		mainboard: []types.Card{
+			{Name: "Lightning Bolt", Colors: []string{"R"}, CMC: 1, Types: []string{"Instant"}},
+			{Name: "Grizzly Bears", Colors: []string{"G"}, CMC: 2, Types: []string{"Creature"}},
+		},
+	}
+	assert.True(t, DeckMatches(d, "t:creature c:G"))
+	assert.False(t, DeckMatches(d, "t:creature c:R"))
+	assert.True(t, DeckMatches(d, "t:creature -c:R"))
+}
+
+func TestDeckMatches_NegatedCardTerm(t *testing.T) {
+	d := &mockDeck{
+		labels:    []string{"aggro"},
+		mainboard: []types.Card{{Name: "Lightning Bolt"}, {Name: "Mountain"}},
+	}
+	// On its own, a negated card term excludes decks running the card.
+	assert.False(t, DeckMatches(d, `-name:"Lightning Bolt"`))
+	assert.True(t, DeckMatches(d, `-name:Counterspell arch:aggro`))
+}
+
+func TestDeckMatches_OrAcrossDeckAndCard(t *testing.T) {
+	d := &mockDeck{
+		player:    "Alice",
+		mainboard: []types.Card{{Name: "Lightning Bolt"}},
+	}
+	assert.True(t, DeckMatches(d, "player:bob OR name:bolt"))
+	assert.True(t, DeckMatches(d, "(player:bob OR player:alice) -name:counterspell"))
+	assert.False(t, DeckMatches(d, "player:bob OR name:counterspell"))
+	assert.False(t, DeckMatches(d, "NOT player:alice"))
+}
+
+func TestDeckMatches_MinCards(t *testing.T) {
+	d := &mockDeck{
+		mainboard: []types.Card{
+			{Name: "Goblin Guide", Types: []string{"Creature"}},
+			{Name: "Goblin Bushwhacker", Types: []string{"Creature"}},
+			{Name: "Lightning Bolt", Types: []string{"Instant"}},
+		},
+	}
+	assert.True(t, DeckMatches(d, "minCards:2 t:creature"))
+	assert.False(t, DeckMatches(d, "minCards:3 t:creature"))
+	assert.True(t, DeckMatches(d, "minCards:3 (t:creature OR t:instant)"))
+}
+
+func TestDeckMatches_StatTermsIgnored(t *testing.T) {
+	d := &mockDeck{mainboard: []types.Card{{Name: "Lightning Bolt", Colors: []string{"R"}}}}
+	assert.True(t, DeckMatches(d, "winpct>55 games>=20 c:R"))
+	assert.False(t, DeckMatches(d, "winpct>55 c:U"))
+}
+
+// A term that can't be evaluated stays neutral when negated rather than
+// excluding everything.
+func TestDeckMatches_NegatedStatTerms(t *testing.T) {
+	d := &mockDeck{mainboard: []types.Card{{Name: "Lightning Bolt", Colors: []string{"R"}}}}
+	assert.True(t, DeckMatches(d, "c:R -winpct>55"))
+	assert.True(t, DeckMatches(d, "-games<5"))
+	assert.True(t, DeckMatches(d, "NOT games>=20"))
+	assert.True(t, DeckMatches(d, "-(c:R winpct>55)"))
+	assert.False(t, DeckMatches(d, "c:U -winpct>55"))
+	assert.False(t, DeckMatches(d, "-(c:R OR winpct>55)"))
+}
+
+func TestCardMatches_NegatedDeckTerms(t *testing.T) {
+	c := types.Card{Name: "Lightning Bolt", Colors: []string{"R"}}
+	assert.True(t, CardMatches(c, "-arch:control"))
+	assert.True(t, CardMatches(c, "c:R NOT player:alice"))
+	assert.False(t, CardMatches(c, "c:U -arch:control"))
+
+	q, err := Parse("-arch:control -winpct>55")
+	assert.NoError(t, err)
+	assert.True(t, q.MatchesCardStats(c, CardStats{WinPercent: 50}))
+	assert.False(t, q.MatchesCardStats(c, CardStats{WinPercent: 60}))
+}
+
+func TestDeckMatches_InvalidQuery(t *testing.T) {
+	d := &mockDeck{player: "Alice"}
+	assert.False(t, DeckMatches(d, "(player:alice"))
+}
+
+// =====================
+// Stat terms
+// =====================
+
+func TestMatchesCardStats(t *testing.T) {
+	c := types.Card{Name: "Lightning Bolt", Colors: []string{"R"}}
+	s := CardStats{Games: 24, Mainboard: 8, Sideboard: 2, Players: 5, Drafts: 9, WinPercent: 58.3}
+
+	for match, want := range map[string]bool{
+		"winpct>55 games>=20 c:R": true,
+		"winpct>60":               false,
+		"mb=8 sb<3":               true,
+		"players>5 OR drafts>=9":  true,
+		"-(games<20) c:U":         false,
+		"arch:aggro winpct>50":    true,
+	} {
+		q, err := Parse(match)
+		assert.NoError(t, err)
+		assert.True(t, q.HasStats(), match)
+		assert.Equal(t, want, q.MatchesCardStats(c, s), match)
+	}
+
+	q, err := Parse("c:R bolt")
+	assert.NoError(t, err)
+	assert.False(t, q.HasStats())
+}
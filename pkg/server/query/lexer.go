@@ -0,0 +1,153 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokLParen
+	tokRParen
+	tokAnd
+	tokOr
+	tokNot
+	tokTerm
+)
+
+// token is one lexeme. Terms carry their field, operator and value already
+// split; a bare word has an empty field and operator.
+type token struct {
+	kind  tokenKind
+	pos   int
+	field string
+	op    string
+	value string
+}
+
+// ParseError is returned for a query that can't be parsed. Pos is the byte
+// offset into the query where the problem was found.
+type ParseError struct {
+	Pos int
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s (at position %d)", e.Msg, e.Pos+1)
+}
+
+func errorAt(pos int, format string, args ...any) *ParseError {
+	return &ParseError{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// operators are the comparisons a term may use, longest first so "<=" isn't
+// read as "<".
+var operators = []string{"!=", "<=", ">=", ":", "=", "<", ">"}
+
+// lex splits a query into tokens. Whitespace separates terms, parentheses are
+// always their own token, and a double-quoted value may contain either.
+func lex(s string) ([]token, error) {
+	var toks []token
+	i := 0
+	for i < len(s) {
+		ch := s[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n':
+			i++
+		case ch == '(':
+			toks = append(toks, token{kind: tokLParen, pos: i})
+			i++
+		case ch == ')':
+			toks = append(toks, token{kind: tokRParen, pos: i})
+			i++
+		case ch == '-' || ch == '!':
+			// A leading - (Scryfall) or ! negates whatever follows. A lone one
+			// followed by space is just a word.
+			if i+1 < len(s) && !isSpace(s[i+1]) {
+				toks = append(toks, token{kind: tokNot, pos: i})
+				i++
+				continue
+			}
+			tok, next, err := lexTerm(s, i)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, tok)
+			i = next
+		default:
+			tok, next, err := lexTerm(s, i)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, tok)
+			i = next
+		}
+	}
+	return append(toks, token{kind: tokEOF, pos: len(s)}), nil
+}
+
+// lexTerm reads one term starting at i: either field, operator and value, or
+// a bare word. It returns the token and the offset just past it.
+func lexTerm(s string, i int) (token, int, error) {
+	start := i
+
+	// A field name is a run of letters immediately followed by an operator.
+	j := i
+	for j < len(s) && (unicode.IsLetter(rune(s[j])) || s[j] == '_') {
+		j++
+	}
+	if j > i {
+		for _, op := range operators {
+			if strings.HasPrefix(s[j:], op) {
+				value, next, err := lexValue(s, j+len(op))
+				if err != nil {
+					return token{}, 0, err
+				}
+				if value == "" {
+					return token{}, 0, errorAt(start, "missing value for %q", s[i:j])
+				}
+				return token{kind: tokTerm, pos: start, field: strings.ToLower(s[i:j]), op: op, value: value}, next, nil
+			}
+		}
+	}
+
+	value, next, err := lexValue(s, i)
+	if err != nil {
+		return token{}, 0, err
+	}
+	if s[i] != '"' {
+		switch strings.ToUpper(value) {
+		case "AND":
+			return token{kind: tokAnd, pos: start}, next, nil
+		case "OR":
+			return token{kind: tokOr, pos: start}, next, nil
+		case "NOT":
+			return token{kind: tokNot, pos: start}, next, nil
+		}
+	}
+	return token{kind: tokTerm, pos: start, value: value}, next, nil
+}
+
+// lexValue reads a value starting at i, either a double-quoted string or a
+// run of characters up to whitespace or a parenthesis.
+func lexValue(s string, i int) (string, int, error) {
+	if i < len(s) && s[i] == '"' {
+		end := strings.IndexByte(s[i+1:], '"')
+		if end < 0 {
+			return "", 0, errorAt(i, "unterminated quote")
+		}
+		return s[i+1 : i+1+end], i + end + 2, nil
+	}
+	j := i
+	for j < len(s) && !isSpace(s[j]) && s[j] != '(' && s[j] != ')' {
+		j++
+	}
+	return s[i:j], j, nil
+}
+
+func isSpace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n'
+}
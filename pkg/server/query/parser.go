@@ -0,0 +1,277 @@
+package query
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/caseydavenport/cube-tools/pkg/types"
+)
+
+// Query is a parsed search query. The zero value, and the result of parsing
+// an empty string, matches everything.
+//
+// The grammar is Scryfall-flavoured:
+//
+//	query   = or
+//	or      = and ("OR" and)*
+//	and     = unary ("AND"? unary)*
+//	unary   = ("-" | "!" | "NOT") unary | primary
+//	primary = "(" or ")" | term
+//	term    = field op value | value
+//	op      = ":" | "=" | "!=" | "<" | "<=" | ">" | ">="
+//
+// Values may be double-quoted to include spaces or parentheses. Card fields:
+//
+//	name (n), o (oracle), t (type), st (subtype), m (mana), tag
+//	    text; ":" contains, "=" is exactly, "!=" is not exactly, * is a wildcard.
+//	    t matches subtypes as well as types.
+//	c (color)
+//	    WUBRG letters, or "c" for colorless; ":" and ">=" are "at least", "<="
+//	    is "at most", "=" is exact. c:UB is blue and black; for blue or black
+//	    write (c:U OR c:B).
+//	mv (cmc), pow (power), tou (toughness)
+//	    numbers; non-numeric power or toughness never matches.
+//	is
+//	    creature, land, interaction, or any keyword ability (flying,
+//	    first_strike, ward...), mechanic (etb, sac_outlet, token_maker,
+//	    card_draw, ramp, recursion, anthem) or role (removal, counterspell,
+//	    handhate, evasive, card_advantage, go_wide) from the types package.
+//
+// Deck fields: arch (a label), player, event, dcolor, draftSize, and
+// minCards, the number of cards that must satisfy the card terms alongside
+// it. Stat fields (games, mb, sb, players, drafts, winpct) are accepted
+// everywhere but only restrict results where per-card stats are available;
+// see MatchesCardStats.
+//
+// A bare word matches card names and oracle text, and for decks also the
+// player, event and labels.
+type Query struct {
+	root node
+
+	// card, stats and deck are root pruned down to the terms each kind of
+	// match can evaluate. See widen.
+	card, stats, deck node
+}
+
+func newQuery(root node) *Query {
+	return &Query{
+		root:  root,
+		card:  widen(root, cardTerm),
+		stats: widen(root, cardStatTerm),
+		deck:  widen(root, deckTerm),
+	}
+}
+
+// Parse parses a query string, returning a *ParseError describing the first
+// problem found.
+func Parse(s string) (*Query, error) {
+	toks, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	if p.peek().kind == tokEOF {
+		return &Query{}, nil
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		if tok.kind == tokRParen {
+			return nil, errorAt(tok.pos, "unexpected )")
+		}
+		return nil, errorAt(tok.pos, "unexpected token")
+	}
+	return newQuery(root), nil
+}
+
+// MatchesCard reports whether the card satisfies the query. Deck and stat
+// terms don't restrict a lone card.
+func (q *Query) MatchesCard(c types.Card) bool {
+	if q == nil || q.card == nil {
+		return true
+	}
+	return q.card.matchCard(c, nil)
+}
+
+// HasStats reports whether the query has stat terms, which only
+// MatchesCardStats can evaluate.
+func (q *Query) HasStats() bool {
+	return q != nil && q.root != nil && hasStats(q.root)
+}
+
+// MatchesCardStats is MatchesCard with stat terms evaluated against the
+// card's play statistics.
+func (q *Query) MatchesCardStats(c types.Card, s CardStats) bool {
+	if q == nil || q.stats == nil {
+		return true
+	}
+	return q.stats.matchCard(c, &s)
+}
+
+// MatchesDeck reports whether the deck satisfies the query, with card terms
+// matched against the named board ("Sideboard" or "Pool", defaulting to the
+// mainboard).
+func (q *Query) MatchesDeck(d Deck, board string) bool {
+	if q == nil || q.deck == nil {
+		return true
+	}
+	return matchDeck(q.deck, d, boardCards(d, board))
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.toks[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) parseOr() (node, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	children := []node{first}
+	for p.peek().kind == tokOr {
+		p.next()
+		child, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+	if len(children) == 1 {
+		return first, nil
+	}
+	return &orNode{children: children}, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	first, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	children := []node{first}
+	for {
+		switch p.peek().kind {
+		case tokEOF, tokRParen, tokOr:
+			if len(children) == 1 {
+				return first, nil
+			}
+			return &andNode{children: children}, nil
+		case tokAnd:
+			p.next()
+		}
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.peek().kind == tokNot {
+		p.next()
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{child: child}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokLParen:
+		if p.peek().kind == tokRParen {
+			return nil, errorAt(tok.pos, "empty parentheses")
+		}
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokRParen {
+			return nil, errorAt(tok.pos, "missing closing )")
+		}
+		return inner, nil
+	case tokTerm:
+		return newTerm(tok)
+	}
+	return nil, errorAt(tok.pos, "expected a term")
+}
+
+// newTerm validates a term token's field, operator and value.
+func newTerm(tok token) (*termNode, error) {
+	t := &termNode{op: tok.op, value: tok.value}
+	if tok.field == "" {
+		t.text = newPattern(tok.value)
+		return t, nil
+	}
+
+	f, ok := fields[tok.field]
+	if !ok {
+		return nil, errorAt(tok.pos, "unknown field %q", tok.field)
+	}
+	t.field, t.kind = f.name, f.kind
+
+	switch t.kind {
+	case cardText, deckText:
+		if t.op != ":" && t.op != "=" && t.op != "!=" {
+			return nil, errorAt(tok.pos, "%s doesn't support %q", tok.field, t.op)
+		}
+		// Labels are whole words, so arch: means "has this label".
+		if t.field == "arch" && t.op == ":" {
+			t.op = "="
+		}
+		t.text = newPattern(t.value)
+	case cardKeyword:
+		if t.op != ":" && t.op != "=" && t.op != "!=" {
+			return nil, errorAt(tok.pos, "%s doesn't support %q", tok.field, t.op)
+		}
+		// Keywords with spaces may be written either way: is:"first strike"
+		// or is:first_strike.
+		t.value = strings.ReplaceAll(strings.ToLower(t.value), " ", "_")
+		if !isKeyword(t.value) {
+			return nil, errorAt(tok.pos, "unknown is: keyword %q", tok.value)
+		}
+	case cardColor, deckColor:
+		v := strings.ToUpper(t.value)
+		if v == "C" || v == "COLORLESS" {
+			t.colorless = true
+			break
+		}
+		for _, r := range v {
+			if !strings.ContainsRune("WUBRG", r) {
+				return nil, errorAt(tok.pos, "invalid color %q in %q", string(r), tok.value)
+			}
+			if c := string(r); !slices.Contains(t.colors, c) {
+				t.colors = append(t.colors, c)
+			}
+		}
+	case cardNumber, deckNumber, statNumber:
+		n, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			return nil, errorAt(tok.pos, "%s needs a number, got %q", tok.field, tok.value)
+		}
+		if t.field == "mincards" && t.op != ":" && t.op != "=" {
+			return nil, errorAt(tok.pos, "%s doesn't support %q", tok.field, t.op)
+		}
+		t.num = n
+	}
+	return t, nil
+}
@@ -6,6 +6,7 @@ import (
 	"math"
 	"net/http"
 
+	"github.com/caseydavenport/cube-tools/pkg/cubes"
 	"github.com/caseydavenport/cube-tools/pkg/server"
 	"github.com/caseydavenport/cube-tools/pkg/server/decks"
 	"github.com/caseydavenport/cube-tools/pkg/server/query"
@@ -70,12 +71,25 @@ func (s *archetypeStatsHandler) ServeHTTP(rw http.ResponseWriter, r *http.Reques
 		}
 	}
 
-	resp := ArchetypeStatsResponse{
+	z := zForConfidence(query.GetFloat(r, "confidence"))
+	resp := archetypeStats(allDecks, cubeCards, cubeMacros(cubeID), z)
+
+	b, err := json.Marshal(resp)
+	if err != nil {
+		http.Error(rw, "could not marshal response", http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(b)
+}
+
+// archetypeStats computes per-archetype stats over the decks, with a row for
+// each of the macro archetypes even if no deck played it.
+func archetypeStats(allDecks []*storage.Deck, cubeCards map[string]types.Card, macros []string, z float64) *ArchetypeStatsResponse {
+	resp := &ArchetypeStatsResponse{
 		Archetypes: make(map[string]*ArchetypeStats),
 	}
 
-	// Initialize macro archetypes.
-	macros := []string{"aggro", "midrange", "control"}
 	for _, m := range macros {
 		resp.Archetypes[m] = &ArchetypeStats{Type: m, SharedWith: make(map[string]int), Players: make(map[string]int)}
 	}
@@ -145,7 +159,6 @@ func (s *archetypeStatsHandler) ServeHTTP(rw http.ResponseWriter, r *http.Reques
 	resp.TotalGames = totalWins
 	numDecks := len(allDecks)
 
-	z := zForConfidence(query.GetFloat(r, "confidence"))
 	for _, as := range resp.Archetypes {
 		as.BuildPercent = pct(float64(as.Count), float64(numDecks))
 		as.Finalize()
@@ -165,12 +178,20 @@ func (s *archetypeStatsHandler) ServeHTTP(rw http.ResponseWriter, r *http.Reques
 			as.AvgShared = math.Round(float64(totalShared)/float64(as.Count)*100) / 100
 		}
 	}
+	return resp
+}
 
-	b, err := json.Marshal(resp)
-	if err != nil {
-		http.Error(rw, "could not marshal response", http.StatusInternalServerError)
-		return
+// cubeMacros returns the macro archetypes the cube's registry entry
+// configures, or the defaults.
+func cubeMacros(cubeID string) []string {
+	return cubes.Lookup("data", cubeID).MacroArchetypes()
+}
+
+// orDefaultMacros returns macros, or the default list for a request built
+// without going through its handler.
+func orDefaultMacros(macros []string) []string {
+	if len(macros) == 0 {
+		return types.DefaultMacros
 	}
-	rw.Header().Set("Content-Type", "application/json")
-	rw.Write(b)
+	return macros
 }
@@ -0,0 +1,147 @@
+package stats
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/caseydavenport/cube-tools/pkg/storage"
+	"github.com/caseydavenport/cube-tools/pkg/types"
+)
+
+// Card stats count the drafts a card turned up in, which shortchanges a card
+// added last month next to one that's been in since the first draft. Each
+// draft's cube-snapshot.json records the list it was run with, so availability
+// counts the drafts a card was actually in the cube for, and the rates below
+// are taken over those drafts only. A draft without a snapshot, or with an
+// empty one, is taken to have run today's cube, and a card someone
+// mainboarded is taken to have been in the cube for that draft whatever its
+// snapshot says.
+//
+// Pick Elo needs care from the other direction: a card that's only been in a
+// couple of drafts has a rating resting on a handful of decks, and can land
+// far from the baseline on very little. NormalizedELO shrinks the distance
+// from the baseline by how many drafts the card was available for, so it
+// takes a few drafts before a rating is taken close to face value.
+
+// eloPriorDrafts is how many drafts' worth of weight the baseline gets when
+// shrinking pick Elo: a card available for that many drafts keeps half its
+// distance from the baseline.
+const eloPriorDrafts = 5
+
+// availability is, per card, how much of the range it was in the cube for.
+type availability struct {
+	// available and decks count, per card, the drafts it was in the cube for
+	// and the decks built in those drafts.
+	available map[string]int
+	decks     map[string]int
+
+	// sinceAdded counts, per card, the latest drafts in a row it was in.
+	sinceAdded map[string]int
+}
+
+// loadSnapshots loads the cube snapshot of each draft among the decks, keyed
+// by draft ID. Drafts without one, or with an empty one, are left out.
+func loadSnapshots(cubeID string, allDecks []*storage.Deck) map[string][]types.Card {
+	snapshots := make(map[string][]types.Card)
+	tried := make(map[string]bool)
+	for _, d := range allDecks {
+		id := d.Metadata.DraftID
+		if tried[id] || id == "" {
+			continue
+		}
+		tried[id] = true
+		if cards, ok := loadSnapshot(cubeID, id); ok {
+			snapshots[id] = cards
+		}
+	}
+	return snapshots
+}
+
+// loadSnapshot loads a single draft's cube snapshot. Some older drafts were
+// saved with an empty card list, which says nothing about what was in the
+// cube, so those count as missing.
+func loadSnapshot(cubeID, draftID string) ([]types.Card, bool) {
+	snap, err := types.LoadCube(fmt.Sprintf("data/%s/%s/cube-snapshot.json", cubeID, draftID))
+	if err != nil || len(snap.Cards) == 0 {
+		return nil, false
+	}
+	return snap.Cards, true
+}
+
+// cardAvailability works out which drafts among the decks each card was in
+// the cube for.
+func cardAvailability(allDecks []*storage.Deck, snapshots map[string][]types.Card, cubeCards map[string]types.Card) *availability {
+	decksIn := make(map[string]int)
+	played := make(map[string]map[string]bool)
+	for _, d := range allDecks {
+		id := d.Metadata.DraftID
+		decksIn[id]++
+		if played[id] == nil {
+			played[id] = make(map[string]bool)
+		}
+		for _, c := range d.Mainboard {
+			played[id][c.Name] = true
+		}
+	}
+	drafts := make([]string, 0, len(decksIn))
+	for id := range decksIn {
+		drafts = append(drafts, id)
+	}
+	sort.Strings(drafts)
+
+	a := &availability{
+		available:  make(map[string]int),
+		decks:      make(map[string]int),
+		sinceAdded: make(map[string]int),
+	}
+
+	// Walk the drafts newest first. run holds the cards that have been in
+	// every draft so far, so a card drops out the first time it's missing.
+	var run map[string]bool
+	for i := len(drafts) - 1; i >= 0; i-- {
+		id := drafts[i]
+		inCube := make(map[string]bool)
+		if snap, ok := snapshots[id]; ok {
+			for _, c := range snap {
+				inCube[c.Name] = true
+			}
+		} else {
+			for name := range cubeCards {
+				inCube[name] = true
+			}
+		}
+		for name := range played[id] {
+			inCube[name] = true
+		}
+		for name := range inCube {
+			a.available[name]++
+			a.decks[name] += decksIn[id]
+		}
+		if run == nil {
+			run = inCube
+		}
+		for name := range run {
+			if !inCube[name] {
+				delete(run, name)
+				continue
+			}
+			a.sinceAdded[name]++
+		}
+	}
+	return a
+}
+
+// apply fills in a card's availability-normalized stats. mainboardDrafts is
+// the number of drafts someone mainboarded it in.
+func (a *availability) apply(card *cardStats, mainboardDrafts int) {
+	card.Available = a.available[card.Name]
+	card.DraftsSinceAdded = a.sinceAdded[card.Name]
+	if card.Available == 0 {
+		return
+	}
+	card.PlayRate = pct(float64(mainboardDrafts), float64(card.Available))
+	card.MainboardRate = pct(float64(card.Mainboard), float64(a.decks[card.Name]))
+	shrink := float64(card.Available) / float64(card.Available+eloPriorDrafts)
+	card.NormalizedELO = int(math.Round(eloBase + float64(card.ELO-int(eloBase))*shrink))
+}
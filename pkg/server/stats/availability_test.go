@@ -0,0 +1,160 @@
+package stats
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/caseydavenport/cube-tools/pkg/storage"
+	"github.com/caseydavenport/cube-tools/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCardAvailability(t *testing.T) {
+	cube := map[string]types.Card{"Old": {Name: "Old"}, "New": {Name: "New"}, "Back": {Name: "Back"}}
+	snapshots := map[string][]types.Card{
+		"d1": {{Name: "Old"}, {Name: "Back"}},
+		"d2": {{Name: "Old"}},
+		"d3": {{Name: "Old"}, {Name: "New"}, {Name: "Back"}},
+		// d4 has no snapshot, so it ran today's cube.
+	}
+	allDecks := []*storage.Deck{
+		makePivotDeck("p1", "d1", "", nil, "", nil, nil),
+		makePivotDeck("p2", "d1", "", nil, "", nil, nil),
+		makePivotDeck("p1", "d2", "", nil, "", nil, nil),
+		makePivotDeck("p1", "d3", "", nil, "", nil, nil),
+		makePivotDeck("p1", "d4", "", nil, "", nil, nil),
+		makePivotDeck("p2", "d4", "", nil, "", nil, nil),
+	}
+
+	a := cardAvailability(allDecks, snapshots, cube)
+	assert.Equal(t, map[string]int{"Old": 4, "New": 2, "Back": 3}, a.available)
+	assert.Equal(t, map[string]int{"Old": 6, "New": 3, "Back": 5}, a.decks)
+
+	// Back was cut for d2 and returned in d3, so its run starts there.
+	assert.Equal(t, map[string]int{"Old": 4, "New": 2, "Back": 2}, a.sinceAdded)
+}
+
+// Some older drafts have a snapshot with no cards in it. Those say nothing
+// about what was in the cube, so they count as today's cube rather than a cube
+// with nothing in it.
+func TestLoadSnapshots_Empty(t *testing.T) {
+	t.Chdir(t.TempDir())
+	for id, body := range map[string]string{
+		"d1": `{"cards":[{"name":"Old"}]}`,
+		"d2": `{"cards":[]}`,
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join("data", "test", id), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join("data", "test", id, "cube-snapshot.json"), []byte(body), 0o644))
+	}
+	allDecks := []*storage.Deck{
+		makePivotDeck("p1", "d1", "", nil, "", nil, nil),
+		makePivotDeck("p1", "d2", "", nil, "", nil, nil),
+		makePivotDeck("p1", "d3", "", nil, "", nil, nil),
+	}
+
+	snapshots := loadSnapshots("test", allDecks)
+	require.Len(t, snapshots, 1)
+	assert.Equal(t, "Old", snapshots["d1"][0].Name)
+
+	cube := map[string]types.Card{"Old": {Name: "Old"}, "New": {Name: "New"}}
+	a := cardAvailability(allDecks, snapshots, cube)
+	assert.Equal(t, map[string]int{"Old": 3, "New": 2}, a.available)
+}
+
+// A card someone mainboarded was in the cube for that draft, whatever the
+// snapshot says, so its rates can't run past 100%.
+func TestCardStatsAvailability_MainboardedOffSnapshot(t *testing.T) {
+	cube := map[string]types.Card{"Old": {Name: "Old"}, "New": {Name: "New"}}
+	snapshots := map[string][]types.Card{
+		"d1": {{Name: "Old"}},
+		"d2": {{Name: "Old"}, {Name: "New"}},
+	}
+	allDecks := []*storage.Deck{
+		makePivotDeck("p1", "d1", "2025-01-01", nil, "", []types.Card{cube["New"]}, nil),
+		makePivotDeck("p2", "d1", "2025-01-01", nil, "", []types.Card{cube["New"]}, nil),
+		makePivotDeck("p1", "d2", "2025-02-01", nil, "", []types.Card{cube["New"]}, nil),
+		makePivotDeck("p2", "d2", "2025-02-01", nil, "", nil, nil),
+	}
+
+	resp := cardStatsForDecks(allDecks, cube, &CardStatsRequest{
+		DecksRequest: &storage.DecksRequest{},
+		snapshots:    snapshots,
+	})
+
+	newCard, ok := resp.Data["New"]
+	require.True(t, ok)
+	assert.Equal(t, 2, newCard.Available)
+	assert.Equal(t, 100.0, newCard.PlayRate)
+	assert.Equal(t, 75.0, newCard.MainboardRate)
+}
+
+func TestCardStatsAvailability(t *testing.T) {
+	cube := map[string]types.Card{"Old": {Name: "Old"}, "New": {Name: "New"}}
+	snapshots := map[string][]types.Card{
+		"d1": {{Name: "Old"}},
+		"d2": {{Name: "Old"}},
+		"d3": {{Name: "Old"}, {Name: "New"}},
+	}
+	mb := func(names ...string) []types.Card {
+		var cards []types.Card
+		for _, n := range names {
+			cards = append(cards, cube[n])
+		}
+		return cards
+	}
+	allDecks := []*storage.Deck{
+		makePivotDeck("p1", "d1", "2025-01-01", nil, "", mb("Old"), nil),
+		makePivotDeck("p2", "d1", "2025-01-01", nil, "", nil, nil),
+		makePivotDeck("p1", "d2", "2025-02-01", nil, "", nil, nil),
+		makePivotDeck("p2", "d2", "2025-02-01", nil, "", nil, nil),
+		makePivotDeck("p1", "d3", "2025-03-01", nil, "", mb("New"), nil),
+		makePivotDeck("p2", "d3", "2025-03-01", nil, "", mb("Old"), nil),
+	}
+
+	resp := cardStatsForDecks(allDecks, cube, &CardStatsRequest{
+		DecksRequest: &storage.DecksRequest{},
+		snapshots:    snapshots,
+	})
+
+	// Old was mainboarded in two of its three drafts; New in the only draft
+	// it was around for.
+	old, ok := resp.Data["Old"]
+	require.True(t, ok)
+	assert.Equal(t, 3, old.Available)
+	assert.Equal(t, 3, old.DraftsSinceAdded)
+	assert.Equal(t, 66.67, old.PlayRate)
+	assert.Equal(t, 33.33, old.MainboardRate)
+
+	newCard, ok := resp.Data["New"]
+	require.True(t, ok)
+	assert.Equal(t, 1, newCard.Available)
+	assert.Equal(t, 1, newCard.DraftsSinceAdded)
+	assert.Equal(t, 100.0, newCard.PlayRate)
+	assert.Equal(t, 50.0, newCard.MainboardRate)
+}
+
+func TestAvailabilityNormalizedELO(t *testing.T) {
+	a := &availability{available: map[string]int{"A": 1, "B": 5, "C": 20}, decks: map[string]int{}, sinceAdded: map[string]int{}}
+
+	// One draft keeps a sixth of the distance, five keep half, and twenty
+	// keep most of it. Nothing overshoots the raw rating.
+	c := &cardStats{Card: types.Card{Name: "A"}, ELO: int(eloBase) + 600}
+	a.apply(c, 0)
+	assert.Equal(t, int(eloBase)+100, c.NormalizedELO)
+
+	c = &cardStats{Card: types.Card{Name: "B"}, ELO: int(eloBase) - 300}
+	a.apply(c, 0)
+	assert.Equal(t, int(eloBase)-150, c.NormalizedELO)
+
+	c = &cardStats{Card: types.Card{Name: "C"}, ELO: int(eloBase) + 100}
+	a.apply(c, 0)
+	assert.Equal(t, int(eloBase)+80, c.NormalizedELO)
+
+	// A card never in the cube gets nothing.
+	c = &cardStats{Card: types.Card{Name: "D"}, ELO: int(eloBase) + 10}
+	a.apply(c, 0)
+	assert.Equal(t, 0, c.Available)
+	assert.Equal(t, 0, c.NormalizedELO)
+}
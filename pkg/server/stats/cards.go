@@ -24,6 +24,10 @@ type CardStatsRequest struct {
 	BucketSize int  `json:"bucket_size"`
 	Sliding    bool `json:"sliding"`
 
+	// BucketBy groups drafts into calendar periods or cube versions before
+	// bucketing. See decks.ValidBucketBy.
+	BucketBy string `json:"bucket_by"`
+
 	// Color to filter by - WUBRG.
 	Color string `json:"color"`
 	// Minimum nubmer of drafts a card must have been in to be included.
@@ -38,6 +42,19 @@ type CardStatsRequest struct {
 	// SignificantOnly drops cards whose interval doesn't exclude 50% at the
 	// chosen confidence, i.e. cards we can't tell apart from a coin flip.
 	SignificantOnly bool `json:"significant_only"`
+
+	// statsQuery is the match query when it has stat terms (winpct>55,
+	// games>=20, ...). The deck store can't evaluate those, so cards are
+	// checked against the whole query once their stats are computed.
+	statsQuery *query.Query
+
+	// macros is the cube's macro archetype taxonomy, from the registry. Every
+	// card reports a by- and against-archetype record for each.
+	macros []string
+
+	// snapshots holds each draft's cube list, by draft ID, for the
+	// availability-normalized stats. See availability.go.
+	snapshots map[string][]types.Card
 }
 
 type CardStatsResponse struct {
@@ -68,6 +85,7 @@ func parseCardsRequest(r *http.Request) *CardStatsRequest {
 	p.Color = query.GetString(r, "color")
 	p.BucketSize = query.GetInt(r, "bucket_size")
 	p.Sliding = query.GetBool(r, "sliding")
+	p.BucketBy = query.GetString(r, "bucket_by")
 	p.MinDrafts = query.GetInt(r, "min_drafts")
 	p.MinGames = query.GetInt(r, "min_games")
 	p.Confidence = query.GetFloat(r, "confidence")
@@ -75,9 +93,20 @@ func parseCardsRequest(r *http.Request) *CardStatsRequest {
 
 	// Parse the embedded deck request.
 	p.DecksRequest = decks.ParseDecksRequest(r)
+	p.statsQuery = statsQuery(p.Match)
 	return &p
 }
 
+// statsQuery parses a match query, returning it only if it has stat terms.
+// Requests with an invalid query are rejected before reaching handlers.
+func statsQuery(match string) *query.Query {
+	q, err := query.Parse(match)
+	if err != nil || !q.HasStats() {
+		return nil
+	}
+	return q
+}
+
 func CardStatsHandler() http.Handler {
 	return &cardStatsHandler{
 		store: storage.NewFileDeckStoreWithCache(),
@@ -97,6 +126,7 @@ func (d *cardStatsHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 	// Build a map of all the cards in the cube, so we can use it to skip any cards
 	// not curerently in the cube.
 	cubeID := server.CubeFromRequest(r)
+	sr.macros = cubeMacros(cubeID)
 	cubeCards := make(map[string]types.Card)
 	cube, err := types.LoadCube(fmt.Sprintf("data/%s/cube.json", cubeID))
 	if err != nil {
@@ -113,14 +143,15 @@ func (d *cardStatsHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		http.Error(rw, "could not load decks", http.StatusInternalServerError)
 		return
 	}
+	sr.snapshots = loadSnapshots(cubeID, allDecks)
 
 	// Initlialize the response structure.
 	resp = CardStatsResponse{}
-	if sr.BucketSize > 0 {
-		// If bucket size is set, then create bucketed response.
-		buckets := decks.DeckBuckets(allDecks, sr.BucketSize, !sr.Sliding)
+	if decks.Bucketed(sr.BucketSize, sr.BucketBy) {
+		// If a bucket size or mode is set, then create bucketed response.
+		buckets := decks.DeckBucketsBy(allDecks, decks.NewBucketOptions(cubeID, allDecks, sr.BucketSize, !sr.Sliding, sr.BucketBy))
 		for _, b := range buckets {
-			s := d.statsForDecks(b.AllDecks(), cubeCards, sr)
+			s := cardStatsForDecks(b.AllDecks(), cubeCards, sr)
 			resp.Buckets = append(resp.Buckets, &Bucket{
 				Cards: *s,
 				Name:  b.Name(),
@@ -129,7 +160,7 @@ func (d *cardStatsHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 			})
 		}
 	} else {
-		resp.All = d.statsForDecks(allDecks, cubeCards, sr)
+		resp.All = cardStatsForDecks(allDecks, cubeCards, sr)
 	}
 
 	// Marshal the response and write it back.
@@ -145,10 +176,11 @@ func (d *cardStatsHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (d *cardStatsHandler) statsForDecks(decks []*storage.Deck, cubeCards map[string]types.Card, sr *CardStatsRequest) *Cards {
+func cardStatsForDecks(decks []*storage.Deck, cubeCards map[string]types.Card, sr *CardStatsRequest) *Cards {
 	resp := &Cards{
 		Data: make(map[string]*cardStats),
 	}
+	macros := orDefaultMacros(sr.macros)
 
 	// Sum up the total number of wins across all decks - we'll use this to calculate
 	// PercentOfWins for each card later.
@@ -162,6 +194,7 @@ func (d *cardStatsHandler) statsForDecks(decks []*storage.Deck, cubeCards map[st
 		}
 		draftsByCard[cardName][draftID] = true
 	}
+	mainboardDrafts := make(map[string]map[string]bool)
 
 	// Go through each deck, adding stats for each card in the deck.
 	for _, deck := range decks {
@@ -192,7 +225,7 @@ func (d *cardStatsHandler) statsForDecks(decks []*storage.Deck, cubeCards map[st
 			cbn.BottomHalf += deck.BottomHalf()
 
 			// Add contribution to archetype-specific stats.
-			arch := deck.Macro()
+			arch := deck.MacroIn(macros)
 			if arch != "" {
 				if cbn.ByArchetype[arch] == nil {
 					cbn.ByArchetype[arch] = &winStats{}
@@ -202,7 +235,7 @@ func (d *cardStatsHandler) statsForDecks(decks []*storage.Deck, cubeCards map[st
 
 			// For each deck that this card was in, go through each opponent deck and add to the AgainstArchetype stats.
 			for _, game := range deck.Games {
-				arch := server.LookupOpponentMacro(decks, deck, game.Opponent)
+				arch := server.LookupOpponentMacro(decks, deck, game.Opponent, macros)
 				if arch == "" {
 					continue // No opponent deck found.
 				}
@@ -254,6 +287,10 @@ func (d *cardStatsHandler) statsForDecks(decks []*storage.Deck, cubeCards map[st
 
 			// Track drafts for this card.
 			seeCardInDraft(card.Name, deck.Metadata.DraftID)
+			if mainboardDrafts[card.Name] == nil {
+				mainboardDrafts[card.Name] = make(map[string]bool)
+			}
+			mainboardDrafts[card.Name][deck.Metadata.DraftID] = true
 		}
 
 		for _, card := range sbSet {
@@ -300,6 +337,7 @@ func (d *cardStatsHandler) statsForDecks(decks []*storage.Deck, cubeCards map[st
 	// Get ELO data to include in the response.
 	eloData := PickELOData(decks)
 	matchEloData := MatchELOData(decks)
+	avail := cardAvailability(decks, sr.snapshots, cubeCards)
 
 	// Now that we've gone through all the decks, calculate win percentages and mainboard/sideboard percentages,
 	// and perform any filtering based on the request parameters.
@@ -336,6 +374,14 @@ func (d *cardStatsHandler) statsForDecks(decks []*storage.Deck, cubeCards map[st
 		// Calculate per-archetype win percentages. Only show archetype-specific
 		// win percentages with at least 15 games played; smaller samples are
 		// noisy and misleading.
+		for _, m := range macros {
+			if card.ByArchetype[m] == nil {
+				card.ByArchetype[m] = &winStats{}
+			}
+			if card.AgainstArchetype[m] == nil {
+				card.AgainstArchetype[m] = &winStats{}
+			}
+		}
 		for _, ws := range card.ByArchetype {
 			if ws.Wins+ws.Losses+ws.Draws > 15 {
 				ws.Finalize()
@@ -353,6 +399,7 @@ func (d *cardStatsHandler) statsForDecks(decks []*storage.Deck, cubeCards map[st
 		if drafts, ok := draftsByCard[card.Name]; ok {
 			card.Drafts = len(drafts)
 		}
+		avail.apply(card, len(mainboardDrafts[card.Name]))
 
 		// Apply filtering based on the request parameters.
 		if shouldFilterCard(card, sr) {
@@ -604,7 +651,7 @@ func PickELOData(decks []*storage.Deck) map[string]int {
 func shouldFilterCard(cbn *cardStats, sr *CardStatsRequest) bool {
 	// For bucketed requests, we don't filter. The filteres in the request only apply to non-bucketed
 	// aggregate requests.
-	if sr.BucketSize > 0 {
+	if decks.Bucketed(sr.BucketSize, sr.BucketBy) {
 		return false
 	}
 
@@ -620,6 +667,10 @@ func shouldFilterCard(cbn *cardStats, sr *CardStatsRequest) bool {
 	if sr.SignificantOnly && !cbn.Significant {
 		return true
 	}
+
+	if sr.statsQuery != nil && !sr.statsQuery.MatchesCardStats(cbn.Card, cbn.queryStats()) {
+		return true
+	}
 	return false
 }
 
@@ -701,25 +752,17 @@ type Cards struct {
 
 func newCardStats(c types.Card) *cardStats {
 	return &cardStats{
-		Card:         c,
-		Archetypes:   make(map[string]int),
-		Players:      make(map[string]int),
-		Sideboarders: make(map[string]int),
-		Land:         c.IsLand(),
-		Interaction:  c.IsInteraction(),
-		Counterspell: c.IsCounterspell(),
-		Removal:      c.IsRemoval(),
-		WordCount:    c.WordCount(),
-		ByArchetype: map[string]*winStats{
-			"aggro":    {},
-			"midrange": {},
-			"control":  {},
-		},
-		AgainstArchetype: map[string]*winStats{
-			"aggro":    {},
-			"midrange": {},
-			"control":  {},
-		},
+		Card:             c,
+		Archetypes:       make(map[string]int),
+		Players:          make(map[string]int),
+		Sideboarders:     make(map[string]int),
+		Land:             c.IsLand(),
+		Interaction:      c.IsInteraction(),
+		Counterspell:     c.IsCounterspell(),
+		Removal:          c.IsRemoval(),
+		WordCount:        c.WordCount(),
+		ByArchetype:      make(map[string]*winStats),
+		AgainstArchetype: make(map[string]*winStats),
 	}
 }
 
@@ -744,6 +787,18 @@ type cardStats struct {
 	// Total number of drafts this card has been in.
 	Drafts int `json:"drafts,omitempty"`
 
+	// Available is the number of drafts the card was in the cube for, going
+	// by each draft's cube snapshot, and DraftsSinceAdded how many of the
+	// latest drafts in a row it's been in: the drafts since it was last added.
+	Available        int `json:"available"`
+	DraftsSinceAdded int `json:"drafts_since_added"`
+
+	// PlayRate is the percentage of available drafts in which someone
+	// mainboarded the card, and MainboardRate the percentage of decks built
+	// in those drafts that did.
+	PlayRate      float64 `json:"play_rate"`
+	MainboardRate float64 `json:"mainboard_rate"`
+
 	// Total number of games this card has been in.
 	TotalGames int `json:"total_games"`
 
@@ -829,6 +884,10 @@ type cardStats struct {
 	// ELO.
 	ELO int `json:"elo"`
 
+	// NormalizedELO is ELO shrunk toward the baseline by how few drafts the
+	// card was available for. See availability.go.
+	NormalizedELO int `json:"normalized_elo"`
+
 	// MatchELO is the card's Elo from actual match results (see MatchELOData),
 	// where ELO above scores draft picks. Cards that never played a match sit at
 	// the baseline, so gate on game/match counts to tell "performed at baseline"
@@ -844,6 +903,18 @@ type cardStats struct {
 	AgainstArchetype map[string]*winStats `json:"against_archetype,omitempty"`
 }
 
+// queryStats returns the card's stats as the query language sees them.
+func (c *cardStats) queryStats() query.CardStats {
+	return query.CardStats{
+		Games:      c.TotalGames,
+		Mainboard:  c.Mainboard,
+		Sideboard:  c.Sideboard,
+		Players:    len(c.Players),
+		Drafts:     c.Drafts,
+		WinPercent: c.WinPercent,
+	}
+}
+
 type winStats struct {
 	Record
 }
@@ -1,13 +1,21 @@
 package stats
 
 import (
+	"encoding/json"
 	"math"
 	"math/rand"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/caseydavenport/cube-tools/pkg/server"
+	"github.com/caseydavenport/cube-tools/pkg/server/query"
 	"github.com/caseydavenport/cube-tools/pkg/storage"
 	"github.com/caseydavenport/cube-tools/pkg/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func makeCardDeck(player string, games []types.Game, mainboard []types.Card, sideboard []types.Card) *storage.Deck {
@@ -299,3 +307,136 @@ func TestEloWinValue_ColorPenalty(t *testing.T) {
 	assert.Greater(t, eloWinValue(base, sameColor), eloWinValue(base, monoVsDualDisjoint),
 		"disjoint colors should drop winValue")
 }
+
+// --- Stat terms in the match query ---
+
+func statsFixture() ([]*storage.Deck, map[string]types.Card) {
+	bolt := types.Card{Name: "Lightning Bolt", Colors: []string{"R"}, Types: []string{"Instant"}}
+	bear := types.Card{Name: "Grizzly Bears", Colors: []string{"G"}, Types: []string{"Creature"}}
+	shock := types.Card{Name: "Shock", Colors: []string{"R"}, Types: []string{"Instant"}}
+	// Alice goes 3-1 against Bob.
+	aliceWin := types.Game{Opponent: "bob", Winner: "alice"}
+	bobWin := types.Game{Opponent: "alice", Winner: "bob"}
+	decks := []*storage.Deck{
+		makePivotDeck("alice", "d1", "2024-01-01", nil, "", []types.Card{bolt, bear},
+			[]types.Game{aliceWin, aliceWin, aliceWin, bobWin}),
+		makePivotDeck("bob", "d1", "2024-01-01", nil, "", []types.Card{shock},
+			[]types.Game{{Opponent: "alice", Winner: "alice"}, {Opponent: "alice", Winner: "alice"},
+				{Opponent: "alice", Winner: "alice"}, {Opponent: "alice", Winner: "bob"}}),
+	}
+	cubeCards := map[string]types.Card{bolt.Name: bolt, bear.Name: bear, shock.Name: shock}
+	return decks, cubeCards
+}
+
+func TestCardStatsForDecks_StatTerms(t *testing.T) {
+	decks, cubeCards := statsFixture()
+	names := func(match string) []string {
+		sr := &CardStatsRequest{DecksRequest: &storage.DecksRequest{Match: match}, statsQuery: statsQuery(match)}
+		var out []string
+		for name := range cardStatsForDecks(decks, cubeCards, sr).Data {
+			out = append(out, name)
+		}
+		return out
+	}
+
+	assert.ElementsMatch(t, []string{"Lightning Bolt"}, names("winpct>55 games>=4 c:R"))
+	assert.ElementsMatch(t, []string{"Lightning Bolt", "Grizzly Bears"}, names("winpct>55"))
+	assert.ElementsMatch(t, []string{"Shock"}, names("winpct<50 OR mb>1"))
+	assert.ElementsMatch(t, []string{"Grizzly Bears"}, names("players=1 drafts=1 -c:R sb=0 winpct>=75"))
+
+	// Without stat terms the query is left to the deck store.
+	assert.Nil(t, statsQuery("c:R"))
+	assert.Len(t, names("c:R"), 3)
+}
+
+// queryDeckStorage filters its decks by the request's match query, the way the
+// file store does.
+type queryDeckStorage struct {
+	mockDeckStorage
+}
+
+func (m *queryDeckStorage) List(_ string, r *storage.DecksRequest) ([]*storage.Deck, error) {
+	q, err := query.Parse(r.Match)
+	if err != nil {
+		return nil, err
+	}
+	var out []*storage.Deck
+	for _, d := range m.decks {
+		if q.MatchesDeck(d, r.Board) {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+// writeTestCube changes into a temp dir holding data/{id}/cube.json with the
+// given cards, for handlers that load the cube list.
+func writeTestCube(t *testing.T, id string, cards map[string]types.Card) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	cube := types.Cube{}
+	for _, c := range cards {
+		cube.Cards = append(cube.Cards, c)
+	}
+	b, err := json.Marshal(cube)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join("data", id), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join("data", id, "cube.json"), b, 0o644))
+}
+
+// Negated stat and deck terms go through the deck store before any card has
+// stats, and through the card filter where decks don't apply. Neither should
+// knock everything out.
+func TestCardStatsHandler_NegatedTerms(t *testing.T) {
+	decks, cubeCards := statsFixture()
+	decks[1].Labels = []string{"control"}
+
+	writeTestCube(t, "test", cubeCards)
+
+	h := &cardStatsHandler{store: &queryDeckStorage{mockDeckStorage{decks: decks}}}
+	names := func(match string) []string {
+		r := httptest.NewRequest("GET", "/api/test/stats/cards?match="+url.QueryEscape(match), nil)
+		r = r.WithContext(server.ContextWithCube(r.Context(), "test"))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		require.Equal(t, 200, rec.Code, match)
+		var resp CardStatsResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		var out []string
+		for name, c := range resp.All.Data {
+			if c.Mainboard > 0 {
+				out = append(out, name)
+			}
+		}
+		return out
+	}
+
+	assert.ElementsMatch(t, []string{"Shock"}, names("c:R -winpct>55"))
+	assert.ElementsMatch(t, []string{"Shock"}, names("NOT winpct>=75"))
+	assert.ElementsMatch(t, []string{"Lightning Bolt", "Grizzly Bears", "Shock"}, names("-games<4"))
+	assert.ElementsMatch(t, []string{"Lightning Bolt", "Grizzly Bears"}, names("-arch:control"))
+	assert.ElementsMatch(t, []string{"Lightning Bolt", "Grizzly Bears"}, names("-arch:control winpct>50"))
+	assert.ElementsMatch(t, []string{"Shock"}, names("-player:alice -games>5"))
+}
+
+func TestRestrictSynergy(t *testing.T) {
+	decks, cubeCards := statsFixture()
+	q, err := query.Parse("winpct>55")
+	assert.NoError(t, err)
+	keep := cardsMatchingStats(q, decks, cubeCards)
+	assert.Equal(t, map[string]bool{"Lightning Bolt": true, "Grizzly Bears": true}, keep)
+
+	resp := SynergyStatsResponse{
+		Pairs: []SynergyResult{
+			{Card1: "Grizzly Bears", Card2: "Lightning Bolt"},
+			{Card1: "Lightning Bolt", Card2: "Shock"},
+		},
+		FocalStats:     []CardFocalStat{{CardName: "Lightning Bolt"}, {CardName: "Shock"}},
+		CardPlayCounts: map[string]int{"Lightning Bolt": 1, "Grizzly Bears": 1, "Shock": 1},
+	}
+	restrictSynergy(&resp, keep)
+	assert.Len(t, resp.Pairs, 1)
+	assert.Equal(t, "Grizzly Bears", resp.Pairs[0].Card1)
+	assert.Len(t, resp.FocalStats, 1)
+	assert.NotContains(t, resp.CardPlayCounts, "Shock")
+}
@@ -0,0 +1,572 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/caseydavenport/cube-tools/pkg/cubes"
+	"github.com/caseydavenport/cube-tools/pkg/server"
+	"github.com/caseydavenport/cube-tools/pkg/server/decks"
+	"github.com/caseydavenport/cube-tools/pkg/server/query"
+	"github.com/caseydavenport/cube-tools/pkg/storage"
+	"github.com/caseydavenport/cube-tools/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// Archetype clustering groups decks by what they play, without looking at the
+// hand-assigned macro archetype or labels. Each deck becomes its rarity-weighted
+// mainboard embedding (see decks.DeckEmbedder) plus a few composition features,
+// and k-means splits them into clusters. A cluster is named after the cards that
+// set it apart from the rest of the cube, and the labels its already-tagged decks
+// agree on become suggestions for its untagged ones.
+
+// compFeatures are the deckComposition dimensions added to each deck's card
+// vector, with the typical spread used to put them on a comparable scale.
+var compFeatures = []struct {
+	dim   string
+	scale float64
+}{
+	{"creatures", 4},
+	{"removal", 2},
+	{"interaction", 3},
+	{"counterspell", 1.5},
+	{"lands", 1},
+	{"avg_cmc", 0.4},
+}
+
+// compWeight is how much the composition features count relative to the card
+// vector, which has unit length.
+const compWeight = 0.15
+
+// labelAgreement is the share of a cluster's labeled decks that must carry a
+// label before it's suggested for the cluster's unlabeled decks.
+const labelAgreement = 0.5
+
+// ArchetypeClustersResponse is the API response for
+// /api/stats/archetype-clusters.
+type ArchetypeClustersResponse struct {
+	Clusters    []*ArchetypeCluster  `json:"clusters"`
+	Suggestions []*ClusterSuggestion `json:"suggestions"`
+}
+
+type ArchetypeCluster struct {
+	Record
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Size int    `json:"size"`
+
+	// Cards are the cluster's most characteristic cards: the ones its decks
+	// play much more often than the cube as a whole.
+	Cards []*ClusterCard `json:"cards"`
+
+	// Composition is the cluster's average for each composition feature.
+	Composition map[string]float64 `json:"composition"`
+
+	// Macros and Labels count the existing tags among the cluster's decks.
+	Macros map[string]int `json:"macros"`
+	Labels map[string]int `json:"labels"`
+
+	// SuggestedMacro and SuggestedLabels are what the tagged decks agree on.
+	SuggestedMacro  string   `json:"suggested_macro,omitempty"`
+	SuggestedLabels []string `json:"suggested_labels"`
+
+	Decks []*ClusterDeck `json:"decks"`
+}
+
+type ClusterCard struct {
+	Name string `json:"name"`
+
+	// InCluster and Overall are the percentage of the cluster's decks, and of
+	// all decks, that mainboard the card.
+	InCluster float64 `json:"in_cluster"`
+	Overall   float64 `json:"overall"`
+}
+
+type ClusterDeck struct {
+	DraftID        string   `json:"draft_id"`
+	Player         string   `json:"player"`
+	MacroArchetype string   `json:"macro_archetype,omitempty"`
+	Labels         []string `json:"labels,omitempty"`
+}
+
+// ClusterSuggestion proposes tags for a deck that has none.
+type ClusterSuggestion struct {
+	DraftID        string   `json:"draft_id"`
+	Player         string   `json:"player"`
+	Cluster        int      `json:"cluster"`
+	MacroArchetype string   `json:"macro_archetype,omitempty"`
+	Labels         []string `json:"labels,omitempty"`
+}
+
+func ArchetypeClustersHandler() http.Handler {
+	return &archetypeClustersHandler{store: storage.NewFileDeckStoreWithCache()}
+}
+
+type archetypeClustersHandler struct {
+	store storage.DeckStorage
+}
+
+func (h *archetypeClustersHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	dr := decks.ParseDecksRequest(r)
+	logrus.WithField("params", dr).Info("/api/stats/archetype-clusters")
+
+	cubeID := server.CubeFromRequest(r)
+	allDecks, err := h.store.List(cubeID, dr)
+	if err != nil {
+		http.Error(rw, "could not load decks", http.StatusInternalServerError)
+		return
+	}
+
+	cubeCards := make(map[string]types.Card)
+	cube, err := types.LoadCube(fmt.Sprintf("data/%s/cube.json", cubeID))
+	if err == nil {
+		for _, c := range cube.Cards {
+			cubeCards[c.Name] = c
+		}
+	}
+
+	seed := int64(query.GetInt(r, "seed"))
+	if seed == 0 {
+		seed = 1
+	}
+	resp := clusterArchetypes(allDecks, cubeCards, query.GetInt(r, "k"), seed, zForConfidence(query.GetFloat(r, "confidence")))
+	writeJSON(rw, resp)
+}
+
+// defaultClusterCount picks k for n decks when the request doesn't.
+func defaultClusterCount(n int) int {
+	return max(2, min(12, int(math.Round(math.Sqrt(float64(n)/2)))))
+}
+
+// clusterArchetypes clusters the decks into k groups (a default when k <= 0)
+// and describes each one. seed fixes k-means initialisation so the same data
+// gives the same clusters.
+func clusterArchetypes(allDecks []*storage.Deck, cubeCards map[string]types.Card, k int, seed int64, z float64) ArchetypeClustersResponse {
+	resp := ArchetypeClustersResponse{Clusters: []*ArchetypeCluster{}, Suggestions: []*ClusterSuggestion{}}
+	if len(allDecks) == 0 {
+		return resp
+	}
+	if k <= 0 {
+		k = defaultClusterCount(len(allDecks))
+	}
+	k = min(k, len(allDecks))
+
+	vectors, comps := deckFeatures(allDecks, cubeCards)
+	assign := kMeans(vectors, k, rand.New(rand.NewSource(seed)))
+
+	// Overall card frequency, for picking each cluster's characteristic cards.
+	overall := mainboardFrequency(allDecks)
+
+	members := make([][]int, k)
+	for i, c := range assign {
+		members[c] = append(members[c], i)
+	}
+	for c, idx := range members {
+		if len(idx) == 0 {
+			continue
+		}
+		cl := describeCluster(c, idx, allDecks, comps, overall, z)
+		resp.Clusters = append(resp.Clusters, cl)
+
+		if cl.SuggestedMacro == "" && len(cl.SuggestedLabels) == 0 {
+			continue
+		}
+		for _, i := range idx {
+			d := allDecks[i]
+			if d.MacroArchetype != "" || len(d.Labels) > 0 {
+				continue
+			}
+			resp.Suggestions = append(resp.Suggestions, &ClusterSuggestion{
+				DraftID:        d.Metadata.DraftID,
+				Player:         d.Player,
+				Cluster:        c,
+				MacroArchetype: cl.SuggestedMacro,
+				Labels:         cl.SuggestedLabels,
+			})
+		}
+	}
+	sort.Slice(resp.Clusters, func(i, j int) bool { return resp.Clusters[i].Size > resp.Clusters[j].Size })
+	return resp
+}
+
+// deckFeatures builds each deck's feature vector: its card embedding plus its
+// composition features, centered on the cube average and scaled by compWeight.
+func deckFeatures(allDecks []*storage.Deck, cubeCards map[string]types.Card) ([]decks.Embedding, []deckComposition) {
+	e := decks.NewDeckEmbedder(allDecks)
+	comps := make([]deckComposition, len(allDecks))
+	means := make(map[string]float64)
+	for i, d := range allDecks {
+		comps[i] = composition(d, cubeCards)
+		for _, f := range compFeatures {
+			means[f.dim] += comps[i].value(f.dim) / float64(len(allDecks))
+		}
+	}
+
+	vectors := make([]decks.Embedding, len(allDecks))
+	for i, d := range allDecks {
+		v := e.Embed(d)
+		for _, f := range compFeatures {
+			v["comp:"+f.dim] = compWeight * (comps[i].value(f.dim) - means[f.dim]) / f.scale
+		}
+		vectors[i] = v
+	}
+	return vectors, comps
+}
+
+// kMeansRestarts is how many differently-seeded runs kMeans tries. Lloyd's
+// algorithm settles into whichever local optimum its seeds lead to, and with a
+// handful of archetypes an unlucky pair of seeds in the same family is common.
+const kMeansRestarts = 10
+
+// kMeans partitions vectors into k clusters, returning each vector's cluster
+// index. It keeps the best of several k-means++ seeded runs, scored by total
+// squared distance to the assigned centroids.
+func kMeans(vectors []decks.Embedding, k int, rng *rand.Rand) []int {
+	var best []int
+	bestCost := math.Inf(1)
+	for run := 0; run < kMeansRestarts; run++ {
+		assign, cost := lloyd(vectors, seedCentroids(vectors, k, rng))
+		// Costs within rounding of each other are the same partition found
+		// twice; keep the first for repeatability.
+		if cost < bestCost-1e-9 {
+			best, bestCost = assign, cost
+		}
+	}
+	return best
+}
+
+// lloyd runs Lloyd's algorithm from the given centroids, returning each
+// vector's cluster and the total squared distance to its centroid.
+func lloyd(vectors []decks.Embedding, centroids []decks.Embedding) ([]int, float64) {
+	assign := make([]int, len(vectors))
+	for i := range assign {
+		assign[i] = -1
+	}
+
+	// Distances expand to |v|^2 + |c|^2 - 2v.c so each one only walks the
+	// deck's few dozen cards, not the centroid's union of every member's.
+	norms := make([]float64, len(vectors))
+	for i, v := range vectors {
+		norms[i] = dot(v, v)
+	}
+
+	const maxIterations = 50
+	var cost float64
+	for iter := 0; iter < maxIterations; iter++ {
+		changed := false
+		cost = 0
+		cnorms := make([]float64, len(centroids))
+		for c, cen := range centroids {
+			cnorms[c] = dot(cen, cen)
+		}
+		for i, v := range vectors {
+			// Sparse sums come out in map order, so equal distances can differ
+			// in the last bits; treat those as ties and keep the lower index
+			// so runs are repeatable.
+			best, bestDist := 0, math.Inf(1)
+			for c, cen := range centroids {
+				if d := norms[i] + cnorms[c] - 2*dot(v, cen); d < bestDist-1e-9 {
+					best, bestDist = c, d
+				}
+			}
+			cost += bestDist
+			if assign[i] != best {
+				assign[i] = best
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+		centroids = recomputeCentroids(vectors, assign, centroids)
+	}
+	return assign, cost
+}
+
+// seedCentroids picks k starting centroids with k-means++: each new one is
+// drawn with probability proportional to its squared distance from the
+// nearest centroid so far, spreading them across the data.
+func seedCentroids(vectors []decks.Embedding, k int, rng *rand.Rand) []decks.Embedding {
+	centroids := []decks.Embedding{vectors[rng.Intn(len(vectors))]}
+	dist := make([]float64, len(vectors))
+	for len(centroids) < k {
+		var total float64
+		for i, v := range vectors {
+			dist[i] = math.Inf(1)
+			for _, c := range centroids {
+				dist[i] = math.Min(dist[i], sqDist(v, c))
+			}
+			total += dist[i]
+		}
+		if total == 0 {
+			// Every remaining vector duplicates a centroid.
+			break
+		}
+		target := rng.Float64() * total
+		next := len(vectors) - 1
+		for i, d := range dist {
+			target -= d
+			if target <= 0 {
+				next = i
+				break
+			}
+		}
+		centroids = append(centroids, vectors[next])
+	}
+	return centroids
+}
+
+// recomputeCentroids averages each cluster's vectors. A cluster that lost all
+// its members keeps its previous centroid.
+func recomputeCentroids(vectors []decks.Embedding, assign []int, prev []decks.Embedding) []decks.Embedding {
+	sums := make([]decks.Embedding, len(prev))
+	counts := make([]int, len(prev))
+	for i, v := range vectors {
+		c := assign[i]
+		if sums[c] == nil {
+			sums[c] = make(decks.Embedding)
+		}
+		for k, x := range v {
+			sums[c][k] += x
+		}
+		counts[c]++
+	}
+	for c := range sums {
+		if counts[c] == 0 {
+			sums[c] = prev[c]
+			continue
+		}
+		for k := range sums[c] {
+			sums[c][k] /= float64(counts[c])
+		}
+	}
+	return sums
+}
+
+// dot is the dot product of two sparse vectors; pass the smaller as a.
+func dot(a, b decks.Embedding) float64 {
+	var d float64
+	for k, x := range a {
+		d += x * b[k]
+	}
+	return d
+}
+
+func sqDist(a, b decks.Embedding) float64 {
+	var d float64
+	for k, x := range a {
+		y := b[k]
+		d += (x - y) * (x - y)
+	}
+	for k, y := range b {
+		if _, ok := a[k]; !ok {
+			d += y * y
+		}
+	}
+	return d
+}
+
+// mainboardFrequency returns the fraction of decks that mainboard each
+// nonbasic card.
+func mainboardFrequency(ds []*storage.Deck) map[string]float64 {
+	freq := make(map[string]float64)
+	for _, d := range ds {
+		seen := make(map[string]bool)
+		for _, c := range d.Mainboard {
+			if c.IsBasicLand() || seen[c.Name] {
+				continue
+			}
+			seen[c.Name] = true
+			freq[c.Name] += 1 / float64(len(ds))
+		}
+	}
+	return freq
+}
+
+// describeCluster summarises the decks at idx as cluster id.
+func describeCluster(id int, idx []int, allDecks []*storage.Deck, comps []deckComposition, overall map[string]float64, z float64) *ArchetypeCluster {
+	cl := &ArchetypeCluster{
+		ID:              id,
+		Size:            len(idx),
+		Composition:     make(map[string]float64),
+		Macros:          make(map[string]int),
+		Labels:          make(map[string]int),
+		SuggestedLabels: []string{},
+		Decks:           make([]*ClusterDeck, 0, len(idx)),
+	}
+
+	members := make([]*storage.Deck, 0, len(idx))
+	lands := make(map[string]bool)
+	labeled := 0
+	for _, i := range idx {
+		d := allDecks[i]
+		members = append(members, d)
+		for _, c := range d.Mainboard {
+			if c.IsLand() {
+				lands[c.Name] = true
+			}
+		}
+		cl.Add(d)
+		for _, f := range compFeatures {
+			cl.Composition[f.dim] += comps[i].value(f.dim)
+		}
+		if d.MacroArchetype != "" {
+			cl.Macros[strings.ToLower(d.MacroArchetype)]++
+		}
+		for _, l := range d.Labels {
+			cl.Labels[strings.ToLower(l)]++
+		}
+		if d.MacroArchetype != "" || len(d.Labels) > 0 {
+			labeled++
+		}
+		cl.Decks = append(cl.Decks, &ClusterDeck{
+			DraftID:        d.Metadata.DraftID,
+			Player:         d.Player,
+			MacroArchetype: d.MacroArchetype,
+			Labels:         d.Labels,
+		})
+	}
+	cl.Finalize()
+	cl.SetInterval(z)
+	for dim, v := range cl.Composition {
+		cl.Composition[dim] = math.Round(100*v/float64(len(idx))) / 100
+	}
+
+	// Characteristic cards: spells played by a good share of the cluster and
+	// ranked by how much more often than the cube at large. Lands mostly say
+	// which colors a deck is, which the spells already show.
+	inCluster := mainboardFrequency(members)
+	for name, f := range inCluster {
+		if lands[name] || f < 0.25 || f <= overall[name] {
+			continue
+		}
+		cl.Cards = append(cl.Cards, &ClusterCard{Name: name, InCluster: round1(100 * f), Overall: round1(100 * overall[name])})
+	}
+	sort.Slice(cl.Cards, func(i, j int) bool {
+		di := cl.Cards[i].InCluster - cl.Cards[i].Overall
+		dj := cl.Cards[j].InCluster - cl.Cards[j].Overall
+		if di != dj {
+			return di > dj
+		}
+		return cl.Cards[i].Name < cl.Cards[j].Name
+	})
+	if len(cl.Cards) > 8 {
+		cl.Cards = cl.Cards[:8]
+	}
+	names := []string{}
+	for _, c := range cl.Cards[:min(3, len(cl.Cards))] {
+		names = append(names, c.Name)
+	}
+	cl.Name = strings.Join(names, " / ")
+	if cl.Name == "" {
+		cl.Name = fmt.Sprintf("Cluster %d", id+1)
+	}
+
+	if labeled > 0 {
+		cl.SuggestedMacro = majority(cl.Macros, labeled)
+		for l, n := range cl.Labels {
+			if float64(n) >= labelAgreement*float64(labeled) {
+				cl.SuggestedLabels = append(cl.SuggestedLabels, l)
+			}
+		}
+		slices.Sort(cl.SuggestedLabels)
+	}
+	return cl
+}
+
+// majority returns the key holding at least labelAgreement of total, if any.
+func majority(counts map[string]int, total int) string {
+	best, bestN := "", 0
+	for k, n := range counts {
+		if n > bestN || (n == bestN && k < best) {
+			best, bestN = k, n
+		}
+	}
+	if float64(bestN) < labelAgreement*float64(total) {
+		return ""
+	}
+	return best
+}
+
+// AcceptClusterSuggestionsRequest is the body of
+// POST /api/{cube}/stats/archetype-clusters/accept.
+type AcceptClusterSuggestionsRequest struct {
+	Suggestions []*ClusterSuggestion `json:"suggestions"`
+}
+
+// AcceptClusterSuggestionsResponse reports how many decks were updated and
+// why any weren't.
+type AcceptClusterSuggestionsResponse struct {
+	Updated int      `json:"updated"`
+	Errors  []string `json:"errors"`
+}
+
+// AcceptClusterSuggestionsHandler applies suggestions in bulk. A suggestion
+// only fills in what a deck lacks: an existing macro archetype is kept and
+// suggested labels are added to any the deck already has.
+func AcceptClusterSuggestionsHandler(store storage.DeckStorage) http.Handler {
+	return &acceptClusterSuggestionsHandler{store: store}
+}
+
+type acceptClusterSuggestionsHandler struct {
+	store storage.DeckStorage
+}
+
+func (h *acceptClusterSuggestionsHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	var req AcceptClusterSuggestionsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(rw, fmt.Sprintf("invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+	cubeID := server.CubeFromRequest(r)
+	logrus.WithField("suggestions", len(req.Suggestions)).Info("/api/stats/archetype-clusters/accept")
+
+	allDecks, err := h.store.List(cubeID, nil)
+	if err != nil {
+		http.Error(rw, "could not load decks", http.StatusInternalServerError)
+		return
+	}
+	byKey := make(map[[2]string]*storage.Deck, len(allDecks))
+	for _, d := range allDecks {
+		byKey[[2]string{d.Metadata.DraftID, strings.ToLower(d.Player)}] = d
+	}
+
+	// Suggestions come back from the client, so hold them to the cube's
+	// taxonomy the same way a manual edit is.
+	cube := cubes.Lookup("data", cubeID)
+	resp := AcceptClusterSuggestionsResponse{Errors: []string{}}
+	for _, s := range req.Suggestions {
+		d, ok := byKey[[2]string{s.DraftID, strings.ToLower(s.Player)}]
+		if !ok {
+			resp.Errors = append(resp.Errors, fmt.Sprintf("%s/%s: deck not found", s.DraftID, s.Player))
+			continue
+		}
+		if err := cube.ValidateDeckMeta(s.MacroArchetype, s.Labels); err != nil {
+			resp.Errors = append(resp.Errors, fmt.Sprintf("%s/%s: %v", s.DraftID, s.Player, err))
+			continue
+		}
+		macro := d.MacroArchetype
+		if macro == "" {
+			macro = strings.ToLower(s.MacroArchetype)
+		}
+		labels := slices.Clone(d.Labels)
+		for _, l := range s.Labels {
+			if !slices.ContainsFunc(labels, func(e string) bool { return strings.EqualFold(e, l) }) {
+				labels = append(labels, l)
+			}
+		}
+		if _, err := h.store.UpdateDeckMeta(cubeID, d.Metadata.DraftID, d.Player, macro, labels, d.Colors); err != nil {
+			logrus.WithError(err).Error("Failed to apply cluster suggestion")
+			resp.Errors = append(resp.Errors, fmt.Sprintf("%s/%s: %v", s.DraftID, s.Player, err))
+			continue
+		}
+		resp.Updated++
+	}
+	writeJSON(rw, resp)
+}
@@ -0,0 +1,161 @@
+package stats
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/caseydavenport/cube-tools/pkg/server"
+	"github.com/caseydavenport/cube-tools/pkg/storage"
+	"github.com/caseydavenport/cube-tools/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clusterDeck builds a deck from a shared core plus one filler card unique to
+// the deck, so decks in the same family are similar but not identical.
+func clusterDeck(player, draftID, macro string, labels []string, core ...string) *storage.Deck {
+	var mb []types.Card
+	for _, c := range core {
+		mb = append(mb, types.Card{Name: c, Types: []string{"Creature"}})
+	}
+	mb = append(mb, types.Card{Name: "filler-" + player + draftID})
+	d := makePivotDeck(player, draftID, "", nil, macro, mb, nil)
+	d.Labels = labels
+	return d
+}
+
+func clusterFixture() []*storage.Deck {
+	var ds []*storage.Deck
+	for i := 0; i < 4; i++ {
+		id := fmt.Sprintf("d%d", i)
+		ds = append(ds,
+			clusterDeck("goblins", id, "aggro", []string{"tokens"}, "Goblin A", "Goblin B", "Goblin C", "Shared"),
+			clusterDeck("wraths", id, "control", nil, "Wrath", "Counterspell", "Dragon", "Shared"),
+		)
+	}
+	ds = append(ds,
+		clusterDeck("new1", "d9", "", nil, "Goblin A", "Goblin B", "Goblin C"),
+		clusterDeck("new2", "d9", "", nil, "Wrath", "Counterspell", "Dragon"),
+	)
+	return ds
+}
+
+func TestClusterArchetypes_SeparatesFamiliesAndSuggests(t *testing.T) {
+	resp := clusterArchetypes(clusterFixture(), nil, 2, 1, zForConfidence(0))
+	require.Len(t, resp.Clusters, 2)
+
+	byMacro := map[string]*ArchetypeCluster{}
+	for _, c := range resp.Clusters {
+		assert.Equal(t, 5, c.Size)
+		byMacro[c.SuggestedMacro] = c
+	}
+	aggro := byMacro["aggro"]
+	require.NotNil(t, aggro)
+	assert.Equal(t, []string{"tokens"}, aggro.SuggestedLabels)
+	assert.Equal(t, 4, aggro.Macros["aggro"])
+	assert.Contains(t, aggro.Name, "Goblin")
+	assert.NotContains(t, aggro.Name, "Shared", "cards everyone plays don't characterise a cluster")
+
+	control := byMacro["control"]
+	require.NotNil(t, control)
+	assert.Empty(t, control.SuggestedLabels)
+	assert.Contains(t, control.Name, "Wrath")
+
+	require.Len(t, resp.Suggestions, 2)
+	sugg := map[string]*ClusterSuggestion{}
+	for _, s := range resp.Suggestions {
+		sugg[s.Player] = s
+	}
+	assert.Equal(t, "aggro", sugg["new1"].MacroArchetype)
+	assert.Equal(t, []string{"tokens"}, sugg["new1"].Labels)
+	assert.Equal(t, "control", sugg["new2"].MacroArchetype)
+}
+
+func TestClusterArchetypes_Deterministic(t *testing.T) {
+	a := clusterArchetypes(clusterFixture(), nil, 3, 7, zForConfidence(0))
+	b := clusterArchetypes(clusterFixture(), nil, 3, 7, zForConfidence(0))
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	assert.JSONEq(t, string(ja), string(jb))
+}
+
+// recordingDeckStorage records UpdateDeckMeta calls.
+type recordingDeckStorage struct {
+	mockDeckStorage
+	updates []updateCall
+}
+
+type updateCall struct {
+	DraftID, Player, Macro string
+	Labels, Colors         []string
+}
+
+func (m *recordingDeckStorage) UpdateDeckMeta(_, draftID, player, macro string, labels, colors []string) (*storage.Deck, error) {
+	m.updates = append(m.updates, updateCall{draftID, player, macro, labels, colors})
+	return nil, nil
+}
+
+func TestAcceptClusterSuggestions_FillsGapsOnly(t *testing.T) {
+	tagged := clusterDeck("alice", "d1", "midrange", []string{"ramp"})
+	tagged.Colors = []string{"G"}
+	untagged := clusterDeck("bob", "d1", "", nil)
+	store := &recordingDeckStorage{mockDeckStorage: mockDeckStorage{decks: []*storage.Deck{tagged, untagged}}}
+
+	body, _ := json.Marshal(AcceptClusterSuggestionsRequest{Suggestions: []*ClusterSuggestion{
+		{DraftID: "d1", Player: "Alice", MacroArchetype: "aggro", Labels: []string{"RAMP", "tokens"}},
+		{DraftID: "d1", Player: "bob", MacroArchetype: "control"},
+		{DraftID: "d2", Player: "carol", MacroArchetype: "control"},
+	}})
+	req := httptest.NewRequest(http.MethodPost, "/api/polyverse/stats/archetype-clusters/accept", bytes.NewReader(body))
+	req = req.WithContext(server.ContextWithCube(context.Background(), "polyverse"))
+	rr := httptest.NewRecorder()
+	AcceptClusterSuggestionsHandler(store).ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var resp AcceptClusterSuggestionsResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Equal(t, 2, resp.Updated)
+	assert.Len(t, resp.Errors, 1)
+
+	require.Len(t, store.updates, 2)
+	assert.Equal(t, updateCall{"d1", "alice", "midrange", []string{"ramp", "tokens"}, []string{"G"}}, store.updates[0])
+	assert.Equal(t, "control", store.updates[1].Macro)
+	assert.Empty(t, store.updates[1].Labels)
+}
+
+// Accepted suggestions are held to the cube's taxonomy like any manual edit,
+// and the macro is stored lowercase.
+func TestAcceptClusterSuggestions_ValidatesTaxonomy(t *testing.T) {
+	store := &recordingDeckStorage{mockDeckStorage: mockDeckStorage{decks: []*storage.Deck{
+		clusterDeck("alice", "d1", "", nil),
+		clusterDeck("bob", "d1", "", nil),
+		clusterDeck("carol", "d1", "", nil),
+	}}}
+
+	body, _ := json.Marshal(AcceptClusterSuggestionsRequest{Suggestions: []*ClusterSuggestion{
+		{DraftID: "d1", Player: "alice", MacroArchetype: "Aggro", Labels: []string{"tokens"}},
+		{DraftID: "d1", Player: "bob", MacroArchetype: "tempo"},
+		{DraftID: "d1", Player: "carol", MacroArchetype: "aggro", Labels: []string{"Control"}},
+	}})
+	req := httptest.NewRequest(http.MethodPost, "/api/polyverse/stats/archetype-clusters/accept", bytes.NewReader(body))
+	req = req.WithContext(server.ContextWithCube(context.Background(), "polyverse"))
+	rr := httptest.NewRecorder()
+	AcceptClusterSuggestionsHandler(store).ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var resp AcceptClusterSuggestionsResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Equal(t, 1, resp.Updated)
+	require.Len(t, resp.Errors, 2)
+	assert.Contains(t, resp.Errors[0], "unknown macro archetype")
+	assert.Contains(t, resp.Errors[1], "is a macro archetype")
+
+	require.Len(t, store.updates, 1)
+	assert.Equal(t, "alice", store.updates[0].Player)
+	assert.Equal(t, "aggro", store.updates[0].Macro)
+}
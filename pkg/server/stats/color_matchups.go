@@ -8,12 +8,34 @@ import (
 
 	"github.com/caseydavenport/cube-tools/pkg/server"
 	"github.com/caseydavenport/cube-tools/pkg/server/decks"
+	"github.com/caseydavenport/cube-tools/pkg/server/query"
 	"github.com/caseydavenport/cube-tools/pkg/storage"
+	"github.com/caseydavenport/cube-tools/pkg/types"
 	"github.com/sirupsen/logrus"
 )
 
+// The color matchup matrix records how each color group fares against each
+// other group, game by game. How a deck maps to groups is the color mode:
+//
+//	"inclusive": every subset of the deck's colors of the group size, so a
+//	             three-color deck counts toward each pair it contains.
+//	"exact":     only decks with exactly the group size in colors.
+//	"primary":   exact, plus three-color decks with a clear primary pair by pips.
+//	"splash":    the deck's colors with splashes removed, when that leaves the
+//	             group size. "RG splash B" is a Gruul deck; a real RGB deck is Jund.
+//	"identity":  one group per deck whatever its size, with splashes written
+//	             after a "+", e.g. "RG+B". The group size is ignored.
+//
+// Only the last two guarantee a deck is counted once, which keeps three-color
+// decks from being credited to every pair they include, so the matrix defaults
+// to "splash". It's the one of the two that respects the group size.
+
 type ColorMatchupResponse struct {
 	Matchups map[string]map[string]*MatchupRecord `json:"matchups"`
+
+	// Groups lists every group in the matrix in WUBRG order, with its name and
+	// the number of decks in it.
+	Groups []*ColorGroup `json:"groups"`
 }
 
 type MatchupRecord struct {
@@ -21,6 +43,18 @@ type MatchupRecord struct {
 	Losses int     `json:"losses"`
 	Draws  int     `json:"draws"`
 	WinPct float64 `json:"win_pct"`
+
+	// Wilson score interval around WinPct at the request's confidence level,
+	// and whether it excludes 50%. See confidence.go.
+	WinPctLow   float64 `json:"win_pct_low"`
+	WinPctHigh  float64 `json:"win_pct_high"`
+	Significant bool    `json:"significant"`
+}
+
+type ColorGroup struct {
+	Key   string `json:"key"`
+	Name  string `json:"name"`
+	Decks int    `json:"decks"`
 }
 
 func ColorMatchupHandler() http.Handler {
@@ -37,7 +71,7 @@ func (h *colorMatchupHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request)
 	dr := decks.ParseDecksRequest(r)
 	colorMode := r.URL.Query().Get("color_mode")
 	if colorMode == "" {
-		colorMode = "inclusive"
+		colorMode = "splash"
 	}
 	colorType := r.URL.Query().Get("color_type")
 	if colorType == "" {
@@ -52,26 +86,55 @@ func (h *colorMatchupHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request)
 	}
 	logrus.WithField("params", dr).Info("/api/stats/color-matchups")
 
-	allDecks, err := h.store.List(server.CubeFromRequest(r), dr)
+	cubeID := server.CubeFromRequest(r)
+	allDecks, err := h.store.List(cubeID, dr)
 	if err != nil {
 		http.Error(rw, "could not load decks", http.StatusInternalServerError)
 		return
 	}
 
+	resp := colorMatchups(allDecks, colorMode, groupSize, loadCubeCards(cubeID), zForConfidence(query.GetFloat(r, "confidence")))
+	b, err := json.Marshal(resp)
+	if err != nil {
+		http.Error(rw, "could not marshal response", http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(b)
+}
+
+// colorMatchups builds the matrix over every game whose opponent deck is known.
+func colorMatchups(allDecks []*storage.Deck, colorMode string, groupSize int, cubeCards map[string]types.Card, z float64) *ColorMatchupResponse {
 	idx := storage.NewOpponentIndex(allDecks)
 
+	// Grouping a deck can mean a pass over its mainboard, and each deck is
+	// looked up once per game against it, so remember the answer.
+	groups := map[*storage.Deck][]string{}
+	groupsOf := func(d *storage.Deck) []string {
+		g, ok := groups[d]
+		if !ok {
+			g = colorGroups(d, colorMode, groupSize, cubeCards)
+			groups[d] = g
+		}
+		return g
+	}
+
 	// Aggregate matchup data. matchups[myColors][oppColors] = {wins, losses}
 	matchups := make(map[string]map[string]*MatchupRecord)
+	deckCounts := map[string]int{}
 
 	for _, deck := range allDecks {
 		if deck.Metadata.DraftID == "" {
 			continue
 		}
 
-		myGroups := colorGroups(deck, colorMode, groupSize)
+		myGroups := groupsOf(deck)
 		if len(myGroups) == 0 {
 			continue
 		}
+		for _, g := range myGroups {
+			deckCounts[g]++
+		}
 
 		for _, game := range deck.Games {
 			oppDeck, ok := idx.OpponentDeck(deck, game.Opponent)
@@ -79,7 +142,7 @@ func (h *colorMatchupHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request)
 				continue
 			}
 
-			oppGroups := colorGroups(oppDeck, colorMode, groupSize)
+			oppGroups := groupsOf(oppDeck)
 			if len(oppGroups) == 0 {
 				continue
 			}
@@ -109,28 +172,46 @@ func (h *colorMatchupHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request)
 	for _, opponents := range matchups {
 		for _, record := range opponents {
 			record.WinPct = winPctOf(record.Wins, record.Losses, record.Draws)
+			record.WinPctLow, record.WinPctHigh = wilsonInterval(record.Wins, record.Losses, record.Draws, z)
+			if record.Wins+record.Losses+record.Draws > 0 {
+				record.Significant = record.WinPctLow > 50 || record.WinPctHigh < 50
+			}
 		}
 	}
 
-	resp := ColorMatchupResponse{Matchups: matchups}
-	b, err := json.Marshal(resp)
-	if err != nil {
-		http.Error(rw, "could not marshal response", http.StatusInternalServerError)
-		return
+	resp := &ColorMatchupResponse{Matchups: matchups, Groups: []*ColorGroup{}}
+	for key, n := range deckCounts {
+		resp.Groups = append(resp.Groups, &ColorGroup{Key: key, Name: colorGroupName(key), Decks: n})
 	}
-	rw.Header().Set("Content-Type", "application/json")
-	rw.Write(b)
+	sort.Slice(resp.Groups, func(i, j int) bool {
+		return colorRank(resp.Groups[i].Key) < colorRank(resp.Groups[j].Key)
+	})
+	return resp
 }
 
-// colorGroups returns canonical color group strings of the given size for a deck.
-// groupSize: 1 for mono-color, 2 for dual-color, 3 for trio-color.
-// mode controls how decks with more colors than groupSize are handled:
-//
-//	"inclusive": all subsets of the given size are returned.
-//	"exact": only decks with exactly groupSize colors return a group.
-//	"primary": same as exact, plus for dual (groupSize=2) 3+ color decks
-//	           with a clear primary pair (splash detection) are included.
-func colorGroups(d *storage.Deck, mode string, groupSize int) []string {
+// colorGroups returns canonical color group strings of the given size for a
+// deck, according to the color mode (see the top of this file). groupSize is
+// 1 for mono-color, 2 for dual-color, 3 for trio-color. Cube cards, when
+// present, fill in mana costs for splash detection.
+func colorGroups(d *storage.Deck, mode string, groupSize int, cubeCards map[string]types.Card) []string {
+	if mode == "splash" || mode == "identity" {
+		base, splash := deckIdentity(d, cubeCards)
+		if len(base) == 0 {
+			return nil
+		}
+		if mode == "identity" {
+			key := strings.Join(base, "")
+			if len(splash) > 0 {
+				key += "+" + strings.Join(splash, "")
+			}
+			return []string{key}
+		}
+		if len(base) != groupSize {
+			return nil
+		}
+		return []string{strings.Join(base, "")}
+	}
+
 	colors := d.GetColors()
 	if len(colors) < groupSize {
 		return nil
@@ -156,6 +237,89 @@ func colorGroups(d *storage.Deck, mode string, groupSize int) []string {
 	return combinations(colors, groupSize)
 }
 
+// deckIdentity splits a deck's colors, in WUBRG order, into its main colors
+// and its splashes. A splash is a color few of the deck's spells need, by the
+// same rule the mana base analysis uses. Without a recorded mainboard there's
+// nothing to judge by, so every color counts as a main color.
+func deckIdentity(d *storage.Deck, cubeCards map[string]types.Card) (base, splash []string) {
+	colors := map[string]bool{}
+	for _, c := range d.GetColors() {
+		colors[c] = true
+	}
+
+	spells := map[string]int{}
+	for _, card := range d.Mainboard {
+		c := card
+		if cc, ok := cubeCards[card.Name]; ok {
+			c = cc
+		}
+		if c.IsLand() {
+			continue
+		}
+		for col := range c.ColorPips() {
+			if colors[col] {
+				spells[col]++
+			}
+		}
+	}
+	most := 0
+	for _, n := range spells {
+		most = max(most, n)
+	}
+
+	for _, col := range []string{"W", "U", "B", "R", "G"} {
+		if !colors[col] {
+			continue
+		}
+		if most > 0 && isSplash(spells[col], most, len(colors)) {
+			splash = append(splash, col)
+		} else {
+			base = append(base, col)
+		}
+	}
+	return base, splash
+}
+
+var colorNames = map[string]string{
+	"W": "White", "U": "Blue", "B": "Black", "R": "Red", "G": "Green",
+
+	// Guilds.
+	"WU": "Azorius", "WB": "Orzhov", "WR": "Boros", "WG": "Selesnya", "UB": "Dimir",
+	"UR": "Izzet", "UG": "Simic", "BR": "Rakdos", "BG": "Golgari", "RG": "Gruul",
+
+	// Shards.
+	"WUG": "Bant", "WUB": "Esper", "UBR": "Grixis", "BRG": "Jund", "WRG": "Naya",
+
+	// Wedges.
+	"WBG": "Abzan", "WUR": "Jeskai", "UBG": "Sultai", "WBR": "Mardu", "URG": "Temur",
+}
+
+// colorGroupName names a color group key: guild, shard and wedge names where
+// they exist, with any splash spelled out, e.g. "Gruul splash B".
+func colorGroupName(key string) string {
+	base, splash, _ := strings.Cut(key, "+")
+	name, ok := colorNames[base]
+	switch {
+	case ok:
+	case len(base) == 4:
+		missing := ""
+		for _, c := range "WUBRG" {
+			if !strings.ContainsRune(base, c) {
+				missing = string(c)
+			}
+		}
+		name = "Four-color (no " + missing + ")"
+	case len(base) == 5:
+		name = "Five-color"
+	default:
+		name = base
+	}
+	if splash != "" {
+		name += " splash " + splash
+	}
+	return name
+}
+
 // combinations returns all size-k subsets of items, joined as strings.
 func combinations(items []string, k int) []string {
 	if k <= 0 || k > len(items) {
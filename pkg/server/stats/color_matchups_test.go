@@ -0,0 +1,120 @@
+package stats
+
+import (
+	"testing"
+
+	"github.com/caseydavenport/cube-tools/pkg/storage"
+	"github.com/caseydavenport/cube-tools/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func spell(name, cost string) types.Card {
+	return types.Card{Name: name, ManaCost: cost, Types: []string{"Instant"}}
+}
+
+// matchupDecks returns a Gruul deck splashing black, a true Jund deck and an
+// Azorius deck, with the Gruul deck beating Azorius twice and Jund losing to
+// it once.
+func matchupDecks() []*storage.Deck {
+	gruul := []types.Card{
+		spell("r1", "{R}"), spell("r2", "{1}{R}"), spell("r3", "{R}{R}"), spell("r4", "{2}{R}"),
+		spell("g1", "{G}"), spell("g2", "{1}{G}"), spell("g3", "{G}{G}"), spell("g4", "{2}{G}"),
+		spell("b1", "{1}{B}"),
+	}
+	jund := []types.Card{
+		spell("r1", "{R}"), spell("r2", "{1}{R}"), spell("r3", "{R}{R}"), spell("r4", "{2}{R}"),
+		spell("g1", "{G}"), spell("g2", "{1}{G}"), spell("g3", "{G}{G}"), spell("g4", "{2}{G}"),
+		spell("b1", "{1}{B}"), spell("b2", "{B}"), spell("b3", "{B}{B}"), spell("b4", "{2}{B}"),
+	}
+	azorius := []types.Card{spell("w1", "{W}"), spell("u1", "{U}")}
+	return []*storage.Deck{
+		makePivotDeck("Alice", "d1", "2025-01-01", []string{"R", "G", "B"}, "aggro", gruul,
+			[]types.Game{{Opponent: "Carol", Winner: "Alice"}, {Opponent: "Carol", Winner: "Alice"}}),
+		makePivotDeck("Bob", "d1", "2025-01-01", []string{"B", "R", "G"}, "midrange", jund,
+			[]types.Game{{Opponent: "Carol", Winner: "Carol"}}),
+		makePivotDeck("Carol", "d1", "2025-01-01", []string{"W", "U"}, "control", azorius,
+			[]types.Game{
+				{Opponent: "Alice", Winner: "Alice"}, {Opponent: "Alice", Winner: "Alice"},
+				{Opponent: "Bob", Winner: "Carol"},
+			}),
+	}
+}
+
+func TestDeckIdentity_Splash(t *testing.T) {
+	decks := matchupDecks()
+
+	base, splash := deckIdentity(decks[0], nil)
+	assert.Equal(t, []string{"R", "G"}, base)
+	assert.Equal(t, []string{"B"}, splash)
+
+	base, splash = deckIdentity(decks[1], nil)
+	assert.Equal(t, []string{"B", "R", "G"}, base)
+	assert.Empty(t, splash)
+
+	// Without a mainboard, every declared color is a main color.
+	d := &storage.Deck{}
+	d.Colors = []string{"G", "R", "B"}
+	base, splash = deckIdentity(d, nil)
+	assert.Equal(t, []string{"B", "R", "G"}, base)
+	assert.Empty(t, splash)
+}
+
+func TestColorMatchups_InclusiveCountsEveryPair(t *testing.T) {
+	resp := colorMatchups(matchupDecks(), "inclusive", 2, nil, zForConfidence(0.8))
+
+	// Both three-color decks land in all three of their pairs.
+	for _, pair := range []string{"BR", "BG", "RG"} {
+		require.Contains(t, resp.Matchups, pair)
+		assert.Equal(t, 2, resp.Matchups[pair]["WU"].Wins, pair)
+		assert.Equal(t, 1, resp.Matchups[pair]["WU"].Losses, pair)
+	}
+}
+
+func TestColorMatchups_SplashCountsOnce(t *testing.T) {
+	resp := colorMatchups(matchupDecks(), "splash", 2, nil, zForConfidence(0.8))
+
+	// The splashing deck is Gruul and nothing else, and Jund isn't a pair.
+	assert.NotContains(t, resp.Matchups, "BR")
+	assert.NotContains(t, resp.Matchups, "BG")
+	rg := resp.Matchups["RG"]["WU"]
+	require.NotNil(t, rg)
+	assert.Equal(t, 2, rg.Wins)
+	assert.Equal(t, 0, rg.Losses)
+	assert.Equal(t, 100.0, rg.WinPct)
+	assert.Greater(t, rg.WinPctLow, 0.0)
+	assert.Equal(t, 100.0, rg.WinPctHigh)
+
+	// Jund is the only trio.
+	trio := colorMatchups(matchupDecks(), "splash", 3, nil, zForConfidence(0.8))
+	require.Len(t, trio.Groups, 1)
+	assert.Equal(t, "BRG", trio.Groups[0].Key)
+}
+
+func TestColorMatchups_Identity(t *testing.T) {
+	resp := colorMatchups(matchupDecks(), "identity", 2, nil, zForConfidence(0.8))
+
+	require.Len(t, resp.Groups, 3)
+	assert.Equal(t, "WU", resp.Groups[0].Key)
+	assert.Equal(t, "Azorius", resp.Groups[0].Name)
+	assert.Equal(t, "RG+B", resp.Groups[1].Key)
+	assert.Equal(t, "Gruul splash B", resp.Groups[1].Name)
+	assert.Equal(t, "BRG", resp.Groups[2].Key)
+	assert.Equal(t, "Jund", resp.Groups[2].Name)
+	for _, g := range resp.Groups {
+		assert.Equal(t, 1, g.Decks, g.Key)
+	}
+
+	assert.Equal(t, 2, resp.Matchups["RG+B"]["WU"].Wins)
+	assert.Equal(t, 2, resp.Matchups["WU"]["RG+B"].Losses)
+	assert.Equal(t, 1, resp.Matchups["WU"]["BRG"].Wins)
+}
+
+func TestColorGroupName(t *testing.T) {
+	assert.Equal(t, "Red", colorGroupName("R"))
+	assert.Equal(t, "Izzet", colorGroupName("UR"))
+	assert.Equal(t, "Mardu", colorGroupName("WBR"))
+	assert.Equal(t, "Esper splash G", colorGroupName("WUB+G"))
+	assert.Equal(t, "Four-color (no G)", colorGroupName("WUBR"))
+	assert.Equal(t, "Five-color", colorGroupName("WUBRG"))
+}
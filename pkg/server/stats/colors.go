@@ -23,6 +23,11 @@ type ColorStatsRequest struct {
 	// Configuration for bucketed responses.
 	BucketSize int  `json:"bucket_size"`
 	Sliding    bool `json:"sliding"`
+
+	// BucketBy groups drafts into calendar periods or cube versions before
+	// bucketing. See decks.ValidBucketBy.
+	BucketBy string `json:"bucket_by"`
+
 	// ColorMode controls how decks are bucketed by color identity.
 	// "inclusive" (default): a WU deck counts as W, U, and WU.
 	// "exact": only exact color identity matches (3+ color decks excluded from 2-color rows).
@@ -61,6 +66,7 @@ func parseColorsRequest(r *http.Request) *ColorStatsRequest {
 	p := ColorStatsRequest{}
 	p.BucketSize = query.GetInt(r, "bucket_size")
 	p.Sliding = query.GetBool(r, "sliding")
+	p.BucketBy = query.GetString(r, "bucket_by")
 	p.ColorMode = r.URL.Query().Get("color_mode")
 	if p.ColorMode == "" {
 		p.ColorMode = "inclusive"
@@ -107,15 +113,15 @@ func (d *colorStatsHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 
 	// Initlialize the response structure.
 	resp = ColorStatsResponse{}
-	if sr.BucketSize > 0 {
-		// If bucket size is set, then create bucketed response.
-		buckets := decks.DeckBuckets(allDecks, sr.BucketSize, !sr.Sliding)
+	if decks.Bucketed(sr.BucketSize, sr.BucketBy) {
+		// If a bucket size or mode is set, then create bucketed response.
+		buckets := decks.DeckBucketsBy(allDecks, decks.NewBucketOptions(cubeID, allDecks, sr.BucketSize, !sr.Sliding, sr.BucketBy))
 		logrus.WithFields(logrus.Fields{
 			"num_buckets": len(buckets),
 			"num_decks":   len(allDecks),
 		}).Info("Created buckets for response")
 		for _, b := range buckets {
-			s := d.statsForDecks(b.AllDecks(), sr, cubeCards)
+			s := colorStatsForDecks(b.AllDecks(), sr, cubeCards)
 			resp.Buckets = append(resp.Buckets, &ColorBucket{
 				Colors: *s,
 				Name:   b.Name(),
@@ -124,7 +130,7 @@ func (d *colorStatsHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 			})
 		}
 	} else {
-		resp.All = d.statsForDecks(allDecks, sr, cubeCards)
+		resp.All = colorStatsForDecks(allDecks, sr, cubeCards)
 	}
 
 	// Print out correlation coefficients between color pick percentages and win percentages.
@@ -197,11 +203,11 @@ func (d *colorStatsHandler) printCorrelations(resp ColorStatsResponse) {
 	}
 }
 
-// statsForDecks computes color-bucketed deck stats. Same logic also lives
+// colorStatsForDecks computes color-bucketed deck stats. Same logic also lives
 // client-side in GetColorStats (ui/src/pages/Colors.js) for per-player
 // breakdowns and live re-filtering. Keep them in sync (see TODO on the JS
 // side for collapsing the duplication).
-func (d *colorStatsHandler) statsForDecks(decks []*storage.Deck, sr *ColorStatsRequest, cubeCards map[string]types.Card) *Colors {
+func colorStatsForDecks(decks []*storage.Deck, sr *ColorStatsRequest, cubeCards map[string]types.Card) *Colors {
 	resp := &Colors{
 		Data: make(map[string]*colorStats),
 	}
@@ -0,0 +1,316 @@
+package stats
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/caseydavenport/cube-tools/pkg/cubes"
+	"github.com/caseydavenport/cube-tools/pkg/server/query"
+	"github.com/caseydavenport/cube-tools/pkg/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// Every other stats endpoint is scoped to one cube, which can't say whether a
+// card is strong in general or only in the company it keeps there. These run
+// the per-cube stats for each cube named in the cubes param (all registered
+// cubes by default) with the same filters, and line them up: shared cards
+// card by card, and the color and archetype metagames row by row.
+//
+// The Elo spread compares each cube's raw pick Elo. NormalizedELO is reported
+// alongside but left out of it: shrinking a card that's new to one cube
+// toward the baseline would read as a difference between the cubes.
+
+// CubeCardStats is one cube's stats for a shared card.
+type CubeCardStats struct {
+	Games          int     `json:"games"`
+	WinPercent     float64 `json:"win_percent"`
+	WinPercentLow  float64 `json:"win_percent_low"`
+	WinPercentHigh float64 `json:"win_percent_high"`
+	Significant    bool    `json:"significant"`
+
+	ELO           int     `json:"elo"`
+	NormalizedELO int     `json:"normalized_elo"`
+	Available     int     `json:"available"`
+	PlayRate      float64 `json:"play_rate"`
+	MainboardRate float64 `json:"mainboard_rate"`
+}
+
+// CardComparison is a card in every compared cube, with its stats in each.
+type CardComparison struct {
+	Name   string                    `json:"name"`
+	Colors []string                  `json:"colors"`
+	Cubes  map[string]*CubeCardStats `json:"cubes"`
+
+	// The spreads are the gap between the highest and lowest cube. Win rate
+	// only counts cubes the card has games in, and Elo and play rate those it
+	// was available in.
+	WinPercentSpread float64 `json:"win_percent_spread"`
+	ELOSpread        int     `json:"elo_spread"`
+	PlayRateSpread   float64 `json:"play_rate_spread"`
+}
+
+// CompareCardsResponse is the API response for /api/compare/cards, sorted
+// with the cards whose win rate differs most between cubes first.
+type CompareCardsResponse struct {
+	Cubes []string          `json:"cubes"`
+	Cards []*CardComparison `json:"cards"`
+}
+
+// MetagameShare is how one color or archetype did in one cube.
+type MetagameShare struct {
+	Record
+	Decks        int     `json:"decks"`
+	BuildPercent float64 `json:"build_percent"`
+}
+
+// MetagameComparison is a color or archetype side by side across cubes.
+// Cubes that never played it have no entry.
+type MetagameComparison struct {
+	Name  string                    `json:"name"`
+	Cubes map[string]*MetagameShare `json:"cubes"`
+
+	// Macro is set for archetypes that are a macro archetype in any of the
+	// cubes, as opposed to a label.
+	Macro bool `json:"macro,omitempty"`
+
+	BuildPercentSpread float64 `json:"build_percent_spread"`
+	WinPercentSpread   float64 `json:"win_percent_spread"`
+}
+
+// CompareMetagameResponse is the API response for /api/compare/metagame.
+// Colors are the mono and two-color identities, in WUBRG order.
+type CompareMetagameResponse struct {
+	Cubes      []string              `json:"cubes"`
+	Colors     []*MetagameComparison `json:"colors"`
+	Archetypes []*MetagameComparison `json:"archetypes"`
+}
+
+// compareCubeIDs returns the cubes named in the request's cubes param, or
+// every registered cube if it's empty. At least two are needed to compare.
+func compareCubeIDs(reg *cubes.Registry, r *http.Request) ([]string, error) {
+	var ids []string
+	if param := query.GetString(r, "cubes"); param != "" {
+		for _, id := range strings.Split(param, ",") {
+			id = strings.TrimSpace(id)
+			if !reg.Has(id) {
+				return nil, fmt.Errorf("unknown cube %q", id)
+			}
+			if !slices.Contains(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+	} else {
+		for _, c := range reg.List() {
+			ids = append(ids, c.ID)
+		}
+	}
+	if len(ids) < 2 {
+		return nil, fmt.Errorf("need at least two cubes to compare")
+	}
+	return ids, nil
+}
+
+func CompareCardsHandler(reg *cubes.Registry) http.Handler {
+	return &compareCardsHandler{
+		reg:   reg,
+		store: storage.NewFileDeckStoreWithCache(),
+	}
+}
+
+type compareCardsHandler struct {
+	reg   *cubes.Registry
+	store storage.DeckStorage
+}
+
+func (h *compareCardsHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	sr := parseCardsRequest(r)
+	logrus.WithField("params", sr).Info("/api/compare/cards")
+
+	ids, err := compareCubeIDs(h.reg, r)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	perCube := make(map[string]*Cards, len(ids))
+	for _, id := range ids {
+		allDecks, err := h.store.List(id, sr.DecksRequest)
+		if err != nil {
+			http.Error(rw, fmt.Sprintf("could not load decks for cube %s", id), http.StatusInternalServerError)
+			return
+		}
+		cubeReq := *sr
+		cube, _ := h.reg.Get(id)
+		cubeReq.macros = cube.MacroArchetypes()
+		cubeReq.snapshots = loadSnapshots(id, allDecks)
+		perCube[id] = cardStatsForDecks(allDecks, loadCubeCards(id), &cubeReq)
+	}
+	writeJSON(rw, compareCards(ids, perCube))
+}
+
+// compareCards lines up the cards that made it into every cube's stats.
+func compareCards(ids []string, perCube map[string]*Cards) *CompareCardsResponse {
+	resp := &CompareCardsResponse{Cubes: ids, Cards: []*CardComparison{}}
+	for name, first := range perCube[ids[0]].Data {
+		cc := &CardComparison{Name: name, Colors: first.Colors, Cubes: make(map[string]*CubeCardStats, len(ids))}
+		var winPcts, elos, playRates []float64
+		for _, id := range ids {
+			s, ok := perCube[id].Data[name]
+			if !ok {
+				break
+			}
+			cc.Cubes[id] = &CubeCardStats{
+				Games:          s.TotalGames,
+				WinPercent:     s.WinPercent,
+				WinPercentLow:  s.WinPercentLow,
+				WinPercentHigh: s.WinPercentHigh,
+				Significant:    s.Significant,
+				ELO:            s.ELO,
+				NormalizedELO:  s.NormalizedELO,
+				Available:      s.Available,
+				PlayRate:       s.PlayRate,
+				MainboardRate:  s.MainboardRate,
+			}
+			if s.TotalGames > 0 {
+				winPcts = append(winPcts, s.WinPercent)
+			}
+			if s.Available > 0 {
+				elos = append(elos, float64(s.ELO))
+				playRates = append(playRates, s.PlayRate)
+			}
+		}
+		if len(cc.Cubes) < len(ids) {
+			continue
+		}
+		cc.WinPercentSpread = round1(spread(winPcts))
+		cc.ELOSpread = int(spread(elos))
+		cc.PlayRateSpread = round1(spread(playRates))
+		resp.Cards = append(resp.Cards, cc)
+	}
+	slices.SortFunc(resp.Cards, func(a, b *CardComparison) int {
+		if a.WinPercentSpread != b.WinPercentSpread {
+			if a.WinPercentSpread > b.WinPercentSpread {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return resp
+}
+
+func CompareMetagameHandler(reg *cubes.Registry) http.Handler {
+	return &compareMetagameHandler{
+		reg:   reg,
+		store: storage.NewFileDeckStoreWithCache(),
+	}
+}
+
+type compareMetagameHandler struct {
+	reg   *cubes.Registry
+	store storage.DeckStorage
+}
+
+func (h *compareMetagameHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	sr := parseColorsRequest(r)
+	logrus.WithField("params", sr).Info("/api/compare/metagame")
+
+	ids, err := compareCubeIDs(h.reg, r)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	z := zForConfidence(sr.Confidence)
+	colors := make(map[string]*Colors, len(ids))
+	archetypes := make(map[string]*ArchetypeStatsResponse, len(ids))
+	macros := make(map[string][]string, len(ids))
+	for _, id := range ids {
+		allDecks, err := h.store.List(id, sr.DecksRequest)
+		if err != nil {
+			http.Error(rw, fmt.Sprintf("could not load decks for cube %s", id), http.StatusInternalServerError)
+			return
+		}
+		cube, _ := h.reg.Get(id)
+		macros[id] = cube.MacroArchetypes()
+		cubeCards := loadCubeCards(id)
+		colors[id] = colorStatsForDecks(allDecks, sr, cubeCards)
+		archetypes[id] = archetypeStats(allDecks, cubeCards, macros[id], z)
+	}
+	writeJSON(rw, compareMetagame(ids, colors, archetypes, macros))
+}
+
+// compareMetagame lines up each cube's color and archetype stats.
+func compareMetagame(ids []string, colors map[string]*Colors, archetypes map[string]*ArchetypeStatsResponse, macros map[string][]string) *CompareMetagameResponse {
+	resp := &CompareMetagameResponse{Cubes: ids, Colors: []*MetagameComparison{}, Archetypes: []*MetagameComparison{}}
+
+	for _, color := range pairColors {
+		mc := &MetagameComparison{Name: color, Cubes: map[string]*MetagameShare{}}
+		for _, id := range ids {
+			if cs, ok := colors[id].Data[color]; ok {
+				mc.Cubes[id] = &MetagameShare{Record: cs.Record, Decks: cs.NumDecks, BuildPercent: cs.BuildPercent}
+			}
+		}
+		resp.Colors = append(resp.Colors, mc.withSpreads(ids))
+	}
+
+	rows := make(map[string]*MetagameComparison)
+	for _, id := range ids {
+		for name, as := range archetypes[id].Archetypes {
+			mc, ok := rows[name]
+			if !ok {
+				mc = &MetagameComparison{Name: name, Cubes: map[string]*MetagameShare{}}
+				rows[name] = mc
+			}
+			mc.Cubes[id] = &MetagameShare{Record: as.Record, Decks: as.Count, BuildPercent: as.BuildPercent}
+			mc.Macro = mc.Macro || slices.Contains(macros[id], name)
+		}
+	}
+	for _, mc := range rows {
+		resp.Archetypes = append(resp.Archetypes, mc.withSpreads(ids))
+	}
+
+	// Macro archetypes first, then labels, each by name.
+	slices.SortFunc(resp.Archetypes, func(a, b *MetagameComparison) int {
+		if a.Macro != b.Macro {
+			if a.Macro {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return resp
+}
+
+// withSpreads fills in the row's spreads. A cube without the row counts
+// toward the build rate spread at zero, but win rate only counts cubes with
+// games.
+func (mc *MetagameComparison) withSpreads(ids []string) *MetagameComparison {
+	var builds, winPcts []float64
+	for _, id := range ids {
+		s, ok := mc.Cubes[id]
+		if !ok {
+			builds = append(builds, 0)
+			continue
+		}
+		builds = append(builds, s.BuildPercent)
+		if s.Wins+s.Losses+s.Draws > 0 {
+			winPcts = append(winPcts, s.WinPercent)
+		}
+	}
+	mc.BuildPercentSpread = round1(spread(builds))
+	mc.WinPercentSpread = round1(spread(winPcts))
+	return mc
+}
+
+// spread returns the gap between the largest and smallest values, or zero for
+// fewer than two.
+func spread(vals []float64) float64 {
+	if len(vals) < 2 {
+		return 0
+	}
+	return slices.Max(vals) - slices.Min(vals)
+}
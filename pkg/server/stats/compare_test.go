@@ -0,0 +1,113 @@
+package stats
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/caseydavenport/cube-tools/pkg/cubes"
+	"github.com/caseydavenport/cube-tools/pkg/storage"
+	"github.com/caseydavenport/cube-tools/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareCubeIDs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cubes.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"cubes":[{"id":"polyverse"},{"id":"aurora"}]}`), 0o644))
+	reg, err := cubes.Load(path)
+	require.NoError(t, err)
+
+	ids, err := compareCubeIDs(reg, httptest.NewRequest("GET", "/api/compare/cards", nil))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"polyverse", "aurora"}, ids)
+
+	ids, err = compareCubeIDs(reg, httptest.NewRequest("GET", "/api/compare/cards?cubes=aurora,polyverse", nil))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"aurora", "polyverse"}, ids)
+
+	_, err = compareCubeIDs(reg, httptest.NewRequest("GET", "/api/compare/cards?cubes=aurora,nope", nil))
+	assert.Error(t, err)
+	_, err = compareCubeIDs(reg, httptest.NewRequest("GET", "/api/compare/cards?cubes=aurora,aurora", nil))
+	assert.Error(t, err)
+}
+
+func TestCompareCards(t *testing.T) {
+	shared, onlyA := types.Card{Name: "Shared"}, types.Card{Name: "OnlyA"}
+	cubeA := map[string]types.Card{"Shared": shared, "OnlyA": onlyA}
+	cubeB := map[string]types.Card{"Shared": shared}
+
+	// Shared wins its game in cube A and loses it in cube B.
+	decksA := []*storage.Deck{
+		makePivotDeck("Alice", "d1", "2025-01-01", nil, "", []types.Card{shared, onlyA}, []types.Game{{Opponent: "Bob", Winner: "Alice"}}),
+		makePivotDeck("Bob", "d1", "2025-01-01", nil, "", nil, []types.Game{{Opponent: "Alice", Winner: "Alice"}}),
+	}
+	decksB := []*storage.Deck{
+		makePivotDeck("Carol", "d1", "2025-01-01", nil, "", []types.Card{shared}, []types.Game{{Opponent: "Dan", Winner: "Dan"}}),
+		makePivotDeck("Dan", "d1", "2025-01-01", nil, "", nil, []types.Game{{Opponent: "Carol", Winner: "Dan"}}),
+	}
+	sr := &CardStatsRequest{DecksRequest: &storage.DecksRequest{}}
+	perCube := map[string]*Cards{
+		"a": cardStatsForDecks(decksA, cubeA, sr),
+		"b": cardStatsForDecks(decksB, cubeB, sr),
+	}
+
+	resp := compareCards([]string{"a", "b"}, perCube)
+	require.Len(t, resp.Cards, 1)
+	c := resp.Cards[0]
+	assert.Equal(t, "Shared", c.Name)
+	assert.Equal(t, 100.0, c.Cubes["a"].WinPercent)
+	assert.Equal(t, 0.0, c.Cubes["b"].WinPercent)
+	assert.Equal(t, 100.0, c.WinPercentSpread)
+
+	// Mainboarded in the one draft of each cube.
+	assert.Equal(t, 100.0, c.Cubes["a"].PlayRate)
+	assert.Equal(t, 0.0, c.PlayRateSpread)
+
+	// The Elo spread goes by raw pick Elo.
+	perCube["a"].Data["Shared"].ELO, perCube["a"].Data["Shared"].NormalizedELO = 1300, 1217
+	perCube["b"].Data["Shared"].ELO, perCube["b"].Data["Shared"].NormalizedELO = 1250, 1240
+	resp = compareCards([]string{"a", "b"}, perCube)
+	assert.Equal(t, 50, resp.Cards[0].ELOSpread)
+}
+
+func TestCompareMetagame(t *testing.T) {
+	colors := map[string]*Colors{
+		"a": {Data: map[string]*colorStats{"W": {Record: Record{Wins: 3, Losses: 1, WinPercent: 75}, NumDecks: 4, BuildPercent: 50}}},
+		"b": {Data: map[string]*colorStats{"W": {Record: Record{Wins: 1, Losses: 1, WinPercent: 50}, NumDecks: 2, BuildPercent: 20}}},
+	}
+	archetypes := map[string]*ArchetypeStatsResponse{
+		"a": {Archetypes: map[string]*ArchetypeStats{
+			"aggro":   {Type: "aggro", Count: 2, BuildPercent: 25},
+			"tokens":  {Type: "tokens", Count: 1, BuildPercent: 12.5},
+			"control": {Type: "control"},
+		}},
+		"b": {Archetypes: map[string]*ArchetypeStats{
+			"aggro": {Type: "aggro", Count: 1, BuildPercent: 10},
+			"combo": {Type: "combo"},
+		}},
+	}
+	macros := map[string][]string{"a": {"aggro", "control"}, "b": {"aggro", "combo"}}
+
+	resp := compareMetagame([]string{"a", "b"}, colors, archetypes, macros)
+
+	require.Len(t, resp.Colors, len(pairColors))
+	w := resp.Colors[0]
+	assert.Equal(t, "W", w.Name)
+	assert.Equal(t, 30.0, w.BuildPercentSpread)
+	assert.Equal(t, 25.0, w.WinPercentSpread)
+	assert.Empty(t, resp.Colors[1].Cubes)
+
+	// Macros from either cube sort ahead of labels.
+	var names []string
+	for _, a := range resp.Archetypes {
+		names = append(names, a.Name)
+	}
+	assert.Equal(t, []string{"aggro", "combo", "control", "tokens"}, names)
+	assert.True(t, resp.Archetypes[1].Macro)
+	assert.False(t, resp.Archetypes[3].Macro)
+
+	// A label only one cube uses still counts the other at zero.
+	assert.Equal(t, 12.5, resp.Archetypes[3].BuildPercentSpread)
+}
@@ -58,3 +58,22 @@ func wilsonInterval(wins, losses, draws int, z float64) (float64, float64) {
 	}
 	return math.Round(1000*low) / 10, math.Round(1000*high) / 10
 }
+
+// diffSignificant reports whether two records' win rates differ at critical
+// value z, using a two-proportion z-test on games with draws as half a win.
+// Records with no games are never significantly different.
+func diffSignificant(a, b Record, z float64) bool {
+	na := float64(a.Wins + a.Losses + a.Draws)
+	nb := float64(b.Wins + b.Losses + b.Draws)
+	if na == 0 || nb == 0 {
+		return false
+	}
+	pa := (float64(a.Wins) + float64(a.Draws)/2) / na
+	pb := (float64(b.Wins) + float64(b.Draws)/2) / nb
+	pooled := (pa*na + pb*nb) / (na + nb)
+	se := math.Sqrt(pooled * (1 - pooled) * (1/na + 1/nb))
+	if se == 0 {
+		return false
+	}
+	return math.Abs(pa-pb)/se > z
+}
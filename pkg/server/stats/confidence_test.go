@@ -76,3 +76,16 @@ func TestSetInterval_NoGamesNotSignificant(t *testing.T) {
 	r.SetInterval(zForConfidence(0.95))
 	assert.False(t, r.Significant)
 }
+
+func TestDiffSignificant(t *testing.T) {
+	z := zForConfidence(0.95)
+
+	// 30-10 against 10-30 is a clear difference.
+	assert.True(t, diffSignificant(Record{Wins: 30, Losses: 10}, Record{Wins: 10, Losses: 30}, z))
+
+	// 3-2 against 2-3 is not.
+	assert.False(t, diffSignificant(Record{Wins: 3, Losses: 2}, Record{Wins: 2, Losses: 3}, z))
+
+	// Nothing to compare against an empty record.
+	assert.False(t, diffSignificant(Record{Wins: 5}, Record{}, z))
+}
@@ -0,0 +1,208 @@
+package stats
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/caseydavenport/cube-tools/pkg/server"
+	"github.com/caseydavenport/cube-tools/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// The balance report looks at the design map from the cube's side rather than
+// the decks'. Group distributions say how much of a group decks end up
+// running; this says whether the cube gives every color a fair shot at it. It
+// breaks each group down by color and mana value, counts per color pair how
+// many cards sit on the enabling (source) and paying-off (target) ends of the
+// links, and flags gaps: a color with a link's enablers but none of its
+// payoffs, so drafters in that color are set up for a theme they can't finish.
+
+// balanceMaxCMC is the top mana value bucket; it holds everything at or above.
+const balanceMaxCMC = 7
+
+// GroupBalanceResponse is the API response for /api/{cube}/stats/group-balance.
+type GroupBalanceResponse struct {
+	Groups []*GroupBalance `json:"groups"`
+	Pairs  []*PairBalance  `json:"pairs"`
+	Gaps   []*LinkGap      `json:"gaps"`
+}
+
+// GroupBalance is one group's membership broken down by color and curve.
+type GroupBalance struct {
+	Name  string `json:"name"`
+	Cards int    `json:"cards"`
+
+	// ByColor counts members of each color, with a gold card counted in each of
+	// its colors and colorless cards under "C".
+	ByColor map[string]int `json:"by_color"`
+
+	// ByCMC counts nonland members by mana value, with the top bucket holding
+	// everything costing balanceMaxCMC or more. Lands counts the rest.
+	ByCMC map[int]int `json:"by_cmc"`
+	Lands int         `json:"lands"`
+}
+
+// PairBalance counts the distinct cards castable in a color pair that sit on
+// each end of the links.
+type PairBalance struct {
+	Colors   string `json:"colors"`
+	Enablers int    `json:"enablers"`
+	Payoffs  int    `json:"payoffs"`
+
+	// Links breaks the counts down by link, for links the pair has any cards
+	// for.
+	Links []*LinkRoles `json:"links"`
+}
+
+type LinkRoles struct {
+	Label    string `json:"label"`
+	Enablers int    `json:"enablers"`
+	Payoffs  int    `json:"payoffs"`
+}
+
+// LinkGap is a color with source cards for a wire but no target cards.
+type LinkGap struct {
+	Link    string   `json:"link"`
+	Color   string   `json:"color"`
+	Sources []string `json:"sources"`
+	Targets []string `json:"targets"`
+
+	// SourceCards is how many of the wire's sources are in the color.
+	// ColorlessTargets is how many targets any deck can play, which soften the
+	// gap without being of the color.
+	SourceCards      int `json:"source_cards"`
+	ColorlessTargets int `json:"colorless_targets"`
+}
+
+func GroupBalanceHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		logrus.Info("/api/stats/group-balance")
+
+		cubeID := server.CubeFromRequest(r)
+		cube, err := types.LoadCube(fmt.Sprintf("data/%s/cube.json", cubeID))
+		if err != nil {
+			http.Error(rw, "could not load cube", http.StatusInternalServerError)
+			return
+		}
+		config, err := loadDesignMap(fmt.Sprintf("data/%s/cube-rules.json", cubeID))
+		if err != nil {
+			logrus.WithError(err).Warn("could not load cube rules")
+			config = DesignMapConfig{}
+		}
+		writeJSON(rw, groupBalance(cube, config))
+	})
+}
+
+func groupBalance(cube *types.Cube, config DesignMapConfig) *GroupBalanceResponse {
+	cardMap := buildCardMap(cube)
+	groupCards, _ := resolveGroupCards(cardMap, config.Groups)
+	addCardRefs(groupCards, cardMap, config.Links)
+	resp := &GroupBalanceResponse{Groups: []*GroupBalance{}, Pairs: []*PairBalance{}, Gaps: []*LinkGap{}}
+
+	for _, g := range config.Groups {
+		gb := &GroupBalance{Name: g.Name, ByColor: map[string]int{}, ByCMC: map[int]int{}}
+		for name := range groupCards[g.Name] {
+			c := cardMap[name]
+			gb.Cards++
+			for _, col := range c.Colors {
+				gb.ByColor[col]++
+			}
+			if len(c.Colors) == 0 {
+				gb.ByColor["C"]++
+			}
+			if c.IsLand() {
+				gb.Lands++
+				continue
+			}
+			gb.ByCMC[min(c.CMC, balanceMaxCMC)]++
+		}
+		resp.Groups = append(resp.Groups, gb)
+	}
+
+	// Each link's enablers are the cards in any of its wires' sources, and its
+	// payoffs those in any target.
+	type roles struct{ sources, targets map[string]bool }
+	linkRoles := make([]roles, len(config.Links))
+	for i, link := range config.Links {
+		linkRoles[i] = roles{map[string]bool{}, map[string]bool{}}
+		for _, wire := range link.Wires {
+			for name := range unionCards(groupCards, wire.Sources) {
+				linkRoles[i].sources[name] = true
+			}
+			for name := range unionCards(groupCards, wire.Targets) {
+				linkRoles[i].targets[name] = true
+			}
+		}
+	}
+
+	for _, colors := range pairColors {
+		if len(colors) != 2 {
+			continue
+		}
+		pb := &PairBalance{Colors: colors, Links: []*LinkRoles{}}
+		enablers, payoffs := map[string]bool{}, map[string]bool{}
+		for i, link := range config.Links {
+			lr := &LinkRoles{Label: link.Label}
+			for name := range linkRoles[i].sources {
+				if castableIn(cardMap[name].Colors, colors) {
+					lr.Enablers++
+					enablers[name] = true
+				}
+			}
+			for name := range linkRoles[i].targets {
+				if castableIn(cardMap[name].Colors, colors) {
+					lr.Payoffs++
+					payoffs[name] = true
+				}
+			}
+			if lr.Enablers+lr.Payoffs > 0 {
+				pb.Links = append(pb.Links, lr)
+			}
+		}
+		pb.Enablers, pb.Payoffs = len(enablers), len(payoffs)
+		resp.Pairs = append(resp.Pairs, pb)
+	}
+
+	for _, link := range config.Links {
+		for _, wire := range link.Wires {
+			sources := unionCards(groupCards, wire.Sources)
+			targets := unionCards(groupCards, wire.Targets)
+			colorless := 0
+			for name := range targets {
+				if len(cardMap[name].Colors) == 0 {
+					colorless++
+				}
+			}
+			for _, col := range []string{"W", "U", "B", "R", "G"} {
+				inColor := func(cards map[string]bool) int {
+					n := 0
+					for name := range cards {
+						if slices.Contains(cardMap[name].Colors, col) {
+							n++
+						}
+					}
+					return n
+				}
+				s := inColor(sources)
+				if s == 0 || inColor(targets) > 0 {
+					continue
+				}
+				resp.Gaps = append(resp.Gaps, &LinkGap{
+					Link:             link.Label,
+					Color:            col,
+					Sources:          wire.Sources,
+					Targets:          wire.Targets,
+					SourceCards:      s,
+					ColorlessTargets: colorless,
+				})
+			}
+		}
+	}
+
+	slices.SortFunc(resp.Groups, func(a, b *GroupBalance) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+	return resp
+}
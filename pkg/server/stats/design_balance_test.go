@@ -0,0 +1,68 @@
+package stats
+
+import (
+	"testing"
+
+	"github.com/caseydavenport/cube-tools/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupBalance(t *testing.T) {
+	cube := &types.Cube{Cards: []types.Card{
+		{Name: "White Outlet", Colors: []string{"W"}, Types: []string{"Creature"}, CMC: 2, OracleText: "sacrifice a creature"},
+		{Name: "Black Outlet", Colors: []string{"B"}, Types: []string{"Creature"}, CMC: 1, OracleText: "sacrifice a creature"},
+		{Name: "Gold Outlet", Colors: []string{"W", "B"}, Types: []string{"Creature"}, CMC: 9, OracleText: "sacrifice a creature"},
+		{Name: "Black Payoff", Colors: []string{"B"}, Types: []string{"Creature"}, CMC: 3, OracleText: "whenever a creature dies"},
+		{Name: "Altar", Types: []string{"Artifact"}, CMC: 3, OracleText: "whenever a creature dies"},
+		{Name: "Sac Land", Types: []string{"Land"}, OracleText: "sacrifice a creature: add {C}"},
+	}}
+	config := DesignMapConfig{
+		Groups: []Group{
+			{Name: "Outlets", Conditions: []string{"o:sacrifice"}},
+			{Name: "Death Payoffs", Conditions: []string{`o:"creature dies"`}},
+		},
+		Links: []Link{
+			{Label: "Aristocrats", Wires: []Wire{{Sources: []string{"Outlets"}, Targets: []string{"Death Payoffs"}}}},
+		},
+	}
+
+	resp := groupBalance(cube, config)
+	require.Len(t, resp.Groups, 2)
+
+	// Sorted by name.
+	payoffs, outlets := resp.Groups[0], resp.Groups[1]
+	assert.Equal(t, "Death Payoffs", payoffs.Name)
+	assert.Equal(t, 2, payoffs.Cards)
+	assert.Equal(t, map[string]int{"B": 1, "C": 1}, payoffs.ByColor)
+
+	assert.Equal(t, 4, outlets.Cards)
+	assert.Equal(t, map[string]int{"W": 2, "B": 2, "C": 1}, outlets.ByColor)
+	assert.Equal(t, map[int]int{1: 1, 2: 1, balanceMaxCMC: 1}, outlets.ByCMC)
+	assert.Equal(t, 1, outlets.Lands)
+
+	pairs := map[string]*PairBalance{}
+	for _, pb := range resp.Pairs {
+		pairs[pb.Colors] = pb
+	}
+	require.Len(t, pairs, 10)
+	assert.Equal(t, 4, pairs["WB"].Enablers)
+	assert.Equal(t, 2, pairs["WB"].Payoffs)
+	require.Len(t, pairs["WB"].Links, 1)
+	assert.Equal(t, "Aristocrats", pairs["WB"].Links[0].Label)
+
+	// Only the colorless cards reach UR.
+	assert.Equal(t, 1, pairs["UR"].Enablers)
+	assert.Equal(t, 1, pairs["UR"].Payoffs)
+	assert.Equal(t, 2, pairs["WU"].Enablers)
+	assert.Equal(t, 1, pairs["WU"].Payoffs)
+
+	// White has outlets but no white payoff; black has both.
+	require.Len(t, resp.Gaps, 1)
+	gap := resp.Gaps[0]
+	assert.Equal(t, "Aristocrats", gap.Link)
+	assert.Equal(t, "W", gap.Color)
+	assert.Equal(t, 2, gap.SourceCards)
+	assert.Equal(t, 1, gap.ColorlessTargets)
+	assert.Equal(t, []string{"Death Payoffs"}, gap.Targets)
+}
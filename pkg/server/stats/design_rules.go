@@ -0,0 +1,564 @@
+package stats
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caseydavenport/cube-tools/pkg/server"
+	"github.com/caseydavenport/cube-tools/pkg/server/query"
+	"github.com/caseydavenport/cube-tools/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// Design rules are versioned. Every save writes the new rules to
+// cube-rules.json and also keeps a copy under .design-rules-history/, named by
+// the UTC time of the save. The directory is hidden so the indexer, which
+// takes every other directory under a cube for a draft, leaves it alone. Each
+// revision file is a complete cube-rules.json, so one can be copied back by
+// hand if the server isn't around to restore it.
+//
+// Restoring a revision is itself a save: it becomes the newest revision, and
+// the rules it replaced stay in the history, so a restore can be undone too.
+
+const (
+	designRulesFileName   = "cube-rules.json"
+	designRulesHistoryDir = ".design-rules-history"
+
+	// revisionIDFormat names revision files. It sorts lexically in time order
+	// and has no characters that need escaping in a path.
+	revisionIDFormat = "20060102T150405.000Z"
+)
+
+var errRevisionNotFound = errors.New("design rules revision not found")
+
+// RuleRevision describes one saved version of a cube's design rules.
+type RuleRevision struct {
+	ID string `json:"id"`
+
+	// Saved is when the revision was written, RFC 3339.
+	Saved  string `json:"saved"`
+	Groups int    `json:"groups"`
+	Links  int    `json:"links"`
+}
+
+// RuleHistoryResponse is the API response for /api/{cube}/design-rules/history.
+type RuleHistoryResponse struct {
+	// Revisions are newest first. The first is the rules in effect.
+	Revisions []RuleRevision `json:"revisions"`
+}
+
+// RuleDiffResponse is the API response for /api/{cube}/design-rules/diff.
+type RuleDiffResponse struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+
+	Groups []*GroupDiff `json:"groups"`
+	Links  []*LinkDiff  `json:"links"`
+}
+
+// Change kinds for a group or link between two revisions.
+const (
+	ruleAdded   = "added"
+	ruleRemoved = "removed"
+	ruleChanged = "changed"
+)
+
+// GroupDiff describes how a group differs between two revisions. Only groups
+// whose definition or membership changed are listed.
+type GroupDiff struct {
+	Name   string `json:"name"`
+	Change string `json:"change"`
+
+	ConditionsAdded   []string `json:"conditions_added,omitempty"`
+	ConditionsRemoved []string `json:"conditions_removed,omitempty"`
+	ExcludeAdded      []string `json:"exclude_added,omitempty"`
+	ExcludeRemoved    []string `json:"exclude_removed,omitempty"`
+
+	// CardsAdded and CardsRemoved are the change in the group's members. Both
+	// sides are resolved against today's cube, so the delta reflects the rule
+	// edit and not cards that came or went from the cube in between.
+	CardsAdded   []string `json:"cards_added,omitempty"`
+	CardsRemoved []string `json:"cards_removed,omitempty"`
+}
+
+// LinkDiff describes how a link differs between two revisions.
+type LinkDiff struct {
+	Label  string `json:"label"`
+	Change string `json:"change"`
+
+	WiresAdded   []Wire `json:"wires_added,omitempty"`
+	WiresRemoved []Wire `json:"wires_removed,omitempty"`
+}
+
+// designRulesStore reads and writes a cube's rules and their history under
+// dataRoot. The mutex serializes saves so two at once can't interleave the
+// revision and the live file.
+type designRulesStore struct {
+	sync.Mutex
+	dataRoot string
+
+	// now is the clock revisions are named by; tests replace it.
+	now func() time.Time
+}
+
+func (s *designRulesStore) rulesPath(cube string) string {
+	return filepath.Join(s.dataRoot, cube, designRulesFileName)
+}
+
+func (s *designRulesStore) historyDir(cube string) string {
+	return filepath.Join(s.dataRoot, cube, designRulesHistoryDir)
+}
+
+func (s *designRulesStore) revisionPath(cube, id string) string {
+	return filepath.Join(s.historyDir(cube), id+".json")
+}
+
+// revisionIDs returns the cube's revision IDs, oldest first. No history
+// directory means no revisions yet.
+func (s *designRulesStore) revisionIDs(cube string) ([]string, error) {
+	entries, err := os.ReadDir(s.historyDir(cube))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, e := range entries {
+		if id, ok := strings.CutSuffix(e.Name(), ".json"); ok && !e.IsDir() {
+			if _, err := time.Parse(revisionIDFormat, id); err == nil {
+				ids = append(ids, id)
+			}
+		}
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// get loads one revision.
+func (s *designRulesStore) get(cube, id string) (DesignMapConfig, error) {
+	if _, err := time.Parse(revisionIDFormat, id); err != nil {
+		return DesignMapConfig{}, errRevisionNotFound
+	}
+	config, err := loadDesignMap(s.revisionPath(cube, id))
+	if os.IsNotExist(err) {
+		return DesignMapConfig{}, errRevisionNotFound
+	}
+	return config, err
+}
+
+func (s *designRulesStore) history(cube string) ([]RuleRevision, error) {
+	ids, err := s.revisionIDs(cube)
+	if err != nil {
+		return nil, err
+	}
+	revisions := make([]RuleRevision, 0, len(ids))
+	for _, id := range slices.Backward(ids) {
+		config, err := s.get(cube, id)
+		if err != nil {
+			return nil, fmt.Errorf("revision %s: %w", id, err)
+		}
+		t, _ := time.Parse(revisionIDFormat, id)
+		revisions = append(revisions, RuleRevision{
+			ID:     id,
+			Saved:  t.Format(time.RFC3339),
+			Groups: len(config.Groups),
+			Links:  len(config.Links),
+		})
+	}
+	return revisions, nil
+}
+
+// save makes config the cube's rules and records it as a revision. Saving
+// rules identical to the newest revision writes the live file but adds no
+// revision.
+func (s *designRulesStore) save(cube string, config DesignMapConfig) (RuleRevision, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return RuleRevision{}, err
+	}
+	if err := os.MkdirAll(s.historyDir(cube), 0o755); err != nil {
+		return RuleRevision{}, err
+	}
+	ids, err := s.revisionIDs(cube)
+	if err != nil {
+		return RuleRevision{}, err
+	}
+
+	// Keep the live rules as a revision if the history doesn't have them:
+	// rules saved before versioning existed, or edited by hand since the last
+	// save. Otherwise this save would lose them.
+	if prev, err := os.ReadFile(s.rulesPath(cube)); err == nil {
+		if len(ids) == 0 || !s.sameRules(cube, ids[len(ids)-1], prev) {
+			info, err := os.Stat(s.rulesPath(cube))
+			if err != nil {
+				return RuleRevision{}, err
+			}
+			id := s.nextID(info.ModTime(), ids)
+			if err := os.WriteFile(s.revisionPath(cube, id), prev, 0o644); err != nil {
+				return RuleRevision{}, err
+			}
+			ids = append(ids, id)
+		}
+	} else if !os.IsNotExist(err) {
+		return RuleRevision{}, err
+	}
+
+	var id string
+	if len(ids) > 0 {
+		if last, err := os.ReadFile(s.revisionPath(cube, ids[len(ids)-1])); err == nil && bytes.Equal(last, data) {
+			id = ids[len(ids)-1]
+		}
+	}
+	if id == "" {
+		id = s.nextID(s.now(), ids)
+		if err := os.WriteFile(s.revisionPath(cube, id), data, 0o644); err != nil {
+			return RuleRevision{}, err
+		}
+	}
+
+	if err := os.WriteFile(s.rulesPath(cube), data, 0o644); err != nil {
+		return RuleRevision{}, err
+	}
+	t, _ := time.Parse(revisionIDFormat, id)
+	return RuleRevision{ID: id, Saved: t.Format(time.RFC3339), Groups: len(config.Groups), Links: len(config.Links)}, nil
+}
+
+// nextID names a revision written at t, moved just past the newest of ids if
+// it doesn't already sort after it. Two saves inside a millisecond would
+// otherwise share a name, and a hand edit's modification time needn't follow
+// the server's clock.
+func (s *designRulesStore) nextID(t time.Time, ids []string) string {
+	id := t.UTC().Format(revisionIDFormat)
+	if len(ids) > 0 && id <= ids[len(ids)-1] {
+		last, _ := time.Parse(revisionIDFormat, ids[len(ids)-1])
+		id = last.Add(time.Millisecond).Format(revisionIDFormat)
+	}
+	return id
+}
+
+// sameRules reports whether live, the contents of cube-rules.json, holds the
+// same rules as revision id. Formatting doesn't count, so a file that's only
+// been reindented isn't taken for an edit; one that doesn't parse is.
+func (s *designRulesStore) sameRules(cube, id string, live []byte) bool {
+	var liveConfig DesignMapConfig
+	if err := json.Unmarshal(live, &liveConfig); err != nil {
+		return false
+	}
+	rev, err := s.get(cube, id)
+	if err != nil {
+		return false
+	}
+	a, errA := json.Marshal(liveConfig)
+	b, errB := json.Marshal(rev)
+	return errA == nil && errB == nil && bytes.Equal(a, b)
+}
+
+// DesignRules serves saving, history, diff and restore for a cube's design
+// rules. Build one with NewDesignRules and register its handlers; they share
+// the store so saves are serialized across routes.
+type DesignRules struct {
+	store *designRulesStore
+}
+
+func NewDesignRules() *DesignRules {
+	return newDesignRules("data")
+}
+
+func newDesignRules(dataRoot string) *DesignRules {
+	return &DesignRules{store: &designRulesStore{dataRoot: dataRoot, now: time.Now}}
+}
+
+// SaveHandler handles POST /api/{cube}/save-design-rules to persist design
+// map config as a new revision.
+func (d *DesignRules) SaveHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var config DesignMapConfig
+		if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+			http.Error(rw, fmt.Sprintf("invalid JSON: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := validateConditions(config.Groups); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		cubeID := server.CubeFromRequest(r)
+		if cubeID == "" {
+			http.Error(rw, "no cube in request", http.StatusForbidden)
+			return
+		}
+		rev, err := d.store.save(cubeID, config)
+		if err != nil {
+			logrus.WithError(err).Error("could not save design rules")
+			http.Error(rw, "could not save config", http.StatusInternalServerError)
+			return
+		}
+		logrus.WithFields(logrus.Fields{"cube": cubeID, "revision": rev.ID}).Info("Saved design rules")
+		writeJSON(rw, rev)
+	})
+}
+
+// HistoryHandler handles GET /api/{cube}/design-rules/history.
+func (d *DesignRules) HistoryHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		revisions, err := d.store.history(server.CubeFromRequest(r))
+		if err != nil {
+			logrus.WithError(err).Error("could not load design rules history")
+			http.Error(rw, "could not load history", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(rw, RuleHistoryResponse{Revisions: revisions})
+	})
+}
+
+// RevisionHandler handles GET /api/{cube}/design-rules/history/{id}, returning
+// the rules as they were saved in that revision.
+func (d *DesignRules) RevisionHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		config, ok := d.lookup(rw, r, r.PathValue("id"))
+		if !ok {
+			return
+		}
+		writeJSON(rw, config)
+	})
+}
+
+// DiffHandler handles GET /api/{cube}/design-rules/diff?from=&to=. To defaults
+// to the newest revision and from to the one saved before to, so a bare call
+// shows what the last save changed.
+func (d *DesignRules) DiffHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		cubeID := server.CubeFromRequest(r)
+		from, to := query.GetString(r, "from"), query.GetString(r, "to")
+		logrus.WithFields(logrus.Fields{"from": from, "to": to}).Info("/api/design-rules/diff")
+
+		ids, err := d.store.revisionIDs(cubeID)
+		if err != nil {
+			logrus.WithError(err).Error("could not load design rules history")
+			http.Error(rw, "could not load history", http.StatusInternalServerError)
+			return
+		}
+		if to == "" && len(ids) > 0 {
+			to = ids[len(ids)-1]
+		}
+		if from == "" {
+			if i := slices.Index(ids, to); i > 0 {
+				from = ids[i-1]
+			}
+		}
+		if from == "" || to == "" {
+			http.Error(rw, "need two revisions to diff", http.StatusBadRequest)
+			return
+		}
+
+		fromConfig, ok := d.lookup(rw, r, from)
+		if !ok {
+			return
+		}
+		toConfig, ok := d.lookup(rw, r, to)
+		if !ok {
+			return
+		}
+		cube, err := types.LoadCube(filepath.Join(d.store.dataRoot, cubeID, "cube.json"))
+		if err != nil {
+			http.Error(rw, "could not load cube", http.StatusInternalServerError)
+			return
+		}
+		resp := diffDesignRules(buildCardMap(cube), fromConfig, toConfig)
+		resp.From, resp.To = from, to
+		writeJSON(rw, resp)
+	})
+}
+
+// RestoreHandler handles POST /api/{cube}/design-rules/history/{id}/restore.
+// The restored rules are saved as a new revision.
+func (d *DesignRules) RestoreHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		config, ok := d.lookup(rw, r, id)
+		if !ok {
+			return
+		}
+		// The query language has changed under old rules before, so hold a
+		// restore to the same check as a save.
+		if err := validateConditions(config.Groups); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		cubeID := server.CubeFromRequest(r)
+		rev, err := d.store.save(cubeID, config)
+		if err != nil {
+			logrus.WithError(err).Error("could not restore design rules")
+			http.Error(rw, "could not restore config", http.StatusInternalServerError)
+			return
+		}
+		logrus.WithFields(logrus.Fields{"cube": cubeID, "from": id, "revision": rev.ID}).Info("Restored design rules")
+		writeJSON(rw, rev)
+	})
+}
+
+// lookup loads a revision, writing a 404 or 500 and returning ok=false when it
+// can't.
+func (d *DesignRules) lookup(rw http.ResponseWriter, r *http.Request, id string) (DesignMapConfig, bool) {
+	config, err := d.store.get(server.CubeFromRequest(r), id)
+	if errors.Is(err, errRevisionNotFound) {
+		http.Error(rw, fmt.Sprintf("no revision %q", id), http.StatusNotFound)
+		return DesignMapConfig{}, false
+	}
+	if err != nil {
+		logrus.WithError(err).Error("could not load design rules revision")
+		http.Error(rw, "could not load revision", http.StatusInternalServerError)
+		return DesignMapConfig{}, false
+	}
+	return config, true
+}
+
+// diffDesignRules compares two versions of the rules. Group membership on both
+// sides is resolved against the same card map.
+func diffDesignRules(cardMap map[string]types.Card, from, to DesignMapConfig) *RuleDiffResponse {
+	resp := &RuleDiffResponse{Groups: []*GroupDiff{}, Links: []*LinkDiff{}}
+	fromCards, _ := resolveGroupCards(cardMap, from.Groups)
+	toCards, _ := resolveGroupCards(cardMap, to.Groups)
+
+	fromGroups := map[string]Group{}
+	for _, g := range from.Groups {
+		fromGroups[g.Name] = g
+	}
+	toGroups := map[string]Group{}
+	for _, g := range to.Groups {
+		toGroups[g.Name] = g
+	}
+
+	for _, name := range unionKeys(fromGroups, toGroups) {
+		before, inFrom := fromGroups[name]
+		after, inTo := toGroups[name]
+		gd := &GroupDiff{Name: name, Change: ruleChanged}
+		switch {
+		case !inFrom:
+			gd.Change = ruleAdded
+		case !inTo:
+			gd.Change = ruleRemoved
+		}
+		gd.ConditionsAdded, gd.ConditionsRemoved = stringsDelta(before.Conditions, after.Conditions)
+		gd.ExcludeAdded, gd.ExcludeRemoved = stringsDelta(before.Exclude, after.Exclude)
+		gd.CardsAdded, gd.CardsRemoved = stringsDelta(setKeys(fromCards[name]), setKeys(toCards[name]))
+
+		if gd.Change == ruleChanged && len(gd.ConditionsAdded)+len(gd.ConditionsRemoved)+len(gd.ExcludeAdded)+
+			len(gd.ExcludeRemoved)+len(gd.CardsAdded)+len(gd.CardsRemoved) == 0 {
+			continue
+		}
+		resp.Groups = append(resp.Groups, gd)
+	}
+
+	fromLinks := map[string]Link{}
+	for _, l := range from.Links {
+		fromLinks[l.Label] = l
+	}
+	toLinks := map[string]Link{}
+	for _, l := range to.Links {
+		toLinks[l.Label] = l
+	}
+	for _, label := range unionKeys(fromLinks, toLinks) {
+		before, inFrom := fromLinks[label]
+		after, inTo := toLinks[label]
+		ld := &LinkDiff{Label: label, Change: ruleChanged}
+		switch {
+		case !inFrom:
+			ld.Change = ruleAdded
+		case !inTo:
+			ld.Change = ruleRemoved
+		}
+		ld.WiresAdded, ld.WiresRemoved = wiresDelta(before.Wires, after.Wires)
+		if ld.Change == ruleChanged && len(ld.WiresAdded)+len(ld.WiresRemoved) == 0 {
+			continue
+		}
+		resp.Links = append(resp.Links, ld)
+	}
+	return resp
+}
+
+// unionKeys returns the keys of both maps, sorted.
+func unionKeys[V any](a, b map[string]V) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, m := range []map[string]V{a, b} {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func setKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// stringsDelta returns the entries of after missing from before, and of
+// before missing from after, each sorted.
+func stringsDelta(before, after []string) (added, removed []string) {
+	for _, s := range after {
+		if !slices.Contains(before, s) && !slices.Contains(added, s) {
+			added = append(added, s)
+		}
+	}
+	for _, s := range before {
+		if !slices.Contains(after, s) && !slices.Contains(removed, s) {
+			removed = append(removed, s)
+		}
+	}
+	slices.Sort(added)
+	slices.Sort(removed)
+	return added, removed
+}
+
+// wiresDelta compares wires as sets, ignoring the order of their entries.
+func wiresDelta(before, after []Wire) (added, removed []Wire) {
+	key := func(w Wire) string {
+		s, t := slices.Sorted(slices.Values(w.Sources)), slices.Sorted(slices.Values(w.Targets))
+		return strings.Join(s, "\x00") + "\x01" + strings.Join(t, "\x00")
+	}
+	beforeKeys := map[string]bool{}
+	for _, w := range before {
+		beforeKeys[key(w)] = true
+	}
+	afterKeys := map[string]bool{}
+	for _, w := range after {
+		afterKeys[key(w)] = true
+		if !beforeKeys[key(w)] {
+			added = append(added, w)
+		}
+	}
+	for _, w := range before {
+		if !afterKeys[key(w)] {
+			removed = append(removed, w)
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,281 @@
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caseydavenport/cube-tools/pkg/commands"
+	"github.com/caseydavenport/cube-tools/pkg/server"
+	"github.com/caseydavenport/cube-tools/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func rulesRequest(method, target, id, body string) *http.Request {
+	r := httptest.NewRequest(method, target, nil)
+	if body != "" {
+		r.Body = io.NopCloser(strings.NewReader(body))
+	}
+	if id != "" {
+		r.SetPathValue("id", id)
+	}
+	return r.WithContext(server.ContextWithCube(context.Background(), "polyverse"))
+}
+
+// newTestDesignRules returns a DesignRules over a temp data root whose clock
+// advances a second per save.
+func newTestDesignRules(t *testing.T) (*DesignRules, string) {
+	t.Helper()
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "polyverse"), 0o755))
+	d := newDesignRules(root)
+	clock := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	d.store.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+	return d, root
+}
+
+func TestDesignRules_SaveHistoryRestore(t *testing.T) {
+	d, root := newTestDesignRules(t)
+
+	save := func(body string) RuleRevision {
+		w := httptest.NewRecorder()
+		d.SaveHandler().ServeHTTP(w, rulesRequest("POST", "/api/polyverse/save-design-rules", "", body))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var rev RuleRevision
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rev))
+		return rev
+	}
+	first := save(`{"groups": [{"name": "Burn", "conditions": ["o:damage"]}]}`)
+	second := save(`{"groups": [{"name": "Burn", "conditions": ["o:damage"]}, {"name": "Tokens", "conditions": ["o:token"]}]}`)
+	assert.Equal(t, "20260501T120001.000Z", first.ID)
+	assert.Equal(t, "2026-05-01T12:00:02Z", second.Saved)
+	assert.Equal(t, 2, second.Groups)
+
+	// Saving the same rules again adds no revision.
+	again := save(`{"groups": [{"name": "Burn", "conditions": ["o:damage"]}, {"name": "Tokens", "conditions": ["o:token"]}]}`)
+	assert.Equal(t, second.ID, again.ID)
+
+	// A bad condition is refused and leaves no trace.
+	w := httptest.NewRecorder()
+	d.SaveHandler().ServeHTTP(w, rulesRequest("POST", "/api/polyverse/save-design-rules", "", `{"groups": [{"name": "Bad", "conditions": ["cmc>"]}]}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	d.HistoryHandler().ServeHTTP(w, rulesRequest("GET", "/api/polyverse/design-rules/history", "", ""))
+	var hist RuleHistoryResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &hist))
+	require.Len(t, hist.Revisions, 2)
+	assert.Equal(t, second.ID, hist.Revisions[0].ID, "newest first")
+	assert.Equal(t, first.ID, hist.Revisions[1].ID)
+
+	// Restoring the first revision makes it current and records a third.
+	w = httptest.NewRecorder()
+	d.RestoreHandler().ServeHTTP(w, rulesRequest("POST", "/api/polyverse/design-rules/history/x/restore", first.ID, ""))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var restored RuleRevision
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &restored))
+	assert.Greater(t, restored.ID, second.ID)
+	assert.Equal(t, 1, restored.Groups)
+
+	live, err := loadDesignMap(filepath.Join(root, "polyverse", designRulesFileName))
+	require.NoError(t, err)
+	require.Len(t, live.Groups, 1)
+	assert.Equal(t, "Burn", live.Groups[0].Name)
+
+	w = httptest.NewRecorder()
+	d.RestoreHandler().ServeHTTP(w, rulesRequest("POST", "/api/polyverse/design-rules/history/x/restore", "nope", ""))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = httptest.NewRecorder()
+	d.RevisionHandler().ServeHTTP(w, rulesRequest("GET", "/api/polyverse/design-rules/history/x", "../cube", ""))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDesignRules_KeepsUnversionedRules(t *testing.T) {
+	d, root := newTestDesignRules(t)
+	path := filepath.Join(root, "polyverse", designRulesFileName)
+	require.NoError(t, os.WriteFile(path, []byte(`{"groups": [{"name": "Old", "conditions": ["t:creature"]}]}`), 0o644))
+	mod := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, os.Chtimes(path, mod, mod))
+
+	_, err := d.store.save("polyverse", DesignMapConfig{Groups: []Group{{Name: "New"}}})
+	require.NoError(t, err)
+
+	revisions, err := d.store.history("polyverse")
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, "20250102T030405.000Z", revisions[1].ID)
+	old, err := d.store.get("polyverse", revisions[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Old", old.Groups[0].Name)
+}
+
+func TestDesignRules_KeepsHandEdits(t *testing.T) {
+	d, root := newTestDesignRules(t)
+	path := filepath.Join(root, "polyverse", designRulesFileName)
+	names := func() []string {
+		revisions, err := d.store.history("polyverse")
+		require.NoError(t, err)
+		var out []string
+		for _, rev := range revisions {
+			config, err := d.store.get("polyverse", rev.ID)
+			require.NoError(t, err)
+			out = append(out, config.Groups[0].Name)
+		}
+		return out
+	}
+
+	_, err := d.store.save("polyverse", DesignMapConfig{Groups: []Group{{Name: "First"}}})
+	require.NoError(t, err)
+
+	// Reformatting the file by hand isn't an edit.
+	require.NoError(t, os.WriteFile(path, []byte(`{"groups":[{"name":"First"}]}`), 0o644))
+	_, err = d.store.save("polyverse", DesignMapConfig{Groups: []Group{{Name: "Second"}}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Second", "First"}, names())
+
+	// Changing the rules is, and the edit is kept ahead of the next save.
+	require.NoError(t, os.WriteFile(path, []byte(`{"groups":[{"name":"By hand"}]}`), 0o644))
+	_, err = d.store.save("polyverse", DesignMapConfig{Groups: []Group{{Name: "Third"}}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Third", "By hand", "Second", "First"}, names())
+}
+
+// Revisions live alongside the cube's drafts; indexing the cube must not take
+// the history for a draft or its revisions for decks.
+func TestDesignRules_HistoryNotIndexed(t *testing.T) {
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.MkdirAll(filepath.Join("data", "polyverse", "2025-01-01"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join("data", "polyverse", "2025-01-01", "alice.json"), []byte(`{}`), 0o644))
+
+	d := newDesignRules("data")
+	_, err := d.store.save("polyverse", DesignMapConfig{Groups: []Group{{Name: "Burn"}}})
+	require.NoError(t, err)
+	_, err = d.store.save("polyverse", DesignMapConfig{Groups: []Group{{Name: "Tokens"}}})
+	require.NoError(t, err)
+	revisions, err := d.store.history("polyverse")
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+
+	// There's no cube.csv, so Index fails after writing the index.
+	_ = commands.Index("polyverse")
+	data, err := os.ReadFile(filepath.Join("data", "polyverse", "index.json"))
+	require.NoError(t, err)
+	var idx commands.MainIndex
+	require.NoError(t, json.Unmarshal(data, &idx))
+	require.Len(t, idx.Drafts, 1)
+	assert.Equal(t, "2025-01-01", idx.Drafts[0].DraftID)
+	assert.Len(t, idx.Drafts[0].Decks, 1)
+}
+
+func TestDesignRules_DiffDefaults(t *testing.T) {
+	d, root := newTestDesignRules(t)
+	require.NoError(t, os.WriteFile(filepath.Join(root, "polyverse", "cube.json"),
+		[]byte(`{"cards": [{"name": "Bolt", "types": ["Instant"], "oracle_text": "deals 3 damage"}]}`), 0o644))
+
+	diff := func(target string) (*RuleDiffResponse, int) {
+		w := httptest.NewRecorder()
+		d.DiffHandler().ServeHTTP(w, rulesRequest("GET", target, "", ""))
+		var resp RuleDiffResponse
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		}
+		return &resp, w.Code
+	}
+
+	// One revision has nothing to compare with.
+	first, err := d.store.save("polyverse", DesignMapConfig{})
+	require.NoError(t, err)
+	_, code := diff("/api/polyverse/design-rules/diff")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// A bare diff shows the last save.
+	second, err := d.store.save("polyverse", DesignMapConfig{Groups: []Group{{Name: "Burn", Conditions: []string{"o:damage"}}}})
+	require.NoError(t, err)
+	resp, code := diff("/api/polyverse/design-rules/diff")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, first.ID, resp.From)
+	assert.Equal(t, second.ID, resp.To)
+	require.Len(t, resp.Groups, 1)
+	assert.Equal(t, []string{"Bolt"}, resp.Groups[0].CardsAdded)
+
+	// Explicit revisions can go either way.
+	resp, code = diff("/api/polyverse/design-rules/diff?from=" + second.ID + "&to=" + first.ID)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, ruleRemoved, resp.Groups[0].Change)
+
+	_, code = diff("/api/polyverse/design-rules/diff?from=20200101T000000.000Z")
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestDiffDesignRules(t *testing.T) {
+	cardMap := buildCardMap(&types.Cube{Cards: []types.Card{
+		{Name: "Bolt", Types: []string{"Instant"}, OracleText: "deals 3 damage"},
+		{Name: "Shock", Types: []string{"Instant"}, OracleText: "deals 2 damage"},
+		{Name: "Bear", Types: []string{"Creature"}, OracleText: "just a bear"},
+		{Name: "Maker", Types: []string{"Creature"}, OracleText: "create a token"},
+	}})
+	from := DesignMapConfig{
+		Groups: []Group{
+			{Name: "Burn", Conditions: []string{"o:damage"}},
+			{Name: "Creatures", Conditions: []string{"t:creature"}},
+			{Name: "Gone", Conditions: []string{"o:bear"}},
+		},
+		Links: []Link{
+			{Label: "Same", Wires: []Wire{{Sources: []string{"Burn", "Creatures"}, Targets: []string{"Creatures"}}}},
+			{Label: "Edited", Wires: []Wire{{Sources: []string{"Burn"}, Targets: []string{"Creatures"}}}},
+		},
+	}
+	to := DesignMapConfig{
+		Groups: []Group{
+			{Name: "Burn", Conditions: []string{"o:damage"}, Exclude: []string{"Shock"}},
+			{Name: "Creatures", Conditions: []string{"t:creature"}},
+			{Name: "Tokens", Conditions: []string{"o:token"}},
+		},
+		Links: []Link{
+			// Entry order within a wire doesn't matter.
+			{Label: "Same", Wires: []Wire{{Sources: []string{"Creatures", "Burn"}, Targets: []string{"Creatures"}}}},
+			{Label: "Edited", Wires: []Wire{{Sources: []string{"Burn"}, Targets: []string{"Tokens"}}}},
+			{Label: "New", Wires: []Wire{{Sources: []string{"card:Bolt"}, Targets: []string{"Tokens"}}}},
+		},
+	}
+
+	diff := diffDesignRules(cardMap, from, to)
+	require.Len(t, diff.Groups, 3)
+
+	burn := diff.Groups[0]
+	assert.Equal(t, "Burn", burn.Name)
+	assert.Equal(t, ruleChanged, burn.Change)
+	assert.Empty(t, burn.ConditionsAdded)
+	assert.Equal(t, []string{"Shock"}, burn.ExcludeAdded)
+	assert.Equal(t, []string{"Shock"}, burn.CardsRemoved)
+
+	gone := diff.Groups[1]
+	assert.Equal(t, "Gone", gone.Name)
+	assert.Equal(t, ruleRemoved, gone.Change)
+	assert.Equal(t, []string{"o:bear"}, gone.ConditionsRemoved)
+	assert.Equal(t, []string{"Bear"}, gone.CardsRemoved)
+
+	tokens := diff.Groups[2]
+	assert.Equal(t, "Tokens", tokens.Name)
+	assert.Equal(t, ruleAdded, tokens.Change)
+	assert.Equal(t, []string{"Maker"}, tokens.CardsAdded)
+
+	require.Len(t, diff.Links, 2)
+	assert.Equal(t, "Edited", diff.Links[0].Label)
+	assert.Equal(t, ruleChanged, diff.Links[0].Change)
+	assert.Equal(t, []string{"Tokens"}, diff.Links[0].WiresAdded[0].Targets)
+	assert.Equal(t, []string{"Creatures"}, diff.Links[0].WiresRemoved[0].Targets)
+	assert.Equal(t, "New", diff.Links[1].Label)
+	assert.Equal(t, ruleAdded, diff.Links[1].Change)
+}
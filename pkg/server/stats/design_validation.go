@@ -0,0 +1,310 @@
+package stats
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/caseydavenport/cube-tools/pkg/server"
+	"github.com/caseydavenport/cube-tools/pkg/storage"
+	"github.com/caseydavenport/cube-tools/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// The design graph says which cards are meant to work together; the
+// validation report checks that against what drafters actually built. For
+// each link it counts the decks that realized it (played a source card and a
+// different target card from the same wire), scores that co-occurrence with
+// the same shrunk lift the synergy page uses, and compares the record of
+// those decks with decks that had only one side of the link.
+//
+// It also looks the other way: card pairs whose synergy lift clears the
+// focal threshold but that no link connects are reported as drafted but
+// undesigned, the interactions the design map is missing.
+
+// undesignedLimit caps the undesigned pairs returned, like the top-100 pairs.
+const undesignedLimit = 100
+
+// DesignValidationResponse is the API response for
+// /api/stats/design-graph/validation.
+type DesignValidationResponse struct {
+	// Decks is the number of decks with a recorded mainboard.
+	Decks int `json:"decks"`
+
+	Links      []*LinkValidation `json:"links"`
+	Undesigned []UndesignedPair  `json:"undesigned"`
+}
+
+type LinkValidation struct {
+	Label string `json:"label"`
+
+	// Pairs is the number of card pairs the link designs, and DraftedPairs
+	// how many of those ever shared a mainboard.
+	Pairs        int `json:"pairs"`
+	DraftedPairs int `json:"drafted_pairs"`
+
+	// Realized is the number of decks that realized the link, and
+	// RealizedShare the percentage of all decks that is.
+	Realized      int     `json:"realized"`
+	RealizedShare float64 `json:"realized_share"`
+
+	// SynergyScore is the shrunk lift of realizing the link over what playing
+	// its sources and targets independently predicts, summed across wires.
+	SynergyScore float64 `json:"synergy_score"`
+
+	// Realizing is the record of decks that realized the link, and Partial of
+	// decks with cards from only one side. WinDelta is the first less the
+	// second, and Significant whether that holds up.
+	Realizing   Record  `json:"realizing"`
+	Partial     Record  `json:"partial"`
+	WinDelta    float64 `json:"win_delta"`
+	Significant bool    `json:"significant"`
+
+	// NeverDrafted flags a link no deck has realized.
+	NeverDrafted bool `json:"never_drafted"`
+}
+
+// UndesignedPair is a card pair that co-occurs well above chance with no
+// link between the cards.
+type UndesignedPair struct {
+	Card1        string  `json:"card1"`
+	Card2        string  `json:"card2"`
+	Count        int     `json:"count"`
+	SynergyScore float64 `json:"synergy_score"`
+	WinPercent   float64 `json:"win_percent"`
+}
+
+func DesignValidationHandler() http.Handler {
+	return &designValidationHandler{store: storage.NewFileDeckStoreWithCache()}
+}
+
+type designValidationHandler struct {
+	store storage.DeckStorage
+}
+
+func (h *designValidationHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	sr := parseSynergyRequest(r)
+	logrus.WithField("params", sr).Info("/api/stats/design-graph/validation")
+
+	cubeID := server.CubeFromRequest(r)
+	cube, err := types.LoadCube(fmt.Sprintf("data/%s/cube.json", cubeID))
+	if err != nil {
+		http.Error(rw, "could not load cube", http.StatusInternalServerError)
+		return
+	}
+	config, err := loadDesignMap(fmt.Sprintf("data/%s/cube-rules.json", cubeID))
+	if err != nil {
+		logrus.WithError(err).Warn("could not load cube rules")
+		config = DesignMapConfig{}
+	}
+	allDecks, err := h.store.List(cubeID, sr.DecksRequest)
+	if err != nil {
+		http.Error(rw, "could not load decks", http.StatusInternalServerError)
+		return
+	}
+	allDecks = filterByRecord(allDecks, sr.Record)
+
+	writeJSON(rw, validateDesign(cube, config, allDecks, sr))
+}
+
+// validateDesign builds the report over decks with a recorded mainboard.
+func validateDesign(cube *types.Cube, config DesignMapConfig, allDecks []*storage.Deck, sr *SynergyStatsRequest) *DesignValidationResponse {
+	cardMap := buildCardMap(cube)
+	groupCards, _ := resolveGroupCards(cardMap, config.Groups)
+	addCardRefs(groupCards, cardMap, config.Links)
+	z := zForConfidence(sr.Confidence)
+
+	var built []*storage.Deck
+	for _, d := range allDecks {
+		if len(d.Mainboard) > 0 {
+			built = append(built, d)
+		}
+	}
+	n := len(built)
+	resp := &DesignValidationResponse{Decks: n, Links: []*LinkValidation{}, Undesigned: []UndesignedPair{}}
+
+	played := map[string]deckSet{}
+	mainboards := make([]map[string]bool, n)
+	for i, d := range built {
+		mainboards[i] = map[string]bool{}
+		for _, c := range d.Mainboard {
+			if _, ok := cardMap[c.Name]; !ok {
+				continue
+			}
+			mainboards[i][c.Name] = true
+			if _, ok := played[c.Name]; !ok {
+				played[c.Name] = newDeckSet(n)
+			}
+			played[c.Name].add(i)
+		}
+	}
+	cooccur := func(a, b string) int {
+		pa, oka := played[a]
+		pb, okb := played[b]
+		if !oka || !okb {
+			return 0
+		}
+		return pa.and(pb).count()
+	}
+
+	designed := map[pair]bool{}
+	for _, link := range config.Links {
+		lv := &LinkValidation{Label: link.Label}
+		linkPairs := map[pair]bool{}
+		realized := make([]bool, n)
+		touched := make([]bool, n)
+		count, expected := 0.0, 0.0
+
+		for _, wire := range link.Wires {
+			sources := unionCards(groupCards, wire.Sources)
+			targets := unionCards(groupCards, wire.Targets)
+			for s := range sources {
+				for t := range targets {
+					if s == t {
+						continue
+					}
+					a, b := s, t
+					if a > b {
+						a, b = b, a
+					}
+					linkPairs[pair{a, b}] = true
+				}
+			}
+
+			hasS, hasT, both := 0, 0, 0
+			for i, mb := range mainboards {
+				s, t, ok := wireRealized(mb, sources, targets)
+				if s {
+					hasS++
+				}
+				if t {
+					hasT++
+				}
+				if s || t {
+					touched[i] = true
+				}
+				if ok {
+					both++
+					realized[i] = true
+				}
+			}
+			count += float64(both)
+			if n > 0 {
+				expected += float64(hasS) * float64(hasT) / float64(n)
+			}
+		}
+
+		lv.Pairs = len(linkPairs)
+		for p := range linkPairs {
+			designed[p] = true
+			if cooccur(p.c1, p.c2) > 0 {
+				lv.DraftedPairs++
+			}
+		}
+		for i, d := range built {
+			switch {
+			case realized[i]:
+				lv.Realized++
+				lv.Realizing.Add(d)
+			case touched[i]:
+				lv.Partial.Add(d)
+			}
+		}
+		lv.RealizedShare = pct(float64(lv.Realized), float64(n))
+		if expected > 0 {
+			lv.SynergyScore = round3(shrinkLift(count, expected, sr.SmoothingK))
+		}
+		for _, rec := range []*Record{&lv.Realizing, &lv.Partial} {
+			rec.Finalize()
+			rec.SetInterval(z)
+		}
+		if lv.Realized > 0 && lv.Partial.Wins+lv.Partial.Losses+lv.Partial.Draws > 0 {
+			lv.WinDelta = round1(lv.Realizing.WinPercent - lv.Partial.WinPercent)
+			lv.Significant = diffSignificant(lv.Realizing, lv.Partial, z)
+		}
+		lv.NeverDrafted = lv.Realized == 0
+		resp.Links = append(resp.Links, lv)
+	}
+	slices.SortFunc(resp.Links, func(a, b *LinkValidation) int {
+		return strings.Compare(strings.ToLower(a.Label), strings.ToLower(b.Label))
+	})
+
+	// Undesigned pairs: strong nonland co-occurrence with no link behind it.
+	var names []string
+	for name, ds := range played {
+		if !cardMap[name].IsLand() && ds.count() >= sr.MinDecks {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for i, a := range names {
+		for _, b := range names[i+1:] {
+			if designed[pair{a, b}] {
+				continue
+			}
+			together := played[a].and(played[b])
+			c := together.count()
+			if c < sr.MinDecks {
+				continue
+			}
+			exp := float64(played[a].count()) * float64(played[b].count()) / float64(n)
+			score := shrinkLift(float64(c), exp, sr.SmoothingK)
+			if score < sr.FocalThreshold {
+				continue
+			}
+			var rec Record
+			for _, idx := range together.members() {
+				rec.Add(built[idx])
+			}
+			rec.Finalize()
+			resp.Undesigned = append(resp.Undesigned, UndesignedPair{
+				Card1:        a,
+				Card2:        b,
+				Count:        c,
+				SynergyScore: round3(score),
+				WinPercent:   rec.WinPercent,
+			})
+		}
+	}
+	sort.Slice(resp.Undesigned, func(i, j int) bool {
+		a, b := resp.Undesigned[i], resp.Undesigned[j]
+		if a.SynergyScore != b.SynergyScore {
+			return a.SynergyScore > b.SynergyScore
+		}
+		return a.Count > b.Count
+	})
+	if len(resp.Undesigned) > undesignedLimit {
+		resp.Undesigned = resp.Undesigned[:undesignedLimit]
+	}
+	return resp
+}
+
+// unionCards merges the cards of the named groups (or card: references).
+func unionCards(groupCards map[string]map[string]bool, entries []string) map[string]bool {
+	out := map[string]bool{}
+	for _, e := range entries {
+		for name := range groupCards[e] {
+			out[name] = true
+		}
+	}
+	return out
+}
+
+// wireRealized reports whether a mainboard has a source card, a target card,
+// and a source and target that are different cards.
+func wireRealized(mb, sources, targets map[string]bool) (hasSource, hasTarget, realized bool) {
+	var s, t []string
+	for name := range mb {
+		if sources[name] {
+			s = append(s, name)
+		}
+		if targets[name] {
+			t = append(t, name)
+		}
+	}
+	hasSource, hasTarget = len(s) > 0, len(t) > 0
+	realized = hasSource && hasTarget && !(len(s) == 1 && len(t) == 1 && s[0] == t[0])
+	return hasSource, hasTarget, realized
+}
@@ -0,0 +1,110 @@
+package stats
+
+import (
+	"testing"
+
+	"github.com/caseydavenport/cube-tools/pkg/storage"
+	"github.com/caseydavenport/cube-tools/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateDesign(t *testing.T) {
+	card := func(name, oracle string) types.Card {
+		return types.Card{Name: name, Types: []string{"Creature"}, OracleText: oracle}
+	}
+	cube := &types.Cube{Cards: []types.Card{
+		card("Outlet", "sacrifice a creature"),
+		card("Tokens", "create a token"),
+		card("More Tokens", "create two tokens"),
+		card("Delver", "flip it"),
+		card("Ponder", "look at the top"),
+		card("Lonely", "nobody plays this"),
+	}}
+	config := DesignMapConfig{
+		Groups: []Group{
+			{Name: "Outlets", Conditions: []string{"o:sacrifice"}},
+			{Name: "Token Makers", Conditions: []string{"o:token"}},
+		},
+		Links: []Link{
+			{Label: "Aristocrats", Wires: []Wire{{Sources: []string{"Outlets"}, Targets: []string{"Token Makers"}}}},
+			{Label: "Lonely", Wires: []Wire{{Sources: []string{"card:Lonely"}, Targets: []string{"Token Makers"}}}},
+		},
+	}
+
+	deck := func(won bool, names ...string) *storage.Deck {
+		var mb []types.Card
+		for _, n := range names {
+			mb = append(mb, types.Card{Name: n})
+		}
+		winner := "o"
+		if won {
+			winner = "p"
+		}
+		games := []types.Game{{Opponent: "o", Winner: winner}, {Opponent: "o", Winner: winner}}
+		return makePivotDeck("p", "d1", "2025-01-01", nil, "", mb, games)
+	}
+	decks := []*storage.Deck{
+		// Aristocrats realized three times, all winning.
+		deck(true, "Outlet", "Tokens"),
+		deck(true, "Outlet", "More Tokens"),
+		deck(true, "Outlet", "Tokens", "More Tokens"),
+		// Tokens without the outlet lose.
+		deck(false, "Tokens", "More Tokens"),
+		deck(false, "Tokens"),
+		// Delver and Ponder always show up together, with no link between them.
+		deck(false, "Delver", "Ponder"),
+		deck(false, "Delver", "Ponder"),
+		deck(false, "Delver", "Ponder"),
+		// No mainboard, no say.
+		{},
+	}
+
+	sr := &SynergyStatsRequest{MinDecks: 3, FocalThreshold: 1.5}
+	resp := validateDesign(cube, config, decks, sr)
+	assert.Equal(t, 8, resp.Decks)
+	require.Len(t, resp.Links, 2)
+
+	ar := resp.Links[0]
+	assert.Equal(t, "Aristocrats", ar.Label)
+	assert.Equal(t, 2, ar.Pairs)
+	assert.Equal(t, 2, ar.DraftedPairs)
+	assert.Equal(t, 3, ar.Realized)
+	assert.Equal(t, 37.5, ar.RealizedShare)
+
+	// 3 realized against 3 * 5/8 expected.
+	assert.Equal(t, 1.6, ar.SynergyScore)
+	assert.Equal(t, 100.0, ar.Realizing.WinPercent)
+	assert.Equal(t, 0.0, ar.Partial.WinPercent)
+	assert.Equal(t, 100.0, ar.WinDelta)
+	assert.True(t, ar.Significant)
+	assert.False(t, ar.NeverDrafted)
+
+	lonely := resp.Links[1]
+	assert.Equal(t, "Lonely", lonely.Label)
+	assert.True(t, lonely.NeverDrafted)
+	assert.Equal(t, 0, lonely.DraftedPairs)
+	assert.Equal(t, 0.0, lonely.SynergyScore)
+
+	// Delver and Ponder are undesigned; the token makers pair up too, and
+	// nothing links them either.
+	require.NotEmpty(t, resp.Undesigned)
+	assert.Equal(t, "Delver", resp.Undesigned[0].Card1)
+	assert.Equal(t, "Ponder", resp.Undesigned[0].Card2)
+	assert.Equal(t, 3, resp.Undesigned[0].Count)
+	assert.InDelta(t, 8.0/3, resp.Undesigned[0].SynergyScore, 0.001)
+	for _, u := range resp.Undesigned {
+		assert.NotEqual(t, "Outlet", u.Card1, "designed pairs aren't undesigned")
+	}
+}
+
+func TestWireRealized(t *testing.T) {
+	both := map[string]bool{"Bolt": true}
+	_, _, ok := wireRealized(map[string]bool{"Bolt": true}, both, both)
+	assert.False(t, ok, "a card can't realize a link with itself")
+
+	s, tgt, ok := wireRealized(map[string]bool{"Bolt": true, "Shock": true}, both, map[string]bool{"Bolt": true, "Shock": true})
+	assert.True(t, s)
+	assert.True(t, tgt)
+	assert.True(t, ok)
+}
@@ -5,12 +5,11 @@ import (
 	"fmt"
 	"net/http"
 	"os"
-	"regexp"
 	"slices"
-	"strconv"
 	"strings"
 
 	"github.com/caseydavenport/cube-tools/pkg/server"
+	"github.com/caseydavenport/cube-tools/pkg/server/query"
 	"github.com/caseydavenport/cube-tools/pkg/storage"
 	"github.com/caseydavenport/cube-tools/pkg/types"
 	"github.com/sirupsen/logrus"
@@ -236,7 +235,12 @@ func DesignGraphMatchHandler() http.Handler {
 			config = DesignMapConfig{}
 		}
 
-		resp := DesignGraphMatchResponse{Cards: matchConditions(cardMap, config, req)}
+		matched, err := matchConditions(cardMap, config, req)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp := DesignGraphMatchResponse{Cards: matched}
 		b, err := json.Marshal(resp)
 		if err != nil {
 			http.Error(rw, "could not marshal response", http.StatusInternalServerError)
@@ -251,7 +255,7 @@ func DesignGraphMatchHandler() http.Handler {
 // returns the per-card breakdown, sorted by name. A condition whose owning
 // group excludes the card is marked rather than dropped, so the editor can
 // show the card greyed instead of silently losing it.
-func matchConditions(cardMap map[string]types.Card, config DesignMapConfig, req DesignGraphMatchRequest) []MatchedCard {
+func matchConditions(cardMap map[string]types.Card, config DesignMapConfig, req DesignGraphMatchRequest) ([]MatchedCard, error) {
 	excludes := make(map[string]map[string]bool)
 	for _, g := range config.Groups {
 		if len(g.Exclude) == 0 {
@@ -270,7 +274,11 @@ func matchConditions(cardMap map[string]types.Card, config DesignMapConfig, req
 		if i < len(req.Groups) {
 			group = req.Groups[i]
 		}
-		for name := range matchCards(cardMap, cond) {
+		matched, err := matchCards(cardMap, cond)
+		if err != nil {
+			return nil, fmt.Errorf("condition %q: %w", cond, err)
+		}
+		for name := range matched {
 			cardConditions[name] = append(cardConditions[name], MatchedCondition{
 				Condition: cond,
 				Group:     group,
@@ -286,7 +294,7 @@ func matchConditions(cardMap map[string]types.Card, config DesignMapConfig, req
 	slices.SortFunc(cards, func(a, b MatchedCard) int {
 		return strings.Compare(a.Name, b.Name)
 	})
-	return cards
+	return cards, nil
 }
 
 // GroupDistribution reports, for one design-map group, how many of the group's
@@ -469,7 +477,14 @@ func resolveGroupCards(cardMap map[string]types.Card, groups []Group) (map[strin
 	for _, g := range groups {
 		cards := make(map[string]bool)
 		for _, cond := range g.Conditions {
-			for name := range matchCards(cardMap, cond) {
+			// Saving rejects bad conditions, so one here predates that check
+			// or was hand-edited. Skip it rather than fail the whole graph.
+			matched, err := matchCards(cardMap, cond)
+			if err != nil {
+				logrus.WithError(err).WithFields(logrus.Fields{"group": g.Name, "condition": cond}).Warn("skipping unparseable design condition")
+				continue
+			}
+			for name := range matched {
 				cards[name] = true
 			}
 		}
@@ -485,18 +500,11 @@ func resolveGroupCards(cardMap map[string]types.Card, groups []Group) (map[strin
 	return groupCards, excluded
 }
 
-func buildDesignGraph(cube *types.Cube, config DesignMapConfig) DesignGraphResponse {
-	cardMap := buildCardMap(cube)
-
-	// Pre-resolve all groups to card sets. Excluded cards are subtracted here,
-	// so everything downstream (edges, group nodes, group edges) never sees
-	// them; the carved-out matches are kept aside for the editor to display.
-	groupCards, groupExcluded := resolveGroupCards(cardMap, config.Groups)
-
-	// Register each card: wire entry as a single-card pseudo-group, so the wire
-	// and group-graph loops below need no special cases. An unknown card (typo,
-	// or cut from the cube) resolves empty, same as a dangling group name.
-	for _, link := range config.Links {
+// addCardRefs registers each card: wire entry as a single-card pseudo-group,
+// so code walking wires needs no special cases. An unknown card (typo, or cut
+// from the cube) resolves empty, same as a dangling group name.
+func addCardRefs(groupCards map[string]map[string]bool, cardMap map[string]types.Card, links []Link) {
+	for _, link := range links {
 		for _, wire := range link.Wires {
 			for _, entry := range slices.Concat(wire.Sources, wire.Targets) {
 				name := cardRefName(entry)
@@ -511,6 +519,16 @@ func buildDesignGraph(cube *types.Cube, config DesignMapConfig) DesignGraphRespo
 			}
 		}
 	}
+}
+
+func buildDesignGraph(cube *types.Cube, config DesignMapConfig) DesignGraphResponse {
+	cardMap := buildCardMap(cube)
+
+	// Pre-resolve all groups to card sets. Excluded cards are subtracted here,
+	// so everything downstream (edges, group nodes, group edges) never sees
+	// them; the carved-out matches are kept aside for the editor to display.
+	groupCards, groupExcluded := resolveGroupCards(cardMap, config.Groups)
+	addCardRefs(groupCards, cardMap, config.Links)
 
 	// Process links: look up source/target groups, create edges.
 	type edgeKey struct{ source, target string }
@@ -768,460 +786,34 @@ func buildGroupGraph(config DesignMapConfig, groupCards map[string]map[string]bo
 	return groupNodes, cardEdges, linkEdges
 }
 
-// matchCards evaluates a query string against all cards and returns the set
-// of matching card names. The query language supports:
-//
-//	n:text      - card name contains text (case-insensitive)
-//	o:text      - oracle text contains text (case-insensitive)
-//	t:text      - card has type containing text (e.g., t:creature)
-//	st:text     - card has subtype containing text (e.g., st:zombie)
-//	c:COLORS    - card's colors include at least one of the listed colors (e.g., c:R, c:UB)
-//	cmc:N       - CMC equals N
-//	cmc<N       - CMC less than N
-//	cmc<=N      - CMC at most N
-//	cmc>N       - CMC greater than N
-//	cmc>=N      - CMC at least N
-//	pow<N       - power less than N
-//	pow<=N      - power at most N
-//	pow>=N      - power at least N
-//	tou<=N      - toughness at most N
-//	tou>=N      - toughness at least N
-//	m:text      - mana cost contains text (e.g., m:X, m:{W}{W})
-//	is:keyword  - built-in classification (creature, land, removal, counterspell, interaction)
-//
-// Negation: prefix any term with ! to negate it: !t:creature, !c:R, !is:land
-//
-// Multiple terms are ANDed. Use OR between terms for disjunction.
-// Use parentheses for grouping: (t:enchantment OR t:artifact) cmc<=2
-func matchCards(cards map[string]types.Card, query string) map[string]bool {
+// matchCards evaluates a condition against all cards and returns the set of
+// matching card names. Conditions use the shared query language; see
+// query.Query for the fields it supports. Conditions written for the design
+// map's old grammar carry over as they were, except for c: with more than one
+// color: c:UB used to match cards of either color and now matches cards of
+// both, so the old meaning is written (c:U OR c:B).
+func matchCards(cards map[string]types.Card, condition string) (map[string]bool, error) {
+	q, err := query.Parse(condition)
+	if err != nil {
+		return nil, err
+	}
 	result := make(map[string]bool)
-	tokens := tokenize(query)
-	expr := parseExpr(&tokens)
-
 	for name, card := range cards {
-		if expr.matches(card) {
+		if q.MatchesCard(card) {
 			result[name] = true
 		}
 	}
-
-	return result
-}
-
-// --- Expression tree ---
-
-type exprNode interface {
-	matches(card types.Card) bool
-}
-
-type termNode struct {
-	term    string
-	negated bool
-}
-
-func (n *termNode) matches(card types.Card) bool {
-	result := matchTerm(card, n.term)
-	if n.negated {
-		return !result
-	}
-	return result
-}
-
-type andNode struct {
-	children []exprNode
-}
-
-func (n *andNode) matches(card types.Card) bool {
-	for _, child := range n.children {
-		if !child.matches(card) {
-			return false
-		}
-	}
-	return true
-}
-
-type orNode struct {
-	children []exprNode
-}
-
-func (n *orNode) matches(card types.Card) bool {
-	for _, child := range n.children {
-		if child.matches(card) {
-			return true
-		}
-	}
-	return false
-}
-
-// --- Parser: recursive descent ---
-// Grammar:
-//   expr    = andExpr ("OR" andExpr)*
-//   andExpr = primary ("AND"? primary)*
-//   primary = "(" expr ")" | "!" primary | term
-
-func parseExpr(tokens *[]string) exprNode {
-	left := parseAndExpr(tokens)
-	var orChildren []exprNode
-	orChildren = append(orChildren, left)
-
-	for len(*tokens) > 0 && (*tokens)[0] == "OR" {
-		*tokens = (*tokens)[1:] // consume OR
-		orChildren = append(orChildren, parseAndExpr(tokens))
-	}
-
-	if len(orChildren) == 1 {
-		return orChildren[0]
-	}
-	return &orNode{children: orChildren}
-}
-
-func parseAndExpr(tokens *[]string) exprNode {
-	left := parsePrimary(tokens)
-	var andChildren []exprNode
-	andChildren = append(andChildren, left)
-
-	for len(*tokens) > 0 {
-		tok := (*tokens)[0]
-		if tok == ")" || tok == "OR" {
-			break
-		}
-		if tok == "AND" {
-			*tokens = (*tokens)[1:] // consume explicit AND
-		}
-		andChildren = append(andChildren, parsePrimary(tokens))
-	}
-
-	if len(andChildren) == 1 {
-		return andChildren[0]
-	}
-	return &andNode{children: andChildren}
-}
-
-func parsePrimary(tokens *[]string) exprNode {
-	if len(*tokens) == 0 {
-		return &termNode{term: ""}
-	}
-
-	tok := (*tokens)[0]
-
-	// Parenthesized group.
-	if tok == "(" {
-		*tokens = (*tokens)[1:] // consume (
-		inner := parseExpr(tokens)
-		if len(*tokens) > 0 && (*tokens)[0] == ")" {
-			*tokens = (*tokens)[1:] // consume )
-		}
-		return inner
-	}
-
-	// Negation.
-	if tok == "!" {
-		*tokens = (*tokens)[1:] // consume !
-		child := parsePrimary(tokens)
-		// If the child is already a termNode, just flip its negated flag.
-		if tn, ok := child.(*termNode); ok {
-			tn.negated = !tn.negated
-			return tn
-		}
-		// For complex negated expressions like !(a OR b), wrap in a negation.
-		return &notNode{child: child}
-	}
-
-	// Term token (possibly with ! prefix baked in from tokenizer).
-	*tokens = (*tokens)[1:]
-	if strings.HasPrefix(tok, "!") {
-		return &termNode{term: tok[1:], negated: true}
-	}
-	return &termNode{term: tok}
-}
-
-type notNode struct {
-	child exprNode
-}
-
-func (n *notNode) matches(card types.Card) bool {
-	return !n.child.matches(card)
-}
-
-// --- Tokenizer ---
-
-// tokenize splits a query string into tokens: terms, "(", ")", "AND", "OR".
-// Supports quoted values: o:"enters the battlefield"
-// Supports negation prefix: !t:creature
-func tokenize(query string) []string {
-	var tokens []string
-	i := 0
-	for i < len(query) {
-		// Skip whitespace.
-		if query[i] == ' ' || query[i] == '\t' {
-			i++
-			continue
-		}
-
-		// Parentheses.
-		if query[i] == '(' || query[i] == ')' {
-			tokens = append(tokens, string(query[i]))
-			i++
-			continue
-		}
-
-		// Standalone negation: "!" followed by "(" or a term.
-		if query[i] == '!' && i+1 < len(query) && query[i+1] == '(' {
-			tokens = append(tokens, "!")
-			i++
-			continue
-		}
-
-		// Read a word/term until whitespace or paren.
-		start := i
-		for i < len(query) && query[i] != ' ' && query[i] != '\t' && query[i] != '(' && query[i] != ')' {
-			// Handle quoted strings within a term.
-			if query[i] == '"' {
-				i++
-				for i < len(query) && query[i] != '"' {
-					i++
-				}
-				if i < len(query) {
-					i++ // consume closing quote
-				}
-			} else {
-				i++
-			}
-		}
-
-		word := query[start:i]
-
-		// Check for AND/OR keywords.
-		if word == "AND" || word == "OR" {
-			tokens = append(tokens, word)
-			continue
-		}
-
-		// Strip quotes from within the term value (e.g., o:"foo bar" -> o:foo bar).
-		word = stripTermQuotes(word)
-		tokens = append(tokens, word)
-	}
-	return tokens
-}
-
-// stripTermQuotes removes quotes from the value portion of a term.
-// e.g., o:"enters the battlefield" -> o:enters the battlefield
-// e.g., !o:"foo" -> !o:foo
-func stripTermQuotes(term string) string {
-	// Find the colon that separates prefix from value.
-	raw := term
-	prefix := ""
-	if strings.HasPrefix(raw, "!") {
-		prefix = "!"
-		raw = raw[1:]
-	}
-	colonIdx := strings.Index(raw, ":")
-	if colonIdx == -1 {
-		return term
-	}
-	key := raw[:colonIdx+1]
-	val := raw[colonIdx+1:]
-	// Strip surrounding quotes from value.
-	if len(val) >= 2 && val[0] == '"' && val[len(val)-1] == '"' {
-		val = val[1 : len(val)-1]
-	}
-	return prefix + key + val
-}
-
-func matchTerm(card types.Card, term string) bool {
-	lower := strings.ToLower(term)
-
-	// Handle comparison operators for cmc, pow, tou.
-	// Check two-char ops (<=, >=) before single-char ops (<, >).
-	for _, prefix := range []string{"cmc<=", "cmc>=", "pow<=", "pow>=", "tou<=", "tou>="} {
-		if strings.HasPrefix(lower, prefix) {
-			return matchComparison(card, prefix[:3], string(prefix[3:5]), lower[len(prefix):])
-		}
-	}
-	for _, prefix := range []string{"cmc<", "cmc>", "pow<", "pow>", "tou<", "tou>"} {
-		if strings.HasPrefix(lower, prefix) {
-			return matchComparison(card, prefix[:3], string(prefix[3:4]), lower[len(prefix):])
-		}
-	}
-
-	// Handle colon-based terms.
-	if idx := strings.Index(term, ":"); idx != -1 {
-		prefix := strings.ToLower(term[:idx])
-		value := term[idx+1:]
-		return matchPrefixedTerm(card, prefix, value)
-	}
-
-	// Bare term: match against card name.
-	return wildcardMatch(strings.ToLower(card.Name), lower)
+	return result, nil
 }
 
-func matchPrefixedTerm(card types.Card, prefix, value string) bool {
-	lowerValue := strings.ToLower(value)
-
-	switch prefix {
-	case "n":
-		return wildcardMatch(strings.ToLower(card.Name), lowerValue)
-	case "o":
-		return wildcardMatch(strings.ToLower(card.OracleText), lowerValue)
-	case "t":
-		for _, t := range card.Types {
-			if wildcardMatch(strings.ToLower(t), lowerValue) {
-				return true
-			}
-		}
-		for _, st := range card.SubTypes {
-			if wildcardMatch(strings.ToLower(st), lowerValue) {
-				return true
-			}
-		}
-		return false
-	case "st":
-		for _, st := range card.SubTypes {
-			if wildcardMatch(strings.ToLower(st), lowerValue) {
-				return true
+// validateConditions checks that every group condition parses.
+func validateConditions(groups []Group) error {
+	for _, g := range groups {
+		for _, cond := range g.Conditions {
+			if _, err := query.Parse(cond); err != nil {
+				return fmt.Errorf("group %q: condition %q: %w", g.Name, cond, err)
 			}
 		}
-		return false
-	case "m":
-		return wildcardMatch(strings.ToLower(card.ManaCost), lowerValue)
-	case "c":
-		return matchColor(card, strings.ToUpper(value))
-	case "cmc":
-		n, err := strconv.Atoi(value)
-		if err != nil {
-			return false
-		}
-		return card.CMC == n
-	case "is":
-		return matchIs(card, lowerValue)
-	default:
-		logrus.WithField("prefix", prefix).Warn("unknown query prefix")
-		return false
-	}
-}
-
-// wildcardMatch checks if haystack contains the pattern, where * in pattern
-// matches any sequence of characters. Without wildcards it behaves like
-// strings.Contains.
-func wildcardMatch(haystack, pattern string) bool {
-	if !strings.Contains(pattern, "*") {
-		return strings.Contains(haystack, pattern)
-	}
-	// Convert the wildcard pattern to a regex: escape regex metacharacters,
-	// then replace * with .*
-	escaped := regexp.QuoteMeta(pattern)
-	escaped = strings.ReplaceAll(escaped, `\*`, ".*")
-	re, err := regexp.Compile(escaped)
-	if err != nil {
-		return strings.Contains(haystack, pattern)
 	}
-	return re.MatchString(haystack)
-}
-
-// matchColor returns true if the card has at least one of the specified colors.
-// e.g., "R" matches red cards, "UB" matches cards that are blue or black.
-func matchColor(card types.Card, colors string) bool {
-	for _, c := range colors {
-		if slices.Contains(card.Colors, string(c)) {
-			return true
-		}
-	}
-	// If no colors specified or card is colorless, handle edge case.
-	if len(colors) == 0 {
-		return len(card.Colors) == 0
-	}
-	return false
-}
-
-func matchIs(card types.Card, keyword string) bool {
-	switch keyword {
-	case "creature":
-		return card.IsCreature()
-	case "land":
-		return card.IsLand()
-	case "removal":
-		return card.IsRemoval()
-	case "counterspell":
-		return card.IsCounterspell()
-	case "interaction":
-		return card.IsInteraction()
-	case "handhate":
-		return card.IsHandHate()
-	default:
-		logrus.WithField("keyword", keyword).Warn("unknown is: keyword")
-		return false
-	}
-}
-
-func matchComparison(card types.Card, field, op, valueStr string) bool {
-	n, err := strconv.Atoi(valueStr)
-	if err != nil {
-		return false
-	}
-
-	var cardVal int
-	switch field {
-	case "cmc":
-		cardVal = card.CMC
-	case "pow":
-		v, err := strconv.Atoi(card.Power)
-		if err != nil {
-			return false
-		}
-		cardVal = v
-	case "tou":
-		v, err := strconv.Atoi(card.Toughness)
-		if err != nil {
-			return false
-		}
-		cardVal = v
-	default:
-		return false
-	}
-
-	switch op {
-	case "<=":
-		return cardVal <= n
-	case ">=":
-		return cardVal >= n
-	case "<":
-		return cardVal < n
-	case ">":
-		return cardVal > n
-	default:
-		return false
-	}
-}
-
-// SaveDesignRulesHandler handles POST /api/save-design-rules to persist design map config.
-func SaveDesignRulesHandler() http.Handler {
-	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-
-		var config DesignMapConfig
-		if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
-			http.Error(rw, fmt.Sprintf("invalid JSON: %v", err), http.StatusBadRequest)
-			return
-		}
-
-		data, err := json.MarshalIndent(config, "", "  ")
-		if err != nil {
-			http.Error(rw, "could not marshal config", http.StatusInternalServerError)
-			return
-		}
-
-		cubeID := server.CubeFromRequest(r)
-		if cubeID == "" {
-			http.Error(rw, "no cube in request", http.StatusForbidden)
-			return
-		}
-		if err := os.WriteFile(fmt.Sprintf("data/%s/cube-rules.json", cubeID), data, 0o644); err != nil {
-			http.Error(rw, "could not save config", http.StatusInternalServerError)
-			return
-		}
-
-		rw.WriteHeader(http.StatusOK)
-	})
+	return nil
 }
@@ -5,6 +5,7 @@ import (
 
 	"github.com/caseydavenport/cube-tools/pkg/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestBuildDesignGraphWithConfig(t *testing.T) {
@@ -377,7 +378,8 @@ func TestMatchConditionsExcluded(t *testing.T) {
 		Groups:     []string{"Red Cards", "Damage"},
 	}
 
-	matched := matchConditions(cards, config, req)
+	matched, err := matchConditions(cards, config, req)
+	require.NoError(t, err)
 
 	byName := map[string][]MatchedCondition{}
 	for _, c := range matched {
@@ -398,7 +400,9 @@ func TestMatchConditionsExcluded(t *testing.T) {
 	// No groups in the request (the group edit modal's raw preview) means no
 	// exclusion marking at all.
 	rawReq := DesignGraphMatchRequest{Conditions: []string{"c:R"}}
-	for _, c := range matchConditions(cards, config, rawReq) {
+	rawMatched, err := matchConditions(cards, config, rawReq)
+	require.NoError(t, err)
+	for _, c := range rawMatched {
 		for _, mc := range c.Conditions {
 			assert.False(t, mc.Excluded)
 		}
@@ -434,7 +438,9 @@ func TestMatchCardsWithConditions(t *testing.T) {
 		if i < len(groups) {
 			group = groups[i]
 		}
-		for name := range matchCards(cards, cond) {
+		matched, err := matchCards(cards, cond)
+		require.NoError(t, err)
+		for name := range matched {
 			cardConditions[name] = append(cardConditions[name], MatchedCondition{
 				Condition: cond,
 				Group:     group,
@@ -523,11 +529,16 @@ func TestMatchCards(t *testing.T) {
 		{"wildcard name", "n:*bolt", []string{"Bolt"}},
 		{"wildcard no match", "o:*flying*", []string{}},
 		{"wildcard mana cost", "m:{*}{G}", []string{"Bear"}},
+		{"not equals type", "t!=creature cmc<=2", []string{"Bolt", "Enchant", "Fireball"}},
+		{"dash negation", "-c:R -t:creature", []string{"Sword", "Enchant"}},
+		{"mana value alias", "mv>=3", []string{"Sword"}},
+		{"colorless", "c:c", []string{"Sword"}},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := matchCards(cards, tt.query)
+			result, err := matchCards(cards, tt.query)
+			require.NoError(t, err)
 			var got []string
 			for name := range result {
 				got = append(got, name)
@@ -536,3 +547,12 @@ func TestMatchCards(t *testing.T) {
 		})
 	}
 }
+
+func TestMatchConditions_InvalidCondition(t *testing.T) {
+	cards := map[string]types.Card{"Bolt": {Name: "Lightning Bolt", Colors: []string{"R"}}}
+	_, err := matchConditions(cards, DesignMapConfig{}, DesignGraphMatchRequest{Conditions: []string{"c:R", "(t:instant"}})
+	assert.ErrorContains(t, err, "(t:instant")
+
+	assert.Error(t, validateConditions([]Group{{Name: "Burn", Conditions: []string{"c:R", "foo:bar"}}}))
+	assert.NoError(t, validateConditions([]Group{{Name: "Burn", Conditions: []string{"c:R o:damage"}}}))
+}
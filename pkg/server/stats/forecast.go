@@ -0,0 +1,282 @@
+package stats
+
+import (
+	"math"
+	"net/http"
+	"sort"
+
+	"github.com/caseydavenport/cube-tools/pkg/server"
+	"github.com/caseydavenport/cube-tools/pkg/server/decks"
+	"github.com/caseydavenport/cube-tools/pkg/server/query"
+	"github.com/caseydavenport/cube-tools/pkg/storage"
+	"github.com/caseydavenport/cube-tools/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// The metagame forecast projects how the next draft's decks will split across
+// colors and macro archetypes, so an archetype that's fading can be buffed
+// before it disappears. Each share is tracked bucket by bucket and run through
+// simple exponential smoothing; the forecast is the final smoothed level. The
+// band around it combines how far the smoothing has missed on past buckets
+// with the sampling noise of a single draft's worth of decks.
+//
+// Cube changes since the last draft nudge the color forecasts: if a color's
+// slice of the cube's spells grew by a tenth, so does its adjusted share.
+// Archetypes don't map to cards cleanly enough to do the same.
+
+// defaultForecastAlpha weights the latest bucket in the smoothing. Higher
+// values chase recent drafts; lower values trust the longer history.
+const defaultForecastAlpha = 0.5
+
+// MetagameForecastResponse is the API response for /api/stats/forecast.
+type MetagameForecastResponse struct {
+	// Buckets are the start dates of the buckets the history is split into.
+	Buckets []string `json:"buckets"`
+	Alpha   float64  `json:"alpha"`
+
+	// DecksPerDraft is the average draft size, which sets the sampling noise
+	// in the bands.
+	DecksPerDraft float64 `json:"decks_per_draft"`
+
+	Colors     []*ShareForecast `json:"colors"`
+	Archetypes []*ShareForecast `json:"archetypes"`
+
+	// CubeChanges describes the cube edits since the most recent draft, when
+	// that draft's snapshot is on disk.
+	CubeChanges *CubeChanges `json:"cube_changes,omitempty"`
+}
+
+// ShareForecast is the history and projection of one color's or archetype's
+// share of decks, in percent.
+type ShareForecast struct {
+	Key     string    `json:"key"`
+	History []float64 `json:"history"`
+	Average float64   `json:"average"`
+
+	Forecast float64 `json:"forecast"`
+	Low      float64 `json:"low"`
+	High     float64 `json:"high"`
+
+	// Adjusted is the forecast scaled by the cube changes. Colors only.
+	Adjusted float64 `json:"adjusted,omitempty"`
+}
+
+type CubeChanges struct {
+	// Since is the draft whose snapshot the current cube is compared with.
+	Since   string   `json:"since"`
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+
+	// ColorShift is, per color, the ratio of its share of the cube's spells now
+	// to its share at the last draft.
+	ColorShift map[string]float64 `json:"color_shift"`
+}
+
+func MetagameForecastHandler() http.Handler {
+	return &forecastHandler{store: storage.NewFileDeckStoreWithCache()}
+}
+
+type forecastHandler struct {
+	store storage.DeckStorage
+}
+
+func (h *forecastHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	dr := decks.ParseDecksRequest(r)
+	logrus.WithField("params", dr).Info("/api/stats/forecast")
+
+	cubeID := server.CubeFromRequest(r)
+	allDecks, err := h.store.List(cubeID, dr)
+	if err != nil {
+		http.Error(rw, "could not load decks", http.StatusInternalServerError)
+		return
+	}
+	bucketSize := query.GetInt(r, "bucket_size")
+	if bucketSize <= 0 {
+		bucketSize = 1
+	}
+	opts := decks.NewBucketOptions(cubeID, allDecks, bucketSize, true, query.GetString(r, "bucket_by"))
+	alpha := query.GetFloat(r, "alpha")
+	if alpha <= 0 || alpha > 1 {
+		alpha = defaultForecastAlpha
+	}
+	resp := metagameForecast(allDecks, opts, cubeMacros(cubeID), alpha, zForConfidence(query.GetFloat(r, "confidence")))
+
+	if len(resp.Buckets) > 0 {
+		last := latestDraft(allDecks)
+		if snap, ok := loadSnapshot(cubeID, last); ok {
+			resp.CubeChanges = cubeChanges(last, snap, loadCubeCards(cubeID))
+			applyColorShift(resp.Colors, resp.CubeChanges.ColorShift)
+		}
+	}
+	writeJSON(rw, resp)
+}
+
+// metagameForecast smooths color and archetype shares over the discrete
+// buckets opts describes and projects them one bucket ahead.
+func metagameForecast(allDecks []*storage.Deck, opts decks.BucketOptions, macros []string, alpha, z float64) *MetagameForecastResponse {
+	resp := &MetagameForecastResponse{
+		Buckets:    []string{},
+		Alpha:      alpha,
+		Colors:     []*ShareForecast{},
+		Archetypes: []*ShareForecast{},
+	}
+	if len(allDecks) == 0 {
+		return resp
+	}
+
+	colors := map[string][]float64{}
+	archetypes := map[string][]float64{}
+	drafts, bucketDecks := 0, 0
+	buckets := decks.DeckBucketsBy(allDecks, opts)
+	for i, b := range buckets {
+		bDecks := b.AllDecks()
+		resp.Buckets = append(resp.Buckets, b.Start())
+		drafts += len(b.Drafts)
+		bucketDecks += len(bDecks)
+
+		colorCounts, archCounts := map[string]int{}, map[string]int{}
+		withArch := 0
+		for _, d := range bDecks {
+			for _, c := range d.GetColors() {
+				colorCounts[c]++
+			}
+			if m := d.MacroIn(macros); m != "" {
+				archCounts[m]++
+				withArch++
+			}
+		}
+		for _, c := range []string{"W", "U", "B", "R", "G"} {
+			colors[c] = append(colors[c], pct(float64(colorCounts[c]), float64(len(bDecks))))
+		}
+		for m := range archCounts {
+			if _, ok := archetypes[m]; !ok {
+				// An archetype first seen now had no share before.
+				archetypes[m] = make([]float64, i)
+			}
+		}
+		for m := range archetypes {
+			archetypes[m] = append(archetypes[m], pct(float64(archCounts[m]), float64(withArch)))
+		}
+	}
+	// Discrete bucketing drops a partial bucket of the oldest drafts, so count
+	// only the decks of the drafts that made it into a bucket.
+	if drafts > 0 {
+		resp.DecksPerDraft = round1(float64(bucketDecks) / float64(drafts))
+	}
+
+	for _, c := range []string{"W", "U", "B", "R", "G"} {
+		resp.Colors = append(resp.Colors, forecastShare(c, colors[c], alpha, resp.DecksPerDraft, z))
+	}
+	for m, series := range archetypes {
+		resp.Archetypes = append(resp.Archetypes, forecastShare(m, series, alpha, resp.DecksPerDraft, z))
+	}
+	sort.Slice(resp.Archetypes, func(i, j int) bool { return resp.Archetypes[i].Key < resp.Archetypes[j].Key })
+	return resp
+}
+
+// forecastShare smooths one share series. The band's variance is the mean
+// squared one-step-ahead error of the smoothing plus the binomial variance of
+// a share measured over n decks.
+func forecastShare(key string, series []float64, alpha, n, z float64) *ShareForecast {
+	sf := &ShareForecast{Key: key, History: series}
+	if len(series) == 0 {
+		return sf
+	}
+
+	level, sq, sum := series[0], 0.0, series[0]
+	for _, x := range series[1:] {
+		miss := x - level
+		sq += miss * miss
+		level += alpha * miss
+		sum += x
+	}
+	variance := 0.0
+	if len(series) > 1 {
+		variance = sq / float64(len(series)-1)
+	}
+	if n > 0 {
+		p := level / 100
+		variance += p * (1 - p) * 10000 / n
+	}
+	margin := z * math.Sqrt(variance)
+
+	sf.Average = round1(sum / float64(len(series)))
+	sf.Forecast = round1(level)
+	sf.Low = round1(math.Max(0, level-margin))
+	sf.High = round1(math.Min(100, level+margin))
+	return sf
+}
+
+// latestDraft returns the most recent draft ID among the decks. Draft IDs
+// start with their date, so the largest is the latest.
+func latestDraft(allDecks []*storage.Deck) string {
+	last := ""
+	for _, d := range allDecks {
+		if d.Metadata.DraftID > last {
+			last = d.Metadata.DraftID
+		}
+	}
+	return last
+}
+
+// cubeChanges compares the cube at a draft with the cube now.
+func cubeChanges(since string, then []types.Card, now map[string]types.Card) *CubeChanges {
+	cc := &CubeChanges{Since: since, Added: []string{}, Removed: []string{}, ColorShift: map[string]float64{}}
+
+	old := map[string]types.Card{}
+	for _, c := range then {
+		if _, dup := old[c.Name]; dup {
+			continue
+		}
+		old[c.Name] = c
+		if _, ok := now[c.Name]; !ok {
+			cc.Removed = append(cc.Removed, c.Name)
+		}
+	}
+	current := make([]types.Card, 0, len(now))
+	for name, c := range now {
+		current = append(current, c)
+		if _, ok := old[name]; !ok {
+			cc.Added = append(cc.Added, name)
+		}
+	}
+	sort.Strings(cc.Added)
+	sort.Strings(cc.Removed)
+
+	before, after := spellColorShares(then), spellColorShares(current)
+	for _, c := range []string{"W", "U", "B", "R", "G"} {
+		if before[c] > 0 {
+			cc.ColorShift[c] = round3(after[c] / before[c])
+		}
+	}
+	return cc
+}
+
+// spellColorShares returns, per color, the fraction of the nonland cards that
+// are that color.
+func spellColorShares(cards []types.Card) map[string]float64 {
+	counts := map[string]int{}
+	spells := 0
+	for _, c := range cards {
+		if c.IsLand() {
+			continue
+		}
+		spells++
+		for _, col := range c.Colors {
+			counts[col]++
+		}
+	}
+	shares := map[string]float64{}
+	for col, n := range counts {
+		shares[col] = float64(n) / float64(spells)
+	}
+	return shares
+}
+
+func applyColorShift(colors []*ShareForecast, shift map[string]float64) {
+	for _, sf := range colors {
+		if s, ok := shift[sf.Key]; ok {
+			sf.Adjusted = round1(math.Min(100, sf.Forecast*s))
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package stats
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/caseydavenport/cube-tools/pkg/server/decks"
+	"github.com/caseydavenport/cube-tools/pkg/storage"
+	"github.com/caseydavenport/cube-tools/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// forecastDecks builds four drafts of four decks each. Red is in every deck
+// of the first draft and one fewer each draft after; control appears only
+// from the third draft on.
+func forecastDecks() []*storage.Deck {
+	var ds []*storage.Deck
+	for draft := 0; draft < 4; draft++ {
+		id := fmt.Sprintf("2025-01-0%d", draft+1)
+		for seat := 0; seat < 4; seat++ {
+			colors := []string{"W"}
+			if seat < 4-draft {
+				colors = []string{"R"}
+			}
+			arch := "aggro"
+			if draft >= 2 && seat == 3 {
+				arch = "control"
+			}
+			ds = append(ds, makePivotDeck(fmt.Sprintf("p%d", seat), id, id, colors, arch, nil, nil))
+		}
+	}
+	return ds
+}
+
+func forecastFor(fs []*ShareForecast, key string) *ShareForecast {
+	for _, f := range fs {
+		if f.Key == key {
+			return f
+		}
+	}
+	return nil
+}
+
+func TestMetagameForecast(t *testing.T) {
+	resp := metagameForecast(forecastDecks(), decks.BucketOptions{Size: 1, Discrete: true}, types.DefaultMacros, 0.5, zForConfidence(0.8))
+	require.Len(t, resp.Buckets, 4)
+	assert.Equal(t, 4.0, resp.DecksPerDraft)
+
+	red := forecastFor(resp.Colors, "R")
+	require.NotNil(t, red)
+	assert.Equal(t, []float64{100, 75, 50, 25}, red.History)
+	assert.Equal(t, 62.5, red.Average)
+
+	// Smoothing with alpha 0.5: 100 -> 87.5 -> 68.75 -> 46.875.
+	assert.Equal(t, 46.9, red.Forecast)
+	assert.Less(t, red.Low, red.Forecast)
+	assert.Greater(t, red.High, red.Forecast)
+
+	// Control shows up with no share before it was first played.
+	control := forecastFor(resp.Archetypes, "control")
+	require.NotNil(t, control)
+	assert.Equal(t, []float64{0, 0, 25, 25}, control.History)
+	assert.Equal(t, "aggro", resp.Archetypes[0].Key)
+
+	// Colors nobody played forecast zero, with a zero-width band.
+	blue := forecastFor(resp.Colors, "U")
+	assert.Equal(t, 0.0, blue.Forecast)
+	assert.Equal(t, 0.0, blue.High)
+}
+
+func TestMetagameForecast_PartialBucket(t *testing.T) {
+	// With two drafts a bucket, the oldest of five drafts is dropped. Give it
+	// extra decks so counting them would skew the per-draft count.
+	ds := forecastDecks()
+	for seat := 4; seat < 8; seat++ {
+		ds = append(ds, makePivotDeck(fmt.Sprintf("p%d", seat), "2025-01-01", "2025-01-01", []string{"W"}, "aggro", nil, nil))
+	}
+	for seat := 0; seat < 4; seat++ {
+		ds = append(ds, makePivotDeck(fmt.Sprintf("p%d", seat), "2025-01-05", "2025-01-05", []string{"W"}, "aggro", nil, nil))
+	}
+
+	resp := metagameForecast(ds, decks.BucketOptions{Size: 2, Discrete: true}, types.DefaultMacros, 0.5, zForConfidence(0.8))
+	require.Len(t, resp.Buckets, 2)
+	assert.Equal(t, 4.0, resp.DecksPerDraft)
+}
+
+func TestForecastShare_Flat(t *testing.T) {
+	// A steady share has no smoothing error, so only sampling noise is left.
+	sf := forecastShare("W", []float64{50, 50, 50}, 0.5, 100, 1.96)
+	assert.Equal(t, 50.0, sf.Forecast)
+	assert.InDelta(t, 40.2, sf.Low, 0.1)
+	assert.InDelta(t, 59.8, sf.High, 0.1)
+}
+
+func TestCubeChanges(t *testing.T) {
+	then := []types.Card{
+		{Name: "Shock", Colors: []string{"R"}},
+		{Name: "Bolt", Colors: []string{"R"}},
+		{Name: "Opt", Colors: []string{"U"}},
+		{Name: "Swords", Colors: []string{"W"}},
+		{Name: "Mountain", Types: []string{"Land"}},
+	}
+	now := map[string]types.Card{
+		"Shock":    {Name: "Shock", Colors: []string{"R"}},
+		"Opt":      {Name: "Opt", Colors: []string{"U"}},
+		"Swords":   {Name: "Swords", Colors: []string{"W"}},
+		"Ponder":   {Name: "Ponder", Colors: []string{"U"}},
+		"Mountain": {Name: "Mountain", Types: []string{"Land"}},
+	}
+	cc := cubeChanges("2025-01-04", then, now)
+	assert.Equal(t, []string{"Ponder"}, cc.Added)
+	assert.Equal(t, []string{"Bolt"}, cc.Removed)
+
+	// Red went from half the spells to a quarter, blue from a quarter to half.
+	assert.Equal(t, 0.5, cc.ColorShift["R"])
+	assert.Equal(t, 2.0, cc.ColorShift["U"])
+	assert.Equal(t, 1.0, cc.ColorShift["W"])
+	assert.NotContains(t, cc.ColorShift, "G")
+
+	colors := []*ShareForecast{{Key: "R", Forecast: 40}, {Key: "U", Forecast: 60}, {Key: "G", Forecast: 10}}
+	applyColorShift(colors, cc.ColorShift)
+	assert.Equal(t, 20.0, colors[0].Adjusted)
+	assert.Equal(t, 100.0, colors[1].Adjusted)
+	assert.Equal(t, 0.0, colors[2].Adjusted)
+}
@@ -0,0 +1,333 @@
+package stats
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/caseydavenport/cube-tools/pkg/server"
+	"github.com/caseydavenport/cube-tools/pkg/server/decks"
+	"github.com/caseydavenport/cube-tools/pkg/server/query"
+	"github.com/caseydavenport/cube-tools/pkg/storage"
+	"github.com/caseydavenport/cube-tools/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// HeadToHeadResponse is the API response for /api/stats/players/h2h. Each pair
+// appears once, oriented so Player sorts before Opponent.
+type HeadToHeadResponse struct {
+	Pairs []*HeadToHead `json:"pairs"`
+}
+
+// HeadToHead is the record between two players across every time they met,
+// from Player's side. Games carries the Wilson interval; with only a handful of
+// meetings per pair it's usually wide, and Significant says whether the
+// rivalry is lopsided beyond chance.
+type HeadToHead struct {
+	Player   string `json:"player"`
+	Opponent string `json:"opponent"`
+
+	MatchWins   int `json:"match_wins"`
+	MatchLosses int `json:"match_losses"`
+	MatchDraws  int `json:"match_draws"`
+
+	Games Record `json:"games"`
+
+	Meetings []*Meeting `json:"meetings"`
+}
+
+// Meeting is a single match between the pair, with the deck each side brought.
+type Meeting struct {
+	DraftID string `json:"draft_id"`
+	Date    string `json:"date"`
+	Round   int    `json:"round,omitempty"`
+
+	// Result is "W", "L" or "D" from Player's side.
+	Result string `json:"result"`
+	Wins   int    `json:"wins"`
+	Losses int    `json:"losses"`
+	Draws  int    `json:"draws"`
+
+	PlayerColors      string `json:"player_colors"`
+	OpponentColors    string `json:"opponent_colors"`
+	PlayerArchetype   string `json:"player_archetype,omitempty"`
+	OpponentArchetype string `json:"opponent_archetype,omitempty"`
+}
+
+// PlayerRivalriesResponse is the API response for
+// /api/stats/players/{player}/h2h: every opponent the player has faced, plus
+// the ones they do notably badly (nemeses) and well (favourable) against.
+type PlayerRivalriesResponse struct {
+	Player     string        `json:"player"`
+	Opponents  []*HeadToHead `json:"opponents"`
+	Nemeses    []*HeadToHead `json:"nemeses"`
+	Favourable []*HeadToHead `json:"favourable"`
+}
+
+// defaultRivalryMinGames is how many games a pair needs before it's considered
+// for the nemesis / favourable lists. Below this a single 2-0 dominates.
+const defaultRivalryMinGames = 4
+
+func HeadToHeadHandler() http.Handler {
+	return &headToHeadHandler{store: storage.NewFileDeckStoreWithCache()}
+}
+
+type headToHeadHandler struct {
+	store storage.DeckStorage
+}
+
+func (h *headToHeadHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	dr := decks.ParseDecksRequest(r)
+	logrus.WithField("params", dr).Info("/api/stats/players/h2h")
+
+	allDecks, err := h.store.List(server.CubeFromRequest(r), dr)
+	if err != nil {
+		http.Error(rw, "could not load decks", http.StatusInternalServerError)
+		return
+	}
+
+	pairs := headToHeads(allDecks, zForConfidence(query.GetFloat(r, "confidence")))
+	writeJSON(rw, HeadToHeadResponse{Pairs: pairs})
+}
+
+func PlayerRivalriesHandler() http.Handler {
+	return &playerRivalriesHandler{store: storage.NewFileDeckStoreWithCache()}
+}
+
+type playerRivalriesHandler struct {
+	store storage.DeckStorage
+}
+
+func (h *playerRivalriesHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	player := r.PathValue("player")
+	dr := decks.ParseDecksRequest(r)
+	logrus.WithFields(logrus.Fields{"player": player, "params": dr}).Info("/api/stats/players/h2h")
+
+	// The player filter would drop the opponents' decks, which we need for
+	// the colors and archetypes they brought.
+	dr.Player = ""
+	allDecks, err := h.store.List(server.CubeFromRequest(r), dr)
+	if err != nil {
+		http.Error(rw, "could not load decks", http.StatusInternalServerError)
+		return
+	}
+
+	minGames := query.GetInt(r, "min_games")
+	if minGames <= 0 {
+		minGames = defaultRivalryMinGames
+	}
+	z := zForConfidence(query.GetFloat(r, "confidence"))
+	resp := playerRivalries(player, headToHeads(allDecks, z), minGames, z)
+	writeJSON(rw, resp)
+}
+
+// meetingKey identifies one match between a pair so the copy recorded in each
+// player's deck is only counted once. seq separates repeat meetings in the same
+// draft that don't record a round.
+type meetingKey struct {
+	draft string
+	a, b  string
+	round int
+	seq   int
+}
+
+// headToHeads builds the record for every pair of players who have met. Both
+// players' decks usually record the same match, so each match is taken from the
+// deck of the player who sorts first and only falls back to the other side's
+// copy when that deck wasn't loaded.
+func headToHeads(allDecks []*storage.Deck, z float64) []*HeadToHead {
+	idx := storage.NewOpponentIndex(allDecks)
+	byPair := make(map[[2]string]*HeadToHead)
+	seen := make(map[meetingKey]bool)
+
+	// Two passes: first the canonical side of every match, then the mirror
+	// side for matches only the later-sorting player recorded.
+	for _, canonical := range []bool{true, false} {
+		for _, deck := range allDecks {
+			seq := make(map[string]int)
+			for _, m := range deck.Matches {
+				if m.Opponent == "" || m.Opponent == deck.Player {
+					continue
+				}
+				first := deck.Player < m.Opponent
+				a, b := deck.Player, m.Opponent
+				if !first {
+					a, b = b, a
+				}
+				seqKey := fmt.Sprintf("%s/%d", m.Opponent, m.Round)
+				key := meetingKey{draft: deck.Metadata.DraftID, a: a, b: b, round: m.Round, seq: seq[seqKey]}
+				seq[seqKey]++
+				if first != canonical || seen[key] {
+					continue
+				}
+				seen[key] = true
+
+				pk := [2]string{a, b}
+				hh, ok := byPair[pk]
+				if !ok {
+					hh = &HeadToHead{Player: a, Opponent: b, Meetings: []*Meeting{}}
+					byPair[pk] = hh
+				}
+				mt := newMeeting(deck, m, idx)
+				if !first {
+					mt = mt.flipped()
+				}
+				hh.add(mt)
+			}
+		}
+	}
+
+	pairs := make([]*HeadToHead, 0, len(byPair))
+	for _, hh := range byPair {
+		hh.Games.Finalize()
+		hh.Games.SetInterval(z)
+		sort.Slice(hh.Meetings, func(i, j int) bool {
+			if hh.Meetings[i].Date != hh.Meetings[j].Date {
+				return hh.Meetings[i].Date < hh.Meetings[j].Date
+			}
+			return hh.Meetings[i].Round < hh.Meetings[j].Round
+		})
+		pairs = append(pairs, hh)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Player != pairs[j].Player {
+			return pairs[i].Player < pairs[j].Player
+		}
+		return pairs[i].Opponent < pairs[j].Opponent
+	})
+	return pairs
+}
+
+// newMeeting describes match m from deck's side, looking up the opponent's deck
+// for the colors and archetype they played.
+func newMeeting(deck *storage.Deck, m types.Match, idx *storage.OpponentIndex) *Meeting {
+	mt := &Meeting{
+		DraftID:         deck.Metadata.DraftID,
+		Date:            deck.Date,
+		Round:           m.Round,
+		Wins:            m.Wins,
+		Losses:          m.Losses,
+		Draws:           m.Draws,
+		PlayerColors:    deckColorString(deck),
+		PlayerArchetype: deck.MacroArchetype,
+	}
+	switch {
+	case m.Winner == deck.Player || (m.Winner == "" && m.Wins > m.Losses):
+		mt.Result = "W"
+	case m.Winner != "" || m.Losses > m.Wins:
+		mt.Result = "L"
+	default:
+		mt.Result = "D"
+	}
+	if opp, ok := idx.OpponentDeck(deck, m.Opponent); ok {
+		mt.OpponentColors = deckColorString(opp)
+		mt.OpponentArchetype = opp.MacroArchetype
+	}
+	return mt
+}
+
+// flipped returns the same meeting seen from the other side of the table.
+func (m *Meeting) flipped() *Meeting {
+	f := *m
+	f.Wins, f.Losses = m.Losses, m.Wins
+	f.PlayerColors, f.OpponentColors = m.OpponentColors, m.PlayerColors
+	f.PlayerArchetype, f.OpponentArchetype = m.OpponentArchetype, m.PlayerArchetype
+	switch m.Result {
+	case "W":
+		f.Result = "L"
+	case "L":
+		f.Result = "W"
+	}
+	return &f
+}
+
+func (h *HeadToHead) add(m *Meeting) {
+	h.Meetings = append(h.Meetings, m)
+	switch m.Result {
+	case "W":
+		h.MatchWins++
+	case "L":
+		h.MatchLosses++
+	default:
+		h.MatchDraws++
+	}
+	h.Games.Wins += m.Wins
+	h.Games.Losses += m.Losses
+	h.Games.Draws += m.Draws
+}
+
+// flipped returns the pair from Opponent's side. The interval is recomputed
+// from the mirrored counts rather than reflected so rounding stays consistent.
+func (h *HeadToHead) flipped(z float64) *HeadToHead {
+	f := &HeadToHead{
+		Player:      h.Opponent,
+		Opponent:    h.Player,
+		MatchWins:   h.MatchLosses,
+		MatchLosses: h.MatchWins,
+		MatchDraws:  h.MatchDraws,
+		Meetings:    make([]*Meeting, len(h.Meetings)),
+	}
+	f.Games.Wins, f.Games.Losses, f.Games.Draws = h.Games.Losses, h.Games.Wins, h.Games.Draws
+	f.Games.Finalize()
+	f.Games.SetInterval(z)
+	for i, m := range h.Meetings {
+		f.Meetings[i] = m.flipped()
+	}
+	return f
+}
+
+// playerRivalries orients every pair involving player toward them. Nemeses are
+// opponents the player has a losing game record against, worst first;
+// favourable matchups are the winning ones, best first. Pairs with fewer than
+// minGames games are listed under Opponents but not ranked.
+func playerRivalries(player string, pairs []*HeadToHead, minGames int, z float64) PlayerRivalriesResponse {
+	resp := PlayerRivalriesResponse{
+		Player:     player,
+		Opponents:  []*HeadToHead{},
+		Nemeses:    []*HeadToHead{},
+		Favourable: []*HeadToHead{},
+	}
+	for _, hh := range pairs {
+		switch {
+		case strings.EqualFold(hh.Player, player):
+			resp.Opponents = append(resp.Opponents, hh)
+		case strings.EqualFold(hh.Opponent, player):
+			resp.Opponents = append(resp.Opponents, hh.flipped(z))
+		}
+	}
+	for _, hh := range resp.Opponents {
+		g := hh.Games
+		if g.Wins+g.Losses+g.Draws < minGames {
+			continue
+		}
+		switch {
+		case g.WinPercent < 50:
+			resp.Nemeses = append(resp.Nemeses, hh)
+		case g.WinPercent > 50:
+			resp.Favourable = append(resp.Favourable, hh)
+		}
+	}
+
+	// Rank by the interval bound nearest 50 so a long, consistently lopsided
+	// rivalry outranks a short streak with the same point estimate.
+	sort.SliceStable(resp.Nemeses, func(i, j int) bool {
+		return resp.Nemeses[i].Games.WinPercentHigh < resp.Nemeses[j].Games.WinPercentHigh
+	})
+	sort.SliceStable(resp.Favourable, func(i, j int) bool {
+		return resp.Favourable[i].Games.WinPercentLow > resp.Favourable[j].Games.WinPercentLow
+	})
+	sort.SliceStable(resp.Opponents, func(i, j int) bool {
+		return len(resp.Opponents[i].Meetings) > len(resp.Opponents[j].Meetings)
+	})
+	return resp
+}
+
+// deckColorString returns the deck's colors in WUBRG order, e.g. "UR".
+func deckColorString(d *storage.Deck) string {
+	colors := d.GetColors()
+	sort.Slice(colors, func(i, j int) bool {
+		return strings.Index("WUBRG", colors[i]) < strings.Index("WUBRG", colors[j])
+	})
+	return strings.Join(colors, "")
+}
@@ -0,0 +1,121 @@
+package stats
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/caseydavenport/cube-tools/pkg/storage"
+	"github.com/caseydavenport/cube-tools/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func h2hDeck(player, draftID, date, arch string, colors []string, games []types.Game) *storage.Deck {
+	d := makeStorageDeck(player, draftID, nil, games, nil)
+	d.Date = date
+	d.MacroArchetype = arch
+	d.Colors = colors
+	for i := range d.Matches {
+		m := &d.Matches[i]
+		switch {
+		case m.Wins > m.Losses:
+			m.Winner = player
+		case m.Losses > m.Wins:
+			m.Winner = m.Opponent
+		}
+	}
+	return d
+}
+
+func TestHeadToHeads_CountsEachMatchOnce(t *testing.T) {
+	// Both sides record the d1 match; Alice wins 2-1.
+	decks := []*storage.Deck{
+		h2hDeck("Alice", "d1", "2025-01-01", "aggro", []string{"R", "W"}, []types.Game{
+			{Opponent: "Bob", Winner: "Alice"}, {Opponent: "Bob", Winner: "Bob"}, {Opponent: "Bob", Winner: "Alice"},
+		}),
+		h2hDeck("Bob", "d1", "2025-01-01", "control", []string{"U"}, []types.Game{
+			{Opponent: "Alice", Winner: "Alice"}, {Opponent: "Alice", Winner: "Bob"}, {Opponent: "Alice", Winner: "Alice"},
+		}),
+		// Only Bob's deck is loaded for d2; Bob wins 2-0.
+		h2hDeck("Bob", "d2", "2025-02-01", "aggro", []string{"B", "R"}, []types.Game{
+			{Opponent: "Alice", Winner: "Bob"}, {Opponent: "Alice", Winner: "Bob"},
+		}),
+	}
+
+	pairs := headToHeads(decks, zForConfidence(0))
+	require.Len(t, pairs, 1)
+	hh := pairs[0]
+	assert.Equal(t, "Alice", hh.Player)
+	assert.Equal(t, "Bob", hh.Opponent)
+	assert.Equal(t, 1, hh.MatchWins)
+	assert.Equal(t, 1, hh.MatchLosses)
+	assert.Equal(t, 2, hh.Games.Wins)
+	assert.Equal(t, 3, hh.Games.Losses)
+	assert.Equal(t, 40.0, hh.Games.WinPercent)
+	assert.Less(t, hh.Games.WinPercentLow, hh.Games.WinPercent)
+	assert.Greater(t, hh.Games.WinPercentHigh, hh.Games.WinPercent)
+
+	require.Len(t, hh.Meetings, 2)
+	first := hh.Meetings[0]
+	assert.Equal(t, "W", first.Result)
+	assert.Equal(t, "WR", first.PlayerColors)
+	assert.Equal(t, "U", first.OpponentColors)
+	assert.Equal(t, "aggro", first.PlayerArchetype)
+	assert.Equal(t, "control", first.OpponentArchetype)
+
+	// The d2 meeting was mirrored from Bob's side; Alice's deck is unknown.
+	second := hh.Meetings[1]
+	assert.Equal(t, "L", second.Result)
+	assert.Equal(t, 0, second.Wins)
+	assert.Equal(t, 2, second.Losses)
+	assert.Equal(t, "", second.PlayerColors)
+	assert.Equal(t, "BR", second.OpponentColors)
+}
+
+func TestPlayerRivalries_NemesesAndFavourable(t *testing.T) {
+	var decks []*storage.Deck
+	for _, id := range []string{"d1", "d2"} {
+		decks = append(decks,
+			h2hDeck("Alice", id, "2025-01-01", "", nil, []types.Game{
+				{Opponent: "Bob", Winner: "Bob"}, {Opponent: "Bob", Winner: "Bob"},
+				{Opponent: "Carol", Winner: "Alice"}, {Opponent: "Carol", Winner: "Alice"},
+				{Opponent: "Dave", Winner: "Alice"},
+			}),
+		)
+	}
+
+	resp := playerRivalries("alice", headToHeads(decks, zForConfidence(0)), 4, zForConfidence(0))
+	assert.Len(t, resp.Opponents, 3)
+	require.Len(t, resp.Nemeses, 1)
+	assert.Equal(t, "Bob", resp.Nemeses[0].Opponent)
+	assert.Equal(t, 0.0, resp.Nemeses[0].Games.WinPercent)
+	require.Len(t, resp.Favourable, 1)
+	assert.Equal(t, "Carol", resp.Favourable[0].Opponent)
+	// Dave has only two games, below the threshold.
+}
+
+func TestPlayerRivalriesHandler_OrientsTowardPlayer(t *testing.T) {
+	decks := []*storage.Deck{
+		h2hDeck("Alice", "d1", "2025-01-01", "", nil, []types.Game{
+			{Opponent: "Zed", Winner: "Alice"}, {Opponent: "Zed", Winner: "Alice"},
+		}),
+	}
+	handler := &playerRivalriesHandler{store: &mockDeckStorage{decks: decks}}
+	req := httptest.NewRequest(http.MethodGet, "/api/stats/players/Zed/h2h?min_games=1", nil)
+	req.SetPathValue("player", "Zed")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var resp PlayerRivalriesResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Len(t, resp.Opponents, 1)
+	assert.Equal(t, "Zed", resp.Opponents[0].Player)
+	assert.Equal(t, "Alice", resp.Opponents[0].Opponent)
+	assert.Equal(t, 2, resp.Opponents[0].Games.Losses)
+	assert.Equal(t, "L", resp.Opponents[0].Meetings[0].Result)
+	require.Len(t, resp.Nemeses, 1)
+	assert.Empty(t, resp.Favourable)
+}
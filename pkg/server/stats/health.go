@@ -17,12 +17,24 @@ import (
 
 type HealthStatsRequest struct {
 	*storage.DecksRequest
-	BucketSize int  `json:"bucket_size"`
-	Sliding    bool `json:"sliding"`
+	BucketSize int     `json:"bucket_size"`
+	Sliding    bool    `json:"sliding"`
+	BucketBy   string  `json:"bucket_by"`
+	Confidence float64 `json:"confidence"`
+
+	// macros is the cube's macro archetype taxonomy, from the registry.
+	macros []string
 }
 
 type HealthStatsResponse struct {
 	Buckets []HealthBucket `json:"buckets"`
+
+	// Macros is the macro archetype list the archetype metrics are taken over.
+	Macros []string `json:"macros"`
+
+	// Config is the scoring config in effect: the defaults with the cube's
+	// health-config.json applied.
+	Config HealthConfig `json:"config"`
 }
 
 type HealthBucket struct {
@@ -33,15 +45,56 @@ type HealthBucket struct {
 	ColorBalanceStdDev float64 `json:"color_balance_stddev"`
 	TrophyGini         float64 `json:"trophy_gini"`
 	AvgWordCount       float64 `json:"avg_word_count"`
+
+	// PlayRateGini is the Gini coefficient of how many mainboards each cube
+	// card made. High means a few cards carry the format. NeverMaindecked is the
+	// percentage of cube cards no deck in the bucket played.
+	PlayRateGini    float64 `json:"play_rate_gini"`
+	NeverMaindecked float64 `json:"never_maindecked"`
+
+	// AvgDeckPower is the mean across decks of each mainboard's average pick
+	// Elo, rated over every deck in the request so buckets are comparable.
+	AvgDeckPower float64 `json:"avg_deck_power"`
+
+	// ColorPairs are the two-color pairs' records, and ColorPairSpread the gap
+	// in win rate between the best and worst pair with enough games to judge.
+	// SpreadSignificant is whether those two pairs' intervals don't overlap.
+	ColorPairs        []*ColorPairRecord `json:"color_pairs"`
+	ColorPairSpread   float64            `json:"color_pair_spread"`
+	SpreadSignificant bool               `json:"spread_significant"`
+
+	// FirstPickConcentration is how much the opening pick of each pack went to
+	// the same few cards: 0 when every first pick was a different card, 1 when
+	// all were the same. FirstPicks is how many picks it's measured over, from
+	// the drafts with a log.
+	FirstPickConcentration float64 `json:"first_pick_concentration"`
+	FirstPicks             int     `json:"first_picks"`
+
+	// Score is the weighted composite of the metrics, 0 to 100, and Warnings
+	// name the metrics past their bad threshold.
+	Score    float64  `json:"score"`
+	Warnings []string `json:"warnings"`
+}
+
+// ColorPairRecord is the record of the decks in exactly one two-color pair.
+type ColorPairRecord struct {
+	Colors string `json:"colors"`
+	Record
 }
 
+// healthMinPairGames is the fewest games a color pair needs to count toward
+// the win rate spread.
+const healthMinPairGames = 10
+
 func parseHealthRequest(r *http.Request) *HealthStatsRequest {
 	p := HealthStatsRequest{}
 	p.BucketSize = query.GetInt(r, "bucket_size")
-	if p.BucketSize == 0 {
+	p.BucketBy = query.GetString(r, "bucket_by")
+	if p.BucketSize == 0 && !decks.Bucketed(0, p.BucketBy) {
 		p.BucketSize = 5
 	}
 	p.Sliding = query.GetBool(r, "sliding")
+	p.Confidence = query.GetFloat(r, "confidence")
 	p.DecksRequest = decks.ParseDecksRequest(r)
 	return &p
 }
@@ -61,6 +114,7 @@ func (h *healthStatsHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request)
 	logrus.WithField("params", sr).Info("/api/stats/health")
 
 	cubeID := server.CubeFromRequest(r)
+	sr.macros = cubeMacros(cubeID)
 	allDecks, err := h.store.List(cubeID, sr.DecksRequest)
 	if err != nil {
 		http.Error(rw, "could not load decks", http.StatusInternalServerError)
@@ -74,9 +128,48 @@ func (h *healthStatsHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request)
 			cubeCards[c.Name] = c
 		}
 	}
+	cfg, err := loadHealthConfig("data", cubeID)
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("could not load health config: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Draft logs and cube snapshots are optional. Drafts without a log don't
+	// contribute to first-pick concentration, and drafts without a snapshot
+	// are measured against today's cube.
+	logs := make(map[string]*types.DraftLog)
+	for _, d := range allDecks {
+		id := d.Metadata.DraftID
+		if _, ok := logs[id]; ok || id == "" {
+			continue
+		}
+		log, err := types.LoadDraftLog(fmt.Sprintf("data/%s/%s/draft-log.json", cubeID, id))
+		if err != nil {
+			log = nil
+		}
+		logs[id] = log
+	}
+	snapshots := loadSnapshots(cubeID, allDecks)
+
+	opts := decks.NewBucketOptions(cubeID, allDecks, sr.BucketSize, !sr.Sliding, sr.BucketBy)
+	resp := healthStats(allDecks, opts, sr, cubeCards, snapshots, logs, cfg)
+	b, err := json.Marshal(resp)
+	if err != nil {
+		http.Error(rw, "could not marshal response", http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(b)
+}
 
-	resp := HealthStatsResponse{}
-	buckets := decks.DeckBuckets(allDecks, sr.BucketSize, !sr.Sliding)
+// healthStats computes the metrics for each bucket of the request.
+func healthStats(allDecks []*storage.Deck, opts decks.BucketOptions, sr *HealthStatsRequest, cubeCards map[string]types.Card, snapshots map[string][]types.Card, logs map[string]*types.DraftLog, cfg HealthConfig) HealthStatsResponse {
+	macros := orDefaultMacros(sr.macros)
+	resp := HealthStatsResponse{Config: cfg, Macros: macros}
+	elo := PickELOData(allDecks)
+	z := zForConfidence(sr.Confidence)
+
+	buckets := decks.DeckBucketsBy(allDecks, opts)
 	for _, b := range buckets {
 		bDecks := b.AllDecks()
 		hb := HealthBucket{
@@ -84,34 +177,55 @@ func (h *healthStatsHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request)
 			Start:    b.Start(),
 			NumDecks: len(bDecks),
 		}
-		hb.ArchetypeEvenness = archetypeEvenness(bDecks)
+		hb.ArchetypeEvenness = archetypeEvenness(bDecks, macros)
 		hb.ColorBalanceStdDev = colorBalanceStdDev(bDecks)
-		hb.TrophyGini = trophyGini(bDecks)
+		hb.TrophyGini = trophyGini(bDecks, macros)
 		hb.AvgWordCount = avgWordCount(bDecks, cubeCards)
-		resp.Buckets = append(resp.Buckets, hb)
-	}
 
-	b, err := json.Marshal(resp)
-	if err != nil {
-		http.Error(rw, "could not marshal response", http.StatusInternalServerError)
-		return
+		// Each metric joins the score only when the bucket has the data for it.
+		values := map[string]float64{}
+		if hb.ArchetypeEvenness > 0 {
+			values["archetype_evenness"] = hb.ArchetypeEvenness
+			values["trophy_gini"] = hb.TrophyGini
+		}
+		if hb.ColorBalanceStdDev > 0 {
+			values["color_balance_stddev"] = hb.ColorBalanceStdDev
+		}
+		if built := builtDecks(bDecks); len(built) > 0 {
+			available := bucketCubeCards(bDecks, snapshots, cubeCards)
+			hb.PlayRateGini, hb.NeverMaindecked = playRateConcentration(built, available)
+			hb.AvgDeckPower = avgDeckPower(built, elo)
+			values["play_rate_gini"] = hb.PlayRateGini
+			if len(available) > 0 {
+				values["never_maindecked"] = hb.NeverMaindecked
+			}
+		}
+		hb.ColorPairs, hb.ColorPairSpread, hb.SpreadSignificant = colorPairSpread(bDecks, z)
+		if hb.ColorPairSpread > 0 {
+			values["color_pair_spread"] = hb.ColorPairSpread
+		}
+		hb.FirstPickConcentration, hb.FirstPicks = firstPickConcentration(bDecks, logs)
+		if hb.FirstPicks > 0 {
+			values["first_pick_concentration"] = hb.FirstPickConcentration
+		}
+		hb.Score, hb.Warnings = cfg.score(values)
+		resp.Buckets = append(resp.Buckets, hb)
 	}
-	rw.Header().Set("Content-Type", "application/json")
-	rw.Write(b)
+	return resp
 }
 
 // archetypeEvenness computes Shannon entropy of macro archetype distribution,
-// normalized by log(3) to produce a 0-1 scale.
-func archetypeEvenness(allDecks []*storage.Deck) float64 {
-	macros := []string{"aggro", "midrange", "control"}
+// normalized by the log of the number of macros to produce a 0-1 scale. Decks
+// with a macro outside the list don't count.
+func archetypeEvenness(allDecks []*storage.Deck, macros []string) float64 {
 	counts := make(map[string]int)
 	for _, m := range macros {
 		counts[m] = 0
 	}
 	total := 0
 	for _, d := range allDecks {
-		m := d.Macro()
-		if m == "" {
+		m := d.MacroIn(macros)
+		if _, ok := counts[m]; !ok {
 			continue
 		}
 		counts[m]++
@@ -143,27 +257,13 @@ func shannonEvenness(counts map[string]int, total int) float64 {
 	return math.Round(h/hMax*1000) / 1000
 }
 
+// dualColors are the ten two-color pairs in their conventional order.
+var dualColors = []string{"WU", "WB", "WR", "WG", "UB", "UR", "UG", "BR", "BG", "RG"}
+
 // colorBalanceStdDev computes the sample standard deviation of win rates across
 // the 10 dual color pairs.
 func colorBalanceStdDev(allDecks []*storage.Deck) float64 {
-	dualColors := []string{"WU", "WB", "WR", "WG", "UB", "UR", "UG", "BR", "BG", "RG"}
-	records := make(map[string]*Record)
-
-	for _, d := range allDecks {
-		colors := d.GetColors()
-		if len(colors) != 2 {
-			continue
-		}
-		sort.Slice(colors, func(i, j int) bool {
-			order := "WUBRG"
-			return indexOf(order, colors[i]) < indexOf(order, colors[j])
-		})
-		pair := colors[0] + colors[1]
-		if _, ok := records[pair]; !ok {
-			records[pair] = &Record{}
-		}
-		records[pair].Add(d)
-	}
+	records := colorPairRecords(allDecks)
 
 	// Compute win rates for pairs that have games.
 	var rates []float64
@@ -200,6 +300,28 @@ func colorBalanceStdDev(allDecks []*storage.Deck) float64 {
 	return math.Round(math.Sqrt(variance)*1000) / 1000
 }
 
+// colorPairRecords tallies the records of decks playing exactly two colors,
+// keyed by the pair in WUBRG order.
+func colorPairRecords(allDecks []*storage.Deck) map[string]*Record {
+	records := make(map[string]*Record)
+	for _, d := range allDecks {
+		colors := d.GetColors()
+		if len(colors) != 2 {
+			continue
+		}
+		sort.Slice(colors, func(i, j int) bool {
+			order := "WUBRG"
+			return indexOf(order, colors[i]) < indexOf(order, colors[j])
+		})
+		pair := colors[0] + colors[1]
+		if _, ok := records[pair]; !ok {
+			records[pair] = &Record{}
+		}
+		records[pair].Add(d)
+	}
+	return records
+}
+
 func indexOf(s, sub string) int {
 	for i := range s {
 		if string(s[i]) == sub {
@@ -210,15 +332,14 @@ func indexOf(s, sub string) int {
 }
 
 // trophyGini computes the Gini coefficient of trophy counts across macro archetypes.
-func trophyGini(allDecks []*storage.Deck) float64 {
-	macros := []string{"aggro", "midrange", "control"}
+func trophyGini(allDecks []*storage.Deck, macros []string) float64 {
 	trophyCounts := make(map[string]int)
 	for _, m := range macros {
 		trophyCounts[m] = 0
 	}
 	for _, d := range allDecks {
-		m := d.Macro()
-		if m == "" {
+		m := d.MacroIn(macros)
+		if _, ok := trophyCounts[m]; !ok {
 			continue
 		}
 		trophyCounts[m] += d.Trophies()
@@ -281,3 +402,173 @@ func giniCoefficient(values []float64) float64 {
 	gini := (2*numerator - float64(n+1)*sum) / (float64(n) * sum)
 	return math.Round(gini*1000) / 1000
 }
+
+// builtDecks returns the decks with a recorded mainboard.
+func builtDecks(allDecks []*storage.Deck) []*storage.Deck {
+	var built []*storage.Deck
+	for _, d := range allDecks {
+		if len(d.Mainboard) > 0 {
+			built = append(built, d)
+		}
+	}
+	return built
+}
+
+// bucketCubeCards returns the cards that were in the cube for a bucket's
+// drafts: the union of their snapshots, with today's cube standing in for any
+// draft without one.
+func bucketCubeCards(bDecks []*storage.Deck, snapshots map[string][]types.Card, cubeCards map[string]types.Card) map[string]types.Card {
+	cards := make(map[string]types.Card)
+	seen := make(map[string]bool)
+	for _, d := range bDecks {
+		id := d.Metadata.DraftID
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		snap, ok := snapshots[id]
+		if !ok {
+			for name, c := range cubeCards {
+				cards[name] = c
+			}
+			continue
+		}
+		for _, c := range snap {
+			cards[c.Name] = c
+		}
+	}
+	return cards
+}
+
+// playRateConcentration returns the Gini coefficient of mainboard counts across
+// cube cards and the percentage of them never played. Without a cube list it
+// can only see the cards that were played, so nothing counts as never played.
+func playRateConcentration(built []*storage.Deck, cubeCards map[string]types.Card) (float64, float64) {
+	counts := make(map[string]int)
+	for name, c := range cubeCards {
+		if !c.IsBasicLand() {
+			counts[name] = 0
+		}
+	}
+	for _, d := range built {
+		seen := make(map[string]bool)
+		for _, c := range d.Mainboard {
+			if c.IsBasicLand() || seen[c.Name] {
+				continue
+			}
+			seen[c.Name] = true
+			if _, ok := counts[c.Name]; ok || len(cubeCards) == 0 {
+				counts[c.Name]++
+			}
+		}
+	}
+
+	values := make([]float64, 0, len(counts))
+	never := 0
+	for _, n := range counts {
+		values = append(values, float64(n))
+		if n == 0 {
+			never++
+		}
+	}
+	return giniCoefficient(values), pct(float64(never), float64(len(counts)))
+}
+
+// avgDeckPower averages, across decks, the mean pick Elo of each mainboard's
+// nonbasic cards. Cards without a rating count at the base rating.
+func avgDeckPower(built []*storage.Deck, elo map[string]int) float64 {
+	sum, n := 0.0, 0
+	for _, d := range built {
+		total, cards := 0.0, 0
+		for _, c := range d.Mainboard {
+			if c.IsBasicLand() {
+				continue
+			}
+			rating, ok := elo[c.Name]
+			if !ok {
+				rating = int(eloBase)
+			}
+			total += float64(rating)
+			cards++
+		}
+		if cards > 0 {
+			sum += total / float64(cards)
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return round1(sum / float64(n))
+}
+
+// colorPairSpread returns each color pair's record with its interval, the gap
+// between the best and worst win rate among pairs with at least
+// healthMinPairGames games, and whether that gap is significant.
+func colorPairSpread(allDecks []*storage.Deck, z float64) ([]*ColorPairRecord, float64, bool) {
+	records := colorPairRecords(allDecks)
+	pairs := []*ColorPairRecord{}
+	var best, worst *ColorPairRecord
+	for _, c := range dualColors {
+		r, ok := records[c]
+		if !ok {
+			continue
+		}
+		cp := &ColorPairRecord{Colors: c, Record: *r}
+		cp.Finalize()
+		cp.SetInterval(z)
+		pairs = append(pairs, cp)
+
+		if cp.Wins+cp.Losses+cp.Draws < healthMinPairGames {
+			continue
+		}
+		if best == nil || cp.WinPercent > best.WinPercent {
+			best = cp
+		}
+		if worst == nil || cp.WinPercent < worst.WinPercent {
+			worst = cp
+		}
+	}
+	if best == nil || best == worst {
+		return pairs, 0, false
+	}
+	return pairs, round1(best.WinPercent - worst.WinPercent), best.WinPercentLow > worst.WinPercentHigh
+}
+
+// firstPickConcentration measures how concentrated the first pick of each pack
+// was across the bucket's drafts with a log, as one less the Shannon entropy of
+// the picked cards over its maximum, the entropy had every pick been a
+// different card. It returns the concentration and the number of picks.
+func firstPickConcentration(allDecks []*storage.Deck, logs map[string]*types.DraftLog) (float64, int) {
+	counts := make(map[string]int)
+	total := 0
+	seen := make(map[string]bool)
+	for _, d := range allDecks {
+		id := d.Metadata.DraftID
+		log := logs[id]
+		if log == nil || seen[id] {
+			continue
+		}
+		seen[id] = true
+		for _, u := range log.Users {
+			for _, pick := range u.Picks {
+				if pick.PickNum != 0 {
+					continue
+				}
+				for _, cardID := range pick.Picked() {
+					counts[log.CardData[cardID].Name]++
+					total++
+				}
+			}
+		}
+	}
+	if total < 2 {
+		return 0, total
+	}
+	h := 0.0
+	for _, c := range counts {
+		p := float64(c) / float64(total)
+		h -= p * math.Log(p)
+	}
+	return round3(1 - h/math.Log(float64(total))), total
+}
@@ -0,0 +1,109 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// The composite health score folds the bucket metrics into one number so a
+// glance at the history shows whether the cube is drifting. Each metric is
+// scored from 0 to 1 by where its value falls between a good and a bad
+// threshold, and the score is the weighted mean of those, out of 100. A metric
+// at or past its bad threshold also raises a warning.
+//
+// The thresholds below are starting points. A cube whose drafters play
+// differently can set its own in data/{cube}/health-config.json, which lists
+// only the metrics it changes:
+//
+//	{"never_maindecked": {"weight": 2, "good": 10, "bad": 35}}
+
+const healthConfigFile = "health-config.json"
+
+// HealthMetricConfig sets how one metric feeds the score. Good may be above or
+// below Bad; whichever side Good is on is the healthy direction. A zero weight
+// leaves the metric out of the score but still warns past Bad.
+type HealthMetricConfig struct {
+	Weight float64 `json:"weight"`
+	Good   float64 `json:"good"`
+	Bad    float64 `json:"bad"`
+}
+
+// HealthConfig maps metric names, as they appear in HealthBucket's JSON, to
+// their scoring config.
+type HealthConfig map[string]HealthMetricConfig
+
+var defaultHealthConfig = HealthConfig{
+	"archetype_evenness":       {Weight: 1, Good: 0.95, Bad: 0.75},
+	"color_balance_stddev":     {Weight: 1, Good: 0.03, Bad: 0.1},
+	"trophy_gini":              {Weight: 1, Good: 0.1, Bad: 0.45},
+	"play_rate_gini":           {Weight: 1, Good: 0.45, Bad: 0.75},
+	"never_maindecked":         {Weight: 1, Good: 10, Bad: 35},
+	"color_pair_spread":        {Weight: 1, Good: 10, Bad: 25},
+	"first_pick_concentration": {Weight: 1, Good: 0.05, Bad: 0.2},
+}
+
+// loadHealthConfig applies the cube's health-config.json under root to the
+// defaults. A missing file means the defaults.
+func loadHealthConfig(root, cubeID string) (HealthConfig, error) {
+	cfg := make(HealthConfig, len(defaultHealthConfig))
+	for name, m := range defaultHealthConfig {
+		cfg[name] = m
+	}
+
+	path := filepath.Join(root, cubeID, healthConfigFile)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var overrides HealthConfig
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for name, m := range overrides {
+		if _, ok := defaultHealthConfig[name]; !ok {
+			return nil, fmt.Errorf("%s: unknown health metric %q", path, name)
+		}
+		if m.Good == m.Bad || m.Weight < 0 {
+			return nil, fmt.Errorf("%s: metric %q needs distinct good and bad thresholds and a non-negative weight", path, name)
+		}
+		cfg[name] = m
+	}
+	return cfg, nil
+}
+
+// score computes the composite score over the metrics present in values, and
+// the warnings for any past their bad threshold.
+func (cfg HealthConfig) score(values map[string]float64) (float64, []string) {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	warnings := []string{}
+	sum, weights := 0.0, 0.0
+	for _, name := range names {
+		m, ok := cfg[name]
+		if !ok {
+			continue
+		}
+		v := values[name]
+		s := math.Max(0, math.Min(1, (v-m.Bad)/(m.Good-m.Bad)))
+		if s == 0 {
+			warnings = append(warnings, fmt.Sprintf("%s is %g, past the bad threshold of %g", name, v, m.Bad))
+		}
+		sum += m.Weight * s
+		weights += m.Weight
+	}
+	if weights == 0 {
+		return 0, warnings
+	}
+	return round1(100 * sum / weights), warnings
+}
@@ -0,0 +1,183 @@
+package stats
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/caseydavenport/cube-tools/pkg/storage"
+	"github.com/caseydavenport/cube-tools/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlayRateConcentration(t *testing.T) {
+	cube := map[string]types.Card{
+		"A": {Name: "A"}, "B": {Name: "B"}, "C": {Name: "C"}, "D": {Name: "D"},
+		"Plains": {Name: "Plains", Types: []string{"Basic", "Land"}},
+		"Gone":   {Name: "Gone"},
+	}
+	mb := func(names ...string) []types.Card {
+		var cards []types.Card
+		for _, n := range names {
+			cards = append(cards, cube[n])
+		}
+		return cards
+	}
+	built := []*storage.Deck{
+		// Duplicates and basics don't count; a card cut from the cube is ignored.
+		makePivotDeck("p1", "d1", "", nil, "", mb("A", "A", "B", "Plains", "Gone"), nil),
+		makePivotDeck("p2", "d1", "", nil, "", mb("A"), nil),
+	}
+
+	// Counts are A=2, B=1, C=0, D=0.
+	inCube := maps.Clone(cube)
+	delete(inCube, "Gone")
+	gini, never := playRateConcentration(built, inCube)
+	assert.Equal(t, 0.583, gini)
+	assert.Equal(t, 50.0, never)
+
+	// Without a cube list only played cards are seen: A=2, B=1, Gone=1.
+	gini, never = playRateConcentration(built, nil)
+	assert.Equal(t, 0.167, gini)
+	assert.Equal(t, 0.0, never)
+}
+
+func TestArchetypeEvennessMacros(t *testing.T) {
+	decks := []*storage.Deck{
+		makePivotDeck("p1", "d1", "", nil, "aggro", nil, nil),
+		makePivotDeck("p2", "d1", "", nil, "combo", nil, nil),
+		makePivotDeck("p3", "d1", "", nil, "", nil, nil),
+	}
+	// An untagged deck's label counts when it names a configured macro.
+	decks[2].Labels = []string{"Ramp"}
+
+	// With the defaults only the aggro deck counts, one of three macros.
+	assert.Equal(t, 0.0, archetypeEvenness(decks, types.DefaultMacros))
+
+	// Configured for them, the three decks split evenly three ways.
+	assert.Equal(t, 1.0, archetypeEvenness(decks, []string{"aggro", "combo", "ramp"}))
+}
+
+func TestBucketCubeCards(t *testing.T) {
+	snapshots := map[string][]types.Card{"d1": {{Name: "Old"}, {Name: "Both"}}}
+	current := map[string]types.Card{"Both": {Name: "Both"}, "New": {Name: "New"}}
+
+	only := bucketCubeCards([]*storage.Deck{makePivotDeck("p", "d1", "", nil, "", nil, nil)}, snapshots, current)
+	assert.Len(t, only, 2)
+	assert.Contains(t, only, "Old")
+
+	// A draft without a snapshot brings in today's cube.
+	mixed := bucketCubeCards([]*storage.Deck{
+		makePivotDeck("p", "d1", "", nil, "", nil, nil),
+		makePivotDeck("p", "d2", "", nil, "", nil, nil),
+	}, snapshots, current)
+	assert.Len(t, mixed, 3)
+}
+
+func TestColorPairSpread(t *testing.T) {
+	games := func(wins, losses int) []types.Game {
+		var g []types.Game
+		for range wins {
+			g = append(g, types.Game{Opponent: "o", Winner: "p"})
+		}
+		for range losses {
+			g = append(g, types.Game{Opponent: "o", Winner: "o"})
+		}
+		return g
+	}
+	decks := []*storage.Deck{
+		makePivotDeck("p", "d1", "", []string{"U", "W"}, "", nil, games(45, 15)),
+		makePivotDeck("p", "d1", "", []string{"B", "R"}, "", nil, games(15, 45)),
+		// Too few games to judge, though it's the extreme.
+		makePivotDeck("p", "d1", "", []string{"G", "R"}, "", nil, games(4, 0)),
+		// Three colors isn't a pair.
+		makePivotDeck("p", "d1", "", []string{"W", "U", "B"}, "", nil, games(0, 30)),
+	}
+	pairs, spread, significant := colorPairSpread(decks, zForConfidence(0.95))
+	require.Len(t, pairs, 3)
+	assert.Equal(t, "WU", pairs[0].Colors)
+	assert.Equal(t, "BR", pairs[1].Colors)
+	assert.Equal(t, "RG", pairs[2].Colors)
+	assert.Equal(t, 50.0, spread)
+	assert.True(t, significant)
+
+	_, spread, significant = colorPairSpread(decks[:1], zForConfidence(0.95))
+	assert.Equal(t, 0.0, spread)
+	assert.False(t, significant)
+}
+
+func TestFirstPickConcentration(t *testing.T) {
+	// Four first picks of the same card, and later picks that don't count.
+	same := profileLog("alice",
+		types.DraftCard{Name: "Bolt"},
+		types.DraftCard{Name: "Bear"},
+	)
+	same.Users["u2"] = same.Users["u1"]
+	log2 := profileLog("bob", types.DraftCard{Name: "Bolt"})
+	log2.Users["u2"] = log2.Users["u1"]
+	logs := map[string]*types.DraftLog{"d1": same, "d2": log2, "d3": nil}
+	decks := []*storage.Deck{
+		makePivotDeck("alice", "d1", "", nil, "", nil, nil),
+		makePivotDeck("carol", "d1", "", nil, "", nil, nil),
+		makePivotDeck("bob", "d2", "", nil, "", nil, nil),
+		makePivotDeck("dave", "d3", "", nil, "", nil, nil),
+	}
+	c, n := firstPickConcentration(decks, logs)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, 1.0, c)
+
+	// All different cards.
+	diff := profileLog("alice", types.DraftCard{Name: "Bolt"})
+	other := profileLog("bob", types.DraftCard{Name: "Bear"})
+	diff.Users["u2"] = other.Users["u1"]
+	diff.CardData["Bear"] = other.CardData["Bear"]
+	c, n = firstPickConcentration(decks[:1], map[string]*types.DraftLog{"d1": diff})
+	assert.Equal(t, 2, n)
+	assert.Equal(t, 0.0, c)
+}
+
+func TestHealthScore(t *testing.T) {
+	cfg := HealthConfig{
+		"up":   {Weight: 1, Good: 1, Bad: 0},
+		"down": {Weight: 3, Good: 10, Bad: 30},
+		"off":  {Weight: 0, Good: 1, Bad: 0.5},
+	}
+	score, warnings := cfg.score(map[string]float64{"up": 0.5, "down": 15, "off": 0.2, "unknown": 7})
+	// (0.5 + 3*0.75) / 4
+	assert.Equal(t, 68.8, score)
+	assert.Equal(t, []string{"off is 0.2, past the bad threshold of 0.5"}, warnings)
+
+	score, warnings = cfg.score(map[string]float64{"down": 40})
+	assert.Equal(t, 0.0, score)
+	assert.Len(t, warnings, 1)
+
+	score, _ = cfg.score(map[string]float64{})
+	assert.Equal(t, 0.0, score)
+}
+
+func TestLoadHealthConfig(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "polyverse"), 0o755))
+
+	cfg, err := loadHealthConfig(root, "polyverse")
+	require.NoError(t, err)
+	assert.Equal(t, defaultHealthConfig, cfg)
+
+	path := filepath.Join(root, "polyverse", healthConfigFile)
+	require.NoError(t, os.WriteFile(path, []byte(`{"never_maindecked": {"weight": 2, "good": 5, "bad": 20}}`), 0o644))
+	cfg, err = loadHealthConfig(root, "polyverse")
+	require.NoError(t, err)
+	assert.Equal(t, HealthMetricConfig{Weight: 2, Good: 5, Bad: 20}, cfg["never_maindecked"])
+	assert.Equal(t, defaultHealthConfig["trophy_gini"], cfg["trophy_gini"])
+	assert.Equal(t, 10.0, defaultHealthConfig["never_maindecked"].Good, "defaults are untouched")
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"vibes": {"weight": 1, "good": 1, "bad": 0}}`), 0o644))
+	_, err = loadHealthConfig(root, "polyverse")
+	assert.ErrorContains(t, err, "unknown health metric")
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"trophy_gini": {"weight": 1, "good": 0.2, "bad": 0.2}}`), 0o644))
+	_, err = loadHealthConfig(root, "polyverse")
+	assert.Error(t, err)
+}
@@ -0,0 +1,552 @@
+package stats
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/caseydavenport/cube-tools/pkg/server"
+	"github.com/caseydavenport/cube-tools/pkg/server/decks"
+	"github.com/caseydavenport/cube-tools/pkg/server/query"
+	"github.com/caseydavenport/cube-tools/pkg/storage"
+	"github.com/caseydavenport/cube-tools/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// The mana base analyzer asks whether a deck's lands can cast its spells. For
+// each deck it counts colored sources among the lands (reading duals, fetches
+// and "any color" lands from their oracle text), lays out the pips the spells
+// need turn by turn, and estimates the chance of casting each spell on curve
+// with the hypergeometric distribution. The cube-wide report then asks the
+// design question: are the cube's fixing lands enough, judged by whether decks
+// with shakier mana actually win less.
+
+// splashMaxSpells is the most spells of a color a deck can run for that color
+// to count as a splash rather than a main color.
+const splashMaxSpells = 3
+
+// isSplash reports whether a color with the given number of spells is a splash
+// in a deck whose most-played color has most spells across colors colors.
+func isSplash(spells, most, colors int) bool {
+	return colors > 1 && spells <= splashMaxSpells && spells < most
+}
+
+// splashMinSources is the fewest sources a splash needs. Three sources in a
+// 40-card deck is the usual floor for a handful of one-pip splash cards.
+const splashMinSources = 3
+
+var basicLandColors = map[string]string{
+	"Plains":   "W",
+	"Island":   "U",
+	"Swamp":    "B",
+	"Mountain": "R",
+	"Forest":   "G",
+}
+
+var (
+	reAddMana      = regexp.MustCompile(`add ([^.]*)`)
+	reManaSymbol   = regexp.MustCompile(`\{([wubrg])\}`)
+	reAnyColor     = regexp.MustCompile(`mana of any (?:one )?(?:color|type)`)
+	reFetch        = regexp.MustCompile(`search your library for (?:an? |up to \w+ )([^.]*?) cards?`)
+	reFetchAnyLand = regexp.MustCompile(`\bland\b`)
+)
+
+// landColors returns the colors a land can produce. Lands that can make any
+// color, or fetch any basic, produce all five.
+func landColors(c types.Card) []string {
+	set := map[string]bool{}
+	for _, st := range c.SubTypes {
+		if col, ok := basicLandColors[st]; ok {
+			set[col] = true
+		}
+	}
+	// Basics hydrated without oracle data still carry their name. Match it
+	// exactly: plenty of nonbasics, like Karplusan Forest, end in one.
+	if types.IsBasic(c.Name) {
+		if col, ok := basicLandColors[strings.TrimPrefix(c.Name, "Snow-Covered ")]; ok {
+			set[col] = true
+		}
+	}
+
+	text := strings.ToLower(c.OracleText)
+	if reAnyColor.MatchString(text) {
+		return []string{"W", "U", "B", "R", "G"}
+	}
+	for _, m := range reAddMana.FindAllStringSubmatch(text, -1) {
+		for _, sym := range reManaSymbol.FindAllStringSubmatch(m[1], -1) {
+			set[strings.ToUpper(sym[1])] = true
+		}
+	}
+	for _, m := range reFetch.FindAllStringSubmatch(text, -1) {
+		// "a basic land card" finds whichever color the deck needs.
+		if reFetchAnyLand.MatchString(m[1]) {
+			return []string{"W", "U", "B", "R", "G"}
+		}
+		for name, col := range basicLandColors {
+			if strings.Contains(m[1], strings.ToLower(name)) {
+				set[col] = true
+			}
+		}
+	}
+
+	var out []string
+	for _, col := range []string{"W", "U", "B", "R", "G"} {
+		if set[col] {
+			out = append(out, col)
+		}
+	}
+	return out
+}
+
+// DeckManaBase is the mana analysis of one deck.
+type DeckManaBase struct {
+	DraftID string `json:"draft_id"`
+	Player  string `json:"player"`
+	Cards   int    `json:"cards"`
+	Lands   int    `json:"lands"`
+
+	// Sources counts the lands that can produce each color. A dual counts
+	// toward both of its colors.
+	Sources map[string]int `json:"sources"`
+
+	// Fixers are the lands producing more than one color.
+	Fixers []string `json:"fixers"`
+
+	PipsByTurn []TurnPips          `json:"pips_by_turn"`
+	Colors     []*ColorRequirement `json:"colors"`
+	Spells     []*SpellCastOdds    `json:"spells"`
+
+	// AvgOnCurve is the mean on-curve cast chance across the deck's spells.
+	AvgOnCurve float64 `json:"avg_on_curve"`
+
+	// UnderSupportedSplash is set when any splash color has too few sources.
+	UnderSupportedSplash bool `json:"under_supported_splash"`
+}
+
+// TurnPips totals the colored pips of the spells castable on a turn, i.e.
+// those whose mana value is that turn number. Zero-cost spells count on turn 1.
+type TurnPips struct {
+	Turn   int            `json:"turn"`
+	Spells int            `json:"spells"`
+	Pips   map[string]int `json:"pips"`
+}
+
+type ColorRequirement struct {
+	Color string `json:"color"`
+
+	// Spells is the number of spells with at least one pip of the color, and
+	// MaxPips the heaviest single requirement among them.
+	Spells  int `json:"spells"`
+	Pips    int `json:"pips"`
+	MaxPips int `json:"max_pips"`
+	Sources int `json:"sources"`
+
+	Splash         bool `json:"splash"`
+	UnderSupported bool `json:"under_supported"`
+}
+
+type SpellCastOdds struct {
+	Name string         `json:"name"`
+	CMC  int            `json:"cmc"`
+	Pips map[string]int `json:"pips"`
+
+	// OnCurve is the chance, on the play, of having the lands and colors to
+	// cast the spell on the turn matching its mana value.
+	OnCurve float64 `json:"on_curve"`
+}
+
+// analyzeManaBase computes the mana analysis for a deck. Cube cards, when
+// present, fill in oracle text the deck's own copies lack.
+func analyzeManaBase(d *storage.Deck, cubeCards map[string]types.Card) *DeckManaBase {
+	mb := &DeckManaBase{
+		DraftID: d.Metadata.DraftID,
+		Player:  d.Player,
+		Cards:   len(d.Mainboard),
+		Sources: map[string]int{},
+		Fixers:  []string{},
+		Spells:  []*SpellCastOdds{},
+	}
+
+	var spells []types.Card
+	for _, card := range d.Mainboard {
+		c := card
+		if cc, ok := cubeCards[card.Name]; ok {
+			c = cc
+		}
+		if !c.IsLand() {
+			spells = append(spells, c)
+			continue
+		}
+		mb.Lands++
+		colors := landColors(c)
+		for _, col := range colors {
+			mb.Sources[col]++
+		}
+		if len(colors) > 1 {
+			mb.Fixers = append(mb.Fixers, c.Name)
+		}
+	}
+
+	turns := map[int]*TurnPips{}
+	reqs := map[string]*ColorRequirement{}
+	sum := 0.0
+	for _, c := range spells {
+		pips := c.ColorPips()
+		turn := max(c.CMC, 1)
+		tp, ok := turns[turn]
+		if !ok {
+			tp = &TurnPips{Turn: turn, Pips: map[string]int{}}
+			turns[turn] = tp
+		}
+		tp.Spells++
+		for col, n := range pips {
+			tp.Pips[col] += n
+			req, ok := reqs[col]
+			if !ok {
+				req = &ColorRequirement{Color: col, Sources: mb.Sources[col]}
+				reqs[col] = req
+			}
+			req.Spells++
+			req.Pips += n
+			req.MaxPips = max(req.MaxPips, n)
+		}
+
+		odds := castOnCurve(mb.Cards, mb.Lands, turn, pips, mb.Sources)
+		sum += odds
+		mb.Spells = append(mb.Spells, &SpellCastOdds{Name: c.Name, CMC: c.CMC, Pips: pips, OnCurve: round3(odds)})
+	}
+	if len(spells) > 0 {
+		mb.AvgOnCurve = round3(sum / float64(len(spells)))
+	}
+
+	for _, tp := range turns {
+		mb.PipsByTurn = append(mb.PipsByTurn, *tp)
+	}
+	sort.Slice(mb.PipsByTurn, func(i, j int) bool { return mb.PipsByTurn[i].Turn < mb.PipsByTurn[j].Turn })
+
+	// A color is a splash when it's a small minority of a multicolor deck.
+	most := 0
+	for _, req := range reqs {
+		most = max(most, req.Spells)
+	}
+	for _, col := range []string{"W", "U", "B", "R", "G"} {
+		req, ok := reqs[col]
+		if !ok {
+			continue
+		}
+		req.Splash = isSplash(req.Spells, most, len(reqs))
+		req.UnderSupported = req.Splash && req.Sources < splashMinSources
+		if req.UnderSupported {
+			mb.UnderSupportedSplash = true
+		}
+		mb.Colors = append(mb.Colors, req)
+	}
+
+	sort.SliceStable(mb.Spells, func(i, j int) bool { return mb.Spells[i].OnCurve < mb.Spells[j].OnCurve })
+	return mb
+}
+
+// castOnCurve is the chance of casting a spell on the given turn, on the play:
+// at least turn lands and at least the pips of each color among the 6+turn
+// cards seen by then. Lands are drawn without replacement, so each color's
+// requirement is an exact two-category hypergeometric joined with the land
+// drop; requirements across colors are treated as independent given the land
+// drop, which slightly overstates the odds for gold cards.
+func castOnCurve(deckSize, lands, turn int, pips map[string]int, sources map[string]int) float64 {
+	if deckSize == 0 {
+		return 0
+	}
+	seen := min(6+turn, deckSize)
+	pLands := atLeastHypergeometric(deckSize, lands, 0, seen, 0, turn)
+	if pLands == 0 {
+		return 0
+	}
+	p := pLands
+	for col, n := range pips {
+		p *= atLeastHypergeometric(deckSize, lands, sources[col], seen, n, turn) / pLands
+	}
+	return p
+}
+
+// atLeastHypergeometric is the chance that n cards drawn from a deck of size
+// N holding L lands, K of which make the color, include at least k of those K
+// and at least m lands overall.
+func atLeastHypergeometric(N, L, K, n, k, m int) float64 {
+	total := logChoose(N, n)
+	p := 0.0
+	for i := k; i <= K && i <= n; i++ {
+		for j := max(0, m-i); j <= L-K && i+j <= n; j++ {
+			rest := n - i - j
+			if rest > N-L {
+				continue
+			}
+			p += math.Exp(logChoose(K, i) + logChoose(L-K, j) + logChoose(N-L, rest) - total)
+		}
+	}
+	return math.Min(p, 1)
+}
+
+func logChoose(n, k int) float64 {
+	if k < 0 || k > n {
+		return math.Inf(-1)
+	}
+	a, _ := math.Lgamma(float64(n + 1))
+	b, _ := math.Lgamma(float64(k + 1))
+	c, _ := math.Lgamma(float64(n - k + 1))
+	return a - b - c
+}
+
+func round3(f float64) float64 {
+	return math.Round(f*1000) / 1000
+}
+
+func DeckManaBaseHandler() http.Handler {
+	return &deckManaBaseHandler{store: storage.NewFileDeckStoreWithCache()}
+}
+
+type deckManaBaseHandler struct {
+	store storage.DeckStorage
+}
+
+func (h *deckManaBaseHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	draftID := r.PathValue("draft_id")
+	player := r.PathValue("player")
+	logrus.WithFields(logrus.Fields{"draft_id": draftID, "player": player}).Info("/api/stats/manabase/deck")
+
+	cubeID := server.CubeFromRequest(r)
+	all, err := h.store.List(cubeID, &storage.DecksRequest{})
+	if err != nil {
+		http.Error(rw, "could not load decks", http.StatusInternalServerError)
+		return
+	}
+	idx := slices.IndexFunc(all, func(d *storage.Deck) bool {
+		return d.Metadata.DraftID == draftID && strings.EqualFold(d.Player, player)
+	})
+	if idx < 0 {
+		http.Error(rw, "Deck not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(rw, analyzeManaBase(all[idx], loadCubeCards(cubeID)))
+}
+
+// FixingReport is the API response for /api/stats/manabase: how mana quality
+// relates to win rate across the cube, and how each fixing land performs.
+type FixingReport struct {
+	Decks int `json:"decks"`
+
+	// AvgFixers is the mean number of multicolor lands per deck, and
+	// AvgOnCurve the mean on-curve cast chance.
+	AvgFixers  float64 `json:"avg_fixers"`
+	AvgOnCurve float64 `json:"avg_on_curve"`
+
+	// UnderSupportedShare is the percentage of splashing decks whose splash
+	// has too few sources.
+	UnderSupportedShare float64 `json:"under_supported_share"`
+
+	ByOnCurve []*FixingBucket `json:"by_on_curve"`
+	ByFixers  []*FixingBucket `json:"by_fixers"`
+	BySplash  []*FixingBucket `json:"by_splash"`
+
+	// Lands covers every fixing land in the cube, including ones no deck has
+	// played.
+	Lands []*FixingLand `json:"lands"`
+}
+
+type FixingBucket struct {
+	Record
+	Label string `json:"label"`
+	Decks int    `json:"decks"`
+}
+
+type FixingLand struct {
+	Record
+	Name   string   `json:"name"`
+	Colors []string `json:"colors"`
+	Decks  int      `json:"decks"`
+}
+
+func FixingReportHandler() http.Handler {
+	return &fixingReportHandler{store: storage.NewFileDeckStoreWithCache()}
+}
+
+type fixingReportHandler struct {
+	store storage.DeckStorage
+}
+
+func (h *fixingReportHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	dr := decks.ParseDecksRequest(r)
+	logrus.WithField("params", dr).Info("/api/stats/manabase")
+
+	cubeID := server.CubeFromRequest(r)
+	allDecks, err := h.store.List(cubeID, dr)
+	if err != nil {
+		http.Error(rw, "could not load decks", http.StatusInternalServerError)
+		return
+	}
+	cubeCards := loadCubeCards(cubeID)
+	writeJSON(rw, fixingReport(allDecks, cubeCards, zForConfidence(query.GetFloat(r, "confidence"))))
+}
+
+// loadCubeCards returns the cube's cards by name, or an empty map when the cube
+// list can't be read.
+func loadCubeCards(cubeID string) map[string]types.Card {
+	cubeCards := make(map[string]types.Card)
+	cube, err := types.LoadCube(fmt.Sprintf("data/%s/cube.json", cubeID))
+	if err == nil {
+		for _, c := range cube.Cards {
+			cubeCards[c.Name] = c
+		}
+	}
+	return cubeCards
+}
+
+func fixingReport(allDecks []*storage.Deck, cubeCards map[string]types.Card, z float64) *FixingReport {
+	report := &FixingReport{}
+
+	onCurve := newBuckets("<70%", "70-80%", "80-90%", "90%+")
+	fixers := newBuckets("0", "1-2", "3-4", "5+")
+	splash := newBuckets("no splash", "supported splash", "under-supported splash")
+	landRecords := map[string]*FixingLand{}
+	for _, c := range cubeCards {
+		if !c.IsLand() {
+			continue
+		}
+		if colors := landColors(c); len(colors) > 1 {
+			landRecords[c.Name] = &FixingLand{Name: c.Name, Colors: colors}
+		}
+	}
+
+	splashing, underSupported := 0, 0
+	sumFixers, sumOnCurve := 0, 0.0
+	for _, d := range allDecks {
+		if len(d.Mainboard) == 0 {
+			continue
+		}
+		mb := analyzeManaBase(d, cubeCards)
+		report.Decks++
+		sumFixers += len(mb.Fixers)
+		sumOnCurve += mb.AvgOnCurve
+
+		switch {
+		case mb.AvgOnCurve < 0.7:
+			onCurve.add("<70%", d)
+		case mb.AvgOnCurve < 0.8:
+			onCurve.add("70-80%", d)
+		case mb.AvgOnCurve < 0.9:
+			onCurve.add("80-90%", d)
+		default:
+			onCurve.add("90%+", d)
+		}
+
+		switch n := len(mb.Fixers); {
+		case n == 0:
+			fixers.add("0", d)
+		case n <= 2:
+			fixers.add("1-2", d)
+		case n <= 4:
+			fixers.add("3-4", d)
+		default:
+			fixers.add("5+", d)
+		}
+
+		hasSplash := slices.ContainsFunc(mb.Colors, func(c *ColorRequirement) bool { return c.Splash })
+		switch {
+		case !hasSplash:
+			splash.add("no splash", d)
+		case mb.UnderSupportedSplash:
+			splashing++
+			underSupported++
+			splash.add("under-supported splash", d)
+		default:
+			splashing++
+			splash.add("supported splash", d)
+		}
+
+		seen := map[string]bool{}
+		for _, name := range mb.Fixers {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			fl, ok := landRecords[name]
+			if !ok {
+				// A fixer that has since left the cube.
+				fl = &FixingLand{Name: name, Colors: landColors(cubeOrDeckCard(name, d, cubeCards))}
+				landRecords[name] = fl
+			}
+			fl.Decks++
+			fl.Add(d)
+		}
+	}
+
+	if report.Decks > 0 {
+		report.AvgFixers = round1(float64(sumFixers) / float64(report.Decks))
+		report.AvgOnCurve = round3(sumOnCurve / float64(report.Decks))
+	}
+	report.UnderSupportedShare = pct(float64(underSupported), float64(splashing))
+	report.ByOnCurve = onCurve.finish(z)
+	report.ByFixers = fixers.finish(z)
+	report.BySplash = splash.finish(z)
+
+	report.Lands = make([]*FixingLand, 0, len(landRecords))
+	for _, fl := range landRecords {
+		fl.Finalize()
+		fl.SetInterval(z)
+		report.Lands = append(report.Lands, fl)
+	}
+	sort.Slice(report.Lands, func(i, j int) bool {
+		a, b := report.Lands[i], report.Lands[j]
+		if a.Decks != b.Decks {
+			return a.Decks > b.Decks
+		}
+		return a.Name < b.Name
+	})
+	return report
+}
+
+func cubeOrDeckCard(name string, d *storage.Deck, cubeCards map[string]types.Card) types.Card {
+	if c, ok := cubeCards[name]; ok {
+		return c
+	}
+	for _, c := range d.Mainboard {
+		if c.Name == name {
+			return c
+		}
+	}
+	return types.Card{Name: name}
+}
+
+// fixingBuckets accumulates deck records under a fixed, ordered set of labels.
+type fixingBuckets struct {
+	order   []string
+	buckets map[string]*FixingBucket
+}
+
+func newBuckets(labels ...string) *fixingBuckets {
+	b := &fixingBuckets{order: labels, buckets: map[string]*FixingBucket{}}
+	for _, l := range labels {
+		b.buckets[l] = &FixingBucket{Label: l}
+	}
+	return b
+}
+
+func (b *fixingBuckets) add(label string, d *storage.Deck) {
+	fb := b.buckets[label]
+	fb.Decks++
+	fb.Add(d)
+}
+
+func (b *fixingBuckets) finish(z float64) []*FixingBucket {
+	out := make([]*FixingBucket, 0, len(b.order))
+	for _, l := range b.order {
+		fb := b.buckets[l]
+		fb.Finalize()
+		fb.SetInterval(z)
+		out = append(out, fb)
+	}
+	return out
+}
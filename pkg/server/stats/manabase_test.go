@@ -0,0 +1,125 @@
+package stats
+
+import (
+	"testing"
+
+	"github.com/caseydavenport/cube-tools/pkg/storage"
+	"github.com/caseydavenport/cube-tools/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLandColors(t *testing.T) {
+	tests := []struct {
+		name string
+		card types.Card
+		want []string
+	}{
+		{"basic by name", types.Card{Name: "Island", Types: []string{"Basic", "Land"}}, []string{"U"}},
+		{"snow basic by name", types.Card{Name: "Snow-Covered Swamp"}, []string{"B"}},
+		{"nonbasic named like a basic", types.Card{Name: "Karplusan Forest", Types: []string{"Land"}}, nil},
+		{"shock land subtypes", types.Card{Name: "Watery Grave", Types: []string{"Land"}, SubTypes: []string{"Island", "Swamp"}}, []string{"U", "B"}},
+		{"pain land", types.Card{Name: "Shivan Reef", OracleText: "{T}: Add {C}.\n{T}: Add {U} or {R}. Shivan Reef deals 1 damage to you."}, []string{"U", "R"}},
+		{"fetch land", types.Card{Name: "Flooded Strand", OracleText: "{T}, Pay 1 life, Sacrifice Flooded Strand: Search your library for a Plains or Island card, put it onto the battlefield, then shuffle."}, []string{"W", "U"}},
+		{"basic fetch", types.Card{Name: "Evolving Wilds", OracleText: "{T}, Sacrifice Evolving Wilds: Search your library for a basic land card, put it onto the battlefield tapped, then shuffle."}, []string{"W", "U", "B", "R", "G"}},
+		{"any color", types.Card{Name: "City of Brass", OracleText: "{T}: Add one mana of any color."}, []string{"W", "U", "B", "R", "G"}},
+		{"colorless", types.Card{Name: "Wasteland", OracleText: "{T}: Add {C}."}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, landColors(tt.card))
+		})
+	}
+}
+
+func TestAtLeastHypergeometric(t *testing.T) {
+	// One of 7 sources in an opening 7 from 40.
+	assert.InDelta(t, 0.7709, atLeastHypergeometric(40, 17, 7, 7, 1, 0), 0.0001)
+	// Two lands and one source among 8 cards.
+	assert.InDelta(t, 0.7971, atLeastHypergeometric(40, 17, 7, 8, 1, 2), 0.0001)
+	// No sources, no chance.
+	assert.Equal(t, 0.0, atLeastHypergeometric(40, 17, 0, 8, 1, 2))
+}
+
+// manaDeck builds a 40-card deck of the given spells and lands, padded with
+// colorless filler.
+func manaDeck(spells []types.Card, lands []types.Card, games []types.Game) *storage.Deck {
+	board := append(append([]types.Card{}, spells...), lands...)
+	for len(board) < 40 {
+		board = append(board, types.Card{Name: "Filler", ManaCost: "{2}", CMC: 2})
+	}
+	return makePivotDeck("Alice", "d1", "2025-01-01", []string{"U", "R"}, "", board, games)
+}
+
+func repeat(c types.Card, n int) []types.Card {
+	out := make([]types.Card, n)
+	for i := range out {
+		out[i] = c
+	}
+	return out
+}
+
+func TestAnalyzeManaBase(t *testing.T) {
+	island := types.Card{Name: "Island", Types: []string{"Basic", "Land"}}
+	reef := types.Card{Name: "Shivan Reef", Types: []string{"Land"}, OracleText: "{T}: Add {U} or {R}."}
+	counter := types.Card{Name: "Counterspell", ManaCost: "{U}{U}", CMC: 2}
+	bolt := types.Card{Name: "Lightning Bolt", ManaCost: "{R}", CMC: 1}
+
+	spells := append(repeat(counter, 10), bolt, bolt)
+	lands := append(repeat(island, 16), reef)
+	mb := analyzeManaBase(manaDeck(spells, lands, nil), nil)
+
+	assert.Equal(t, 17, mb.Lands)
+	assert.Equal(t, map[string]int{"U": 17, "R": 1}, mb.Sources)
+	assert.Equal(t, []string{"Shivan Reef"}, mb.Fixers)
+	require.Len(t, mb.Colors, 2)
+	assert.False(t, mb.Colors[0].Splash, "blue is the main color")
+	assert.True(t, mb.Colors[1].Splash)
+	assert.True(t, mb.Colors[1].UnderSupported, "one red source can't support a splash")
+	assert.True(t, mb.UnderSupportedSplash)
+
+	// The splash card is the least castable, so it sorts first.
+	assert.Equal(t, "Lightning Bolt", mb.Spells[0].Name)
+	assert.Less(t, mb.Spells[0].OnCurve, 0.2)
+	require.Len(t, mb.PipsByTurn, 2)
+	assert.Equal(t, map[string]int{"R": 2}, mb.PipsByTurn[0].Pips)
+	assert.Equal(t, map[string]int{"U": 20}, mb.PipsByTurn[1].Pips)
+}
+
+func TestFixingReport(t *testing.T) {
+	island := types.Card{Name: "Island", Types: []string{"Basic", "Land"}}
+	mountain := types.Card{Name: "Mountain", Types: []string{"Basic", "Land"}}
+	reef := types.Card{Name: "Shivan Reef", Types: []string{"Land"}, OracleText: "{T}: Add {U} or {R}."}
+	grave := types.Card{Name: "Watery Grave", Types: []string{"Land"}, SubTypes: []string{"Island", "Swamp"}}
+	counter := types.Card{Name: "Counterspell", ManaCost: "{U}{U}", CMC: 2}
+	bolt := types.Card{Name: "Lightning Bolt", ManaCost: "{R}", CMC: 1}
+	spells := append(repeat(counter, 10), bolt, bolt)
+
+	won := []types.Game{{Opponent: "Bob", Winner: "Alice"}}
+	lost := []types.Game{{Opponent: "Bob", Winner: "Bob"}}
+	all := []*storage.Deck{
+		// A well-supported splash that wins, and a stretched one that loses.
+		manaDeck(spells, append(append(repeat(island, 12), repeat(mountain, 4)...), reef), won),
+		manaDeck(spells, append(repeat(island, 16), reef), lost),
+	}
+	cubeCards := map[string]types.Card{reef.Name: reef, grave.Name: grave, island.Name: island}
+
+	report := fixingReport(all, cubeCards, 1.28)
+	assert.Equal(t, 2, report.Decks)
+	assert.Equal(t, 1.0, report.AvgFixers)
+	assert.Equal(t, 50.0, report.UnderSupportedShare)
+
+	require.Len(t, report.BySplash, 3)
+	assert.Equal(t, "supported splash", report.BySplash[1].Label)
+	assert.Equal(t, 1, report.BySplash[1].Wins)
+	assert.Equal(t, "under-supported splash", report.BySplash[2].Label)
+	assert.Equal(t, 1, report.BySplash[2].Losses)
+
+	// Every fixer in the cube is listed, played or not.
+	require.Len(t, report.Lands, 2)
+	assert.Equal(t, "Shivan Reef", report.Lands[0].Name)
+	assert.Equal(t, 2, report.Lands[0].Decks)
+	assert.Equal(t, 50.0, report.Lands[0].WinPercent)
+	assert.Equal(t, "Watery Grave", report.Lands[1].Name)
+	assert.Equal(t, 0, report.Lands[1].Decks)
+}
@@ -28,8 +28,20 @@ import (
 // dnaTag is the Cube Cobra tag marking signature build-around cards.
 const dnaTag = "🧬"
 
+// traitDimPrefix marks a composition dimension counting the deck's nonland
+// cards with one oracle trait, e.g. "trait:flying" or "trait:token_maker". See
+// types.IsTrait for the names.
+const traitDimPrefix = "trait:"
+
+// traitOf returns the trait a "trait:" dimension counts.
+func traitOf(dim string) (string, bool) {
+	name, ok := strings.CutPrefix(dim, traitDimPrefix)
+	return name, ok && types.IsTrait(name)
+}
+
 // PivotDimension names a way to key a deck (or its opponent). For color dims,
-// Granularity picks mono/dual/trio and ColorMode picks inclusive/exact/primary.
+// Granularity picks mono/dual/trio and ColorMode picks inclusive, exact,
+// primary, splash or identity (see color_matchups.go).
 type PivotDimension struct {
 	Dim         string `json:"dim"`
 	Granularity int    `json:"granularity"`
@@ -50,6 +62,7 @@ type PivotRequest struct {
 	GroupBy    PivotDimension   `json:"group_by"`
 	SplitBy    PivotDimension   `json:"split_by"`
 	BucketSize int              `json:"bucket_size"`
+	BucketBy   string           `json:"bucket_by"`
 	Predicates []PivotPredicate `json:"predicates"`
 
 	// ExcludePlayers drops these players entirely - both their own decks and
@@ -60,6 +73,13 @@ type PivotRequest struct {
 	// Confidence level for the per-cell win-rate interval (0,1). Defaults to
 	// defaultConfidence. See confidence.go.
 	Confidence float64 `json:"confidence"`
+
+	// macros is the cube's macro archetype taxonomy, from the registry. The
+	// archetype dims key untagged decks by a label naming one of these.
+	macros []string
+
+	// versions maps draft IDs to cube versions, when bucketing time by them.
+	versions map[string]string
 }
 
 // PivotCell is one group×split record. deckSet is internal bookkeeping for the
@@ -100,6 +120,23 @@ func PivotHandler() http.Handler {
 	return &pivotHandler{store: storage.NewFileDeckStoreWithCache()}
 }
 
+// validate checks the parts of the request that can be malformed: the bucket
+// mode and any card query predicates.
+func (req *PivotRequest) validate() error {
+	if err := decks.ValidBucketBy(req.BucketBy); err != nil {
+		return err
+	}
+	for _, p := range req.Predicates {
+		if p.Dim != "card_query" {
+			continue
+		}
+		if _, err := query.Parse(p.Value); err != nil {
+			return fmt.Errorf("invalid card query %q: %v", p.Value, err)
+		}
+	}
+	return nil
+}
+
 type pivotHandler struct {
 	store storage.DeckStorage
 }
@@ -116,8 +153,13 @@ func (h *pivotHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 	logrus.WithField("params", req).Info("/api/stats/pivot")
+	if err := req.validate(); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	cubeID := server.CubeFromRequest(r)
+	req.macros = cubeMacros(cubeID)
 
 	// Cube cards carry the richer oracle text and Tags, so composition dims
 	// prefer them over the deck's own (possibly sparser) card copies.
@@ -138,6 +180,9 @@ func (h *pivotHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.BucketBy == decks.BucketByCubeVersion {
+		req.versions = decks.CubeVersions(cubeID, allDecks)
+	}
 	resp := computePivot(allDecks, &req, cubeCards)
 
 	b, err := json.Marshal(resp)
@@ -153,15 +198,21 @@ func (h *pivotHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 
 func computePivot(allDecks []*storage.Deck, req *PivotRequest, cubeCards map[string]types.Card) *PivotResponse {
 	idx := storage.NewOpponentIndex(allDecks)
+	macros := orDefaultMacros(req.macros)
 
 	// A time dimension needs a stable draft->bucket label map, built over the
 	// whole population so the axis doesn't shift when predicates change.
 	var draftBucket map[string]string
 	if req.GroupBy.Dim == "time" || req.SplitBy.Dim == "time" {
-		draftBucket = buildDraftBuckets(allDecks, req.BucketSize)
+		draftBucket = buildDraftBuckets(allDecks, decks.BucketOptions{
+			Size:     req.BucketSize,
+			Discrete: true,
+			By:       req.BucketBy,
+			Versions: req.versions,
+		})
 	}
 
-	groupKeyer := deckKeyer(req.GroupBy, draftBucket, cubeCards)
+	groupKeyer := deckKeyer(req.GroupBy, draftBucket, cubeCards, macros)
 
 	// The split can be opponent-derived (per game) or deck-derived (constant per
 	// deck). splitLevel tells the loop which path to take.
@@ -172,10 +223,10 @@ func computePivot(allDecks []*storage.Deck, req *PivotRequest, cubeCards map[str
 		// No split; only the overall column.
 	case strings.HasPrefix(req.SplitBy.Dim, "opponent_"):
 		splitLevel = "opponent"
-		splitKeyer = opponentKeyer(req.SplitBy, cubeCards)
+		splitKeyer = opponentKeyer(req.SplitBy, cubeCards, macros)
 	default:
 		splitLevel = "deck"
-		splitKeyer = deckKeyer(req.SplitBy, draftBucket, cubeCards)
+		splitKeyer = deckKeyer(req.SplitBy, draftBucket, cubeCards, macros)
 	}
 
 	excluded := make(map[string]bool, len(req.ExcludePlayers))
@@ -204,7 +255,7 @@ func computePivot(allDecks []*storage.Deck, req *PivotRequest, cubeCards map[str
 		if excluded[strings.ToLower(d.Player)] {
 			continue
 		}
-		if !deckPasses(d, req.Predicates, cubeCards) {
+		if !deckPasses(d, req.Predicates, cubeCards, macros) {
 			continue
 		}
 		groupKeys := groupKeyer(d)
@@ -310,18 +361,18 @@ func granularityOf(dim PivotDimension) int {
 // deckKeyer returns a function mapping a deck to zero or more keys for the given
 // dimension. A deck can produce several keys (inclusive color mode, multiple
 // labels); an empty result drops the deck from that dimension.
-func deckKeyer(dim PivotDimension, draftBucket map[string]string, cubeCards map[string]types.Card) func(*storage.Deck) []string {
+func deckKeyer(dim PivotDimension, draftBucket map[string]string, cubeCards map[string]types.Card, macros []string) func(*storage.Deck) []string {
 	switch dim.Dim {
 	case "color":
 		return func(d *storage.Deck) []string {
-			return colorGroups(d, colorModeOf(dim), granularityOf(dim))
+			return colorGroups(d, colorModeOf(dim), granularityOf(dim), cubeCards)
 		}
 	case "archetype":
 		return func(d *storage.Deck) []string {
-			if d.MacroArchetype == "" {
-				return nil
+			if m := d.MacroIn(macros); m != "" {
+				return []string{m}
 			}
-			return []string{d.MacroArchetype}
+			return nil
 		}
 	case "label":
 		return func(d *storage.Deck) []string { return d.Labels }
@@ -339,12 +390,17 @@ func deckKeyer(dim PivotDimension, draftBucket map[string]string, cubeCards map[
 			}
 			return nil
 		}
-	case "removal", "interaction", "counterspell", "creatures", "multicolor", "lands", "dna", "avg_cmc":
+	case "removal", "interaction", "counterspell", "creatures", "multicolor", "lands", "dna", "two_drops", "avg_cmc":
 		return func(d *storage.Deck) []string {
 			comp := composition(d, cubeCards)
 			return []string{compBucketLabel(dim.Dim, comp.value(dim.Dim))}
 		}
 	}
+	if _, ok := traitOf(dim.Dim); ok {
+		return func(d *storage.Deck) []string {
+			return []string{compBucketLabel(dim.Dim, composition(d, cubeCards).value(dim.Dim))}
+		}
+	}
 	return func(*storage.Deck) []string { return nil }
 }
 
@@ -352,22 +408,30 @@ func deckKeyer(dim PivotDimension, draftBucket map[string]string, cubeCards map[
 // color identity for opponent_color, composition buckets for the rest. Decks
 // with no recorded mainboard produce no composition key rather than a bogus
 // zero bucket.
-func opponentKeyer(dim PivotDimension, cubeCards map[string]types.Card) func(*storage.Deck) []string {
+func opponentKeyer(dim PivotDimension, cubeCards map[string]types.Card, macros []string) func(*storage.Deck) []string {
 	if dim.Dim == "opponent_color" {
 		return func(opp *storage.Deck) []string {
-			return colorGroups(opp, colorModeOf(dim), granularityOf(dim))
+			return colorGroups(opp, colorModeOf(dim), granularityOf(dim), cubeCards)
 		}
 	}
 	base := strings.TrimPrefix(dim.Dim, "opponent_")
 	switch base {
 	case "archetype":
 		return func(opp *storage.Deck) []string {
-			if opp.MacroArchetype == "" {
+			if m := opp.MacroIn(macros); m != "" {
+				return []string{m}
+			}
+			return nil
+		}
+	case "removal", "interaction", "counterspell", "creatures", "multicolor", "lands", "dna", "two_drops", "avg_cmc":
+		return func(opp *storage.Deck) []string {
+			if len(opp.Mainboard) == 0 {
 				return nil
 			}
-			return []string{opp.MacroArchetype}
+			return []string{compBucketLabel(base, composition(opp, cubeCards).value(base))}
 		}
-	case "removal", "interaction", "counterspell", "creatures", "multicolor", "lands", "dna", "avg_cmc":
+	}
+	if _, ok := traitOf(base); ok {
 		return func(opp *storage.Deck) []string {
 			if len(opp.Mainboard) == 0 {
 				return nil
@@ -379,13 +443,12 @@ func opponentKeyer(dim PivotDimension, cubeCards map[string]types.Card) func(*st
 }
 
 // buildDraftBuckets maps each draft ID to a bucket label (the bucket's start
-// date), reusing the shared discrete bucketing.
-func buildDraftBuckets(allDecks []*storage.Deck, bucketSize int) map[string]string {
-	if bucketSize < 1 {
-		bucketSize = 1
-	}
+// date), reusing the shared discrete bucketing. Start dates rather than
+// period or version names keep the time axis sorting chronologically.
+func buildDraftBuckets(allDecks []*storage.Deck, opts decks.BucketOptions) map[string]string {
+	opts.Size = max(opts.Size, 1)
 	out := map[string]string{}
-	for _, b := range decks.DeckBuckets(allDecks, bucketSize, true) {
+	for _, b := range decks.DeckBucketsBy(allDecks, opts) {
 		label := b.Start()
 		for _, draft := range b.Drafts {
 			out[draft.Name] = label
@@ -404,7 +467,11 @@ type deckComposition struct {
 	Multicolor   int
 	Lands        int
 	DNA          int
+	TwoDrops     int
 	AvgCMC       float64
+
+	// Traits counts nonland cards by oracle trait, for "trait:" dims.
+	Traits map[string]int
 }
 
 func (c deckComposition) value(dim string) float64 {
@@ -423,14 +490,19 @@ func (c deckComposition) value(dim string) float64 {
 		return float64(c.Lands)
 	case "dna":
 		return float64(c.DNA)
+	case "two_drops":
+		return float64(c.TwoDrops)
 	case "avg_cmc":
 		return c.AvgCMC
 	}
+	if name, ok := traitOf(dim); ok {
+		return float64(c.Traits[name])
+	}
 	return 0
 }
 
 func composition(d *storage.Deck, cubeCards map[string]types.Card) deckComposition {
-	comp := deckComposition{}
+	comp := deckComposition{Traits: map[string]int{}}
 	cmcSum, nonland := 0, 0
 	for _, card := range d.Mainboard {
 		c := card
@@ -455,6 +527,9 @@ func composition(d *storage.Deck, cubeCards map[string]types.Card) deckCompositi
 		if c.IsCreature() {
 			comp.Creatures++
 		}
+		if c.CMC == 2 {
+			comp.TwoDrops++
+		}
 		if len(c.Colors) >= 2 {
 			comp.Multicolor++
 		}
@@ -464,6 +539,9 @@ func composition(d *storage.Deck, cubeCards map[string]types.Card) deckCompositi
 				break
 			}
 		}
+		for _, t := range c.Traits() {
+			comp.Traits[t]++
+		}
 	}
 	if nonland > 0 {
 		comp.AvgCMC = float64(cmcSum) / float64(nonland)
@@ -525,16 +603,16 @@ func compBucketLabel(dim string, v float64) string {
 }
 
 // deckPasses reports whether a deck satisfies every predicate (implicit AND).
-func deckPasses(d *storage.Deck, preds []PivotPredicate, cubeCards map[string]types.Card) bool {
+func deckPasses(d *storage.Deck, preds []PivotPredicate, cubeCards map[string]types.Card, macros []string) bool {
 	for _, p := range preds {
-		if !predicatePasses(d, p, cubeCards) {
+		if !predicatePasses(d, p, cubeCards, macros) {
 			return false
 		}
 	}
 	return true
 }
 
-func predicatePasses(d *storage.Deck, p PivotPredicate, cubeCards map[string]types.Card) bool {
+func predicatePasses(d *storage.Deck, p PivotPredicate, cubeCards map[string]types.Card, macros []string) bool {
 	switch p.Dim {
 	case "color":
 		colors := deckColorSet(d)
@@ -556,7 +634,7 @@ func predicatePasses(d *storage.Deck, p PivotPredicate, cubeCards map[string]typ
 			return true
 		}
 	case "archetype":
-		return compareString(d.MacroArchetype, p.Op, p.Value)
+		return compareString(d.MacroIn(macros), p.Op, p.Value)
 	case "player":
 		return compareString(d.Player, p.Op, p.Value)
 	case "label":
@@ -577,7 +655,7 @@ func predicatePasses(d *storage.Deck, p PivotPredicate, cubeCards map[string]typ
 			return !m
 		}
 		return m
-	case "removal", "interaction", "counterspell", "creatures", "lands", "dna", "avg_cmc":
+	case "removal", "interaction", "counterspell", "creatures", "lands", "dna", "two_drops", "avg_cmc":
 		got := composition(d, cubeCards).value(p.Dim)
 		want, err := strconv.ParseFloat(p.Value, 64)
 		if err != nil {
@@ -585,6 +663,13 @@ func predicatePasses(d *storage.Deck, p PivotPredicate, cubeCards map[string]typ
 		}
 		return compareNumber(got, p.Op, want)
 	}
+	if _, ok := traitOf(p.Dim); ok {
+		want, err := strconv.ParseFloat(p.Value, 64)
+		if err != nil {
+			return true
+		}
+		return compareNumber(composition(d, cubeCards).value(p.Dim), p.Op, want)
+	}
 	return true
 }
 
@@ -633,8 +718,12 @@ func orderRows(rows map[string]*PivotRow, dim PivotDimension) []*PivotRow {
 	for _, r := range rows {
 		out = append(out, r)
 	}
-	switch dim.Dim {
-	case "color", "time", "removal", "interaction", "counterspell", "creatures", "lands", "dna", "avg_cmc":
+	dimName := dim.Dim
+	if _, ok := traitOf(dimName); ok {
+		dimName = "trait"
+	}
+	switch dimName {
+	case "color", "time", "removal", "interaction", "counterspell", "creatures", "lands", "dna", "two_drops", "avg_cmc", "trait":
 		keys := make([]string, len(out))
 		for i, r := range out {
 			keys[i] = r.Key
@@ -667,13 +756,16 @@ func sortKeys(keys []string, dim string) {
 // alphabetically. Opponent dims rank the same as their subject counterparts.
 func keyRanks(keys []string, dim string) map[string]float64 {
 	dim = strings.TrimPrefix(dim, "opponent_")
+	if _, ok := traitOf(dim); ok {
+		dim = "trait"
+	}
 	rank := map[string]float64{}
 	switch dim {
 	case "color", "opponent_color":
 		for _, k := range keys {
 			rank[k] = colorRank(k)
 		}
-	case "removal", "interaction", "counterspell", "creatures", "lands", "dna", "avg_cmc":
+	case "removal", "interaction", "counterspell", "creatures", "lands", "dna", "two_drops", "avg_cmc", "trait":
 		for _, k := range keys {
 			rank[k] = leadingNumber(k)
 		}
@@ -688,13 +780,19 @@ func keyRanks(keys []string, dim string) map[string]float64 {
 }
 
 // colorRank turns a color key like "WU" into a sortable number: shorter
-// identities first, then WUBRG order within a length.
+// identities first, then WUBRG order within a length. A splash ("RG+B") sorts
+// just after its main colors.
 func colorRank(key string) float64 {
-	r := float64(len(key)) * 100000
-	for _, c := range key {
+	base, splash, _ := strings.Cut(key, "+")
+	r := float64(len(base)) * 100000
+	for _, c := range base {
 		r = r*10 + float64(strings.IndexRune("WUBRG", c)+1)
 	}
-	return r
+	s := 0.0
+	for _, c := range splash {
+		s = s*10 + float64(strings.IndexRune("WUBRG", c)+1)
+	}
+	return r + s/100000
 }
 
 func leadingNumber(key string) float64 {
@@ -178,6 +178,26 @@ func TestPivot_TimeGrouping(t *testing.T) {
 	assert.Equal(t, "2025-02-01", resp.Rows[1].Key)
 }
 
+func TestPivot_TimeGroupingByQuarter(t *testing.T) {
+	decks := []*storage.Deck{
+		makePivotDeck("Alice", "2025-01-01_a", "2025-01-01", []string{"R"}, "", nil,
+			[]types.Game{{Opponent: "Bob", Winner: "Alice"}}),
+		makePivotDeck("Carol", "2025-02-01_b", "2025-02-01", []string{"R"}, "", nil,
+			[]types.Game{{Opponent: "Dave", Winner: "Dave"}}),
+		makePivotDeck("Erin", "2025-04-01_c", "2025-04-01", []string{"R"}, "", nil,
+			[]types.Game{{Opponent: "Dave", Winner: "Erin"}}),
+	}
+	resp := computePivot(decks, &PivotRequest{GroupBy: dim("time", 0, ""), BucketBy: "quarter"}, nil)
+
+	// Both first-quarter drafts land in one row keyed by its start date.
+	require.Len(t, resp.Rows, 2)
+	q1 := rowByKey(resp, "2025-01-01")
+	require.NotNil(t, q1)
+	assert.Equal(t, 1, q1.Cells[""].Wins)
+	assert.Equal(t, 1, q1.Cells[""].Losses)
+	require.NotNil(t, rowByKey(resp, "2025-04-01"))
+}
+
 // Excluding a player drops both their decks and every game played against them,
 // so a heavy outlier can be removed from the aggregate entirely.
 func TestPivot_ExcludePlayers(t *testing.T) {
@@ -213,6 +233,30 @@ func TestPivot_ExcludePlayers(t *testing.T) {
 	assert.Equal(t, 0, u.Cells[""].Losses)
 }
 
+func TestPivot_ConfiguredMacros(t *testing.T) {
+	decks := []*storage.Deck{
+		makePivotDeck("Alice", "d1", "2025-01-01", nil, "Combo", nil, []types.Game{{Opponent: "Bob", Winner: "Alice"}}),
+		makePivotDeck("Bob", "d1", "2025-01-01", nil, "", nil, []types.Game{{Opponent: "Alice", Winner: "Alice"}}),
+	}
+	decks[1].Labels = []string{"ramp"}
+
+	// The defaults don't know ramp, so the untagged deck drops out.
+	resp := computePivot(decks, &PivotRequest{GroupBy: dim("archetype", 0, "")}, nil)
+	assert.Len(t, resp.Rows, 1)
+	require.NotNil(t, rowByKey(resp, "combo"))
+
+	resp = computePivot(decks, &PivotRequest{
+		GroupBy: dim("archetype", 0, ""),
+		SplitBy: dim("opponent_archetype", 0, ""),
+		macros:  []string{"combo", "ramp"},
+	}, nil)
+	combo := rowByKey(resp, "combo")
+	require.NotNil(t, combo)
+	require.NotNil(t, combo.Cells["ramp"])
+	assert.Equal(t, 1, combo.Cells["ramp"].Wins)
+	require.NotNil(t, rowByKey(resp, "ramp"))
+}
+
 // composition counts nonland cards by classifier and reports the land count and
 // average mana value separately.
 func TestComposition(t *testing.T) {
@@ -233,6 +277,38 @@ func TestComposition(t *testing.T) {
 	assert.InDelta(t, 2.67, comp.AvgCMC, 0.01)
 }
 
+// Trait dims count nonland cards by oracle trait, as group keys and as
+// numeric predicates.
+func TestPivot_TraitDimension(t *testing.T) {
+	flyer := types.Card{Name: "Bird", Types: []string{"Creature"}, OracleText: "Flying"}
+	bear := types.Card{Name: "Bear", Types: []string{"Creature"}, OracleText: "A bear."}
+
+	decks := []*storage.Deck{
+		makePivotDeck("Alice", "d1", "2025-01-01", []string{"U"}, "tempo",
+			[]types.Card{flyer, flyer, flyer, bear},
+			[]types.Game{{Opponent: "Bob", Winner: "Alice"}}),
+		makePivotDeck("Bob", "d1", "2025-01-01", []string{"G"}, "midrange",
+			[]types.Card{bear, bear},
+			[]types.Game{{Opponent: "Alice", Winner: "Alice"}}),
+	}
+	resp := computePivot(decks, &PivotRequest{GroupBy: dim("trait:flying", 0, "")}, nil)
+	require.Len(t, resp.Rows, 2)
+	assert.Equal(t, "0-2", resp.Rows[0].Key)
+	assert.Equal(t, "3-5", resp.Rows[1].Key)
+	assert.Equal(t, 1, resp.Rows[1].Cells[""].Wins)
+
+	resp = computePivot(decks, &PivotRequest{
+		GroupBy:    dim("archetype", 0, ""),
+		Predicates: []PivotPredicate{{Dim: "trait:flying", Op: "gte", Value: "1"}},
+	}, nil)
+	require.Len(t, resp.Rows, 1)
+	assert.Equal(t, "tempo", resp.Rows[0].Key)
+
+	// Unknown traits key nothing.
+	resp = computePivot(decks, &PivotRequest{GroupBy: dim("trait:shiny", 0, "")}, nil)
+	assert.Empty(t, resp.Rows)
+}
+
 // Opponent composition and archetype splits key each game by the opponent
 // deck's bucket, and skip opponents with no recorded mainboard rather than
 // bucketing them at zero.
@@ -0,0 +1,365 @@
+package stats
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/caseydavenport/cube-tools/pkg/server"
+	"github.com/caseydavenport/cube-tools/pkg/server/decks"
+	"github.com/caseydavenport/cube-tools/pkg/server/query"
+	"github.com/caseydavenport/cube-tools/pkg/storage"
+	"github.com/caseydavenport/cube-tools/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// PlayerProfileResponse is the API response for
+// /api/stats/players/{player}/profile. Profile describes how the player drafts
+// and builds; Cube is the same metrics over every deck in range, so each
+// number has something to be compared against.
+type PlayerProfileResponse struct {
+	Player  string              `json:"player"`
+	Profile StyleProfile        `json:"profile"`
+	Cube    StyleProfile        `json:"cube"`
+	Similar []*PlayerSimilarity `json:"similar"`
+}
+
+// StyleProfile summarizes drafting and deckbuilding habits over a set of decks.
+// Per-deck metrics are averages across those decks.
+type StyleProfile struct {
+	Decks int `json:"decks"`
+
+	// CommitPick is the average overall pick (1-based) from which the colors the
+	// deck ended up in led the pool for the rest of the draft, and
+	// CommitPercent is the same point as a percentage of the draft. Both come
+	// from draft logs, so they're zero when none of the decks have one;
+	// CommitDrafts says how many did.
+	CommitPick    float64 `json:"commit_pick"`
+	CommitPercent float64 `json:"commit_percent"`
+	CommitDrafts  int     `json:"commit_drafts"`
+
+	// AvgPickElo is the mean pick Elo of every card in the drafted pools, not
+	// just the mainboards.
+	AvgPickElo float64 `json:"avg_pick_elo"`
+
+	AvgCreatures float64 `json:"avg_creatures"`
+	AvgCMC       float64 `json:"avg_cmc"`
+
+	// Curve is the average number of nonland mainboard cards per mana value.
+	// "1" includes zero-drops and "6+" everything above five.
+	Curve map[string]float64 `json:"curve"`
+
+	// SplashPercent is the share of decks that play a third color as a splash
+	// on top of a primary pair.
+	SplashPercent float64 `json:"splash_percent"`
+
+	// AvgRemoval is spot removal per deck, and RemovalDensity the share of
+	// nonland mainboard cards that are spot removal.
+	AvgRemoval     float64 `json:"avg_removal"`
+	RemovalDensity float64 `json:"removal_density"`
+}
+
+// PlayerSimilarity is the cosine similarity between two players' mainboard
+// card choices, from 0 (nothing in common) to 1 (identical picks).
+type PlayerSimilarity struct {
+	Player     string  `json:"player"`
+	Similarity float64 `json:"similarity"`
+}
+
+// curveBuckets are the Curve keys, in display order.
+var curveBuckets = []string{"1", "2", "3", "4", "5", "6+"}
+
+func curveBucket(cmc int) string {
+	switch {
+	case cmc <= 1:
+		return "1"
+	case cmc >= 6:
+		return "6+"
+	}
+	return fmt.Sprintf("%d", cmc)
+}
+
+func PlayerProfileHandler() http.Handler {
+	return &playerProfileHandler{store: storage.NewFileDeckStoreWithCache()}
+}
+
+type playerProfileHandler struct {
+	store storage.DeckStorage
+}
+
+func (h *playerProfileHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	player := r.PathValue("player")
+	dr := decks.ParseDecksRequest(r)
+	logrus.WithFields(logrus.Fields{"player": player, "params": dr}).Info("/api/stats/players/profile")
+
+	// Load everyone's decks: the cube baseline, pick Elo, and similarity all
+	// need them.
+	dr.Player = ""
+	cubeID := server.CubeFromRequest(r)
+	allDecks, err := h.store.List(cubeID, dr)
+	if err != nil {
+		http.Error(rw, "could not load decks", http.StatusInternalServerError)
+		return
+	}
+
+	var playerDecks []*storage.Deck
+	for _, d := range allDecks {
+		if strings.EqualFold(d.Player, player) {
+			playerDecks = append(playerDecks, d)
+		}
+	}
+	if len(playerDecks) == 0 {
+		http.Error(rw, fmt.Sprintf("no decks for player %q", player), http.StatusNotFound)
+		return
+	}
+
+	cubeCards := make(map[string]types.Card)
+	cube, err := types.LoadCube(fmt.Sprintf("data/%s/cube.json", cubeID))
+	if err == nil {
+		for _, c := range cube.Cards {
+			cubeCards[c.Name] = c
+		}
+	}
+
+	// Draft logs are optional; drafts without one just don't contribute to
+	// commitment timing.
+	logs := make(map[string]*types.DraftLog)
+	for _, d := range allDecks {
+		id := d.Metadata.DraftID
+		if _, ok := logs[id]; ok || id == "" {
+			continue
+		}
+		log, err := types.LoadDraftLog(fmt.Sprintf("data/%s/%s/draft-log.json", cubeID, id))
+		if err != nil {
+			log = nil
+		}
+		logs[id] = log
+	}
+
+	limit := query.GetInt(r, "limit")
+	if limit <= 0 {
+		limit = 5
+	}
+
+	overrides, err := loadRemovalOverrides("data", cubeID)
+	if err != nil {
+		logrus.WithError(err).Warn("could not load removal overrides; using heuristics only")
+	}
+
+	elo := PickELOData(allDecks)
+	resp := PlayerProfileResponse{
+		Player:  player,
+		Profile: styleProfile(playerDecks, cubeCards, overrides, elo, logs),
+		Cube:    styleProfile(allDecks, cubeCards, overrides, elo, logs),
+		Similar: similarPlayers(player, allDecks, limit),
+	}
+	writeJSON(rw, resp)
+}
+
+// styleProfile computes the style metrics over the given decks. logs maps a
+// draft ID to its draft log, or nil when the draft has none.
+func styleProfile(ds []*storage.Deck, cubeCards map[string]types.Card, overrides RemovalOverrides, elo map[string]int, logs map[string]*types.DraftLog) StyleProfile {
+	p := StyleProfile{Decks: len(ds), Curve: make(map[string]float64)}
+	for _, b := range curveBuckets {
+		p.Curve[b] = 0
+	}
+	if len(ds) == 0 {
+		return p
+	}
+
+	var creatures, cmc, removal, nonland, splashes float64
+	var eloSum, eloCount float64
+	var commitPick, commitPct float64
+	for _, d := range ds {
+		comp := composition(d, cubeCards)
+		creatures += float64(comp.Creatures)
+		cmc += comp.AvgCMC
+
+		for _, card := range d.Mainboard {
+			c := card
+			if cc, ok := cubeCards[card.Name]; ok {
+				c = cc
+			}
+			if c.IsLand() {
+				continue
+			}
+			nonland++
+			p.Curve[curveBucket(c.CMC)]++
+			if classifyRemoval(c, overrides).Spot {
+				removal++
+			}
+		}
+
+		for _, pool := range [][]types.Card{d.Mainboard, d.Sideboard, d.Pool} {
+			for _, c := range pool {
+				if c.IsBasicLand() {
+					continue
+				}
+				if e, ok := elo[c.Name]; ok {
+					eloSum += float64(e)
+					eloCount++
+				}
+			}
+		}
+
+		if len(d.GetColors()) > 2 && d.PrimaryColorPair() != nil {
+			splashes++
+		}
+
+		if log := logs[d.Metadata.DraftID]; log != nil {
+			if pick, total, ok := commitmentPick(log, d); ok {
+				commitPick += float64(pick)
+				commitPct += 100 * float64(pick) / float64(total)
+				p.CommitDrafts++
+			}
+		}
+	}
+
+	n := float64(len(ds))
+	p.AvgCreatures = round1(creatures / n)
+	p.AvgCMC = math.Round(100*cmc/n) / 100
+	for b, v := range p.Curve {
+		p.Curve[b] = round1(v / n)
+	}
+	p.SplashPercent = pct(splashes, n)
+	p.AvgRemoval = round1(removal / n)
+	p.RemovalDensity = pct(removal, nonland)
+	if eloCount > 0 {
+		p.AvgPickElo = math.Round(eloSum / eloCount)
+	}
+	if p.CommitDrafts > 0 {
+		p.CommitPick = round1(commitPick / float64(p.CommitDrafts))
+		p.CommitPercent = round1(commitPct / float64(p.CommitDrafts))
+	}
+	return p
+}
+
+// commitmentPick walks the deck owner's picks in the draft log and returns the
+// pick (1-based) from which the deck's main colors each had more picked cards
+// than any other color, holding until the end of the draft, along with the
+// total number of picks. ok is false when the player isn't in the log or
+// never settled into the colors they played.
+func commitmentPick(log *types.DraftLog, d *storage.Deck) (int, int, bool) {
+	main := mainColors(d)
+	if len(main) == 0 {
+		return 0, 0, false
+	}
+	var user *types.User
+	for _, u := range log.Users {
+		if strings.EqualFold(u.UserName, d.Player) {
+			user = &u
+			break
+		}
+	}
+	if user == nil || len(user.Picks) == 0 {
+		return 0, 0, false
+	}
+
+	counts := make(map[string]int)
+	committedAt := -1
+	for i, pick := range user.Picks {
+		for _, id := range pick.Picked() {
+			for _, c := range log.CardData[id].Colors {
+				counts[c]++
+			}
+		}
+		if leads(counts, main) {
+			if committedAt < 0 {
+				committedAt = i
+			}
+		} else {
+			committedAt = -1
+		}
+	}
+	if committedAt < 0 {
+		return 0, 0, false
+	}
+	return committedAt + 1, len(user.Picks), true
+}
+
+// mainColors returns the colors a deck is built around: its primary pair when
+// the rest are splashes, otherwise all of its colors.
+func mainColors(d *storage.Deck) []string {
+	if pair := d.PrimaryColorPair(); pair != nil {
+		return pair
+	}
+	return d.GetColors()
+}
+
+// leads reports whether every color in main has strictly more picks than any
+// color outside it.
+func leads(counts map[string]int, main []string) bool {
+	lowest := math.MaxInt
+	for _, c := range main {
+		lowest = min(lowest, counts[c])
+	}
+	if lowest == 0 {
+		return false
+	}
+	for c, n := range counts {
+		if !hasColor(main, c) && n >= lowest {
+			return false
+		}
+	}
+	return true
+}
+
+// similarPlayers ranks the other players by cosine similarity between their
+// mainboard card counts and player's, most similar first. Basic lands are
+// ignored since everyone plays them.
+func similarPlayers(player string, allDecks []*storage.Deck, limit int) []*PlayerSimilarity {
+	vectors := make(map[string]map[string]float64)
+	names := make(map[string]string)
+	for _, d := range allDecks {
+		key := strings.ToLower(d.Player)
+		if _, ok := vectors[key]; !ok {
+			vectors[key] = make(map[string]float64)
+			names[key] = d.Player
+		}
+		for _, c := range d.Mainboard {
+			if !c.IsBasicLand() {
+				vectors[key][c.Name]++
+			}
+		}
+	}
+
+	self := vectors[strings.ToLower(player)]
+	res := []*PlayerSimilarity{}
+	for key, v := range vectors {
+		if key == strings.ToLower(player) {
+			continue
+		}
+		res = append(res, &PlayerSimilarity{
+			Player:     names[key],
+			Similarity: math.Round(1000*cosine(self, v)) / 1000,
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Similarity != res[j].Similarity {
+			return res[i].Similarity > res[j].Similarity
+		}
+		return res[i].Player < res[j].Player
+	})
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res
+}
+
+// cosine returns the cosine similarity of two sparse vectors.
+func cosine(a, b map[string]float64) float64 {
+	var dot, na, nb float64
+	for k, x := range a {
+		dot += x * b[k]
+		na += x * x
+	}
+	for _, y := range b {
+		nb += y * y
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
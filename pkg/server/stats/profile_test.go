@@ -0,0 +1,102 @@
+package stats
+
+import (
+	"testing"
+
+	"github.com/caseydavenport/cube-tools/pkg/storage"
+	"github.com/caseydavenport/cube-tools/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// profileLog builds a one-player draft log where the player takes the cards in
+// order, one per pick.
+func profileLog(player string, picks ...types.DraftCard) *types.DraftLog {
+	log := &types.DraftLog{CardData: map[string]types.DraftCard{}}
+	u := types.User{UserName: player}
+	for i, c := range picks {
+		c.ID = c.Name
+		log.CardData[c.ID] = c
+		u.Picks = append(u.Picks, types.Pick{PickNum: i, Pick: []int{1}, Booster: []string{"other", c.ID}})
+	}
+	log.Users = map[string]types.User{"u1": u}
+	return log
+}
+
+func TestCommitmentPick(t *testing.T) {
+	d := makePivotDeck("alice", "d1", "", []string{"R", "G"}, "", nil, nil)
+	log := profileLog("Alice",
+		types.DraftCard{Name: "a", Colors: []string{"U"}},
+		types.DraftCard{Name: "b", Colors: []string{"R"}},
+		types.DraftCard{Name: "c", Colors: []string{"G"}},
+		types.DraftCard{Name: "d", Colors: []string{"G"}},
+		types.DraftCard{Name: "e"},
+		types.DraftCard{Name: "f", Colors: []string{"R"}},
+	)
+
+	// Green leads from the third pick, but red stays tied with blue until the
+	// sixth.
+	pick, total, ok := commitmentPick(log, d)
+	require.True(t, ok)
+	assert.Equal(t, 6, pick)
+	assert.Equal(t, 6, total)
+
+	// A drift back out of the colors resets the commitment.
+	log = profileLog("alice",
+		types.DraftCard{Name: "a", Colors: []string{"R"}},
+		types.DraftCard{Name: "b", Colors: []string{"G"}},
+		types.DraftCard{Name: "c", Colors: []string{"B"}},
+		types.DraftCard{Name: "d", Colors: []string{"B"}},
+		types.DraftCard{Name: "e", Colors: []string{"R", "G"}},
+		types.DraftCard{Name: "f", Colors: []string{"R", "G"}},
+	)
+	pick, _, ok = commitmentPick(log, d)
+	require.True(t, ok)
+	assert.Equal(t, 6, pick)
+
+	// The player isn't in the log.
+	_, _, ok = commitmentPick(profileLog("bob"), d)
+	assert.False(t, ok)
+}
+
+func TestStyleProfile(t *testing.T) {
+	bolt := types.Card{Name: "Lightning Bolt", Types: []string{"Instant"}, CMC: 1, Colors: []string{"R"},
+		OracleText: "Lightning Bolt deals 3 damage to any target."}
+	bear := types.Card{Name: "Bear", Types: []string{"Creature"}, CMC: 2, Colors: []string{"G"}}
+	giant := types.Card{Name: "Giant", Types: []string{"Creature"}, CMC: 7, Colors: []string{"G"}}
+	mountain := types.Card{Name: "Mountain", Types: []string{"Basic", "Land"}}
+
+	d1 := makePivotDeck("alice", "d1", "", []string{"R", "G"}, "", []types.Card{bolt, bear, giant, mountain}, nil)
+	d2 := makePivotDeck("alice", "d2", "", []string{"G"}, "", []types.Card{bear, bear, mountain}, nil)
+
+	p := styleProfile([]*storage.Deck{d1, d2}, nil, nil, map[string]int{"Lightning Bolt": 1300, "Bear": 1100, "Giant": 1200}, nil)
+	assert.Equal(t, 2, p.Decks)
+	assert.Equal(t, 2.0, p.AvgCreatures)
+	assert.Equal(t, 0.5, p.AvgRemoval)
+	assert.Equal(t, 20.0, p.RemovalDensity)
+	assert.Equal(t, 0.5, p.Curve["1"])
+	assert.Equal(t, 1.5, p.Curve["2"])
+	assert.Equal(t, 0.5, p.Curve["6+"])
+	assert.Equal(t, 0.0, p.Curve["3"])
+	assert.Equal(t, 0.0, p.SplashPercent)
+	assert.Equal(t, 0, p.CommitDrafts)
+	// (1300 + 1100 + 1200 + 1100 + 1100) / 5
+	assert.Equal(t, 1160.0, p.AvgPickElo)
+}
+
+func TestSimilarPlayers(t *testing.T) {
+	card := func(n string) types.Card { return types.Card{Name: n, Types: []string{"Creature"}} }
+	decks := []*storage.Deck{
+		makePivotDeck("alice", "d1", "", nil, "", []types.Card{card("a"), card("b"), card("c")}, nil),
+		makePivotDeck("bob", "d1", "", nil, "", []types.Card{card("a"), card("b"), card("d")}, nil),
+		makePivotDeck("carol", "d1", "", nil, "", []types.Card{card("x"), card("y")}, nil),
+	}
+	sims := similarPlayers("Alice", decks, 5)
+	require.Len(t, sims, 2)
+	assert.Equal(t, "bob", sims[0].Player)
+	assert.InDelta(t, 0.667, sims[0].Similarity, 0.001)
+	assert.Equal(t, "carol", sims[1].Player)
+	assert.Equal(t, 0.0, sims[1].Similarity)
+
+	assert.Len(t, similarPlayers("alice", decks, 1), 1)
+}
@@ -0,0 +1,220 @@
+package stats
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+
+	"github.com/caseydavenport/cube-tools/pkg/server"
+	"github.com/caseydavenport/cube-tools/pkg/server/decks"
+	"github.com/caseydavenport/cube-tools/pkg/server/query"
+	"github.com/caseydavenport/cube-tools/pkg/storage"
+	"github.com/caseydavenport/cube-tools/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// The optimal-range report turns our deck history into deckbuilding advice:
+// for each macro archetype, which land count, curve, creature count, amount of
+// interaction and number of two-drops have gone with the best results. Each
+// metric is binned by value, and the recommended range is the run of adjacent
+// bins whose combined record has the highest lower confidence bound, so a
+// lucky handful of decks can't win out over a well-populated range.
+
+// rangeMetric is a composition value the report covers, and the width of the
+// bins it is split into.
+type rangeMetric struct {
+	name string
+	step float64
+}
+
+var rangeMetrics = []rangeMetric{
+	{"lands", 1},
+	{"avg_cmc", 0.25},
+	{"creatures", 1},
+	{"interaction", 1},
+	{"two_drops", 1},
+}
+
+// allArchetypes is the pseudo-archetype covering every deck.
+const allArchetypes = "all"
+
+// OptimalRangesResponse is the API response for /api/stats/optimal-ranges.
+type OptimalRangesResponse struct {
+	Archetypes []*ArchetypeRanges `json:"archetypes"`
+}
+
+type ArchetypeRanges struct {
+	Record
+	Archetype string         `json:"archetype"`
+	Decks     int            `json:"decks"`
+	Metrics   []*MetricRange `json:"metrics"`
+}
+
+type MetricRange struct {
+	Metric string  `json:"metric"`
+	Median float64 `json:"median"`
+
+	// Bins are the metric's values in ascending order, and Best the run of
+	// adjacent bins with the strongest record. Best is nil when no run holds
+	// enough decks.
+	Bins []*RangeBin `json:"bins"`
+	Best *RangeBin   `json:"best,omitempty"`
+}
+
+// RangeBin is a range of a metric's values, Lo inclusive and Hi exclusive.
+type RangeBin struct {
+	Record
+	Label string  `json:"label"`
+	Lo    float64 `json:"lo"`
+	Hi    float64 `json:"hi"`
+	Decks int     `json:"decks"`
+}
+
+func OptimalRangesHandler() http.Handler {
+	return &optimalRangesHandler{store: storage.NewFileDeckStoreWithCache()}
+}
+
+type optimalRangesHandler struct {
+	store storage.DeckStorage
+}
+
+func (h *optimalRangesHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	dr := decks.ParseDecksRequest(r)
+	logrus.WithField("params", dr).Info("/api/stats/optimal-ranges")
+
+	cubeID := server.CubeFromRequest(r)
+	allDecks, err := h.store.List(cubeID, dr)
+	if err != nil {
+		http.Error(rw, "could not load decks", http.StatusInternalServerError)
+		return
+	}
+	resp := optimalRanges(allDecks, loadCubeCards(cubeID), cubeMacros(cubeID), query.GetInt(r, "min_decks"), zForConfidence(query.GetFloat(r, "confidence")))
+	writeJSON(rw, resp)
+}
+
+// optimalRanges builds the report over decks with a recorded mainboard.
+// minDecks is the fewest decks a recommended range may hold; zero picks a
+// fifth of the archetype's decks, and never fewer than three.
+func optimalRanges(allDecks []*storage.Deck, cubeCards map[string]types.Card, macros []string, minDecks int, z float64) *OptimalRangesResponse {
+	byArch := map[string][]*storage.Deck{}
+	comps := map[*storage.Deck]deckComposition{}
+	for _, d := range allDecks {
+		if len(d.Mainboard) == 0 {
+			continue
+		}
+		comps[d] = composition(d, cubeCards)
+		byArch[allArchetypes] = append(byArch[allArchetypes], d)
+		if m := d.MacroIn(macros); m != "" {
+			byArch[m] = append(byArch[m], d)
+		}
+	}
+
+	resp := &OptimalRangesResponse{Archetypes: []*ArchetypeRanges{}}
+	for arch, ds := range byArch {
+		ar := &ArchetypeRanges{Archetype: arch, Decks: len(ds)}
+		for _, d := range ds {
+			ar.Add(d)
+		}
+		ar.Finalize()
+		ar.SetInterval(z)
+
+		need := minDecks
+		if need <= 0 {
+			need = max(3, int(math.Ceil(float64(len(ds))/5)))
+		}
+		for _, m := range rangeMetrics {
+			ar.Metrics = append(ar.Metrics, metricRange(m, ds, comps, need, z))
+		}
+		resp.Archetypes = append(resp.Archetypes, ar)
+	}
+	sort.Slice(resp.Archetypes, func(i, j int) bool {
+		a, b := resp.Archetypes[i].Archetype, resp.Archetypes[j].Archetype
+		if (a == allArchetypes) != (b == allArchetypes) {
+			return a == allArchetypes
+		}
+		return a < b
+	})
+	return resp
+}
+
+func metricRange(m rangeMetric, ds []*storage.Deck, comps map[*storage.Deck]deckComposition, minDecks int, z float64) *MetricRange {
+	mr := &MetricRange{Metric: m.name, Bins: []*RangeBin{}}
+
+	bins := map[int]*RangeBin{}
+	values := make([]float64, 0, len(ds))
+	for _, d := range ds {
+		v := comps[d].value(m.name)
+		values = append(values, v)
+		key := int(math.Floor(v / m.step))
+		b, ok := bins[key]
+		if !ok {
+			lo := float64(key) * m.step
+			b = &RangeBin{Lo: lo, Hi: lo + m.step, Label: rangeLabel(m, lo, lo+m.step)}
+			bins[key] = b
+		}
+		b.Decks++
+		b.Add(d)
+	}
+	for _, b := range bins {
+		b.Finalize()
+		b.SetInterval(z)
+		mr.Bins = append(mr.Bins, b)
+	}
+	sort.Slice(mr.Bins, func(i, j int) bool { return mr.Bins[i].Lo < mr.Bins[j].Lo })
+
+	sort.Float64s(values)
+	if n := len(values); n > 0 {
+		mr.Median = values[n/2]
+		if n%2 == 0 {
+			mr.Median = (values[n/2-1] + values[n/2]) / 2
+		}
+		mr.Median = math.Round(mr.Median*100) / 100
+	}
+
+	mr.Best = bestRange(m, mr.Bins, minDecks, z)
+	return mr
+}
+
+// bestRange finds the run of adjacent bins holding at least minDecks decks
+// whose combined win rate has the highest lower bound. Ties go to the
+// narrower run.
+func bestRange(m rangeMetric, bins []*RangeBin, minDecks int, z float64) *RangeBin {
+	var best *RangeBin
+	bestWidth := 0
+	for i := range bins {
+		run := &RangeBin{Lo: bins[i].Lo}
+		for j := i; j < len(bins); j++ {
+			run.Decks += bins[j].Decks
+			run.Wins += bins[j].Wins
+			run.Losses += bins[j].Losses
+			run.Draws += bins[j].Draws
+			if run.Decks < minDecks {
+				continue
+			}
+			cand := *run
+			cand.Hi = bins[j].Hi
+			cand.Label = rangeLabel(m, cand.Lo, cand.Hi)
+			cand.Finalize()
+			cand.SetInterval(z)
+			width := j - i
+			if best == nil || cand.WinPercentLow > best.WinPercentLow ||
+				(cand.WinPercentLow == best.WinPercentLow && width < bestWidth) {
+				best, bestWidth = &cand, width
+			}
+		}
+	}
+	return best
+}
+
+// rangeLabel names the values in [lo, hi): whole counts as "15" or "15-16",
+// fractional metrics by their bounds.
+func rangeLabel(m rangeMetric, lo, hi float64) string {
+	if m.step == 1 {
+		if hi-lo == 1 {
+			return fmt.Sprintf("%d", int(lo))
+		}
+		return fmt.Sprintf("%d-%d", int(lo), int(hi)-1)
+	}
+	return fmt.Sprintf("%.2f-%.2f", lo, hi)
+}
@@ -0,0 +1,70 @@
+package stats
+
+import (
+	"testing"
+
+	"github.com/caseydavenport/cube-tools/pkg/storage"
+	"github.com/caseydavenport/cube-tools/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptimalRanges(t *testing.T) {
+	land := types.Card{Name: "Mountain", Types: []string{"Basic", "Land"}}
+	bear := types.Card{Name: "Bear", Types: []string{"Creature"}, CMC: 2}
+
+	// Aggro decks on 16 lands win; on 17 or 18 they lose.
+	deck := func(player string, lands int, won bool) *storage.Deck {
+		board := append(repeat(land, lands), repeat(bear, 23)...)
+		winner := "Opp"
+		if won {
+			winner = player
+		}
+		return makePivotDeck(player, "d1", "2025-01-01", []string{"R"}, "aggro", board,
+			[]types.Game{{Opponent: "Opp", Winner: winner}, {Opponent: "Opp", Winner: winner}})
+	}
+	var all []*storage.Deck
+	for i := range 4 {
+		all = append(all, deck(string(rune('A'+i)), 16, true))
+	}
+	all = append(all, deck("E", 17, false), deck("F", 17, false), deck("G", 18, false))
+	all = append(all, &storage.Deck{}) // no mainboard, skipped
+
+	resp := optimalRanges(all, nil, types.DefaultMacros, 3, 1.28)
+	require.Len(t, resp.Archetypes, 2)
+	assert.Equal(t, "all", resp.Archetypes[0].Archetype)
+	aggro := resp.Archetypes[1]
+	assert.Equal(t, "aggro", aggro.Archetype)
+	assert.Equal(t, 7, aggro.Decks)
+
+	lands := aggro.Metrics[0]
+	assert.Equal(t, "lands", lands.Metric)
+	assert.Equal(t, 16.0, lands.Median)
+	require.Len(t, lands.Bins, 3)
+	assert.Equal(t, "16", lands.Bins[0].Label)
+	assert.Equal(t, 4, lands.Bins[0].Decks)
+	require.NotNil(t, lands.Best)
+	assert.Equal(t, "16", lands.Best.Label)
+	assert.Equal(t, 100.0, lands.Best.WinPercent)
+
+	twoDrops := aggro.Metrics[4]
+	assert.Equal(t, "two_drops", twoDrops.Metric)
+	assert.Equal(t, 23.0, twoDrops.Median)
+
+	// Too few decks for any range.
+	resp = optimalRanges(all, nil, types.DefaultMacros, 10, 1.28)
+	assert.Nil(t, resp.Archetypes[1].Metrics[0].Best)
+}
+
+func TestBestRange_SpansBins(t *testing.T) {
+	m := rangeMetric{"lands", 1}
+	bins := []*RangeBin{
+		{Lo: 15, Hi: 16, Decks: 1, Record: Record{Wins: 2}},
+		{Lo: 16, Hi: 17, Decks: 1, Record: Record{Wins: 2}},
+		{Lo: 17, Hi: 18, Decks: 3, Record: Record{Losses: 6}},
+	}
+	best := bestRange(m, bins, 2, 1.28)
+	require.NotNil(t, best)
+	assert.Equal(t, "15-16", best.Label)
+	assert.Equal(t, 2, best.Decks)
+}
@@ -26,73 +26,52 @@ import (
 // Cost modelling matters here: a card's printed mana value is often not the real
 // cost of its removal (delve, X spells, cost reducers, and activated abilities on
 // permanents/lands). We use a delve heuristic and an X≈3 heuristic, and a small
-// curated table for the one-off oddballs, so the efficiency numbers reflect what
-// the removal actually costs to fire.
+// curated overrides file for the one-off oddballs (see removal_overrides.go), so
+// the efficiency numbers reflect what the removal actually costs to fire.
 
 // representativeX is the value we assume for X-cost removal ("average" case).
+// Overrides for bounded-X removal like Prismatic Ending are written against it.
 const representativeX = 3
 
 // RemovalProfile is the parsed kill condition of a spot-removal spell. A zero
 // value on a Max* axis means that axis is unconstrained.
 type RemovalProfile struct {
-	Spot         bool
-	Kind         string // "destroy", "exile", "damage", "shrink", "fight"
-	Restriction  string // human label, e.g. "toughness ≤ 3", "P+T ≤ 5", "any creature"
-	MaxToughness int
-	MaxPower     int
-	MaxMV        int
-	MaxPTSum     int
-	ColorExclude string
-	ColorOnly    string
-	FlyingOnly   bool
-	Scalable     bool
+	Spot         bool   `json:"spot"`
+	Kind         string `json:"kind"`        // "destroy", "exile", "damage", "shrink", "fight"
+	Restriction  string `json:"restriction"` // human label, e.g. "toughness ≤ 3", "P+T ≤ 5", "any creature"
+	MaxToughness int    `json:"max_toughness,omitempty"`
+	MaxPower     int    `json:"max_power,omitempty"`
+	MaxMV        int    `json:"max_mv,omitempty"`
+	MaxPTSum     int    `json:"max_pt_sum,omitempty"`
+	ColorExclude string `json:"color_exclude,omitempty"`
+	ColorOnly    string `json:"color_only,omitempty"`
+	FlyingOnly   bool   `json:"flying_only,omitempty"`
+	Scalable     bool   `json:"scalable,omitempty"`
 }
 
-// removalMode is one cost/target mode of a multi-mode removal spell (kicker,
+// RemovalMode is one cost/target mode of a multi-mode removal spell (kicker,
 // revolt, etc.). Each mode becomes its own row.
-type removalMode struct {
-	label   string
-	cost    int
-	profile RemovalProfile
+type RemovalMode struct {
+	Label   string         `json:"label"`
+	Cost    int            `json:"cost"`
+	Profile RemovalProfile `json:"profile"`
 }
 
-// removalOverride hand-corrects cards our heuristics get wrong: cost overrides
-// the effective mana cost (activated abilities, reduced costs); profile, when
-// set, replaces the parsed kill condition; modes, when set, splits the card into
-// several rows (one per cost/target mode).
-type removalOverride struct {
-	cost    int
-	profile *RemovalProfile
-	modes   []removalMode
+// RemovalOverride hand-corrects a card our heuristics get wrong: Cost overrides
+// the effective mana cost (activated abilities, reduced costs); Profile, when
+// set, replaces the parsed kill condition; Modes, when set, splits the card
+// into several rows (one per cost/target mode). Note says why, since the JSON
+// file it lives in can't carry comments.
+type RemovalOverride struct {
+	Cost    int             `json:"cost,omitempty"`
+	Profile *RemovalProfile `json:"profile,omitempty"`
+	Modes   []RemovalMode   `json:"modes,omitempty"`
+	Note    string          `json:"note,omitempty"`
 }
 
-var removalOverrides = map[string]removalOverride{
-	// Reduced / alternate cast costs.
-	"Leyline Binding": {cost: 2}, // domain, typically ~{1}-{2} in a 5-color cube
-	"Ride's End":      {cost: 2}, // {3} less targeting a tapped permanent
-	// The removal is the adventure/back face (Locthwain Scorn, {1}{B}), not the
-	// {5}{B}{B} front face the printed mana value reflects.
-	"Virtue of Persistence // Locthwain Scorn": {cost: 2},
-	// Activated / channel removal on permanents & lands: cost is the ability, not
-	// the card's mana value.
-	"Barbarian Ring":              {cost: 1},
-	"Eiganjo, Seat of the Empire": {cost: 3},
-	"Seal of Fire":                {cost: 1},
-	"Pyrite Spellbomb":            {cost: 2},
-	"Grim Lavamancer":             {cost: 2},
-	// Effects our regexes misread.
-	"Prismatic Ending": {cost: representativeX + 1, profile: &RemovalProfile{Spot: true, Kind: "exile", MaxMV: representativeX, Restriction: fmt.Sprintf("MV ≤ %d (X≈%d)", representativeX, representativeX)}},
-	"Chainweb Aracnir": {cost: 1, profile: &RemovalProfile{Spot: true, Kind: "damage", MaxToughness: 1, FlyingOnly: true, Restriction: "toughness ≤ 1, fliers"}},
-	// Multi-mode: a cheap restricted mode and a pricier/looser mode, as separate rows.
-	"Bloodchief's Thirst": {modes: []removalMode{
-		{label: "base", cost: 1, profile: RemovalProfile{Spot: true, Kind: "destroy", MaxMV: 2, Restriction: "MV ≤ 2"}},
-		{label: "kicked", cost: 4, profile: RemovalProfile{Spot: true, Kind: "destroy", Restriction: "any creature"}},
-	}},
-	"Fatal Push": {modes: []removalMode{
-		{label: "base", cost: 1, profile: RemovalProfile{Spot: true, Kind: "destroy", MaxMV: 2, Restriction: "MV ≤ 2"}},
-		{label: "revolt", cost: 1, profile: RemovalProfile{Spot: true, Kind: "destroy", MaxMV: 4, Restriction: "MV ≤ 4 (revolt)"}},
-	}},
-}
+// RemovalOverrides maps a card name to its override. See
+// removal_overrides.go for where they're loaded from.
+type RemovalOverrides map[string]RemovalOverride
 
 var (
 	reSweeper   = regexp.MustCompile(`all creatures get|destroy all|exile all|each player sacrifices|damage to each creature`)
@@ -118,9 +97,9 @@ var colorLetter = map[string]string{"white": "W", "blue": "U", "black": "B", "re
 // classifyRemoval parses a card into its spot-removal profile. Cards that aren't
 // removal, or are sweepers/edicts/tempo/graveyard-hate/unparseable, come back with
 // Spot=false.
-func classifyRemoval(c types.Card) RemovalProfile {
-	if o, ok := removalOverrides[c.Name]; ok && o.profile != nil {
-		return *o.profile
+func classifyRemoval(c types.Card, overrides RemovalOverrides) RemovalProfile {
+	if o, ok := overrides[c.Name]; ok && o.Profile != nil {
+		return *o.Profile
 	}
 	if !c.IsRemoval() {
 		return RemovalProfile{}
@@ -227,9 +206,9 @@ func classifyRemoval(c types.Card) RemovalProfile {
 // effectiveCost is what the removal really costs to fire, not its printed mana
 // value. Curated overrides win; then delve (drop the generic, assume fully
 // delved) and X (assume X≈3); otherwise the printed mana value.
-func effectiveCost(c types.Card) int {
-	if o, ok := removalOverrides[c.Name]; ok && o.cost > 0 {
-		return o.cost
+func effectiveCost(c types.Card, overrides RemovalOverrides) int {
+	if o, ok := overrides[c.Name]; ok && o.Cost > 0 {
+		return o.Cost
 	}
 	text := strings.ToLower(c.OracleText)
 	if strings.Contains(text, "delve") {
@@ -345,6 +324,11 @@ func (h *removalHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		http.Error(rw, "could not load cube", http.StatusInternalServerError)
 		return
 	}
+	overrides, err := loadRemovalOverrides("data", cubeID)
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("could not load removal overrides: %v", err), http.StatusInternalServerError)
+		return
+	}
 
 	creatures := make([]creatureInfo, 0)
 	for _, c := range cube.Cards {
@@ -366,25 +350,15 @@ func (h *removalHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		totalWeight += playWeight[cr.name]
 	}
 
-	resp := RemovalResponse{CreatureCount: len(creatures), TotalPlayWeight: totalWeight}
-	for _, c := range cube.Cards {
+	answers, excluded := spotRemoval(cube.Cards, overrides)
+	resp := RemovalResponse{CreatureCount: len(creatures), TotalPlayWeight: totalWeight, Excluded: excluded}
+	for _, a := range answers {
+		rc := buildRemovalCard(a.card, a.profile, a.cost, creatures, playWeight, totalWeight)
 		// Multi-mode removal (kicker, revolt) is emitted as one row per mode.
-		if o, ok := removalOverrides[c.Name]; ok && len(o.modes) > 0 {
-			for _, m := range o.modes {
-				rc := buildRemovalCard(c, m.profile, m.cost, creatures, playWeight, totalWeight)
-				rc.Name = fmt.Sprintf("%s (%s)", c.Name, m.label)
-				resp.Cards = append(resp.Cards, rc)
-			}
-			continue
-		}
-		prof := classifyRemoval(c)
-		if !prof.Spot {
-			if c.IsRemoval() {
-				resp.Excluded++
-			}
-			continue
+		if a.mode != "" {
+			rc.Name = fmt.Sprintf("%s (%s)", a.card.Name, a.mode)
 		}
-		resp.Cards = append(resp.Cards, buildRemovalCard(c, prof, effectiveCost(c), creatures, playWeight, totalWeight))
+		resp.Cards = append(resp.Cards, rc)
 	}
 
 	// Default to widest reach first, with scalable removal grouped at the bottom
@@ -410,6 +384,39 @@ func (h *removalHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// removalAnswer is one way to fire a piece of spot removal: the card itself,
+// or one mode of a multi-mode card.
+type removalAnswer struct {
+	card    types.Card
+	mode    string
+	cost    int
+	profile RemovalProfile
+}
+
+// spotRemoval returns every spot-removal answer among cards, one per mode for
+// multi-mode removal, along with how many removal cards couldn't be profiled.
+func spotRemoval(cards []types.Card, overrides RemovalOverrides) ([]removalAnswer, int) {
+	var answers []removalAnswer
+	excluded := 0
+	for _, c := range cards {
+		if o, ok := overrides[c.Name]; ok && len(o.Modes) > 0 {
+			for _, m := range o.Modes {
+				answers = append(answers, removalAnswer{card: c, mode: m.Label, cost: m.Cost, profile: m.Profile})
+			}
+			continue
+		}
+		prof := classifyRemoval(c, overrides)
+		if !prof.Spot {
+			if c.IsRemoval() {
+				excluded++
+			}
+			continue
+		}
+		answers = append(answers, removalAnswer{card: c, cost: effectiveCost(c, overrides), profile: prof})
+	}
+	return answers, excluded
+}
+
 func buildRemovalCard(c types.Card, prof RemovalProfile, eff int, creatures []creatureInfo, playWeight map[string]int, totalWeight int) RemovalCard {
 	rc := RemovalCard{
 		Name:        c.Name,
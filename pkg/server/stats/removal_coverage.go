@@ -0,0 +1,261 @@
+package stats
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/caseydavenport/cube-tools/pkg/server"
+	"github.com/caseydavenport/cube-tools/pkg/server/query"
+	"github.com/caseydavenport/cube-tools/pkg/storage"
+	"github.com/caseydavenport/cube-tools/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// Removal coverage is the removal page turned around: instead of asking how
+// much of the creature base each removal spell kills, it asks, for each
+// creature, how many of the cube's removal spells can answer it and what they
+// cost. Creatures with few or expensive answers are the threats a drafter
+// can't plan around. It reuses the same kill profiles, so any hand correction
+// in the removal overrides applies to both views.
+//
+// Scalable removal (X damage, fights) can answer almost anything given enough
+// mana or a big enough creature, so it's left out of the counts rather than
+// credited with every creature.
+
+// pairColors are the colors a coverage matrix row can cast: each mono color
+// and each two-color pair.
+var pairColors = []string{
+	"W", "U", "B", "R", "G",
+	"WU", "WB", "WR", "WG", "UB", "UR", "UG", "BR", "BG", "RG",
+}
+
+// RemovalCoverageResponse is the API response for /api/stats/removal/coverage.
+type RemovalCoverageResponse struct {
+	// Colors echoes the color filter on answers, if any.
+	Colors string `json:"colors,omitempty"`
+
+	// RemovalCount is the number of removal cards counted as answers.
+	RemovalCount int `json:"removal_count"`
+
+	// Creatures are ordered hardest to answer first.
+	Creatures []*CreatureCoverage `json:"creatures"`
+
+	// Matrix has a row per mono color and color pair, giving the share of the
+	// cube's creatures of each color that the row's removal can answer.
+	Matrix []*CoverageRow `json:"matrix"`
+}
+
+type CreatureCoverage struct {
+	Name      string   `json:"name"`
+	Colors    []string `json:"colors"`
+	MV        int      `json:"mv"`
+	Power     string   `json:"power"`
+	Toughness string   `json:"toughness"`
+
+	// Answers lists the removal that can kill the creature. Cost is the
+	// cheapest way each answer can do it, averaged in AvgCost.
+	Answers      []string `json:"answers"`
+	AnswerCount  int      `json:"answer_count"`
+	AnswerShare  float64  `json:"answer_share"`
+	AvgCost      float64  `json:"avg_cost"`
+	CheapestCost int      `json:"cheapest_cost"`
+
+	// Played is how many mainboards ran the creature, to tell a real problem
+	// from a hard-to-answer card nobody plays.
+	Played int `json:"played"`
+}
+
+type CoverageRow struct {
+	Colors string `json:"colors"`
+
+	// Removal is the number of answers castable in the row's colors.
+	Removal int `json:"removal"`
+
+	// Answerable is keyed by creature color ("C" for colorless, "all" for every
+	// creature) and holds the percentage of those creatures at least one of the
+	// row's removal spells can kill.
+	Answerable map[string]float64 `json:"answerable"`
+}
+
+func RemovalCoverageHandler() http.Handler {
+	return &removalCoverageHandler{store: storage.NewFileDeckStoreWithCache()}
+}
+
+type removalCoverageHandler struct {
+	store storage.DeckStorage
+}
+
+func (h *removalCoverageHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	colors := strings.ToUpper(query.GetString(r, "colors"))
+	logrus.WithField("colors", colors).Info("/api/stats/removal/coverage")
+
+	cubeID := server.CubeFromRequest(r)
+	cards := loadCubeCards(cubeID)
+	if len(cards) == 0 {
+		http.Error(rw, "could not load cube", http.StatusInternalServerError)
+		return
+	}
+	cubeCards := make([]types.Card, 0, len(cards))
+	for _, c := range cards {
+		cubeCards = append(cubeCards, c)
+	}
+	overrides, err := loadRemovalOverrides("data", cubeID)
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("could not load removal overrides: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	playWeight := map[string]int{}
+	if decks, err := h.store.List(cubeID, &storage.DecksRequest{}); err == nil {
+		for _, d := range decks {
+			for _, c := range d.Mainboard {
+				playWeight[c.Name]++
+			}
+		}
+	}
+	writeJSON(rw, removalCoverage(cubeCards, overrides, playWeight, colors))
+}
+
+// removalCoverage builds the coverage report. colors, when set, limits answers
+// to removal castable with those colors.
+func removalCoverage(cards []types.Card, overrides RemovalOverrides, playWeight map[string]int, colors string) *RemovalCoverageResponse {
+	answers, _ := spotRemoval(cards, overrides)
+	var usable []removalAnswer
+	for _, a := range answers {
+		if !a.profile.Scalable {
+			usable = append(usable, a)
+		}
+	}
+
+	var creatures []types.Card
+	for _, c := range cards {
+		if c.IsCreature() {
+			creatures = append(creatures, c)
+		}
+	}
+	sort.Slice(creatures, func(i, j int) bool { return creatures[i].Name < creatures[j].Name })
+
+	// costs[creature][removal] is the cheapest cost at which the removal kills
+	// the creature, across its modes.
+	costs := make(map[string]map[string]int, len(creatures))
+	for _, c := range creatures {
+		info := toCreatureInfo(c)
+		costs[c.Name] = map[string]int{}
+		for _, a := range usable {
+			if !a.profile.killable(info) {
+				continue
+			}
+			if prev, ok := costs[c.Name][a.card.Name]; !ok || a.cost < prev {
+				costs[c.Name][a.card.Name] = a.cost
+			}
+		}
+	}
+
+	// The colors of each removal card, and of the ones passing the filter.
+	allRemoval := map[string][]string{}
+	for _, a := range usable {
+		allRemoval[a.card.Name] = a.card.Colors
+	}
+	removalColors := map[string][]string{}
+	for name, cols := range allRemoval {
+		if castableIn(cols, colors) {
+			removalColors[name] = cols
+		}
+	}
+
+	resp := &RemovalCoverageResponse{Colors: colors, RemovalCount: len(removalColors)}
+
+	for _, c := range creatures {
+		cc := &CreatureCoverage{
+			Name:      c.Name,
+			Colors:    c.Colors,
+			MV:        c.CMC,
+			Power:     c.Power,
+			Toughness: c.Toughness,
+			Answers:   []string{},
+			Played:    playWeight[c.Name],
+		}
+		sum := 0
+		for name, cost := range costs[c.Name] {
+			if _, ok := removalColors[name]; !ok {
+				continue
+			}
+			cc.Answers = append(cc.Answers, name)
+			sum += cost
+			if cc.AnswerCount == 0 || cost < cc.CheapestCost {
+				cc.CheapestCost = cost
+			}
+			cc.AnswerCount++
+		}
+		sort.Strings(cc.Answers)
+		if cc.AnswerCount > 0 {
+			cc.AvgCost = round1(float64(sum) / float64(cc.AnswerCount))
+		}
+		cc.AnswerShare = pct(float64(cc.AnswerCount), float64(resp.RemovalCount))
+		resp.Creatures = append(resp.Creatures, cc)
+	}
+
+	// Hardest first: fewest answers, then the priciest ones, then the most
+	// played, since a popular threat matters more.
+	sort.SliceStable(resp.Creatures, func(i, j int) bool {
+		a, b := resp.Creatures[i], resp.Creatures[j]
+		if a.AnswerCount != b.AnswerCount {
+			return a.AnswerCount < b.AnswerCount
+		}
+		if a.AvgCost != b.AvgCost {
+			return a.AvgCost > b.AvgCost
+		}
+		return a.Played > b.Played
+	})
+
+	for _, row := range pairColors {
+		cr := &CoverageRow{Colors: row, Answerable: map[string]float64{}}
+		for _, cols := range allRemoval {
+			if castableIn(cols, row) {
+				cr.Removal++
+			}
+		}
+		total, answered := map[string]int{}, map[string]int{}
+		for _, c := range creatures {
+			keys := append([]string{"all"}, c.Colors...)
+			if len(c.Colors) == 0 {
+				keys = append(keys, "C")
+			}
+			hit := false
+			for name := range costs[c.Name] {
+				if castableIn(allRemoval[name], row) {
+					hit = true
+					break
+				}
+			}
+			for _, k := range keys {
+				total[k]++
+				if hit {
+					answered[k]++
+				}
+			}
+		}
+		for k, n := range total {
+			cr.Answerable[k] = pct(float64(answered[k]), float64(n))
+		}
+		resp.Matrix = append(resp.Matrix, cr)
+	}
+	return resp
+}
+
+// castableIn reports whether a card of the given colors can be cast with only
+// the colors in allowed. Colorless cards always can, and an empty allowed set
+// allows everything.
+func castableIn(cardColors []string, allowed string) bool {
+	if allowed == "" {
+		return true
+	}
+	for _, c := range cardColors {
+		if !strings.Contains(allowed, c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+package stats
+
+import (
+	"testing"
+
+	"github.com/caseydavenport/cube-tools/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func coverageFixture() []types.Card {
+	return []types.Card{
+		{Name: "Murder", Colors: []string{"B"}, CMC: 3, OracleText: "Destroy target creature."},
+		{Name: "Shock", Colors: []string{"R"}, CMC: 1, OracleText: "Shock deals 2 damage to any target."},
+		{Name: "Fireball", Colors: []string{"R"}, CMC: 1, OracleText: "Fireball deals X damage to any target."},
+		{Name: "Fatal Push", Colors: []string{"B"}, CMC: 1, OracleText: "Destroy target creature if it has mana value 2 or less."},
+		{Name: "Elf", Colors: []string{"G"}, Types: []string{"Creature"}, CMC: 1, Power: "1", Toughness: "1"},
+		{Name: "Titan", Colors: []string{"G"}, Types: []string{"Creature"}, CMC: 6, Power: "6", Toughness: "6"},
+		{Name: "Zombie", Colors: []string{"B"}, Types: []string{"Creature"}, CMC: 5, Power: "5", Toughness: "5"},
+	}
+}
+
+func TestRemovalCoverage_Creatures(t *testing.T) {
+	resp := removalCoverage(coverageFixture(), defaultOverrides(t), map[string]int{"Titan": 3}, "")
+
+	// Fireball is scalable and doesn't count.
+	assert.Equal(t, 3, resp.RemovalCount)
+	require.Len(t, resp.Creatures, 3)
+
+	// The big creatures only die to Murder; the more played one ranks as the
+	// bigger problem.
+	titan := resp.Creatures[0]
+	assert.Equal(t, "Titan", titan.Name)
+	assert.Equal(t, []string{"Murder"}, titan.Answers)
+	assert.Equal(t, 3.0, titan.AvgCost)
+	assert.Equal(t, 3, titan.Played)
+	assert.Equal(t, "Zombie", resp.Creatures[1].Name)
+
+	elf := resp.Creatures[2]
+	assert.Equal(t, []string{"Fatal Push", "Murder", "Shock"}, elf.Answers)
+	assert.Equal(t, 1, elf.CheapestCost)
+	assert.InDelta(t, 100.0, elf.AnswerShare, 0.01)
+}
+
+func TestRemovalCoverage_ColorFilter(t *testing.T) {
+	resp := removalCoverage(coverageFixture(), defaultOverrides(t), nil, "R")
+	assert.Equal(t, 1, resp.RemovalCount)
+	for _, c := range resp.Creatures {
+		if c.Name == "Elf" {
+			assert.Equal(t, []string{"Shock"}, c.Answers)
+		}
+	}
+}
+
+func TestRemovalCoverage_Matrix(t *testing.T) {
+	resp := removalCoverage(coverageFixture(), defaultOverrides(t), nil, "")
+	rows := map[string]*CoverageRow{}
+	for _, r := range resp.Matrix {
+		rows[r.Colors] = r
+	}
+	require.Len(t, rows, 15)
+
+	// Red only answers the Elf: half the green creatures, none of the black.
+	assert.Equal(t, 1, rows["R"].Removal)
+	assert.Equal(t, 50.0, rows["R"].Answerable["G"])
+	assert.Equal(t, 0.0, rows["R"].Answerable["B"])
+	// Black adds Murder for everything else.
+	assert.Equal(t, 100.0, rows["BR"].Answerable["G"])
+	assert.Equal(t, 100.0, rows["BR"].Answerable["all"])
+	// White has no removal.
+	assert.Equal(t, 0.0, rows["W"].Answerable["all"])
+}
+
+func TestCastableIn(t *testing.T) {
+	assert.True(t, castableIn(nil, "W"))
+	assert.True(t, castableIn([]string{"U", "B"}, "UB"))
+	assert.False(t, castableIn([]string{"U", "B"}, "U"))
+	assert.True(t, castableIn([]string{"R"}, ""))
+}
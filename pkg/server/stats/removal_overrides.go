@@ -0,0 +1,162 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/caseydavenport/cube-tools/pkg/server"
+	"github.com/caseydavenport/cube-tools/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// Removal overrides live in JSON so a new oddball doesn't need a code change.
+// data/removal-overrides.json holds the defaults every cube shares, and
+// data/{cube}/removal-overrides.json adds to or replaces them card by card.
+// Only the per-cube file is editable through the API.
+
+const removalOverridesFile = "removal-overrides.json"
+
+// loadRemovalOverrides merges the shared defaults under root with the cube's
+// own overrides. Either file may be missing.
+func loadRemovalOverrides(root, cubeID string) (RemovalOverrides, error) {
+	merged, err := readRemovalOverrides(filepath.Join(root, removalOverridesFile))
+	if err != nil {
+		return nil, err
+	}
+	if cubeID == "" {
+		return merged, nil
+	}
+	cube, err := readRemovalOverrides(filepath.Join(root, cubeID, removalOverridesFile))
+	if err != nil {
+		return nil, err
+	}
+	for name, o := range cube {
+		merged[name] = o
+	}
+	return merged, nil
+}
+
+// readRemovalOverrides reads one overrides file, returning an empty set when
+// it doesn't exist.
+func readRemovalOverrides(path string) (RemovalOverrides, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return RemovalOverrides{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	overrides := RemovalOverrides{}
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return overrides, nil
+}
+
+// validateRemovalOverrides checks that every override names a card in the cube
+// and actually overrides something.
+func validateRemovalOverrides(overrides RemovalOverrides, cube *types.Cube) error {
+	inCube := make(map[string]bool, len(cube.Cards))
+	for _, c := range cube.Cards {
+		inCube[c.Name] = true
+	}
+
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []string
+	for _, name := range names {
+		o := overrides[name]
+		switch {
+		case !inCube[name]:
+			problems = append(problems, fmt.Sprintf("%q is not in the cube", name))
+		case o.Cost <= 0 && o.Profile == nil && len(o.Modes) == 0:
+			problems = append(problems, fmt.Sprintf("%q sets no cost, profile or modes", name))
+		}
+		for i, m := range o.Modes {
+			if m.Label == "" {
+				problems = append(problems, fmt.Sprintf("%q mode %d has no label", name, i+1))
+			}
+			if m.Cost < 0 {
+				problems = append(problems, fmt.Sprintf("%q mode %q has a negative cost", name, m.Label))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid removal overrides: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// RemovalOverridesResponse is the API response for GET
+// /api/{cube}/removal-overrides.
+type RemovalOverridesResponse struct {
+	// Defaults are the shared overrides, and Overrides the cube's own, which
+	// win where both name a card.
+	Defaults  RemovalOverrides `json:"defaults"`
+	Overrides RemovalOverrides `json:"overrides"`
+}
+
+func RemovalOverridesHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		cubeID := server.CubeFromRequest(r)
+		defaults, err := readRemovalOverrides(filepath.Join("data", removalOverridesFile))
+		if err != nil {
+			http.Error(rw, fmt.Sprintf("could not load removal overrides: %v", err), http.StatusInternalServerError)
+			return
+		}
+		cube, err := readRemovalOverrides(filepath.Join("data", cubeID, removalOverridesFile))
+		if err != nil {
+			http.Error(rw, fmt.Sprintf("could not load removal overrides: %v", err), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(rw, RemovalOverridesResponse{Defaults: defaults, Overrides: cube})
+	})
+}
+
+// SaveRemovalOverridesHandler replaces the cube's removal-overrides.json with
+// the posted overrides after validating them against the cube list.
+func SaveRemovalOverridesHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var overrides RemovalOverrides
+		if err := json.NewDecoder(r.Body).Decode(&overrides); err != nil {
+			http.Error(rw, fmt.Sprintf("invalid JSON: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		cubeID := server.CubeFromRequest(r)
+		if cubeID == "" {
+			http.Error(rw, "no cube in request", http.StatusForbidden)
+			return
+		}
+		cube, err := types.LoadCube(fmt.Sprintf("data/%s/cube.json", cubeID))
+		if err != nil {
+			http.Error(rw, "could not load cube", http.StatusInternalServerError)
+			return
+		}
+		if err := validateRemovalOverrides(overrides, cube); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		data, err := json.MarshalIndent(overrides, "", "  ")
+		if err != nil {
+			http.Error(rw, "could not marshal overrides", http.StatusInternalServerError)
+			return
+		}
+		if err := os.WriteFile(filepath.Join("data", cubeID, removalOverridesFile), data, 0o644); err != nil {
+			http.Error(rw, "could not save overrides", http.StatusInternalServerError)
+			return
+		}
+		logrus.WithFields(logrus.Fields{"cube": cubeID, "overrides": len(overrides)}).Info("saved removal overrides")
+		rw.WriteHeader(http.StatusOK)
+	})
+}
@@ -0,0 +1,66 @@
+package stats
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/caseydavenport/cube-tools/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadRemovalOverrides_CubeWins(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, removalOverridesFile),
+		[]byte(`{"Seal of Fire": {"cost": 1}, "Fatal Push": {"cost": 1}}`), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "mycube"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "mycube", removalOverridesFile),
+		[]byte(`{"Fatal Push": {"cost": 2, "note": "no revolt here"}, "Ride's End": {"cost": 2}}`), 0o644))
+
+	o, err := loadRemovalOverrides(root, "mycube")
+	require.NoError(t, err)
+	assert.Len(t, o, 3)
+	assert.Equal(t, 1, o["Seal of Fire"].Cost)
+	assert.Equal(t, 2, o["Fatal Push"].Cost)
+	assert.Equal(t, "no revolt here", o["Fatal Push"].Note)
+
+	// A cube without its own file gets the defaults.
+	o, err = loadRemovalOverrides(root, "other")
+	require.NoError(t, err)
+	assert.Len(t, o, 2)
+}
+
+func TestLoadRemovalOverrides_Invalid(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, removalOverridesFile), []byte(`{"Seal of Fire": `), 0o644))
+	_, err := loadRemovalOverrides(root, "")
+	assert.Error(t, err)
+}
+
+func TestDefaultOverridesMatchProfiles(t *testing.T) {
+	o := defaultOverrides(t)
+	push := o["Fatal Push"]
+	require.Len(t, push.Modes, 2)
+	assert.Equal(t, "revolt", push.Modes[1].Label)
+	assert.Equal(t, 4, push.Modes[1].Profile.MaxMV)
+}
+
+func TestValidateRemovalOverrides(t *testing.T) {
+	cube := &types.Cube{Cards: []types.Card{{Name: "Fatal Push"}, {Name: "Seal of Fire"}}}
+
+	assert.NoError(t, validateRemovalOverrides(RemovalOverrides{
+		"Seal of Fire": {Cost: 1},
+		"Fatal Push":   {Modes: []RemovalMode{{Label: "base", Cost: 1}}},
+	}, cube))
+
+	err := validateRemovalOverrides(RemovalOverrides{
+		"Lightning Bolt": {Cost: 1},
+		"Seal of Fire":   {},
+		"Fatal Push":     {Modes: []RemovalMode{{Cost: 1}}},
+	}, cube)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"Lightning Bolt" is not in the cube`)
+	assert.Contains(t, err.Error(), `"Seal of Fire" sets no cost, profile or modes`)
+	assert.Contains(t, err.Error(), `"Fatal Push" mode 1 has no label`)
+}
@@ -5,85 +5,99 @@ import (
 
 	"github.com/caseydavenport/cube-tools/pkg/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func removalCard(name, oracle string) types.Card {
 	return types.Card{Name: name, Types: []string{"Instant"}, OracleText: oracle}
 }
 
+// defaultOverrides loads the shared removal overrides shipped in data/.
+func defaultOverrides(t *testing.T) RemovalOverrides {
+	t.Helper()
+	o, err := loadRemovalOverrides("../../../data", "")
+	require.NoError(t, err)
+	require.NotEmpty(t, o)
+	return o
+}
+
 func TestClassifyRemoval(t *testing.T) {
 	// Unconditional destroy (delve noted).
-	p := classifyRemoval(removalCard("Murderous Cut", "Delve (Each card you exile from your graveyard while casting this spell pays for {1}.)\nDestroy target creature."))
+	p := classifyRemoval(removalCard("Murderous Cut", "Delve (Each card you exile from your graveyard while casting this spell pays for {1}.)\nDestroy target creature."), nil)
 	assert.True(t, p.Spot)
 	assert.Equal(t, "destroy", p.Kind)
 	assert.Equal(t, "any creature (delve)", p.Restriction)
 	assert.Equal(t, 0, p.MaxToughness, "unconditional has no toughness cap")
 
 	// Exile verb is labelled distinctly from destroy.
-	p = classifyRemoval(removalCard("Path to Exile", "Exile target creature. Its controller may search their library for a basic land card, put that card onto the battlefield tapped, then shuffle."))
+	p = classifyRemoval(removalCard("Path to Exile", "Exile target creature. Its controller may search their library for a basic land card, put that card onto the battlefield tapped, then shuffle."), nil)
 	assert.True(t, p.Spot)
 	assert.Equal(t, "exile", p.Kind)
 	assert.Equal(t, "any creature", p.Restriction)
 
 	// Total power and toughness.
-	p = classifyRemoval(removalCard("Cut Down", "Destroy target creature with total power and toughness 5 or less."))
+	p = classifyRemoval(removalCard("Cut Down", "Destroy target creature with total power and toughness 5 or less."), nil)
 	assert.True(t, p.Spot)
 	assert.Equal(t, 5, p.MaxPTSum)
 
 	// Mana value, with kicker note.
-	p = classifyRemoval(removalCard("Bloodchief's Thirst", "Kicker {2}{B}\nDestroy target creature or planeswalker with mana value 2 or less. If this spell was kicked, instead destroy that permanent."))
+	p = classifyRemoval(removalCard("Bloodchief's Thirst", "Kicker {2}{B}\nDestroy target creature or planeswalker with mana value 2 or less. If this spell was kicked, instead destroy that permanent."), nil)
 	assert.True(t, p.Spot)
 	assert.Equal(t, 2, p.MaxMV)
 	assert.Contains(t, p.Restriction, "kicker")
 
 	// -X/-X shrink caps by toughness.
-	p = classifyRemoval(removalCard("Disfigure", "Target creature gets -2/-2 until end of turn."))
+	p = classifyRemoval(removalCard("Disfigure", "Target creature gets -2/-2 until end of turn."), nil)
 	assert.True(t, p.Spot)
 	assert.Equal(t, "shrink", p.Kind)
 	assert.Equal(t, 2, p.MaxToughness)
 
 	// Damage caps by toughness.
-	p = classifyRemoval(removalCard("Abrade", "Choose one —\n• Abrade deals 3 damage to target creature.\n• Destroy target artifact."))
+	p = classifyRemoval(removalCard("Abrade", "Choose one —\n• Abrade deals 3 damage to target creature.\n• Destroy target artifact."), nil)
 	assert.True(t, p.Spot)
 	assert.Equal(t, "damage", p.Kind)
 	assert.Equal(t, 3, p.MaxToughness)
 
 	// Sweeper and edict are excluded.
-	assert.False(t, classifyRemoval(removalCard("Toxic Deluge", "As an additional cost to cast this spell, pay X life.\nAll creatures get -X/-X until end of turn.")).Spot)
-	assert.False(t, classifyRemoval(removalCard("Diabolic Edict", "Target player sacrifices a creature.")).Spot)
+	assert.False(t, classifyRemoval(removalCard("Toxic Deluge", "As an additional cost to cast this spell, pay X life.\nAll creatures get -X/-X until end of turn."), nil).Spot)
+	assert.False(t, classifyRemoval(removalCard("Diabolic Edict", "Target player sacrifices a creature."), nil).Spot)
 
 	// Graveyard-card exile (Deathrite Shaman) is not battlefield removal.
-	p = classifyRemoval(types.Card{Name: "Deathrite Shaman", Types: []string{"Creature"}, OracleText: "{1}{G}, {T}: Exile target creature card from a graveyard. You gain 3 life."})
+	p = classifyRemoval(types.Card{Name: "Deathrite Shaman", Types: []string{"Creature"}, OracleText: "{1}{G}, {T}: Exile target creature card from a graveyard. You gain 3 life."}, nil)
 	assert.False(t, p.Spot, "exiling a creature card from a graveyard isn't removal")
 
 	// Non-removal returns the zero profile.
-	assert.False(t, classifyRemoval(types.Card{Name: "Bear", Types: []string{"Creature"}, OracleText: "Vanilla."}).Spot)
+	assert.False(t, classifyRemoval(types.Card{Name: "Bear", Types: []string{"Creature"}, OracleText: "Vanilla."}, nil).Spot)
 }
 
 func TestClassifyRemoval_Override(t *testing.T) {
+	overrides := defaultOverrides(t)
+
 	// Curated profile override: Chainweb Aracnir is flying-restricted.
-	p := classifyRemoval(types.Card{Name: "Chainweb Aracnir", OracleText: "When this creature enters, it deals damage equal to its power to target creature with flying an opponent controls."})
+	p := classifyRemoval(types.Card{Name: "Chainweb Aracnir", OracleText: "When this creature enters, it deals damage equal to its power to target creature with flying an opponent controls."}, overrides)
 	assert.True(t, p.Spot)
 	assert.True(t, p.FlyingOnly)
 	assert.Equal(t, 1, p.MaxToughness)
 
 	// Prismatic Ending resolves to a representative X.
-	p = classifyRemoval(types.Card{Name: "Prismatic Ending", OracleText: "Converge — Exile target nonland permanent if its mana value is less than or equal to the number of colors of mana spent to cast this spell."})
+	p = classifyRemoval(types.Card{Name: "Prismatic Ending", OracleText: "Converge — Exile target nonland permanent if its mana value is less than or equal to the number of colors of mana spent to cast this spell."}, overrides)
 	assert.Equal(t, representativeX, p.MaxMV)
 }
 
 func TestEffectiveCost(t *testing.T) {
+	overrides := defaultOverrides(t)
+
 	// Delve: drop the generic, assume fully delved. {4}{B} -> {B}.
-	assert.Equal(t, 1, effectiveCost(types.Card{Name: "Murderous Cut", CMC: 5, ManaCost: "{4}{B}", OracleText: "Delve\nDestroy target creature."}))
+	assert.Equal(t, 1, effectiveCost(types.Card{Name: "Murderous Cut", CMC: 5, ManaCost: "{4}{B}", OracleText: "Delve\nDestroy target creature."}, overrides))
 	// Curated override (activated ability on a land).
-	assert.Equal(t, 1, effectiveCost(types.Card{Name: "Barbarian Ring", CMC: 0, ManaCost: ""}))
+	assert.Equal(t, 1, effectiveCost(types.Card{Name: "Barbarian Ring", CMC: 0, ManaCost: ""}, overrides))
 	// Plain spell: printed mana value (no X guessing).
-	assert.Equal(t, 2, effectiveCost(types.Card{Name: "Whatever", CMC: 2, ManaCost: "{1}{B}", OracleText: "Destroy target creature with toughness 2 or less."}))
+	assert.Equal(t, 2, effectiveCost(types.Card{Name: "Whatever", CMC: 2, ManaCost: "{1}{B}", OracleText: "Destroy target creature with toughness 2 or less."}, overrides))
 }
 
 func TestClassifyRemoval_ScalableX(t *testing.T) {
 	// A literal X in damage stays scalable rather than being force-fit to a number.
-	p := classifyRemoval(removalCard("Fireball", "Fireball deals X damage to target creature."))
+	p := classifyRemoval(removalCard("Fireball", "Fireball deals X damage to target creature."), nil)
 	assert.True(t, p.Spot)
 	assert.True(t, p.Scalable)
 	assert.Equal(t, 0, p.MaxToughness)
@@ -134,7 +148,7 @@ func TestRemovalCoverage(t *testing.T) {
 
 	uncond := buildRemovalCard(
 		types.Card{Name: "Doom", CMC: 2, ManaCost: "{1}{B}", OracleText: "Destroy target creature."},
-		classifyRemoval(removalCard("Doom", "Destroy target creature.")),
+		classifyRemoval(removalCard("Doom", "Destroy target creature."), nil),
 		2, creatures, weights, total,
 	)
 	assert.Equal(t, 3, uncond.Targets)
@@ -148,7 +162,7 @@ func TestRemovalCoverage(t *testing.T) {
 	// average pulled down by cheap creatures.
 	casket := buildRemovalCard(
 		types.Card{Name: "Casket", CMC: 2, ManaCost: "{1}{W}", OracleText: "exile target creature with mana value 3 or less."},
-		classifyRemoval(removalCard("Casket", "exile target creature with mana value 3 or less.")),
+		classifyRemoval(removalCard("Casket", "exile target creature with mana value 3 or less."), nil),
 		2, creatures, weights, total,
 	)
 	assert.Equal(t, 3, casket.MaxMVKilled)
@@ -0,0 +1,350 @@
+package stats
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caseydavenport/cube-tools/pkg/server"
+	"github.com/caseydavenport/cube-tools/pkg/server/decks"
+	"github.com/caseydavenport/cube-tools/pkg/server/query"
+	"github.com/sirupsen/logrus"
+)
+
+// Saved queries let a team name the analyses they re-run after every draft
+// (a pivot cut, a filtered card table, a synergy view) and re-execute them
+// against whatever data is on disk now. They're stored per cube in
+// saved-queries.json. A saved query records the request, not the result, so
+// running one always reflects the latest decks.
+
+const savedQueriesFileName = "saved-queries.json"
+
+// Saved query kinds, each naming the stats endpoint the query re-executes.
+const (
+	savedKindPivot   = "pivot"
+	savedKindCards   = "cards"
+	savedKindSynergy = "synergy"
+)
+
+// SavedQuery is one named, persisted request. Pivot queries carry the POST
+// body; cards and synergy queries carry the URL parameters their GET
+// endpoints take.
+type SavedQuery struct {
+	Name        string `json:"name"`
+	Kind        string `json:"kind"`
+	Description string `json:"description,omitempty"`
+
+	// Pivot is the request body for kind "pivot".
+	Pivot *PivotRequest `json:"pivot,omitempty"`
+
+	// Params are the query parameters for kind "cards" or "synergy", e.g.
+	// {"color": "R", "min_games": "20"}.
+	Params map[string]string `json:"params,omitempty"`
+
+	// Updated is when the query was last saved, RFC 3339.
+	Updated string `json:"updated,omitempty"`
+}
+
+type savedQueriesFile struct {
+	Queries []SavedQuery `json:"queries"`
+}
+
+// SavedQueriesResponse is the API response for GET /api/{cube}/saved.
+type SavedQueriesResponse struct {
+	Queries []SavedQuery `json:"queries"`
+}
+
+var errSavedQueryNotFound = errors.New("saved query not found")
+
+// savedQueryStore reads and writes a cube's saved-queries.json under dataRoot.
+// The mutex serializes load-modify-write so concurrent saves don't drop each
+// other's edits.
+type savedQueryStore struct {
+	sync.Mutex
+	dataRoot string
+}
+
+func (s *savedQueryStore) path(cube string) string {
+	return filepath.Join(s.dataRoot, cube, savedQueriesFileName)
+}
+
+// list returns the cube's saved queries sorted by name. A missing file is an
+// empty list, not an error.
+func (s *savedQueryStore) list(cube string) ([]SavedQuery, error) {
+	data, err := os.ReadFile(s.path(cube))
+	if os.IsNotExist(err) {
+		return []SavedQuery{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f savedQueriesFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", s.path(cube), err)
+	}
+	if f.Queries == nil {
+		f.Queries = []SavedQuery{}
+	}
+	slices.SortFunc(f.Queries, func(a, b SavedQuery) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+	return f.Queries, nil
+}
+
+func (s *savedQueryStore) get(cube, name string) (SavedQuery, error) {
+	queries, err := s.list(cube)
+	if err != nil {
+		return SavedQuery{}, err
+	}
+	for _, q := range queries {
+		if q.Name == name {
+			return q, nil
+		}
+	}
+	return SavedQuery{}, errSavedQueryNotFound
+}
+
+func (s *savedQueryStore) write(cube string, queries []SavedQuery) error {
+	data, err := json.MarshalIndent(savedQueriesFile{Queries: queries}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path(cube), data, 0o644)
+}
+
+// put inserts q, or replaces the saved query with the same name.
+func (s *savedQueryStore) put(cube string, q SavedQuery) error {
+	s.Lock()
+	defer s.Unlock()
+
+	queries, err := s.list(cube)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(queries, func(e SavedQuery) bool { return e.Name == q.Name })
+	if i >= 0 {
+		queries[i] = q
+	} else {
+		queries = append(queries, q)
+	}
+	return s.write(cube, queries)
+}
+
+func (s *savedQueryStore) delete(cube, name string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	queries, err := s.list(cube)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(queries, func(e SavedQuery) bool { return e.Name == name })
+	if i < 0 {
+		return errSavedQueryNotFound
+	}
+	return s.write(cube, slices.Delete(queries, i, i+1))
+}
+
+// validate checks that the query names a known kind and carries a request
+// that kind's endpoint would accept, so a typo in a match string fails the
+// save rather than every later run.
+func (q SavedQuery) validate() error {
+	if strings.TrimSpace(q.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	switch q.Kind {
+	case savedKindPivot:
+		if q.Pivot == nil {
+			return fmt.Errorf("pivot query needs a pivot request")
+		}
+		return q.Pivot.validate()
+	case savedKindCards, savedKindSynergy:
+		if _, err := query.Parse(q.Params["match"]); err != nil {
+			return fmt.Errorf("invalid match query: %v", err)
+		}
+		return decks.ValidBucketBy(q.Params["bucket_by"])
+	default:
+		return fmt.Errorf("unknown kind %q", q.Kind)
+	}
+}
+
+// SavedQueries serves CRUD and re-execution for a cube's saved queries. Build
+// one with NewSavedQueries and register its handlers; they share the store so
+// writes are serialized across routes.
+type SavedQueries struct {
+	store *savedQueryStore
+
+	// runners maps a query kind to the endpoint that executes it.
+	runners map[string]http.Handler
+}
+
+func NewSavedQueries() *SavedQueries {
+	return newSavedQueries("data", map[string]http.Handler{
+		savedKindPivot:   PivotHandler(),
+		savedKindCards:   CardStatsHandler(),
+		savedKindSynergy: SynergyStatsHandler(),
+	})
+}
+
+func newSavedQueries(dataRoot string, runners map[string]http.Handler) *SavedQueries {
+	return &SavedQueries{store: &savedQueryStore{dataRoot: dataRoot}, runners: runners}
+}
+
+// ListHandler handles GET /api/{cube}/saved.
+func (s *SavedQueries) ListHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		queries, err := s.store.list(server.CubeFromRequest(r))
+		if err != nil {
+			logrus.WithError(err).Error("could not load saved queries")
+			http.Error(rw, "could not load saved queries", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(rw, SavedQueriesResponse{Queries: queries})
+	})
+}
+
+// GetHandler handles GET /api/{cube}/saved/{name}.
+func (s *SavedQueries) GetHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		q, ok := s.lookup(rw, r)
+		if !ok {
+			return
+		}
+		writeJSON(rw, q)
+	})
+}
+
+// SaveHandler handles POST /api/{cube}/saved/{name}, creating or replacing the
+// named query. The name in the path wins over any name in the body.
+func (s *SavedQueries) SaveHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var q SavedQuery
+		if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+			http.Error(rw, fmt.Sprintf("invalid JSON: %v", err), http.StatusBadRequest)
+			return
+		}
+		q.Name = r.PathValue("name")
+		if err := q.validate(); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		q.Updated = time.Now().UTC().Format(time.RFC3339)
+
+		cubeID := server.CubeFromRequest(r)
+		if err := s.store.put(cubeID, q); err != nil {
+			logrus.WithError(err).Error("could not save query")
+			http.Error(rw, "could not save query", http.StatusInternalServerError)
+			return
+		}
+		logrus.WithFields(logrus.Fields{"cube": cubeID, "name": q.Name}).Info("Saved query")
+		writeJSON(rw, q)
+	})
+}
+
+// DeleteHandler handles DELETE /api/{cube}/saved/{name}.
+func (s *SavedQueries) DeleteHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		err := s.store.delete(server.CubeFromRequest(r), r.PathValue("name"))
+		if errors.Is(err, errSavedQueryNotFound) {
+			http.NotFound(rw, r)
+			return
+		}
+		if err != nil {
+			logrus.WithError(err).Error("could not delete saved query")
+			http.Error(rw, "could not delete saved query", http.StatusInternalServerError)
+			return
+		}
+		rw.WriteHeader(http.StatusOK)
+	})
+}
+
+// RunHandler handles GET /api/{cube}/saved/{name}/run. It replays the saved
+// request through the same handler that serves the live endpoint, so the
+// response is exactly what the original page would get on today's data. The
+// rebuilt request goes through the same validation as the live route too,
+// which catches queries saved before it was checked on save.
+func (s *SavedQueries) RunHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		q, ok := s.lookup(rw, r)
+		if !ok {
+			return
+		}
+		runner, ok := s.runners[q.Kind]
+		if !ok {
+			http.Error(rw, fmt.Sprintf("no runner for kind %q", q.Kind), http.StatusInternalServerError)
+			return
+		}
+		inner, err := savedRequest(r, q)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		logrus.WithFields(logrus.Fields{"name": q.Name, "kind": q.Kind}).Info("/api/saved/run")
+		server.WithValidMatch(server.WithValidBucketBy(runner)).ServeHTTP(rw, inner)
+	})
+}
+
+// lookup loads the query named in the path, writing a 404 or 500 and
+// returning ok=false when it can't.
+func (s *SavedQueries) lookup(rw http.ResponseWriter, r *http.Request) (SavedQuery, bool) {
+	q, err := s.store.get(server.CubeFromRequest(r), r.PathValue("name"))
+	if errors.Is(err, errSavedQueryNotFound) {
+		http.NotFound(rw, r)
+		return SavedQuery{}, false
+	}
+	if err != nil {
+		logrus.WithError(err).Error("could not load saved queries")
+		http.Error(rw, "could not load saved queries", http.StatusInternalServerError)
+		return SavedQuery{}, false
+	}
+	return q, true
+}
+
+// savedRequest rebuilds the request the saved query stands for, keeping the
+// incoming request's context so the cube ID carries through.
+func savedRequest(r *http.Request, q SavedQuery) (*http.Request, error) {
+	inner := r.Clone(r.Context())
+	if q.Kind == savedKindPivot {
+		body, err := json.Marshal(q.Pivot)
+		if err != nil {
+			return nil, err
+		}
+		inner.Method = http.MethodPost
+		inner.Body = io.NopCloser(bytes.NewReader(body))
+		inner.ContentLength = int64(len(body))
+		inner.URL.RawQuery = ""
+		return inner, nil
+	}
+	params := url.Values{}
+	for k, v := range q.Params {
+		params.Set(k, v)
+	}
+	inner.Method = http.MethodGet
+	inner.Body = http.NoBody
+	inner.URL.RawQuery = params.Encode()
+	return inner, nil
+}
+
+// writeJSON marshals v as the response body, logging rather than failing when
+// the write itself errors (the status line is already sent by then).
+func writeJSON(rw http.ResponseWriter, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		http.Error(rw, "could not marshal response", http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	if _, err := rw.Write(b); err != nil {
+		logrus.WithError(err).Error("could not write response")
+	}
+}
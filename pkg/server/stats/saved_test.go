@@ -0,0 +1,152 @@
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/caseydavenport/cube-tools/pkg/server"
+	"github.com/caseydavenport/cube-tools/pkg/storage"
+	"github.com/caseydavenport/cube-tools/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func savedRequestFor(method, target, name, body string) *http.Request {
+	r := httptest.NewRequest(method, target, nil)
+	if body != "" {
+		r.Body = io.NopCloser(strings.NewReader(body))
+	}
+	r.SetPathValue("name", name)
+	return r.WithContext(server.ContextWithCube(context.Background(), "polyverse"))
+}
+
+func newTestSavedQueries(t *testing.T, runners map[string]http.Handler) *SavedQueries {
+	t.Helper()
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "polyverse"), 0o755))
+	return newSavedQueries(root, runners)
+}
+
+func TestSavedQueries_CRUD(t *testing.T) {
+	s := newTestSavedQueries(t, nil)
+
+	// Save two queries; the path name wins over the body.
+	w := httptest.NewRecorder()
+	s.SaveHandler().ServeHTTP(w, savedRequestFor("POST", "/api/polyverse/saved/red", "red cards",
+		`{"name": "ignored", "kind": "cards", "params": {"color": "R", "min_games": "20"}}`))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = httptest.NewRecorder()
+	s.SaveHandler().ServeHTTP(w, savedRequestFor("POST", "/api/polyverse/saved/arch", "archetypes",
+		`{"kind": "pivot", "pivot": {"group_by": {"dim": "archetype"}}}`))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// List is sorted by name.
+	w = httptest.NewRecorder()
+	s.ListHandler().ServeHTTP(w, savedRequestFor("GET", "/api/polyverse/saved", "", ""))
+	var list SavedQueriesResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Queries, 2)
+	assert.Equal(t, "archetypes", list.Queries[0].Name)
+	assert.Equal(t, "red cards", list.Queries[1].Name)
+	assert.Equal(t, "R", list.Queries[1].Params["color"])
+	assert.NotEmpty(t, list.Queries[1].Updated)
+
+	// Saving under an existing name replaces it.
+	w = httptest.NewRecorder()
+	s.SaveHandler().ServeHTTP(w, savedRequestFor("POST", "/api/polyverse/saved/red", "red cards",
+		`{"kind": "cards", "params": {"color": "RG"}}`))
+	require.Equal(t, http.StatusOK, w.Code)
+	w = httptest.NewRecorder()
+	s.GetHandler().ServeHTTP(w, savedRequestFor("GET", "/api/polyverse/saved/red", "red cards", ""))
+	var got SavedQuery
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, "RG", got.Params["color"])
+
+	// Delete, then the query is gone.
+	w = httptest.NewRecorder()
+	s.DeleteHandler().ServeHTTP(w, savedRequestFor("DELETE", "/api/polyverse/saved/red", "red cards", ""))
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = httptest.NewRecorder()
+	s.GetHandler().ServeHTTP(w, savedRequestFor("GET", "/api/polyverse/saved/red", "red cards", ""))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = httptest.NewRecorder()
+	s.DeleteHandler().ServeHTTP(w, savedRequestFor("DELETE", "/api/polyverse/saved/red", "red cards", ""))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestSavedQueries_RejectsInvalid(t *testing.T) {
+	s := newTestSavedQueries(t, nil)
+	for _, body := range []string{
+		`{"kind": "bogus"}`,
+		`{"kind": "pivot"}`,
+		`not json`,
+		// Requests their endpoint would reject.
+		`{"kind": "cards", "params": {"match": "(t:creature"}}`,
+		`{"kind": "synergy", "params": {"match": "foo:bar"}}`,
+		`{"kind": "cards", "params": {"bucket_by": "fortnight"}}`,
+		`{"kind": "pivot", "pivot": {"predicates": [{"dim": "card_query", "value": "(t:creature"}]}}`,
+		`{"kind": "pivot", "pivot": {"bucket_by": "fortnight"}}`,
+	} {
+		w := httptest.NewRecorder()
+		s.SaveHandler().ServeHTTP(w, savedRequestFor("POST", "/api/polyverse/saved/x", "x", body))
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+}
+
+// A query saved before match strings were checked still gets a 400 when run,
+// rather than reaching the endpoint.
+func TestSavedQueries_RunValidatesMatch(t *testing.T) {
+	ran := false
+	s := newTestSavedQueries(t, map[string]http.Handler{
+		savedKindCards: http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) { ran = true }),
+	})
+	require.NoError(t, s.store.write("polyverse", []SavedQuery{
+		{Name: "typo", Kind: savedKindCards, Params: map[string]string{"match": "(t:creature"}},
+	}))
+
+	w := httptest.NewRecorder()
+	s.RunHandler().ServeHTTP(w, savedRequestFor("GET", "/api/polyverse/saved/typo/run", "typo", ""))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.False(t, ran)
+}
+
+// Running a saved pivot replays it through the pivot handler against the
+// store's current decks.
+func TestSavedQueries_RunPivot(t *testing.T) {
+	decks := []*storage.Deck{
+		makePivotDeck("Alice", "d1", "2025-01-01", []string{"W"}, "aggro", nil,
+			[]types.Game{{Opponent: "Bob", Winner: "Alice"}, {Opponent: "Bob", Winner: "Alice"}}),
+		makePivotDeck("Bob", "d1", "2025-01-01", []string{"R"}, "control", nil,
+			[]types.Game{{Opponent: "Alice", Winner: "Alice"}, {Opponent: "Alice", Winner: "Alice"}}),
+	}
+	s := newTestSavedQueries(t, map[string]http.Handler{
+		savedKindPivot: &pivotHandler{store: &mockDeckStorage{decks: decks}},
+	})
+
+	w := httptest.NewRecorder()
+	s.SaveHandler().ServeHTTP(w, savedRequestFor("POST", "/api/polyverse/saved/arch", "arch",
+		`{"kind": "pivot", "pivot": {"group_by": {"dim": "archetype"}}}`))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	s.RunHandler().ServeHTTP(w, savedRequestFor("GET", "/api/polyverse/saved/arch/run", "arch", ""))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp PivotResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "archetype", resp.GroupBy)
+	aggro := rowByKey(&resp, "aggro")
+	require.NotNil(t, aggro)
+	assert.Equal(t, 2, aggro.Cells[""].Wins)
+	control := rowByKey(&resp, "control")
+	require.NotNil(t, control)
+	assert.Equal(t, 2, control.Cells[""].Losses)
+}
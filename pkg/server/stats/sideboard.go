@@ -0,0 +1,216 @@
+package stats
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/caseydavenport/cube-tools/pkg/server"
+	"github.com/caseydavenport/cube-tools/pkg/server/decks"
+	"github.com/caseydavenport/cube-tools/pkg/server/query"
+	"github.com/caseydavenport/cube-tools/pkg/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// The sideboard report looks at the build step of a draft rather than the
+// picks. Pick Elo already pits each mainboard card against the castable cards
+// left beside it; this turns the same comparison into build advice. For each
+// card it counts how often a deck that could cast it left it in the
+// sideboard, compares the record of decks that played it with those that
+// didn't, and measures how often drafting the card turned into playing it,
+// overall and per color pair.
+//
+// Only decks with a recorded mainboard count. A pool with no build tells us
+// what was drafted but not what was played.
+
+// defaultSideboardMinDecks is the fewest decks that must have had a card
+// available to build with before it's reported.
+const defaultSideboardMinDecks = 3
+
+// SideboardResponse is the API response for /api/stats/sideboard.
+type SideboardResponse struct {
+	// Decks is the number of built decks considered.
+	Decks int `json:"decks"`
+
+	// Cards are ordered by how often they were left in a sideboard that could
+	// cast them.
+	Cards []*SideboardCard `json:"cards"`
+}
+
+type SideboardCard struct {
+	Name    string   `json:"name"`
+	Colors  []string `json:"colors"`
+	PickElo int      `json:"pick_elo"`
+
+	// Mained is the number of decks that played the card, and Sided the number
+	// that could cast it but left it in the sideboard. MainRate is the
+	// percentage of the two that played it.
+	Mained   int     `json:"mained"`
+	Sided    int     `json:"sided"`
+	MainRate float64 `json:"main_rate"`
+
+	// MainRecord and SidedRecord are the records of those two groups of decks.
+	// WinDelta is the first less the second, and Significant whether the
+	// difference holds up at the request's confidence.
+	MainRecord  Record  `json:"main_record"`
+	SidedRecord Record  `json:"sided_record"`
+	WinDelta    float64 `json:"win_delta"`
+	Significant bool    `json:"significant"`
+
+	// Drafted counts decks with the card anywhere in their pool, castable or
+	// not, and Conversion the percentage of those that played it.
+	Drafted    int     `json:"drafted"`
+	Conversion float64 `json:"conversion"`
+
+	// ByColors splits Conversion by the drafting deck's colors.
+	ByColors []*PoolConversion `json:"by_colors"`
+}
+
+// PoolConversion is a card's draft-to-mainboard rate among decks of one color
+// identity. Three-color decks that splash their third color count as their
+// primary pair.
+type PoolConversion struct {
+	Colors     string  `json:"colors"`
+	Drafted    int     `json:"drafted"`
+	Mained     int     `json:"mained"`
+	Conversion float64 `json:"conversion"`
+}
+
+func SideboardHandler() http.Handler {
+	return &sideboardHandler{store: storage.NewFileDeckStoreWithCache()}
+}
+
+type sideboardHandler struct {
+	store storage.DeckStorage
+}
+
+func (h *sideboardHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	dr := decks.ParseDecksRequest(r)
+	logrus.WithField("params", dr).Info("/api/stats/sideboard")
+
+	cubeID := server.CubeFromRequest(r)
+	allDecks, err := h.store.List(cubeID, dr)
+	if err != nil {
+		http.Error(rw, "could not load decks", http.StatusInternalServerError)
+		return
+	}
+	minDecks := query.GetInt(r, "min_decks")
+	if minDecks <= 0 {
+		minDecks = defaultSideboardMinDecks
+	}
+	writeJSON(rw, sideboardReport(allDecks, minDecks, zForConfidence(query.GetFloat(r, "confidence"))))
+}
+
+// sideboardReport builds the report, keeping cards that were available to at
+// least minDecks decks: played, or castable and sided.
+func sideboardReport(allDecks []*storage.Deck, minDecks int, z float64) *SideboardResponse {
+	var built []*storage.Deck
+	for _, d := range allDecks {
+		if len(d.Mainboard) > 0 {
+			built = append(built, d)
+		}
+	}
+	resp := &SideboardResponse{Decks: len(built), Cards: []*SideboardCard{}}
+	elo := PickELOData(built)
+
+	cards := map[string]*SideboardCard{}
+	byColors := map[string]map[string]*PoolConversion{}
+	get := func(name string, colors []string) *SideboardCard {
+		sc, ok := cards[name]
+		if !ok {
+			sc = &SideboardCard{Name: name, Colors: colors, PickElo: elo[name]}
+			cards[name] = sc
+			byColors[name] = map[string]*PoolConversion{}
+		}
+		return sc
+	}
+
+	for _, d := range built {
+		key := conversionColors(d)
+
+		// A card counts once per deck, however many copies it held.
+		mained := map[string]bool{}
+		for _, c := range d.Mainboard {
+			if c.IsBasicLand() || mained[c.Name] {
+				continue
+			}
+			mained[c.Name] = true
+			sc := get(c.Name, c.Colors)
+			sc.Mained++
+			sc.MainRecord.Add(d)
+		}
+		sided := map[string]bool{}
+		for _, c := range d.Sideboard {
+			if c.IsBasicLand() || mained[c.Name] || sided[c.Name] || !d.CanCast(c) {
+				continue
+			}
+			sided[c.Name] = true
+			sc := get(c.Name, c.Colors)
+			sc.Sided++
+			sc.SidedRecord.Add(d)
+		}
+
+		drafted := map[string]bool{}
+		for _, c := range d.AllCards() {
+			if c.IsBasicLand() || drafted[c.Name] {
+				continue
+			}
+			drafted[c.Name] = true
+			sc := get(c.Name, c.Colors)
+			sc.Drafted++
+			pc, ok := byColors[c.Name][key]
+			if !ok {
+				pc = &PoolConversion{Colors: key}
+				byColors[c.Name][key] = pc
+			}
+			pc.Drafted++
+			if mained[c.Name] {
+				pc.Mained++
+			}
+		}
+	}
+
+	for name, sc := range cards {
+		if sc.Mained+sc.Sided < minDecks {
+			continue
+		}
+		sc.MainRate = pct(float64(sc.Mained), float64(sc.Mained+sc.Sided))
+		sc.Conversion = pct(float64(sc.Mained), float64(sc.Drafted))
+		for _, rec := range []*Record{&sc.MainRecord, &sc.SidedRecord} {
+			rec.Finalize()
+			rec.SetInterval(z)
+		}
+		if sc.Mained > 0 && sc.Sided > 0 {
+			sc.WinDelta = round1(sc.MainRecord.WinPercent - sc.SidedRecord.WinPercent)
+			sc.Significant = diffSignificant(sc.MainRecord, sc.SidedRecord, z)
+		}
+
+		sc.ByColors = make([]*PoolConversion, 0, len(byColors[name]))
+		for _, pc := range byColors[name] {
+			pc.Conversion = pct(float64(pc.Mained), float64(pc.Drafted))
+			sc.ByColors = append(sc.ByColors, pc)
+		}
+		sort.Slice(sc.ByColors, func(i, j int) bool {
+			return colorRank(sc.ByColors[i].Colors) < colorRank(sc.ByColors[j].Colors)
+		})
+		resp.Cards = append(resp.Cards, sc)
+	}
+
+	sort.Slice(resp.Cards, func(i, j int) bool {
+		a, b := resp.Cards[i], resp.Cards[j]
+		if a.Sided != b.Sided {
+			return a.Sided > b.Sided
+		}
+		return a.Name < b.Name
+	})
+	return resp
+}
+
+// conversionColors keys a deck for the per-color conversion split: its colors
+// in WUBRG order, or its primary pair when any further colors are splashes.
+func conversionColors(d *storage.Deck) string {
+	if pair := d.PrimaryColorPair(); pair != nil {
+		return strings.Join(pair, "")
+	}
+	return deckColorString(d)
+}
@@ -0,0 +1,86 @@
+package stats
+
+import (
+	"testing"
+
+	"github.com/caseydavenport/cube-tools/pkg/storage"
+	"github.com/caseydavenport/cube-tools/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sideboardCard(resp *SideboardResponse, name string) *SideboardCard {
+	for _, c := range resp.Cards {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestSideboardReport(t *testing.T) {
+	bolt := types.Card{Name: "Lightning Bolt", Colors: []string{"R"}, ColorIdentity: []string{"R"}}
+	shock := types.Card{Name: "Shock", Colors: []string{"R"}, ColorIdentity: []string{"R"}}
+	murder := types.Card{Name: "Murder", Colors: []string{"B"}, ColorIdentity: []string{"B"}}
+	win := []types.Game{{Opponent: "X", Winner: "P"}, {Opponent: "X", Winner: "P"}}
+	loss := []types.Game{{Opponent: "X", Winner: "X"}, {Opponent: "X", Winner: "X"}}
+
+	deck := func(player string, colors []string, main, side []types.Card, games []types.Game) *storage.Deck {
+		for i := range games {
+			if games[i].Winner == "P" {
+				games[i].Winner = player
+			}
+		}
+		d := makePivotDeck(player, "d1", "2025-01-01", colors, "aggro", main, games)
+		d.Sideboard = side
+		return d
+	}
+	decks := []*storage.Deck{
+		// Two red decks play Shock and win; two leave it out and lose.
+		deck("A", []string{"R", "G"}, []types.Card{bolt, shock}, nil, append([]types.Game{}, win...)),
+		deck("B", []string{"R", "G"}, []types.Card{bolt, shock}, nil, append([]types.Game{}, win...)),
+		deck("C", []string{"B", "R"}, []types.Card{bolt}, []types.Card{shock}, append([]types.Game{}, loss...)),
+		deck("D", []string{"B", "R"}, []types.Card{bolt}, []types.Card{shock, murder}, append([]types.Game{}, loss...)),
+		// A blue-green deck drafted Shock but couldn't cast it.
+		deck("E", []string{"U", "G"}, []types.Card{{Name: "Island"}}, []types.Card{shock}, append([]types.Game{}, win...)),
+		// A pool with no build doesn't count.
+		{Deck: types.Deck{Pool: []types.Card{shock}}},
+	}
+
+	resp := sideboardReport(decks, 1, zForConfidence(0.8))
+	assert.Equal(t, 5, resp.Decks)
+
+	s := sideboardCard(resp, "Shock")
+	require.NotNil(t, s)
+	assert.Equal(t, 2, s.Mained)
+	assert.Equal(t, 2, s.Sided, "the blue-green deck couldn't cast it")
+	assert.Equal(t, 50.0, s.MainRate)
+	assert.Equal(t, 100.0, s.MainRecord.WinPercent)
+	assert.Equal(t, 0.0, s.SidedRecord.WinPercent)
+	assert.Equal(t, 100.0, s.WinDelta)
+	assert.True(t, s.Significant)
+	assert.Equal(t, 5, s.Drafted)
+	assert.Equal(t, 40.0, s.Conversion)
+
+	require.Len(t, s.ByColors, 3)
+	assert.Equal(t, "UG", s.ByColors[0].Colors)
+	assert.Equal(t, 0.0, s.ByColors[0].Conversion)
+	assert.Equal(t, "BR", s.ByColors[1].Colors)
+	assert.Equal(t, 0.0, s.ByColors[1].Conversion)
+	assert.Equal(t, "RG", s.ByColors[2].Colors)
+	assert.Equal(t, 100.0, s.ByColors[2].Conversion)
+
+	// Murder sat in a black deck's sideboard and was never played.
+	m := sideboardCard(resp, "Murder")
+	require.NotNil(t, m)
+	assert.Equal(t, 0, m.Mained)
+	assert.Equal(t, 1, m.Sided)
+	assert.Equal(t, 0.0, m.WinDelta)
+	assert.False(t, m.Significant)
+
+	// Shock was sided most often, so it leads.
+	assert.Equal(t, "Shock", resp.Cards[0].Name)
+
+	// Raising the bar drops Murder.
+	assert.Nil(t, sideboardCard(sideboardReport(decks, 2, zForConfidence(0.8)), "Murder"))
+}
@@ -3,6 +3,7 @@ package stats
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
 	"sort"
 	"strconv"
@@ -40,6 +41,10 @@ type SynergyStatsRequest struct {
 	// "losing" (wins < losses). Any other value uses every deck. Lets the
 	// pop/synergy graph compare how cards shift between winning and losing decks.
 	Record string `json:"record"`
+
+	// Confidence level for the interaction interval (0,1). Defaults to
+	// defaultConfidence. See confidence.go.
+	Confidence float64 `json:"confidence"`
 }
 
 type SynergyStatsResponse struct {
@@ -47,6 +52,11 @@ type SynergyStatsResponse struct {
 	Pairs      []SynergyResult `json:"pairs"`
 	FocalStats []CardFocalStat `json:"focal_stats"`
 
+	// InteractionRanking is the pairs whose win rate together most beats what
+	// each card does alone, ranked by the lower bound of the interaction
+	// effect. Unlike Pairs it isn't ordered by how often the cards meet.
+	InteractionRanking []SynergyResult `json:"interaction_ranking"`
+
 	// CardPlayCounts maps each played card to the number of decks in this pool that
 	// mainboarded it. Unlike FocalStats it covers every played card, so the
 	// winning-vs-losing comparison can tell "not played here" from "played, no synergy".
@@ -92,6 +102,28 @@ type SynergyResult struct {
 	// of that ceiling it filled. A modest lift at high saturation is a maxed-out rare
 	// pair; the same lift at low saturation has headroom left.
 	Saturation float64 `json:"saturation"`
+
+	// Interaction compares the pair's win rate with each card alone and with
+	// neither. Nil when one of those groups has no games.
+	Interaction *PairInteraction `json:"interaction,omitempty"`
+}
+
+// PairInteraction splits the decks that could cast both cards of a pair by
+// which of the two they played. Effect is the interaction term, in percentage
+// points: (both - card1 only) - (card2 only - neither), the extra win rate the
+// pair earns together beyond what each card adds by itself. A pair that's
+// merely played together a lot has an effect near zero; one that actually
+// wins together has a positive one.
+type PairInteraction struct {
+	Both      Record `json:"both"`
+	Card1Only Record `json:"card1_only"`
+	Card2Only Record `json:"card2_only"`
+	Neither   Record `json:"neither"`
+
+	Effect      float64 `json:"effect"`
+	EffectLow   float64 `json:"effect_low"`
+	EffectHigh  float64 `json:"effect_high"`
+	Significant bool    `json:"significant"`
 }
 
 type pair struct {
@@ -133,6 +165,7 @@ func parseSynergyRequest(r *http.Request) *SynergyStatsRequest {
 	// Default to false; only enable when explicitly set to "true".
 	p.ColorAdjust = r.URL.Query().Get("color_adjust") == "true"
 	p.Record = r.URL.Query().Get("record")
+	p.Confidence = query.GetFloat(r, "confidence")
 	p.DecksRequest = decks.ParseDecksRequest(r)
 	return &p
 }
@@ -189,6 +222,13 @@ func (s *synergyStatsHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request)
 		http.Error(rw, "could not load decks", http.StatusInternalServerError)
 		return
 	}
+
+	// Stat terms describe a card's overall results in the range, so compute
+	// them before narrowing to winning or losing decks.
+	var keep map[string]bool
+	if q := statsQuery(sr.Match); q != nil {
+		keep = cardsMatchingStats(q, allDecks, cubeCardMap)
+	}
 	allDecks = filterByRecord(allDecks, sr.Record)
 
 	numDecks := len(allDecks)
@@ -309,11 +349,13 @@ func (s *synergyStatsHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request)
 	}
 
 	resp := SynergyStatsResponse{
-		TotalDecks:     numDecks,
-		Pairs:          []SynergyResult{},
-		FocalStats:     []CardFocalStat{},
-		CardPlayCounts: cardCounts,
+		TotalDecks:         numDecks,
+		Pairs:              []SynergyResult{},
+		FocalStats:         []CardFocalStat{},
+		InteractionRanking: []SynergyResult{},
+		CardPlayCounts:     cardCounts,
 	}
+	z := zForConfidence(sr.Confidence)
 
 	// Temporary map to hold synergy scores for each card.
 	cardSynergies := make(map[string][]SynergyResult)
@@ -382,9 +424,13 @@ func (s *synergyStatsHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request)
 			SynergyScore:  score,
 			WinPercent:    stats.WinPercent,
 			Saturation:    saturation,
+			Interaction:   pairInteraction(p, allDecks, deckHasCard, deckCanCast, z),
 		}
 
 		resp.Pairs = append(resp.Pairs, result)
+		if result.Interaction != nil {
+			resp.InteractionRanking = append(resp.InteractionRanking, result)
+		}
 
 		// Collect synergies for focal score calculation
 		cardSynergies[p.c1] = append(cardSynergies[p.c1], result)
@@ -459,6 +505,10 @@ func (s *synergyStatsHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request)
 		})
 	}
 
+	if keep != nil {
+		restrictSynergy(&resp, keep)
+	}
+
 	// Sort FocalStats by FocalScore descending
 	sort.Slice(resp.FocalStats, func(i, j int) bool {
 		return resp.FocalStats[i].FocalScore > resp.FocalStats[j].FocalScore
@@ -472,10 +522,21 @@ func (s *synergyStatsHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request)
 		return resp.Pairs[i].SynergyScore > resp.Pairs[j].SynergyScore
 	})
 
+	sort.Slice(resp.InteractionRanking, func(i, j int) bool {
+		a, b := resp.InteractionRanking[i].Interaction, resp.InteractionRanking[j].Interaction
+		if a.EffectLow == b.EffectLow {
+			return a.Effect > b.Effect
+		}
+		return a.EffectLow > b.EffectLow
+	})
+
 	// Limit to top 100 for API response sanity.
 	if len(resp.Pairs) > 100 {
 		resp.Pairs = resp.Pairs[:100]
 	}
+	if len(resp.InteractionRanking) > 100 {
+		resp.InteractionRanking = resp.InteractionRanking[:100]
+	}
 
 	// Marshal and write response.
 	b, err := json.Marshal(resp)
@@ -489,3 +550,100 @@ func (s *synergyStatsHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request)
 		logrus.WithError(err).Error("could not write response")
 	}
 }
+
+// pairInteraction builds the interaction comparison for a pair over the decks
+// that could cast both cards (or played them anyway). The interval is the
+// normal approximation, summing the variances of the four win rates. Each
+// variance uses the rate with a win and a loss added, (w+1)/(n+2), so a group
+// that won or lost every game still counts its uncertainty.
+func pairInteraction(p pair, allDecks []*storage.Deck, deckHasCard, deckCanCast []map[string]bool, z float64) *PairInteraction {
+	pi := &PairInteraction{}
+	for i, d := range allDecks {
+		has1, has2 := deckHasCard[i][p.c1], deckHasCard[i][p.c2]
+		if !(has1 || deckCanCast[i][p.c1]) || !(has2 || deckCanCast[i][p.c2]) {
+			continue
+		}
+		switch {
+		case has1 && has2:
+			pi.Both.Add(d)
+		case has1:
+			pi.Card1Only.Add(d)
+		case has2:
+			pi.Card2Only.Add(d)
+		default:
+			pi.Neither.Add(d)
+		}
+	}
+
+	effect, variance := 0.0, 0.0
+	for i, r := range []*Record{&pi.Both, &pi.Card1Only, &pi.Card2Only, &pi.Neither} {
+		n := float64(r.Wins + r.Losses + r.Draws)
+		if n == 0 {
+			return nil
+		}
+		r.Finalize()
+		r.SetInterval(z)
+		rate := (float64(r.Wins) + float64(r.Draws)/2) / n
+		if i == 0 || i == 3 {
+			effect += rate
+		} else {
+			effect -= rate
+		}
+		adj := (n*rate + 1) / (n + 2)
+		variance += adj * (1 - adj) / (n + 2)
+	}
+	margin := z * math.Sqrt(variance)
+	pi.Effect = round1(100 * effect)
+	pi.EffectLow = round1(100 * (effect - margin))
+	pi.EffectHigh = round1(100 * (effect + margin))
+	pi.Significant = pi.EffectLow > 0 || pi.EffectHigh < 0
+	return pi
+}
+
+// cardsMatchingStats computes card stats over decks and returns the names of
+// the cards satisfying q, stat terms included.
+func cardsMatchingStats(q *query.Query, decks []*storage.Deck, cubeCards map[string]types.Card) map[string]bool {
+	stats := cardStatsForDecks(decks, cubeCards, &CardStatsRequest{DecksRequest: &storage.DecksRequest{}})
+	keep := make(map[string]bool)
+	for name, cs := range stats.Data {
+		if q.MatchesCardStats(cs.Card, cs.queryStats()) {
+			keep[name] = true
+		}
+	}
+	return keep
+}
+
+// restrictSynergy narrows a synergy response to the kept cards: pairs where
+// both cards are kept, and focal stats and play counts for kept cards. Scores
+// are untouched, since they were computed against the full deck population.
+func restrictSynergy(resp *SynergyStatsResponse, keep map[string]bool) {
+	pairs := resp.Pairs[:0]
+	for _, p := range resp.Pairs {
+		if keep[p.Card1] && keep[p.Card2] {
+			pairs = append(pairs, p)
+		}
+	}
+	resp.Pairs = pairs
+
+	ranking := resp.InteractionRanking[:0]
+	for _, p := range resp.InteractionRanking {
+		if keep[p.Card1] && keep[p.Card2] {
+			ranking = append(ranking, p)
+		}
+	}
+	resp.InteractionRanking = ranking
+
+	focal := resp.FocalStats[:0]
+	for _, f := range resp.FocalStats {
+		if keep[f.CardName] {
+			focal = append(focal, f)
+		}
+	}
+	resp.FocalStats = focal
+
+	for name := range resp.CardPlayCounts {
+		if !keep[name] {
+			delete(resp.CardPlayCounts, name)
+		}
+	}
+}
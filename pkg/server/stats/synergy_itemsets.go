@@ -0,0 +1,315 @@
+package stats
+
+import (
+	"fmt"
+	"math/bits"
+	"net/http"
+	"sort"
+
+	"github.com/caseydavenport/cube-tools/pkg/server"
+	"github.com/caseydavenport/cube-tools/pkg/server/query"
+	"github.com/caseydavenport/cube-tools/pkg/storage"
+	"github.com/caseydavenport/cube-tools/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// Pairwise lift can't see an engine. If a sac outlet, a token maker and a
+// payoff only shine together, each pair among them looks merely decent, and
+// the package gets lost among pairs that are just two good cards in the same
+// color. The itemset report mines the mainboards for sets of three or four
+// cards that show up together in at least MinDecks decks and scores each set
+// against the best prediction its own subsets make.
+//
+// Mining is Eclat: every card gets a bitset of the decks that played it, and
+// sets grow depth-first by intersecting bitsets, dropping any branch once it
+// falls under the minimum support. With a few hundred decks the bitsets are a
+// handful of words each, so this is quicker and much simpler than building an
+// FP-tree.
+//
+// The lift of a set S is its count over the largest leave-one-out expectation,
+// max over cards c of count(S without c) * P(c). A set scores above 1 only if
+// it co-occurs more than any of its sub-packages plus one more card would
+// explain, so a strong pair with two passengers doesn't pass as a package.
+
+const (
+	// itemsetMinSize and itemsetMaxSize bound the sets mined when a request
+	// doesn't say. A request can't go past itemsetMaxSize, nor mine sets
+	// played by fewer than itemsetMinDecks decks: the number of sets grows
+	// combinatorially with both, and one request could tie up the server.
+	itemsetMinSize  = 3
+	itemsetMaxSize  = 4
+	itemsetMinDecks = 2
+
+	// itemsetLimit caps the response, like the top-100 pairs.
+	itemsetLimit = 100
+)
+
+type SynergyItemsetsResponse struct {
+	TotalDecks int              `json:"total_decks"`
+	Itemsets   []*ItemsetResult `json:"itemsets"`
+}
+
+type ItemsetResult struct {
+	Cards []string `json:"cards"`
+
+	// Count is the number of decks playing every card in the set, and Support
+	// the percentage of eligible decks that is.
+	Count         int     `json:"count"`
+	Support       float64 `json:"support"`
+	EligibleDecks int     `json:"eligible_decks"`
+
+	// SynergyScore is the shrunk lift over the best leave-one-out expectation,
+	// and Loosest the card whose removal gave that expectation: the one most
+	// likely along for the ride.
+	SynergyScore float64 `json:"synergy_score"`
+	Loosest      string  `json:"loosest"`
+
+	// Record is the combined record of the decks playing the set.
+	Record
+}
+
+func SynergyItemsetsHandler() http.Handler {
+	return &synergyItemsetsHandler{store: storage.NewFileDeckStoreWithCache()}
+}
+
+type synergyItemsetsHandler struct {
+	store storage.DeckStorage
+}
+
+func (s *synergyItemsetsHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	sr := parseSynergyRequest(r)
+	logrus.WithField("params", sr).Info("/api/stats/synergy/itemsets")
+	if sr.MinDecks < itemsetMinDecks {
+		http.Error(rw, fmt.Sprintf("min_decks must be at least %d", itemsetMinDecks), http.StatusBadRequest)
+		return
+	}
+
+	cubeID := server.CubeFromRequest(r)
+	cube, err := types.LoadCube(fmt.Sprintf("data/%s/cube.json", cubeID))
+	if err != nil {
+		http.Error(rw, "could not load cube", http.StatusInternalServerError)
+		return
+	}
+	cubeCards := make(map[string]types.Card)
+	for _, c := range cube.Cards {
+		cubeCards[c.Name] = c
+	}
+
+	allDecks, err := s.store.List(cubeID, sr.DecksRequest)
+	if err != nil {
+		http.Error(rw, "could not load decks", http.StatusInternalServerError)
+		return
+	}
+
+	// As with pairs, stat terms are judged over every deck in range.
+	var keep map[string]bool
+	if q := statsQuery(sr.Match); q != nil {
+		keep = cardsMatchingStats(q, allDecks, cubeCards)
+	}
+	allDecks = filterByRecord(allDecks, sr.Record)
+
+	minSize, maxSize := itemsetSizes(query.GetInt(r, "min_size"), query.GetInt(r, "max_size"))
+	resp := synergyItemsets(allDecks, cubeCards, sr, minSize, maxSize, zForConfidence(query.GetFloat(r, "confidence")))
+	if keep != nil {
+		kept := resp.Itemsets[:0]
+		for _, set := range resp.Itemsets {
+			if allKept(set.Cards, keep) {
+				kept = append(kept, set)
+			}
+		}
+		resp.Itemsets = kept
+	}
+	if len(resp.Itemsets) > itemsetLimit {
+		resp.Itemsets = resp.Itemsets[:itemsetLimit]
+	}
+	writeJSON(rw, resp)
+}
+
+// itemsetSizes resolves the requested set size bounds, filling in defaults
+// and holding both to itemsetMaxSize.
+func itemsetSizes(minSize, maxSize int) (int, int) {
+	if minSize < 2 {
+		minSize = itemsetMinSize
+	}
+	minSize = min(minSize, itemsetMaxSize)
+	if maxSize < minSize || maxSize > itemsetMaxSize {
+		maxSize = itemsetMaxSize
+	}
+	return minSize, maxSize
+}
+
+func allKept(cards []string, keep map[string]bool) bool {
+	for _, c := range cards {
+		if !keep[c] {
+			return false
+		}
+	}
+	return true
+}
+
+// synergyItemsets mines sets of minSize to maxSize cards, ranked by synergy
+// score. Only in-cube nonland mainboard cards count, as for pairs.
+func synergyItemsets(allDecks []*storage.Deck, cubeCards map[string]types.Card, sr *SynergyStatsRequest, minSize, maxSize int, z float64) *SynergyItemsetsResponse {
+	n := len(allDecks)
+	resp := &SynergyItemsetsResponse{TotalDecks: n, Itemsets: []*ItemsetResult{}}
+
+	played := map[string]deckSet{}
+	for i, d := range allDecks {
+		for _, c := range d.Mainboard {
+			// Deck lists don't always carry types, so ask the cube.
+			if cc, ok := cubeCards[c.Name]; !ok || cc.IsLand() || c.IsLand() {
+				continue
+			}
+			if _, ok := played[c.Name]; !ok {
+				played[c.Name] = newDeckSet(n)
+			}
+			played[c.Name].add(i)
+		}
+	}
+
+	// Cards under the minimum support can't be in a frequent set.
+	var items []string
+	for name, ds := range played {
+		if ds.count() >= sr.MinDecks {
+			items = append(items, name)
+		}
+	}
+	sort.Strings(items)
+
+	castable := map[string]deckSet{}
+	if sr.ColorAdjust {
+		for _, name := range items {
+			ds := newDeckSet(n)
+			for i, d := range allDecks {
+				if d.CanCast(cubeCards[name]) {
+					ds.add(i)
+				}
+			}
+			castable[name] = ds
+		}
+	}
+
+	all := newDeckSet(n)
+	for i := range allDecks {
+		all.add(i)
+	}
+
+	var grow func(prefix []string, decks deckSet, start int)
+	grow = func(prefix []string, decks deckSet, start int) {
+		if len(prefix) >= minSize {
+			if res := scoreItemset(prefix, decks, played, castable, all, allDecks, sr, z); res != nil {
+				resp.Itemsets = append(resp.Itemsets, res)
+			}
+		}
+		if len(prefix) == maxSize {
+			return
+		}
+		for j := start; j < len(items); j++ {
+			next := decks.and(played[items[j]])
+			if next.count() < sr.MinDecks {
+				continue
+			}
+			grow(append(prefix[:len(prefix):len(prefix)], items[j]), next, j+1)
+		}
+	}
+	grow(nil, all, 0)
+
+	sort.Slice(resp.Itemsets, func(i, j int) bool {
+		a, b := resp.Itemsets[i], resp.Itemsets[j]
+		if a.SynergyScore != b.SynergyScore {
+			return a.SynergyScore > b.SynergyScore
+		}
+		return a.Count > b.Count
+	})
+	return resp
+}
+
+// scoreItemset computes the lift and record of one frequent set, or nil when
+// there's no baseline to compare it with. With color adjustment on, every
+// count is taken over the decks that could cast the whole set.
+func scoreItemset(cards []string, decks deckSet, played, castable map[string]deckSet, all deckSet, allDecks []*storage.Deck, sr *SynergyStatsRequest, z float64) *ItemsetResult {
+	eligible := all
+	if sr.ColorAdjust {
+		for _, c := range cards {
+			eligible = eligible.and(castable[c])
+		}
+	}
+	total := eligible.count()
+	count := decks.and(eligible).count()
+	if total == 0 || count < sr.MinDecks {
+		return nil
+	}
+
+	// The leave-one-out expectations: the rest of the set's count times the
+	// left-out card's rate.
+	expected, loosest := 0.0, ""
+	for i, c := range cards {
+		rest := eligible
+		for j, other := range cards {
+			if j != i {
+				rest = rest.and(played[other])
+			}
+		}
+		e := float64(rest.count()) * float64(played[c].and(eligible).count()) / float64(total)
+		if e > expected {
+			expected, loosest = e, c
+		}
+	}
+	if expected == 0 {
+		return nil
+	}
+
+	res := &ItemsetResult{
+		Cards:         append([]string(nil), cards...),
+		Count:         count,
+		Support:       pct(float64(count), float64(total)),
+		EligibleDecks: total,
+		SynergyScore:  round3(shrinkLift(float64(count), expected, sr.SmoothingK)),
+		Loosest:       loosest,
+	}
+	for _, i := range decks.and(eligible).members() {
+		res.Add(allDecks[i])
+	}
+	res.Finalize()
+	res.SetInterval(z)
+	return res
+}
+
+// deckSet is a bitset of deck indices.
+type deckSet []uint64
+
+func newDeckSet(n int) deckSet {
+	return make(deckSet, (n+63)/64)
+}
+
+func (s deckSet) add(i int) {
+	s[i/64] |= 1 << (i % 64)
+}
+
+func (s deckSet) and(o deckSet) deckSet {
+	out := make(deckSet, len(s))
+	for i := range s {
+		out[i] = s[i] & o[i]
+	}
+	return out
+}
+
+func (s deckSet) count() int {
+	n := 0
+	for _, w := range s {
+		n += bits.OnesCount64(w)
+	}
+	return n
+}
+
+func (s deckSet) members() []int {
+	var out []int
+	for i, w := range s {
+		for w != 0 {
+			b := bits.TrailingZeros64(w)
+			out = append(out, i*64+b)
+			w &^= 1 << b
+		}
+	}
+	return out
+}
@@ -0,0 +1,181 @@
+package stats
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/caseydavenport/cube-tools/pkg/server"
+	"github.com/caseydavenport/cube-tools/pkg/storage"
+	"github.com/caseydavenport/cube-tools/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// itemsetFixture has a red three-card engine (A, B, C) that wins whenever it
+// comes together, a colorless pair (X, Y) that always brings a very common
+// card P along, and assorted partial packages.
+func itemsetFixture() ([]*storage.Deck, map[string]types.Card) {
+	red := func(name string) types.Card {
+		return types.Card{Name: name, Colors: []string{"R"}, ColorIdentity: []string{"R"}, Types: []string{"Creature"}}
+	}
+	colorless := func(name string) types.Card {
+		return types.Card{Name: name, Types: []string{"Artifact"}}
+	}
+	cube := map[string]types.Card{
+		"A": red("A"), "B": red("B"), "C": red("C"),
+		"X": colorless("X"), "Y": colorless("Y"), "P": colorless("P"),
+	}
+	cards := func(names ...string) []types.Card {
+		var out []types.Card
+		for _, n := range names {
+			out = append(out, cube[n])
+		}
+		return out
+	}
+
+	var ds []*storage.Deck
+	add := func(times int, colors []string, games []types.Game, names ...string) {
+		for range times {
+			ds = append(ds, makePivotDeck("p", "d1", "2025-01-01", colors, "", cards(names...), games))
+		}
+	}
+	win := []types.Game{{Opponent: "o", Winner: "p"}, {Opponent: "o", Winner: "p"}}
+	loss := []types.Game{{Opponent: "o", Winner: "o"}, {Opponent: "o", Winner: "o"}}
+	r, u := []string{"R"}, []string{"U"}
+
+	add(4, r, win, "A", "B", "C")
+	add(2, r, loss, "A", "B", "P")
+	add(2, r, loss, "B", "C", "P")
+	add(2, r, loss, "A", "C", "P")
+	add(4, u, loss, "X", "Y", "P")
+	add(4, u, loss, "P")
+	return ds, cube
+}
+
+func itemset(resp *SynergyItemsetsResponse, cards ...string) *ItemsetResult {
+	for _, s := range resp.Itemsets {
+		if assert.ObjectsAreEqual(s.Cards, cards) {
+			return s
+		}
+	}
+	return nil
+}
+
+func TestSynergyItemsets(t *testing.T) {
+	ds, cube := itemsetFixture()
+	sr := &SynergyStatsRequest{MinDecks: 3}
+	resp := synergyItemsets(ds, cube, sr, 3, 4, zForConfidence(0.8))
+	assert.Equal(t, 18, resp.TotalDecks)
+	require.Len(t, resp.Itemsets, 2)
+
+	// The engine leads: 4 decks against an expected 6 * 8/18 from any pair
+	// plus the third card.
+	abc := resp.Itemsets[0]
+	assert.Equal(t, []string{"A", "B", "C"}, abc.Cards)
+	assert.Equal(t, 4, abc.Count)
+	assert.Equal(t, 22.22, abc.Support)
+	assert.Equal(t, 1.5, abc.SynergyScore)
+	assert.Equal(t, 100.0, abc.WinPercent)
+	assert.True(t, abc.Significant)
+
+	// X and Y explain the set; P is along for the ride.
+	xyp := itemset(resp, "P", "X", "Y")
+	require.NotNil(t, xyp)
+	assert.Equal(t, "P", xyp.Loosest)
+	assert.Equal(t, 1.286, xyp.SynergyScore)
+	assert.Equal(t, 0.0, xyp.WinPercent)
+}
+
+func TestSynergyItemsets_Smoothing(t *testing.T) {
+	ds, cube := itemsetFixture()
+	resp := synergyItemsets(ds, cube, &SynergyStatsRequest{MinDecks: 3, SmoothingK: 5}, 3, 4, zForConfidence(0.8))
+	abc := itemset(resp, "A", "B", "C")
+	require.NotNil(t, abc)
+
+	// (4+5) / (8/3+5), pulled toward 1.
+	assert.InDelta(t, 1.174, abc.SynergyScore, 0.001)
+}
+
+func TestSynergyItemsets_ColorAdjust(t *testing.T) {
+	ds, cube := itemsetFixture()
+	resp := synergyItemsets(ds, cube, &SynergyStatsRequest{MinDecks: 3, ColorAdjust: true}, 3, 4, zForConfidence(0.8))
+
+	// Among the ten red decks C is common enough that the engine is no more
+	// than its pairs predict: 4 against 6 * 8/10.
+	abc := itemset(resp, "A", "B", "C")
+	require.NotNil(t, abc)
+	assert.Equal(t, 10, abc.EligibleDecks)
+	assert.Equal(t, 40.0, abc.Support)
+	assert.InDelta(t, 0.833, abc.SynergyScore, 0.001)
+}
+
+func TestSynergyItemsets_SizeBounds(t *testing.T) {
+	ds, cube := itemsetFixture()
+
+	// Pairs are minable too, and nothing here reaches four cards.
+	resp := synergyItemsets(ds, cube, &SynergyStatsRequest{MinDecks: 3}, 2, 4, zForConfidence(0.8))
+	assert.NotNil(t, itemset(resp, "A", "B"))
+	for _, s := range resp.Itemsets {
+		assert.LessOrEqual(t, len(s.Cards), 3)
+	}
+
+	// A higher bar drops everything.
+	resp = synergyItemsets(ds, cube, &SynergyStatsRequest{MinDecks: 5}, 3, 4, zForConfidence(0.8))
+	assert.Empty(t, resp.Itemsets)
+}
+
+func TestItemsetSizes(t *testing.T) {
+	for _, tc := range []struct{ min, max, wantMin, wantMax int }{
+		{0, 0, itemsetMinSize, itemsetMaxSize},
+		{2, 2, 2, 2},
+		{2, 6, 2, itemsetMaxSize},
+		{9, 0, itemsetMaxSize, itemsetMaxSize},
+		{3, 1, 3, itemsetMaxSize},
+	} {
+		gotMin, gotMax := itemsetSizes(tc.min, tc.max)
+		assert.Equal(t, tc.wantMin, gotMin, "min_size=%d max_size=%d", tc.min, tc.max)
+		assert.Equal(t, tc.wantMax, gotMax, "min_size=%d max_size=%d", tc.min, tc.max)
+	}
+}
+
+func TestSynergyItemsetsHandler_Bounds(t *testing.T) {
+	ds, cube := itemsetFixture()
+	writeTestCube(t, "test", cube)
+	h := &synergyItemsetsHandler{store: &mockDeckStorage{decks: ds}}
+	get := func(params string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/api/test/stats/synergy/itemsets?"+params, nil)
+		r = r.WithContext(server.ContextWithCube(r.Context(), "test"))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec
+	}
+
+	// Too low a support is refused outright.
+	for _, params := range []string{"min_decks=1", "min_decks=-2"} {
+		assert.Equal(t, http.StatusBadRequest, get(params).Code, params)
+	}
+
+	// An oversized max_size is held to the cap.
+	rec := get("min_decks=2&min_size=2&max_size=50")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp SynergyItemsetsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.NotEmpty(t, resp.Itemsets)
+	for _, s := range resp.Itemsets {
+		assert.LessOrEqual(t, len(s.Cards), itemsetMaxSize)
+	}
+}
+
+func TestDeckSet(t *testing.T) {
+	a, b := newDeckSet(130), newDeckSet(130)
+	for _, i := range []int{0, 5, 64, 129} {
+		a.add(i)
+	}
+	for _, i := range []int{5, 64, 100} {
+		b.add(i)
+	}
+	assert.Equal(t, 4, a.count())
+	assert.Equal(t, []int{5, 64}, a.and(b).members())
+}
@@ -3,7 +3,10 @@ package stats
 import (
 	"testing"
 
+	"github.com/caseydavenport/cube-tools/pkg/storage"
+	"github.com/caseydavenport/cube-tools/pkg/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // At independence (count == expected), the score should be exactly 1.0
@@ -51,6 +54,119 @@ func TestShrinkLift_NoSmoothing(t *testing.T) {
 	assert.InDelta(t, 0.5, shrinkLift(5, 10, 0), 1e-9)
 }
 
+// interactionDecks builds decks playing the given cards with the given game
+// results, plus the has/can-cast lookups the synergy handler precomputes.
+// Every deck can cast both cards unless named in offColor.
+func interactionDecks(specs []struct {
+	cards     []string
+	wins, los int
+}, offColor map[int]bool) ([]*storage.Deck, []map[string]bool, []map[string]bool) {
+	var ds []*storage.Deck
+	var has, can []map[string]bool
+	for i, s := range specs {
+		var games []types.Game
+		for range s.wins {
+			games = append(games, types.Game{Opponent: "o", Winner: "p"})
+		}
+		for range s.los {
+			games = append(games, types.Game{Opponent: "o", Winner: "o"})
+		}
+		ds = append(ds, makePivotDeck("p", "d1", "2025-01-01", nil, "", nil, games))
+		h := map[string]bool{}
+		for _, c := range s.cards {
+			h[c] = true
+		}
+		has = append(has, h)
+		can = append(can, map[string]bool{"A": !offColor[i], "B": !offColor[i]})
+	}
+	return ds, has, can
+}
+
+// A pair that only wins together shows a positive interaction; each card on
+// its own is a coin flip, as are decks with neither.
+func TestPairInteraction_WinsTogether(t *testing.T) {
+	specs := []struct {
+		cards     []string
+		wins, los int
+	}{
+		{[]string{"A", "B"}, 80, 20},
+		{[]string{"A"}, 50, 50},
+		{[]string{"B"}, 50, 50},
+		{nil, 50, 50},
+		// An off-color deck with neither card doesn't count.
+		{nil, 0, 100},
+	}
+	ds, has, can := interactionDecks(specs, map[int]bool{4: true})
+
+	pi := pairInteraction(pair{"A", "B"}, ds, has, can, zForConfidence(0.95))
+	require.NotNil(t, pi)
+	assert.Equal(t, 80.0, pi.Both.WinPercent)
+	assert.Equal(t, 50.0, pi.Card1Only.WinPercent)
+	assert.Equal(t, 50.0, pi.Neither.WinPercent)
+	assert.Equal(t, 50, pi.Neither.Wins)
+
+	// (80 - 50) - (50 - 50).
+	assert.Equal(t, 30.0, pi.Effect)
+	assert.Greater(t, pi.EffectLow, 0.0)
+	assert.Less(t, pi.EffectLow, pi.Effect)
+	assert.Greater(t, pi.EffectHigh, pi.Effect)
+	assert.True(t, pi.Significant)
+}
+
+// Two good cards that each add their own win rate have no interaction.
+func TestPairInteraction_Additive(t *testing.T) {
+	specs := []struct {
+		cards     []string
+		wins, los int
+	}{
+		{[]string{"A", "B"}, 7, 3},
+		{[]string{"A"}, 6, 4},
+		{[]string{"B"}, 6, 4},
+		{nil, 5, 5},
+	}
+	ds, has, can := interactionDecks(specs, nil)
+
+	pi := pairInteraction(pair{"A", "B"}, ds, has, can, zForConfidence(0.95))
+	require.NotNil(t, pi)
+	assert.Equal(t, 0.0, pi.Effect)
+	assert.False(t, pi.Significant)
+}
+
+// Groups that won or lost every game still carry sampling error, so the
+// interval keeps its width.
+func TestPairInteraction_AllOrNothing(t *testing.T) {
+	specs := []struct {
+		cards     []string
+		wins, los int
+	}{
+		{[]string{"A", "B"}, 3, 0},
+		{[]string{"A"}, 0, 3},
+		{[]string{"B"}, 0, 3},
+		{nil, 3, 0},
+	}
+	ds, has, can := interactionDecks(specs, nil)
+
+	pi := pairInteraction(pair{"A", "B"}, ds, has, can, zForConfidence(0.95))
+	require.NotNil(t, pi)
+	assert.Equal(t, 200.0, pi.Effect)
+	assert.InDelta(t, 129.9, pi.EffectLow, 0.1)
+	assert.InDelta(t, 270.1, pi.EffectHigh, 0.1)
+}
+
+// Without a deck on one side of the comparison there's nothing to measure.
+func TestPairInteraction_MissingGroup(t *testing.T) {
+	specs := []struct {
+		cards     []string
+		wins, los int
+	}{
+		{[]string{"A", "B"}, 7, 3},
+		{[]string{"A"}, 6, 4},
+		{nil, 5, 5},
+	}
+	ds, has, can := interactionDecks(specs, nil)
+	assert.Nil(t, pairInteraction(pair{"A", "B"}, ds, has, can, zForConfidence(0.95)))
+}
+
 func abs(x float64) float64 {
 	if x < 0 {
 		return -x
@@ -150,7 +150,7 @@ func (s *deckStore) List(cube string, req *DecksRequest) ([]*Deck, error) {
 	if err != nil {
 		return nil, err
 	}
-	return filter(c.decks, req), nil
+	return filter(c.decks, req)
 }
 
 // cacheForLocked returns the cube's cache, loading and decorating it from disk
@@ -406,11 +406,18 @@ func opponentRecordExcluding(opponentDeck *Deck, exclude string) (int, int) {
 	return wins, total
 }
 
-func filter(decks []*Deck, r *DecksRequest) []*Deck {
+// filter returns the decks matching the request. An unparseable match query is
+// an error rather than an empty result, so callers can report it.
+func filter(decks []*Deck, r *DecksRequest) ([]*Deck, error) {
 	// Check if we need to do any filtering.
 	var empty DecksRequest
 	if r == nil || *r == empty {
-		return decks
+		return decks, nil
+	}
+
+	q, err := query.Parse(r.Match)
+	if err != nil {
+		return nil, fmt.Errorf("invalid match query: %w", err)
 	}
 
 	filtered := []*Deck{}
@@ -456,11 +463,11 @@ func filter(decks []*Deck, r *DecksRequest) []*Deck {
 		}
 
 		// Check the query string.
-		if r.Match != "" && !query.DeckMatchesBoard(d, r.Match, r.Board) {
+		if !q.MatchesDeck(d, r.Board) {
 			continue
 		}
 
 		filtered = append(filtered, d)
 	}
-	return filtered
+	return filtered, nil
 }
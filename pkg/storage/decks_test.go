@@ -290,11 +290,11 @@ func TestFilter_EmptyRequest(t *testing.T) {
 	}
 
 	// nil request
-	result := filter(decks, nil)
+	result, _ := filter(decks, nil)
 	assert.Equal(t, 2, len(result))
 
 	// empty request
-	result = filter(decks, &DecksRequest{})
+	result, _ = filter(decks, &DecksRequest{})
 	assert.Equal(t, 2, len(result))
 }
 
@@ -305,7 +305,7 @@ func TestFilter_ByPlayer(t *testing.T) {
 		makeStorageDeck("alice", "d2", "2024-01-02", nil, nil, nil), // case-insensitive match
 	}
 
-	result := filter(decks, &DecksRequest{Player: "Alice"})
+	result, _ := filter(decks, &DecksRequest{Player: "Alice"})
 	assert.Equal(t, 2, len(result))
 	for _, d := range result {
 		assert.True(t, d.Player == "Alice" || d.Player == "alice")
@@ -320,15 +320,15 @@ func TestFilter_ByDateRange(t *testing.T) {
 	}
 
 	// Start only
-	result := filter(decks, &DecksRequest{Start: "2024-06-01"})
+	result, _ := filter(decks, &DecksRequest{Start: "2024-06-01"})
 	assert.Equal(t, 2, len(result))
 
 	// End only
-	result = filter(decks, &DecksRequest{End: "2024-06-30"})
+	result, _ = filter(decks, &DecksRequest{End: "2024-06-30"})
 	assert.Equal(t, 2, len(result))
 
 	// Both (inclusive)
-	result = filter(decks, &DecksRequest{Start: "2024-06-15", End: "2024-06-15"})
+	result, _ = filter(decks, &DecksRequest{Start: "2024-06-15", End: "2024-06-15"})
 	assert.Equal(t, 1, len(result))
 	assert.Equal(t, "2024-06-15", result[0].Date)
 }
@@ -343,13 +343,32 @@ func TestFilter_ByDraftSize(t *testing.T) {
 
 	decks := []*Deck{d1, d2, d3}
 
-	result := filter(decks, &DecksRequest{DraftSize: 6})
+	result, _ := filter(decks, &DecksRequest{DraftSize: 6})
 	assert.Equal(t, 2, len(result))
 	for _, d := range result {
 		assert.GreaterOrEqual(t, d.DraftSize, 6)
 	}
 }
 
+func TestFilter_ByMatch(t *testing.T) {
+	decks := []*Deck{
+		makeStorageDeck("Alice", "d1", "2024-01-01", nil, nil, []string{"aggro"}),
+		makeStorageDeck("Bob", "d1", "2024-01-01", nil, nil, []string{"control"}),
+	}
+
+	result, err := filter(decks, &DecksRequest{Match: "arch:aggro OR player:bob"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(result))
+
+	result, err = filter(decks, &DecksRequest{Match: "-arch:aggro"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(result))
+
+	// A malformed query is reported rather than matching nothing.
+	_, err = filter(decks, &DecksRequest{Match: "(arch:aggro"})
+	assert.Error(t, err)
+}
+
 func TestDeckStore_ScopedByCube(t *testing.T) {
 	// Tests run with CWD=pkg/storage; chdir to repo root so loadDecks can find
 	// the data directory.
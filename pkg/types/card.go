@@ -35,6 +35,12 @@ type Card struct {
 	// from the cube export. Used as a filter dimension in the UI, not to drive
 	// the IsRemoval/IsInteraction heuristics (tags drift out of sync).
 	Tags []string `json:"tags,omitempty"`
+
+	// Keywords, Mechanics and Roles are the oracle-derived traits set by
+	// Classify; see traits.go.
+	Keywords  []string `json:"keywords,omitempty"`
+	Mechanics []string `json:"mechanics,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
 }
 
 func (c Card) IsBasicLand() bool {
@@ -267,6 +273,7 @@ func FromOracle(o OracleCard) Card {
 		}
 	}
 
+	c.Classify(o.Keywords)
 	return c
 }
 
@@ -232,8 +232,9 @@ type Deck struct {
 	// Contains metadata about the deck file itself.
 	Metadata Metadata `json:"metadata"`
 
-	// MacroArchetype is the deck's high-level strategy classification.
-	// Expected values: "aggro", "midrange", "control", or empty.
+	// MacroArchetype is the deck's high-level strategy classification: one of
+	// the cube's configured macros (DefaultMacros unless the registry sets its
+	// own), or empty.
 	MacroArchetype string `json:"macro_archetype,omitempty"`
 
 	// Tags represents metadata associated with this deck. Used for
@@ -413,16 +414,23 @@ func (d *Deck) AddGame(opponent, winner string) {
 	})
 }
 
+// DefaultMacros are the macro archetypes used by a cube that doesn't
+// configure its own in the registry.
+var DefaultMacros = []string{"aggro", "midrange", "control"}
+
 func (d *Deck) Macro() string {
-	// Return one of "aggro", "midrange", "control", or "".
-	// Prefer the dedicated field; fall back to scanning Labels for decks
-	// that haven't been backfilled yet.
+	return d.MacroIn(DefaultMacros)
+}
+
+// MacroIn returns the deck's macro archetype, lowercased, or "". Prefer the
+// dedicated field; fall back to scanning Labels for one of macros, for decks
+// that haven't been backfilled yet.
+func (d *Deck) MacroIn(macros []string) string {
 	if d.MacroArchetype != "" {
 		return strings.ToLower(d.MacroArchetype)
 	}
 	for _, label := range d.Labels {
-		switch strings.ToLower(label) {
-		case "aggro", "midrange", "control":
+		if slices.Contains(macros, strings.ToLower(label)) {
 			return strings.ToLower(label)
 		}
 	}
@@ -1,5 +1,11 @@
 package types
 
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
 type DraftLog struct {
 	Users    map[string]User      `json:"users"`
 	CardData map[string]DraftCard `json:"carddata"`
@@ -13,15 +19,30 @@ type User struct {
 }
 
 type Pick struct {
-	PackNum int      `json:"packNum"`
-	PickNum int      `json:"pickNum"`
-	Pick    int      `json:"pick"`
+	PackNum int `json:"packNum"`
+	PickNum int `json:"pickNum"`
+
+	// Pick holds the indices into Booster of the card(s) taken. Draftmancer
+	// records a list so that multi-pick formats fit the same shape.
+	Pick    []int    `json:"pick"`
 	Booster []string `json:"booster"`
 }
 
+// Picked returns the card IDs taken at this pick.
+func (p Pick) Picked() []string {
+	ids := make([]string, 0, len(p.Pick))
+	for _, i := range p.Pick {
+		if i >= 0 && i < len(p.Booster) {
+			ids = append(ids, p.Booster[i])
+		}
+	}
+	return ids
+}
+
 type DraftCard struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID     string   `json:"id"`
+	Name   string   `json:"name"`
+	Colors []string `json:"colors"`
 }
 
 type Decklist struct {
@@ -35,3 +56,16 @@ func (log *DraftLog) Card(n string) Card {
 	card.Name = draftCard.Name
 	return card
 }
+
+// LoadDraftLog reads a draft-log.json file.
+func LoadDraftLog(path string) (*DraftLog, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	log := &DraftLog{}
+	if err := json.Unmarshal(contents, log); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return log, nil
+}
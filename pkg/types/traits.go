@@ -0,0 +1,231 @@
+package types
+
+import (
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Traits are the oracle-derived classifications stored on a Card: keyword
+// abilities, mechanics and the broader roles a card plays in a deck. Names are
+// lowercase with underscores so they can be used directly as is: terms and
+// pivot dimensions.
+
+// KeywordAbilities are the keyword abilities we recognise, either from
+// Scryfall's keyword list or from the card's own keyword lines.
+var KeywordAbilities = []string{
+	"deathtouch",
+	"defender",
+	"double_strike",
+	"first_strike",
+	"flash",
+	"flying",
+	"haste",
+	"hexproof",
+	"indestructible",
+	"lifelink",
+	"menace",
+	"prowess",
+	"reach",
+	"trample",
+	"vigilance",
+	"ward",
+	"cycling",
+	"flashback",
+	"kicker",
+	"cascade",
+	"convoke",
+	"equip",
+}
+
+// Mechanics are what a card does, detected from its oracle text.
+var Mechanics = []string{
+	"etb",
+	"sac_outlet",
+	"token_maker",
+	"card_draw",
+	"ramp",
+	"recursion",
+	"anthem",
+}
+
+// Roles group cards by the job they do in a deck. The first three mirror
+// IsRemoval, IsCounterspell and IsHandHate.
+var Roles = []string{
+	"removal",
+	"counterspell",
+	"handhate",
+	"evasive",
+	"card_advantage",
+	"go_wide",
+}
+
+// IsTrait reports whether name is a known keyword, mechanic or role.
+func IsTrait(name string) bool {
+	return slices.Contains(KeywordAbilities, name) || slices.Contains(Mechanics, name) || slices.Contains(Roles, name)
+}
+
+var mechanicPatterns = map[string][]oraclePattern{
+	"etb": {
+		rx(`when(ever)? [^.]*\benters\b`),
+	},
+	// A sacrifice outlet lets you sacrifice at will, so only count sacrifices
+	// that are part of an activation cost.
+	"sac_outlet": {
+		rx(`(^|\n|, )sacrifice (a|an|another|two|x) [^.:]*:`),
+	},
+	"token_maker": {
+		rx(`\bcreates? [^.]*\btokens?\b`),
+		sub("amass"),
+		sub("populate"),
+	},
+	"card_draw": {
+		rx(`\bdraws? (a|an|two|three|four|x|that many|cards equal)\b[^.]*\bcards?\b`),
+		rx(`\bdraw a card\b`),
+		sub("investigate"),
+	},
+	"ramp": {
+		rx(`\{t\}[^.:]*: add \{`),
+		rx(`search your library for [^.]*\bland cards?\b[^.]*(onto the battlefield|into your hand)`),
+		sub("you may play an additional land"),
+		sub("create a treasure token"),
+	},
+	"recursion": {
+		rx(`return [^.]*\bfrom your graveyard to (your hand|the battlefield)`),
+		rx(`return [^.]*\bcards? from your graveyard\b`),
+		sub("you may cast this card from your graveyard"),
+		sub("flashback"),
+	},
+	"anthem": {
+		rx(`\b(other )?([a-z]+ )?creatures you control get \+\d+/\+\d+`),
+	},
+}
+
+var evasionPatterns = []oraclePattern{
+	sub("can't be blocked"),
+	sub("shadow"),
+}
+
+// Classify fills in the card's Keywords, Mechanics and Roles from its oracle
+// text and, where available, the keywords Scryfall lists for it.
+func (c *Card) Classify(scryfallKeywords []string) {
+	c.Keywords = classifyKeywords(c.OracleText, scryfallKeywords)
+	c.Mechanics = classifyMechanics(*c)
+	c.Roles = classifyRoles(*c, c.Keywords, c.Mechanics)
+}
+
+// Traits returns every keyword, mechanic and role the card has. Cards loaded
+// from data written before classification existed carry none, so those are
+// classified from their oracle text on the fly.
+func (c Card) Traits() []string {
+	if c.Keywords == nil && c.Mechanics == nil && c.Roles == nil {
+		return slices.Clone(classifiedTraits(c))
+	}
+	return c.allTraits()
+}
+
+func (c Card) allTraits() []string {
+	out := make([]string, 0, len(c.Keywords)+len(c.Mechanics)+len(c.Roles))
+	out = append(out, c.Keywords...)
+	out = append(out, c.Mechanics...)
+	return append(out, c.Roles...)
+}
+
+// traitCache holds the traits of cards classified on the fly. A card with no
+// traits looks the same as one never classified, so without it those would be
+// re-run through every pattern on each call.
+var traitCache sync.Map // traitsKey -> []string
+
+// traitsKey is everything classification reads from a card without Scryfall's
+// keywords.
+type traitsKey struct {
+	text string
+	land bool
+}
+
+func classifiedTraits(c Card) []string {
+	key := traitsKey{text: c.OracleText, land: c.IsLand()}
+	if t, ok := traitCache.Load(key); ok {
+		return t.([]string)
+	}
+	c.Classify(nil)
+	t, _ := traitCache.LoadOrStore(key, c.allTraits())
+	return t.([]string)
+}
+
+// HasTrait reports whether the card has the named keyword, mechanic or role.
+func (c Card) HasTrait(name string) bool {
+	return slices.Contains(c.Traits(), name)
+}
+
+// traitName normalizes a keyword like "First strike" to "first_strike".
+func traitName(s string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(s)), " ", "_")
+}
+
+// classifyKeywords combines Scryfall's keyword list with the keyword lines in
+// the oracle text. Only keywords at the start of a line or in a comma list
+// count, so "creatures with flying" doesn't give a card flying.
+func classifyKeywords(text string, scryfall []string) []string {
+	found := map[string]bool{}
+	for _, k := range scryfall {
+		found[traitName(k)] = true
+	}
+	text = reminderTextRe.ReplaceAllString(text, "")
+	for _, line := range strings.Split(text, "\n") {
+		for _, part := range strings.Split(line, ",") {
+			part = traitName(part)
+			for _, k := range KeywordAbilities {
+				if part == k || strings.HasPrefix(part, k+"_") {
+					found[k] = true
+				}
+			}
+		}
+	}
+
+	var out []string
+	for _, k := range KeywordAbilities {
+		if found[k] {
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+func classifyMechanics(c Card) []string {
+	var out []string
+	for _, m := range Mechanics {
+		// Lands that tap for mana aren't ramp.
+		if m == "ramp" && c.IsLand() {
+			continue
+		}
+		if anyPattern(c.OracleText, mechanicPatterns[m]) {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+func classifyRoles(c Card, keywords, mechanics []string) []string {
+	var out []string
+	if c.IsRemoval() {
+		out = append(out, "removal")
+	}
+	if c.IsCounterspell() {
+		out = append(out, "counterspell")
+	}
+	if c.IsHandHate() {
+		out = append(out, "handhate")
+	}
+	if slices.Contains(keywords, "flying") || slices.Contains(keywords, "menace") ||
+		slices.Contains(keywords, "trample") || anyPattern(c.OracleText, evasionPatterns) {
+		out = append(out, "evasive")
+	}
+	if slices.Contains(mechanics, "card_draw") || slices.Contains(mechanics, "recursion") {
+		out = append(out, "card_advantage")
+	}
+	if slices.Contains(mechanics, "token_maker") || slices.Contains(mechanics, "anthem") {
+		out = append(out, "go_wide")
+	}
+	return out
+}
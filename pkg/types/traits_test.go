@@ -0,0 +1,102 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyKeywords(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		scryfall []string
+		want     []string
+	}{
+		{"keyword line", "Flying, first strike\nWhen this creature dies, draw a card.", nil, []string{"first_strike", "flying"}},
+		{"ward with cost", "Ward {2}", nil, []string{"ward"}},
+		{"reminder text ignored", "Trample (This creature can deal excess combat damage.)", nil, []string{"trample"}},
+		{"granting isn't having", "Creatures you control with flying get +1/+1.", nil, nil},
+		{"flashback isn't flash", "Flashback {2}{R}", nil, []string{"flashback"}},
+		{"scryfall keywords", "", []string{"Haste", "Double strike", "Landfall"}, []string{"double_strike", "haste"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, classifyKeywords(tt.text, tt.scryfall))
+		})
+	}
+}
+
+func TestClassifyMechanics_RealCards(t *testing.T) {
+	tests := []struct {
+		name  string
+		types []string
+		text  string
+		want  []string
+	}{
+		{"mulldrifter", []string{"Creature"}, "Flying\nWhen this creature enters, draw two cards.\nEvoke {2}{U}", []string{"etb", "card_draw"}},
+		{"viscera seer", []string{"Creature"}, "Sacrifice a creature: Scry 1.", []string{"sac_outlet"}},
+		{"raise the alarm", []string{"Instant"}, "Create two 1/1 white Soldier creature tokens.", []string{"token_maker"}},
+		{"llanowar elves", []string{"Creature"}, "{T}: Add {G}.", []string{"ramp"}},
+		{"rampant growth", []string{"Sorcery"}, "Search your library for a basic land card, put that card onto the battlefield tapped, then shuffle.", []string{"ramp"}},
+		{"gravedigger", []string{"Creature"}, "When this creature enters, you may return target creature card from your graveyard to your hand.", []string{"etb", "recursion"}},
+		{"glorious anthem", []string{"Enchantment"}, "Creatures you control get +1/+1.", []string{"anthem"}},
+		{"land taps for mana", []string{"Land"}, "{T}: Add {U} or {B}.", nil},
+		{"sacrifice as an effect", []string{"Instant"}, "Each opponent sacrifices a creature.", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Card{Types: tt.types, OracleText: tt.text}
+			assert.Equal(t, tt.want, classifyMechanics(c))
+		})
+	}
+}
+
+func TestFromOracle_Classifies(t *testing.T) {
+	c := FromOracle(OracleCard{
+		Name:       "Serra Angel",
+		TypeLine:   "Creature — Angel",
+		OracleText: "Flying, vigilance",
+		Keywords:   []string{"Flying", "Vigilance"},
+	})
+	assert.Equal(t, []string{"flying", "vigilance"}, c.Keywords)
+	assert.Nil(t, c.Mechanics)
+	assert.Equal(t, []string{"evasive"}, c.Roles)
+}
+
+func TestHasTrait(t *testing.T) {
+	// Cards without stored traits are classified from their text.
+	c := Card{OracleText: "Destroy target creature. Draw a card."}
+	assert.True(t, c.HasTrait("removal"))
+	assert.True(t, c.HasTrait("card_draw"))
+	assert.True(t, c.HasTrait("card_advantage"))
+	assert.False(t, c.HasTrait("flying"))
+
+	// Stored traits are used as is.
+	c = Card{OracleText: "Flying", Keywords: []string{"haste"}}
+	assert.True(t, c.HasTrait("haste"))
+	assert.False(t, c.HasTrait("flying"))
+}
+
+func TestTraits_Cached(t *testing.T) {
+	// A card with no traits is only classified once.
+	c := Card{OracleText: "This card does nothing of note."}
+	assert.Empty(t, c.Traits())
+	_, ok := traitCache.Load(traitsKey{text: c.OracleText})
+	assert.True(t, ok)
+
+	// The cache goes by what classification reads, so a land with the same
+	// text is classified on its own.
+	land := Card{OracleText: "{T}: Add {G}.", Types: []string{"Land"}}
+	spell := Card{OracleText: "{T}: Add {G}.", Types: []string{"Creature"}}
+	assert.False(t, land.HasTrait("ramp"))
+	assert.True(t, spell.HasTrait("ramp"))
+}
+
+func TestIsTrait(t *testing.T) {
+	assert.True(t, IsTrait("first_strike"))
+	assert.True(t, IsTrait("token_maker"))
+	assert.True(t, IsTrait("evasive"))
+	assert.False(t, IsTrait("creature"))
+	assert.False(t, IsTrait("landfall"))
+}
@@ -1,5 +1,5 @@
 import React from 'react'
-import { IsBasicLand, SortFunc, CardImageURL } from "../utils/Utils.js"
+import { IsBasicLand, MacroLabel, SortFunc, CardImageURL } from "../utils/Utils.js"
 import { DropdownHeader, NumericInput, Checkbox, DateSelector } from "../components/Dropdown.js"
 import { PillSearchInput } from "../components/PillSearchInput.js"
 import { Section, SectionNav } from "../components/PageSections.js"
@@ -65,15 +65,28 @@ export const NumPlayersOption = "# Players"
 export const DraftOrderOption = "Avg. draft pick"
 export const NumTrophiesOption = "# Trophies"
 export const NumLastPlaceOption = "# Last place"
-export const VersusAggroOption = "vs Aggro Win %"
-export const VersusControlOption = "vs Control Win %"
-export const VersusMidrangeOption = "vs Midrange Win %"
-export const InAggroOption = "in Aggro Win %"
-export const InControlOption = "in Control Win %"
-export const InMidrangeOption = "in Midrange Win %"
 export const WordCountOption = "Word Count"
 
-export const CardScatterAxes = [
+// The per-archetype axes are named for the cube's macro archetypes, e.g.
+// "vs Aggro Win %" and "in Aggro Win %".
+const macroAxis = /^(vs|in) (.+) Win %$/
+
+export function VersusMacroOption(macro) {
+  return `vs ${MacroLabel(macro)} Win %`
+}
+
+export function InMacroOption(macro) {
+  return `in ${MacroLabel(macro)} Win %`
+}
+
+// macroWinPercent returns the win rate recorded for a macro archetype, or null
+// if the card has none for it, e.g. because nobody has built that macro yet.
+function macroWinPercent(byMacro, macro) {
+  const s = byMacro && byMacro[macro]
+  return s ? s.win_percent : null
+}
+
+const baseScatterAxes = [
   {label: NumDecksOption, value: NumDecksOption},
   {label: NumSideboardOption, value: NumSideboardOption},
   {label: MainboardPercentOption, value: MainboardPercentOption},
@@ -91,15 +104,17 @@ export const CardScatterAxes = [
   {label: DraftOrderOption, value: DraftOrderOption},
   {label: NumTrophiesOption, value: NumTrophiesOption},
   {label: NumLastPlaceOption, value: NumLastPlaceOption},
-  {label: VersusAggroOption, value: VersusAggroOption},
-  {label: VersusControlOption, value: VersusControlOption},
-  {label: VersusMidrangeOption, value: VersusMidrangeOption},
-  {label: InAggroOption, value: InAggroOption},
-  {label: InControlOption, value: InControlOption},
-  {label: InMidrangeOption, value: InMidrangeOption},
-  {label: WordCountOption, value: WordCountOption},
 ]
 
+export function CardScatterAxes(macros) {
+  return [
+    ...baseScatterAxes,
+    ...macros.map((m) => ({label: VersusMacroOption(m), value: VersusMacroOption(m)})),
+    ...macros.map((m) => ({label: InMacroOption(m), value: InMacroOption(m)})),
+    {label: WordCountOption, value: WordCountOption},
+  ]
+}
+
 const Interaction = "All interaction"
 const Counterspells = "Counterspells"
 const Removal = "Removal"
@@ -136,7 +151,7 @@ const tagOpts = [
     if (input.manaValue >=0 && card.cmc != input.manaValue) {
       return true
     }
-    if (input.localMatchStr != "" && !CardMatches(card, input.localMatchStr, true)) {
+    if (input.localMatchStr != "" && !CardMatches(card, input.localMatchStr)) {
       return true
     }
 
@@ -234,6 +249,21 @@ function sortValue(sortBy, card) {
     case "match_elo":
       sort = card.match_elo
       break
+    case "available":
+      sort = card.available
+      break
+    case "since_added":
+      sort = card.drafts_since_added
+      break
+    case "play_rate":
+      sort = card.play_rate
+      break
+    case "mainboard_rate":
+      sort = card.mainboard_rate
+      break
+    case "normalized_elo":
+      sort = card.normalized_elo
+      break
     case "in-color-sb":
       sort = card.playable_sideboard
       break
@@ -280,24 +310,14 @@ function sortValue(sortBy, card) {
     case "draws":
       sort = card.draws
       break;
-    case "vsaggro":
-      sort = card.against_archetype.aggro.win_percent
-      break;
-    case "vsmidrange":
-      sort = card.against_archetype.midrange.win_percent
-      break;
-    case "vscontrol":
-      sort = card.against_archetype.control.win_percent
-      break;
-    case "inaggro":
-      sort = card.by_archetype.aggro.win_percent
-      break;
-    case "inmidrange":
-      sort = card.by_archetype.midrange.win_percent
-      break;
-    case "incontrol":
-      sort = card.by_archetype.control.win_percent
-      break;
+    default:
+      // Per-macro columns are "vs:<macro>" and "in:<macro>". A macro with no
+      // record sorts blank.
+      if (sortBy && sortBy.startsWith("vs:")) {
+        sort = macroWinPercent(card.against_archetype, sortBy.slice(3)) ?? NaN
+      } else if (sortBy && sortBy.startsWith("in:")) {
+        sort = macroWinPercent(card.by_archetype, sortBy.slice(3)) ?? NaN
+      }
   }
   return sort
 }
@@ -473,19 +493,41 @@ const METADATA_COLUMNS = [
   { id: "players", text: "# Players", tip: "Number of unique players who have mainboarded this card.", cell: (c, i, k) => valueCell(c, i, k, Object.entries(c.players).length) },
 ]
 
-const VS_ARCH_COLUMNS = [
-  colorsColumn, cardColumn, gamesColumn,
-  { id: "vsaggro", text: "vs Aggro", tip: "Win rate when this card's deck plays against aggro archetypes.", cell: (c, i, k) => valueCell(c, i, k, `${c.against_archetype.aggro.win_percent.toFixed(0)}%`) },
-  { id: "vscontrol", text: "vs Control", tip: "Win rate when this card's deck plays against control archetypes.", cell: (c, i, k) => valueCell(c, i, k, `${c.against_archetype.control.win_percent.toFixed(0)}%`) },
-  { id: "vsmidrange", text: "vs Midrange", tip: "Win rate when this card's deck plays against midrange archetypes.", cell: (c, i, k) => valueCell(c, i, k, `${c.against_archetype.midrange.win_percent.toFixed(0)}%`) },
+const AVAILABILITY_COLUMNS = [
+  colorsColumn,
+  cardColumn,
+  { id: "available", text: "Available", tip: "Number of drafts this card was in the cube for, from each draft's cube snapshot.", cell: (c, i, k) => valueCell(c, i, k, c.available) },
+  { id: "since_added", text: "Since added", tip: "Number of drafts in a row this card has been in the cube, counting back from the latest.", cell: (c, i, k) => valueCell(c, i, k, c.drafts_since_added) },
+  { id: "play_rate", text: "Play%", tip: "Percentage of the drafts this card was available in that someone mainboarded it.", cell: (c, i, k) => valueCell(c, i, k, `${c.play_rate}%`) },
+  { id: "mainboard_rate", text: "MB%", tip: "Percentage of decks built while this card was in the cube that mainboarded it.", cell: (c, i, k) => valueCell(c, i, k, `${c.mainboard_rate}%`) },
+  { id: "normalized_elo", text: "Norm. ELO", tip: "Pick ELO pulled toward the 1200 baseline for cards that have only been in the cube for a few drafts.", cell: (c, i, k) => valueCell(c, i, k, c.normalized_elo) },
 ]
 
-const BY_ARCH_COLUMNS = [
-  colorsColumn, cardColumn, gamesColumn,
-  { id: "inaggro", text: "in Aggro", tip: "Win rate of this card when played in aggro decks.", cell: (c, i, k) => valueCell(c, i, k, `${c.by_archetype.aggro.win_percent.toFixed(0)}%`) },
-  { id: "incontrol", text: "in Control", tip: "Win rate of this card when played in control decks.", cell: (c, i, k) => valueCell(c, i, k, `${c.by_archetype.control.win_percent.toFixed(0)}%`) },
-  { id: "inmidrange", text: "in Midrange", tip: "Win rate of this card when played in midrange decks.", cell: (c, i, k) => valueCell(c, i, k, `${c.by_archetype.midrange.win_percent.toFixed(0)}%`) },
-]
+// macroPctCell shows a per-macro win rate, or a dash if the card has none.
+function macroPctCell(card, input, key, pct) {
+  return valueCell(card, input, key, pct == null ? "—" : `${pct.toFixed(0)}%`)
+}
+
+// The archetype modes get a column per macro archetype the cube configures.
+function vsArchColumns(macros) {
+  return [
+    colorsColumn, cardColumn, gamesColumn,
+    ...macros.map((m) => ({
+      id: `vs:${m}`, text: `vs ${MacroLabel(m)}`, tip: `Win rate when this card's deck plays against ${m} archetypes.`,
+      cell: (c, i, k) => macroPctCell(c, i, k, macroWinPercent(c.against_archetype, m)),
+    })),
+  ]
+}
+
+function byArchColumns(macros) {
+  return [
+    colorsColumn, cardColumn, gamesColumn,
+    ...macros.map((m) => ({
+      id: `in:${m}`, text: `in ${MacroLabel(m)}`, tip: `Win rate of this card when played in ${m} decks.`,
+      cell: (c, i, k) => macroPctCell(c, i, k, macroWinPercent(c.by_archetype, m)),
+    })),
+  ]
+}
 
 // CardStatsTable renders a list of column descriptors over the card list.
 function CardStatsTable(cards, columns, input) {
@@ -551,9 +593,11 @@ function CardWidgetTable(input) {
     case "Metadata":
       return CardStatsTable(cards, METADATA_COLUMNS, input)
     case "Versus archetype":
-      return CardStatsTable(cards, VS_ARCH_COLUMNS, input)
+      return CardStatsTable(cards, vsArchColumns(input.parsed.macros), input)
     case "By archetype":
-      return CardStatsTable(cards, BY_ARCH_COLUMNS, input)
+      return CardStatsTable(cards, byArchColumns(input.parsed.macros), input)
+    case "Availability":
+      return CardStatsTable(cards, AVAILABILITY_COLUMNS, input)
     default:
       return CardStatsTable(cards, OVERVIEW_COLUMNS, input)
   }
@@ -1165,13 +1209,13 @@ function CardGraph(input) {
       <div className="selector-group" style={{"justifyContent": "center", "marginBottom": "1rem"}}>
         <DropdownHeader
           label="X Axis"
-          options={CardScatterAxes}
+          options={CardScatterAxes(input.parsed.macros)}
           value={input.xAxis}
           onChange={input.onXAxisSelected}
         />
         <DropdownHeader
           label="Y Axis"
-          options={CardScatterAxes}
+          options={CardScatterAxes(input.parsed.macros)}
           value={input.yAxis}
           onChange={input.onYAxisSelected}
         />
@@ -1254,21 +1298,16 @@ function getValue(axis, card, archetypeData, playerData, decks, draftData) {
         return null
       }
       return Math.round(pick.pickNumSum / pick.count * 10) / 10
-    case VersusAggroOption:
-      return card.against_archetype.aggro.win_percent
-    case VersusMidrangeOption:
-      return card.against_archetype.midrange.win_percent
-    case VersusControlOption:
-      return card.against_archetype.control.win_percent
-    case InAggroOption:
-      return card.by_archetype.aggro.win_percent
-    case InMidrangeOption:
-      return card.by_archetype.midrange.win_percent
-    case InControlOption:
-      return card.by_archetype.control.win_percent
     case WordCountOption:
       return card.word_count
   }
+
+  // Macro archetypes are stored lowercase, so the axis label maps back to one.
+  const m = macroAxis.exec(axis)
+  if (m) {
+    const byMacro = m[1] === "vs" ? card.against_archetype : card.by_archetype
+    return macroWinPercent(byMacro, m[2].toLowerCase())
+  }
   return null
 }
 
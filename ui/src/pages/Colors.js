@@ -148,7 +148,12 @@ export function ColorWidget(input) {
 
           <tr key="matchup-heatmap">
             <td colSpan="2" style={{"paddingTop": "50px"}}>
-              <ColorMatchupHeatmap matchupData={input.colorMatchupData} colorType={input.colorTypeSelection} />
+              <ColorMatchupHeatmap
+                matchupData={input.colorMatchupData}
+                colorType={input.colorTypeSelection}
+                mode={input.matchupMode}
+                onModeChanged={input.onMatchupModeChanged}
+              />
             </td>
           </tr>
         </tbody>
@@ -1046,35 +1051,41 @@ function ColorRateChart(input) {
   );
 }
 
-const monoColors = ["W", "U", "B", "R", "G"];
-const dualColors = ["WU", "WB", "WR", "WG", "UB", "UR", "UG", "BR", "BG", "RG"];
-const trioColors = ["WUB", "WUR", "WUG", "WBR", "WBG", "WRG", "UBR", "UBG", "URG", "BRG"];
-
-const colorGroupNames = {
-  W: "White", U: "Blue", B: "Black", R: "Red", G: "Green",
-  WU: "Azorius", WB: "Orzhov", WR: "Boros", WG: "Selesnya",
-  UB: "Dimir", UR: "Izzet", UG: "Simic", BR: "Rakdos", BG: "Golgari", RG: "Gruul",
-  WUB: "Esper", WUR: "Jeskai", WUG: "Bant", WBR: "Mardu", WBG: "Abzan",
-  WRG: "Naya", UBR: "Grixis", UBG: "Sultai", URG: "Temur", BRG: "Jund",
-};
-
-function ColorMatchupHeatmap({ matchupData, colorType }) {
+// The heatmap has its own color mode, separate from the table's, since the
+// splash and identity modes only exist for matchups. It defaults to splash:
+// like identity it counts each deck once, so a three-color deck isn't credited
+// to every pair it contains, but it keeps to the Mono/Dual/Trio selection,
+// whereas identity makes a group of every combination seen, which can grow the
+// grid past what fits on the page.
+const matchupModeOptions = [
+  { label: "Inclusive", value: "inclusive" },
+  { label: "Exact", value: "exact" },
+  { label: "Primary pair", value: "primary" },
+  { label: "Splash", value: "splash" },
+  { label: "Identity", value: "identity" },
+];
+
+function ColorMatchupHeatmap({ matchupData, colorType, mode, onModeChanged }) {
   const [sortColumn, setSortColumn] = React.useState(null);
 
-  if (!matchupData || Object.keys(matchupData).length === 0) {
+  const matchups = matchupData?.matchups || {};
+  if (Object.keys(matchups).length === 0) {
     return null;
   }
 
-  let groups = dualColors;
-  if (colorType === "Mono") groups = monoColors;
-  else if (colorType === "Trio") groups = trioColors;
+  // The server lists the groups in the matrix in WUBRG order, with names.
+  const names = {};
+  for (const g of matchupData.groups || []) {
+    names[g.key] = g.name;
+  }
+  const groups = (matchupData.groups || []).map(g => g.key);
 
   // Sort rows by win% against the selected column, descending.
   let sortedRows = [...groups];
   if (sortColumn && groups.includes(sortColumn)) {
     sortedRows.sort((a, b) => {
-      const aRecord = matchupData[a]?.[sortColumn];
-      const bRecord = matchupData[b]?.[sortColumn];
+      const aRecord = matchups[a]?.[sortColumn];
+      const bRecord = matchups[b]?.[sortColumn];
       const aPct = aRecord ? aRecord.win_pct : -1;
       const bPct = bRecord ? bRecord.win_pct : -1;
       return bPct - aPct;
@@ -1095,13 +1106,23 @@ function ColorMatchupHeatmap({ matchupData, colorType }) {
     }
   }
 
-  const title = colorType === "Mono" ? "Mono-Color" : colorType === "Trio" ? "Trio-Color" : "Color Pair";
+  let title = colorType === "Mono" ? "Mono-Color" : colorType === "Trio" ? "Trio-Color" : "Color Pair";
+  if (mode === "identity") title = "Deck Identity";
 
   return (
     <div>
       <h4 style={{textAlign: "center", color: "var(--primary)", marginBottom: "1rem"}}>
         {title} Matchup Heatmap
       </h4>
+      <div className="selector-group" style={{"justifyContent": "center", "marginBottom": "1rem"}}>
+        <DropdownHeader
+          label="Matchup color mode"
+          className="dropdown"
+          options={matchupModeOptions}
+          value={mode}
+          onChange={onModeChanged}
+        />
+      </div>
       <div style={{overflowX: "auto"}}>
         <table className="widget-table" style={{margin: "0 auto", fontSize: "0.85em"}}>
           <thead>
@@ -1113,7 +1134,7 @@ function ColorMatchupHeatmap({ matchupData, colorType }) {
                   background: sortColumn === cp ? "var(--primary)" : undefined,
                   color: sortColumn === cp ? "var(--page-background)" : undefined,
                 }} onClick={() => setSortColumn(sortColumn === cp ? null : cp)}>
-                  {colorGroupNames[cp] || cp}
+                  {names[cp] || cp}
                 </td>
               ))}
             </tr>
@@ -1121,7 +1142,7 @@ function ColorMatchupHeatmap({ matchupData, colorType }) {
           <tbody>
             {sortedRows.map(myColor => (
               <tr key={myColor} className="widget-table-row">
-                <td className="header-cell" style={{fontWeight: "bold"}}>{colorGroupNames[myColor] || myColor}</td>
+                <td className="header-cell" style={{fontWeight: "bold"}}>{names[myColor] || myColor}</td>
                 {groups.map(oppColor => {
                   if (myColor === oppColor) {
                     return (
@@ -1130,7 +1151,7 @@ function ColorMatchupHeatmap({ matchupData, colorType }) {
                       </td>
                     );
                   }
-                  const record = matchupData[myColor]?.[oppColor];
+                  const record = matchups[myColor]?.[oppColor];
                   const wins = record?.wins || 0;
                   const losses = record?.losses || 0;
                   const total = wins + losses;
@@ -1143,7 +1164,7 @@ function ColorMatchupHeatmap({ matchupData, colorType }) {
                       delay={{ show: 100, hide: 100 }}
                       overlay={
                         <Popover id={`matchup-${myColor}-${oppColor}`}>
-                          <Popover.Header as="h3">{colorGroupNames[myColor]} vs {colorGroupNames[oppColor]}</Popover.Header>
+                          <Popover.Header as="h3">{names[myColor]} vs {names[oppColor]}</Popover.Header>
                           <Popover.Body>
                             {wins}W - {losses}L ({total} games)
                           </Popover.Body>
@@ -1,10 +1,10 @@
 import React, { useState, useEffect, useMemo, useRef, useLayoutEffect } from 'react'
-import { LoadCube, LoadDecks, FetchNotes, SaveNotes, SaveDeckMeta } from "../utils/Fetch.js"
+import { LoadCube, LoadDecks, FetchNotes, SaveNotes, SaveDeckMeta, LoadCubeMacros, DefaultMacros } from "../utils/Fetch.js"
 import { useCube } from "../contexts/CubeContext.js"
 import { Record, MatchRecord, Wins, Losses, Draws, MatchWins, MatchLosses, MatchDraws, InDeckColor } from "../utils/Deck.js"
 import { RemovalMatches, CounterspellMatches } from "../pages/Decks.js"
-import { SortFunc, StringToColor, CheckboxesToColors, IsBasicLand, CardImageURL, CountManaPips } from "../utils/Utils.js"
-import { CardMatches, DeckMatches } from "../utils/Query.js"
+import { SortFunc, StringToColor, CheckboxesToColors, IsBasicLand, CardImageURL, CountManaPips, MacroLabel } from "../utils/Utils.js"
+import { CardHighlighted } from "../utils/Query.js"
 import { ColorImages, ManaPipBar } from "../utils/Colors.js"
 import { Button, TextInput, DropdownHeader, NumericInput, Checkbox, DateSelector } from "../components/Dropdown.js"
 import { PillSearchInput } from "../components/PillSearchInput.js"
@@ -430,12 +430,8 @@ export function DeckViewer(props) {
 
 function getMacro(deck) {
   const m = (deck.macro_archetype || "").toLowerCase()
-  switch (m) {
-    case "aggro": return "Aggro"
-    case "midrange": return "Midrange"
-    case "control": return "Control"
-    default: return "N/A"
-  }
+  if (!m) return "N/A"
+  return MacroLabel(m)
 }
 
 // DropdownSelector is a dropdown selector that sits right below the main navbar.
@@ -881,7 +877,7 @@ function CardImagesList({cards, deck, sb, opts, matchStr}) {
             {
               chunk.map(function(card, idx) {
                 let className = "cardimage"
-                if (matchStr && CardMatches(card, matchStr, true)) {
+                if (matchStr && CardHighlighted(card, matchStr)) {
                   className += " button-selected"
                 } else if (sb && InDeckColor(card, deck)) {
                   className += " card-playable-highlight"
@@ -1050,6 +1046,12 @@ function PlayerFrame(input) {
   const onDeckUpdated = input.onDeckUpdated
   const [saveError, setSaveError] = useState(null)
 
+  // The macro choices come from the cube's registry entry.
+  const [macros, setMacros] = useState(DefaultMacros)
+  useEffect(() => {
+    LoadCubeMacros(cube, setMacros).catch(() => setMacros(DefaultMacros))
+  }, [cube])
+
   // Build the WUBRG checkbox array from the deck's effective colors.
   const colorBools = ["W", "U", "B", "R", "G"].map((c) => (deck.colors || []).includes(c))
   const hasOverride = !!(deck.colors_override && deck.colors_override.length)
@@ -1213,9 +1215,7 @@ function PlayerFrame(input) {
             value={deck.macro_archetype || ""}
             options={[
               { label: "—", value: "" },
-              { label: "Aggro", value: "aggro" },
-              { label: "Midrange", value: "midrange" },
-              { label: "Control", value: "control" },
+              ...macros.map((m) => ({ label: getMacro({ macro_archetype: m }), value: m })),
             ]}
             onChange={(e) => commit({ macro: e.target.value })}
           />
@@ -1417,7 +1417,7 @@ function CardList({player, cards, deck, sb, opts, matchStr, basicsOnly, hovered,
                     let className = "decklist-row"
 
                     // Dynamic highlighting check
-                    if (matchStr && CardMatches(card, matchStr, true)) {
+                    if (matchStr && CardHighlighted(card, matchStr)) {
                       className += " button-selected"
                     } else if (sb && InDeckColor(card, deck)) {
                       className += " card-playable-highlight"
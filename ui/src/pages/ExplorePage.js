@@ -1,7 +1,7 @@
 import React, { useState, useEffect, useMemo } from 'react'
 import { useCube } from "../contexts/CubeContext.js"
 import { DropdownHeader, DateSelector, NumericInput, Button } from "../components/Dropdown.js"
-import { WIN_CONFIDENCE_OPTS } from "../utils/Stats.js"
+import { WIN_CONFIDENCE_OPTS, BUCKET_BY_OPTS } from "../utils/Stats.js"
 import { PredicateBuilder } from "../components/PredicateBuilder.js"
 import { bucketXScale } from "../utils/Buckets.js"
 import { guildColor } from "../utils/Colors.js"
@@ -188,6 +188,7 @@ export function ExplorePage(props) {
   const [metric, setMetric] = useState("win_pct")
   const [confidence, setConfidence] = useState("0.8")
   const [bucketSize, setBucketSize] = useState(3)
+  const [bucketBy, setBucketBy] = useState("drafts")
   const [predicates, setPredicates] = useState([])
 
   const [result, setResult] = useState(null)
@@ -236,6 +237,7 @@ export function ExplorePage(props) {
       group_by: groupBy,
       split_by: splitBy,
       bucket_size: usesTime ? Number(bucketSize) : 0,
+      bucket_by: usesTime ? bucketBy : "",
       predicates: predicates.filter(p => p.dim === "card_query" ? p.value !== "" : p.value !== ""),
       exclude_players: [...excluded],
       confidence: Number(confidence),
@@ -248,7 +250,7 @@ export function ExplorePage(props) {
       .then(r => r.json())
       .then(setResult)
       .catch(() => setResult(null))
-  }, [cubeID, start, end, groupBy, splitBy, bucketSize, predicates, refresh, usesTime, excluded, confidence])
+  }, [cubeID, start, end, groupBy, splitBy, bucketSize, bucketBy, predicates, refresh, usesTime, excluded, confidence])
 
   function togglePlayer(name) {
     setExcluded(prev => {
@@ -299,6 +301,10 @@ export function ExplorePage(props) {
           <NumericInput label="Bucket" value={bucketSize}
             onChange={(e) => setBucketSize(Math.max(1, Number(e.target.value)))} />
         )}
+        {usesTime && (
+          <DropdownHeader label="Bucket by" value={bucketBy} options={BUCKET_BY_OPTS}
+            onChange={(e) => setBucketBy(e.target.value)} />
+        )}
       </div>
 
       {meta.playerFreq.length > 0 && (
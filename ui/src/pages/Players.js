@@ -339,7 +339,7 @@ export function PlayerDetailsPanel(input) {
   // Add archetype data from the given player's decks.
   let archData = playerEntry.archetype_stats
 
-  let archRows = [newTracker("aggro"), newTracker("control"), newTracker("midrange")]
+  let archRows = input.parsed.macros.map((m) => newTracker(m))
   for (let a of archRows) {
     let name = a.get("name")
     // Check for both exact match and lowercase match
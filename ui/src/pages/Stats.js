@@ -48,9 +48,9 @@ export function StatsViewer(props) {
 
   // Destructure filter setters for the SelectorBar and Widgets
   const {
-    bucketSize, setBucketSize, playerMatch, setPlayerMatch, minDraftSize, setMinDraftSize,
+    bucketSize, setBucketSize, bucketBy, setBucketBy, playerMatch, setPlayerMatch, minDraftSize, setMinDraftSize,
     manaValue, setManaValue, selectedBucket, setSelectedBucket, colorTypeSelection, setColorTypeSelection,
-    colorSortBy, setColorSortBy, colorMode, setColorMode, colorCheckboxes, setColorCheckboxes,
+    colorSortBy, setColorSortBy, colorMode, setColorMode, matchupMode, setMatchupMode, colorCheckboxes, setColorCheckboxes,
     cardWidgetSelection, setCardWidgetSelection, minDrafts, setMinDrafts, minGames, setMinGames,
     winConfidence, setWinConfidence, significantOnly, setSignificantOnly,
     minPlayers, setMinPlayers, maxPlayers, setMaxPlayers, selectedCard, setSelectedCard,
@@ -110,6 +110,8 @@ export function StatsViewer(props) {
           onEndSelected={props.onEndSelected}
           bucketSize={bucketSize}
           onBucketsChanged={(e) => setBucketSize(Math.max(1, e.target.value))}
+          bucketBy={bucketBy}
+          onBucketBySelected={(e) => setBucketBy(e.target.value)}
           minDraftSize={minDraftSize}
           onMinDraftSizeChanged={(e) => setMinDraftSize(e.target.value)}
           winConfidence={winConfidence}
@@ -147,7 +149,8 @@ export function StatsViewer(props) {
           colorSortBy={colorSortBy} bucketSize={bucketSize} colorMode={colorMode}
           onColorModeChanged={(e) => setColorMode(e.target.value)}
           selectedBucket={selectedBucket} show={display[0]}
-          colorMatchupData={colorMatchupData}
+          colorMatchupData={colorMatchupData} matchupMode={matchupMode}
+          onMatchupModeChanged={(e) => setMatchupMode(e.target.value)}
         />
 
         <ArchetypeWidget
@@ -176,7 +179,7 @@ export function StatsViewer(props) {
           decks={parsed.filteredDecks} dropdownSelection={cardWidgetSelection}
           cardFilter={cardFilter} onCardFilterSelected={(e) => setCardFilter(e.target.value)}
           tagFilter={tagFilter} onTagFilterSelected={(e) => setTagFilter(e.target.value)}
-          cardWidgetOpts={[{ label: "Overview", value: "Overview" }, { label: "Metadata", value: "Metadata" }, { label: "Versus archetype", value: "Versus archetype" }, { label: "By archetype", value: "By archetype" }]}
+          cardWidgetOpts={[{ label: "Overview", value: "Overview" }, { label: "Metadata", value: "Metadata" }, { label: "Versus archetype", value: "Versus archetype" }, { label: "By archetype", value: "By archetype" }, { label: "Availability", value: "Availability" }]}
           onSelected={(e) => setCardWidgetSelection(e.target.value)}
           onCardSelected={(e) => setSelectedCard(e.currentTarget.id)}
           selectedCard={selectedCard}
@@ -1,5 +1,5 @@
 import React, { useState, useEffect, useMemo } from 'react';
-import { LoadCube, LoadDecks, LoadArchetypeData, LoadDrafts } from "../utils/Fetch.js";
+import { LoadCube, LoadDecks, LoadArchetypeData, LoadDrafts, LoadCubeMacros, DefaultMacros } from "../utils/Fetch.js";
 import { useCube } from "../contexts/CubeContext.js";
 import { ArchetypeData } from "./Types.js";
 import { PlayerData } from "./Players.js";
@@ -12,12 +12,14 @@ import { CheckboxesToColors } from "../utils/Utils.js";
 
 export function useStatsFilters() {
   const [bucketSize, setBucketSize] = useState(5);
+  const [bucketBy, setBucketBy] = useState("drafts");
   const [minDraftSize, setMinDraftSize] = useState(0);
   const [manaValue, setManaValue] = useState(-1);
   const [selectedBucket, setSelectedBucket] = useState("ALL");
   const [colorTypeSelection, setColorTypeSelection] = useState("Mono");
   const [colorSortBy, setColorSortBy] = useState("win");
   const [colorMode, setColorMode] = useState("inclusive");
+  const [matchupMode, setMatchupMode] = useState("splash");
   const [colorCheckboxes, setColorCheckboxes] = useState([false, false, false, false, false]);
   const [cardWidgetSelection, setCardWidgetSelection] = useState("Overview");
   const [minDrafts, setMinDrafts] = useState(0);
@@ -56,7 +58,8 @@ export function useStatsFilters() {
   const [playerColorSortBy, setPlayerColorSortBy] = useState("build_pct");
   const [playerColorSortInvert, setPlayerColorSortInvert] = useState(false);
   const [selectedPlayer, setSelectedPlayer] = useState("");
-  const [selectedArchetype, setSelectedArchetype] = useState("aggro");
+  // Picks the cube's first macro archetype once the macros load.
+  const [selectedArchetype, setSelectedArchetype] = useState("");
   const [sortBy, setSortBy] = useState("");
   const [minSynergyDecks, setMinSynergyDecks] = useState(5);
   const [focalThreshold, setFocalThreshold] = useState(1.5);
@@ -68,12 +71,14 @@ export function useStatsFilters() {
 
   return {
     bucketSize, setBucketSize,
+    bucketBy, setBucketBy,
     minDraftSize, setMinDraftSize,
     manaValue, setManaValue,
     selectedBucket, setSelectedBucket,
     colorTypeSelection, setColorTypeSelection,
     colorSortBy, setColorSortBy,
     colorMode, setColorMode,
+    matchupMode, setMatchupMode,
     colorCheckboxes, setColorCheckboxes,
     cardWidgetSelection, setCardWidgetSelection,
     minDrafts, setMinDrafts,
@@ -128,6 +133,7 @@ export function useStatsData(filters, props, refresh) {
   const cubeID = useCube();
   const [decks, setDecks] = useState([]);
   const [cube, setCube] = useState({ "cards": [] });
+  const [macros, setMacros] = useState(DefaultMacros);
   const [drafts, setDrafts] = useState(null);
   const [archetypeMatchups, setArchetypeMatchups] = useState([]);
   const [cardData, setCardData] = useState(new Map());
@@ -145,10 +151,19 @@ export function useStatsData(filters, props, refresh) {
   const { startDate, endDate } = props;
   const {
     minDraftSize, cardWidgetColorSelection, minDrafts,
-    minGames, winConfidence, significantOnly, bucketSize, colorMode, minSynergyDecks,
+    minGames, winConfidence, significantOnly, bucketSize, bucketBy, colorMode, matchupMode, minSynergyDecks,
     focalThreshold, smoothingK, colorAdjust, synergyRecord
   } = filters;
 
+  // The cube's macro archetypes drive the per-archetype columns and charts.
+  useEffect(() => {
+    const onLoad = (m) => {
+      setMacros(m);
+      filters.setSelectedArchetype(a => a || m[0]);
+    };
+    LoadCubeMacros(cubeID, onLoad).catch(() => onLoad(DefaultMacros));
+  }, [cubeID]);
+
   // Initial Load
   useEffect(() => {
     Promise.all([
@@ -167,10 +182,10 @@ export function useStatsData(filters, props, refresh) {
   }, [cardWidgetColorSelection, minDrafts, minGames, winConfidence, significantOnly, startDate, endDate, minDraftSize, props.matchStr, refresh]);
 
   useEffect(() => {
-    fetch(`/api/${cubeID}/stats/cards?color=${cardWidgetColorSelection}&min_drafts=${minDrafts}&min_games=${minGames}&bucket_size=${bucketSize}&sliding=true&match=${encodeURIComponent(props.matchStr || "")}`)
+    fetch(`/api/${cubeID}/stats/cards?color=${cardWidgetColorSelection}&min_drafts=${minDrafts}&min_games=${minGames}&bucket_size=${bucketSize}&bucket_by=${bucketBy}&sliding=true&match=${encodeURIComponent(props.matchStr || "")}`)
       .then(r => r.json())
       .then(d => setCardDataBucketed(Array.from(d.buckets)));
-  }, [cardWidgetColorSelection, minDrafts, minGames, bucketSize, props.matchStr, refresh]);
+  }, [cardWidgetColorSelection, minDrafts, minGames, bucketSize, bucketBy, props.matchStr, refresh]);
 
   // Color Data
   useEffect(() => {
@@ -180,10 +195,10 @@ export function useStatsData(filters, props, refresh) {
   }, [colorMode, winConfidence, startDate, endDate, minDraftSize, props.matchStr, refresh]);
 
   useEffect(() => {
-    fetch(`/api/${cubeID}/stats/colors?start=${startDate}&end=${endDate}&size=${minDraftSize}&color_mode=${colorMode}&bucket_size=${bucketSize}&sliding=true&match=${encodeURIComponent(props.matchStr || "")}`)
+    fetch(`/api/${cubeID}/stats/colors?start=${startDate}&end=${endDate}&size=${minDraftSize}&color_mode=${colorMode}&bucket_size=${bucketSize}&bucket_by=${bucketBy}&sliding=true&match=${encodeURIComponent(props.matchStr || "")}`)
       .then(r => r.json())
       .then(d => setColorDataBucketed(Array.from(d.buckets)));
-  }, [colorMode, bucketSize, startDate, endDate, minDraftSize, props.matchStr, refresh]);
+  }, [colorMode, bucketSize, bucketBy, startDate, endDate, minDraftSize, props.matchStr, refresh]);
 
   // Archetype & Player Stats (Aggregated)
   useEffect(() => {
@@ -236,10 +251,10 @@ export function useStatsData(filters, props, refresh) {
 
   // Color Matchup Data
   useEffect(() => {
-    fetch(`/api/${cubeID}/stats/color-matchups?start=${startDate}&end=${endDate}&size=${minDraftSize}&color_mode=${colorMode}&color_type=${filters.colorTypeSelection}&match=${encodeURIComponent(props.matchStr || "")}`)
+    fetch(`/api/${cubeID}/stats/color-matchups?start=${startDate}&end=${endDate}&size=${minDraftSize}&color_mode=${matchupMode}&color_type=${filters.colorTypeSelection}&match=${encodeURIComponent(props.matchStr || "")}`)
       .then(r => r.json())
-      .then(d => setColorMatchupData(d.matchups || {}));
-  }, [startDate, endDate, minDraftSize, colorMode, filters.colorTypeSelection, props.matchStr, refresh]);
+      .then(d => setColorMatchupData(d));
+  }, [startDate, endDate, minDraftSize, matchupMode, filters.colorTypeSelection, props.matchStr, refresh]);
 
   // Design Graph Data
   useEffect(() => {
@@ -250,10 +265,10 @@ export function useStatsData(filters, props, refresh) {
 
   // Health Data
   useEffect(() => {
-    fetch(`/api/${cubeID}/stats/health?bucket_size=${bucketSize}&sliding=true&start=${startDate}&end=${endDate}&size=${minDraftSize}&match=${encodeURIComponent(props.matchStr || "")}`)
+    fetch(`/api/${cubeID}/stats/health?bucket_size=${bucketSize}&bucket_by=${bucketBy}&sliding=true&start=${startDate}&end=${endDate}&size=${minDraftSize}&match=${encodeURIComponent(props.matchStr || "")}`)
       .then(r => r.json())
       .then(d => setHealthData(d.buckets || []));
-  }, [bucketSize, startDate, endDate, minDraftSize, props.matchStr, refresh]);
+  }, [bucketSize, bucketBy, startDate, endDate, minDraftSize, props.matchStr, refresh]);
 
   // Derived Data
   const filteredDecks = useMemo(() => {
@@ -273,22 +288,22 @@ export function useStatsData(filters, props, refresh) {
 
   const archetypeData = useMemo(() => {
     let filterByColor = filters.colorCheckboxes.some(e => e);
-    if (filterByColor || props.matchStr) return ArchetypeData(filteredDecks, winConfidence);
+    if (filterByColor || props.matchStr) return ArchetypeData(filteredDecks, winConfidence, macros);
     return archetypeStats instanceof Map ? archetypeStats : new Map(Object.entries(archetypeStats));
-  }, [filteredDecks, archetypeStats, filters.colorCheckboxes, props.matchStr, winConfidence]);
+  }, [filteredDecks, archetypeStats, filters.colorCheckboxes, props.matchStr, winConfidence, macros]);
 
   const playerData = useMemo(() => {
     let filterByColor = filters.colorCheckboxes.some(e => e);
     if (filterByColor || props.matchStr) {
       const pd = PlayerData(filteredDecks);
       for (let d of pd.values()) {
-        d.archetypeData = ArchetypeData(d.decks, winConfidence);
+        d.archetypeData = ArchetypeData(d.decks, winConfidence, macros);
         d.colorStats = GetColorStats(d.decks, filters.colorMode);
       }
       return pd;
     }
     return playerStats instanceof Map ? playerStats : new Map(Object.entries(playerStats));
-  }, [filteredDecks, playerStats, filters.colorCheckboxes, props.matchStr, filters.colorMode, winConfidence]);
+  }, [filteredDecks, playerStats, filters.colorCheckboxes, props.matchStr, filters.colorMode, winConfidence, macros]);
 
   const deckBuckets = useMemo(() => {
     if (filteredDecks.length === 0) return [];
@@ -296,17 +311,17 @@ export function useStatsData(filters, props, refresh) {
     for (let b of db) {
       let bucketDecks = [];
       for (let draft of b) bucketDecks = bucketDecks.concat(draft.decks);
-      b.archetypeData = ArchetypeData(bucketDecks, winConfidence);
+      b.archetypeData = ArchetypeData(bucketDecks, winConfidence, macros);
       b.playerData = PlayerData(bucketDecks);
     }
     return db;
-  }, [filteredDecks, bucketSize, winConfidence]);
+  }, [filteredDecks, bucketSize, winConfidence, macros]);
 
   const pickInfo = useMemo(() => AggregatedPickInfo(drafts, cube, ""), [drafts, cube]);
 
   const parsed = useMemo(() => ({
-    bucketSize, filteredDecks, archetypeData, playerData, pickInfo, colorData, colorDataBucketed, deckBuckets,
-  }), [bucketSize, filteredDecks, archetypeData, playerData, pickInfo, colorData, colorDataBucketed, deckBuckets]);
+    bucketSize, filteredDecks, archetypeData, playerData, pickInfo, colorData, colorDataBucketed, deckBuckets, macros,
+  }), [bucketSize, filteredDecks, archetypeData, playerData, pickInfo, colorData, colorDataBucketed, deckBuckets, macros]);
 
   const graphData = useMemo(() => BuildGraphData({ filteredDecks, deckBuckets, bucketSize }), [filteredDecks, deckBuckets, bucketSize]);
 
@@ -337,7 +352,7 @@ export function useStatsData(filters, props, refresh) {
   }, [drafts]);
 
   return {
-    decks, cube, drafts, archetypeMatchups, cardData, cardDataBucketed,
+    decks, cube, macros, drafts, archetypeMatchups, cardData, cardDataBucketed,
     colorData, colorDataBucketed, synergyData, synergyCompare, colorMatchupData, healthData,
     designGraphData, parsed, graphData, archetypeDropdownOptions, draftLogs
   };
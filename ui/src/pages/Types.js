@@ -1,5 +1,5 @@
 import React from 'react'
-import { IsBasicLand, MinWinningPctDecks, Pct, SortFunc, StringToColor } from "../utils/Utils.js"
+import { IsBasicLand, MacroLabel, MinWinningPctDecks, Pct, SortFunc, StringToColor } from "../utils/Utils.js"
 import { Trophies, LastPlaceFinishes, Winning, Losing, Wins, Losses } from "../utils/Deck.js"
 import { DropdownHeader, NumericInput, Checkbox, DateSelector } from "../components/Dropdown.js"
 import { Section, SectionNav } from "../components/PageSections.js"
@@ -71,9 +71,7 @@ export function ArchetypeWidget(input) {
     { label: "Macro Trophy %", value: "macro_trophy_pct" },
     { label: "Macro Last Place %", value: "macro_lastplace_pct" },
     { label: "Macro CMC", value: "macro_cmc" },
-    { label: "Matchup: Aggro", value: "matchup_aggro" },
-    { label: "Matchup: Midrange", value: "matchup_midrange" },
-    { label: "Matchup: Control", value: "matchup_control" },
+    ...input.parsed.macros.map((m) => ({ label: `Matchup: ${MacroLabel(m)}`, value: `matchup:${m}` })),
     { label: "Pie: Builds", value: "pie_builds" },
     { label: "Pie: Wins", value: "pie_wins" },
     { label: "Micro Build %", value: "micro_builds" },
@@ -85,6 +83,9 @@ export function ArchetypeWidget(input) {
   ]
 
   const renderChart = (chartId) => {
+    if (chartId.startsWith("matchup:")) {
+      return <WinsByMatchup focus={chartId.slice("matchup:".length)} macros={input.parsed.macros} matchups={input.matchups} />;
+    }
     switch (chartId) {
       case "macro_delta": return <MacroArchetypeDeltaChart parsed={input.parsed} />;
       case "macro_builds": return <MacroArchetypesChart parsed={input.parsed} decks={input.decks} bucketSize={input.bucketSize} dataset="builds" />;
@@ -94,9 +95,6 @@ export function ArchetypeWidget(input) {
       case "macro_trophy_pct": return <MacroArchetypesChart parsed={input.parsed} decks={input.decks} bucketSize={input.bucketSize} dataset="trophy_pct" />;
       case "macro_lastplace_pct": return <MacroArchetypesChart parsed={input.parsed} decks={input.decks} bucketSize={input.bucketSize} dataset="lastplace_pct" />;
       case "macro_cmc": return <MacroArchetypesChart parsed={input.parsed} decks={input.decks} bucketSize={input.bucketSize} dataset="cmc" />;
-      case "matchup_aggro": return <WinsByMatchup focus="aggro" matchups={input.matchups} />;
-      case "matchup_midrange": return <WinsByMatchup focus="midrange" matchups={input.matchups} />;
-      case "matchup_control": return <WinsByMatchup focus="control" matchups={input.matchups} />;
       case "pie_builds": return <MacroArchetypesPieChart parsed={input.parsed} decks={input.decks} dataset="builds" />;
       case "pie_wins": return <MacroArchetypesPieChart parsed={input.parsed} decks={input.decks} dataset="wins" />;
       case "micro_builds": return <MicroArchetypesChart parsed={input.parsed} decks={input.decks} bucketSize={input.bucketSize} dataset="builds" />;
@@ -180,13 +178,19 @@ export function ArchetypeWidget(input) {
   );
 }
 
-const macroArchetypes = new Set(["aggro", "midrange", "control"]);
+// macroColors keeps the colors the default macro archetypes have always been
+// drawn in; any other macro a cube configures gets one derived from its name.
+const macroColors = new Map([["aggro", Colors.get("R")], ["midrange", Colors.get("G")], ["control", Colors.get("U")]])
+
+function macroColor(macro) {
+  return macroColors.get(macro) || StringToColor(macro)
+}
 
 const archetypeHeaders = [
   {
     id: "type",
     text: "Tag",
-    tip: "Archetype (aggro / midrange / control) or tag applied to a deck. A deck may have multiple tags"
+    tip: "Macro archetype (as configured for the cube) or tag applied to a deck. A deck may have multiple tags"
   },
   {
     id: "build_percent",
@@ -348,7 +352,7 @@ function ArchetypeStatsTable(input) {
   let microData = []
   for (let arch of archetypes.values()) {
     if (arch.build_percent >= watermark) {
-      if (macroArchetypes.has(arch.type)) {
+      if (input.parsed.macros.includes(arch.type)) {
         macroData.push(arch)
       } else {
         microData.push(arch)
@@ -659,7 +663,7 @@ function ArchetypeDetailsPanel(input) {
   );
 }
 
-export function ArchetypeData(decks, confidence) {
+export function ArchetypeData(decks, confidence, macros) {
   let newType = function(type) {
     return {
       type: type,
@@ -688,11 +692,11 @@ export function ArchetypeData(decks, confidence) {
   let totalGames = 0
   let tracker = new Map()
 
-  // We set aggro/midrange/control for every set of decks, even if they are
+  // We set every macro archetype for every set of decks, even if they are
   // zeroed out. This enables graphs that expect these to exist.
-  tracker.set("aggro", newType("aggro"))
-  tracker.set("midrange", newType("midrange"))
-  tracker.set("control", newType("control"))
+  for (let m of macros) {
+    tracker.set(m, newType(m))
+  }
 
   for (let deck of decks) {
     // We only need to count wins, because every loss is counted in another deck as a win.
@@ -730,8 +734,8 @@ export function ArchetypeData(decks, confidence) {
 
       // Track other types shared with this one, and how frequent.
       for (var k in types) {
-        // Skip aggro / control / midrange since those are applied to every deck.
-        if (types[k] == "aggro" || types[k] == "midrange" || types[k] == "control") {
+        // Skip macro archetypes since those are applied to every deck.
+        if (macros.includes(types[k])) {
           continue
         }
         if (types[k] != type) {
@@ -793,9 +797,9 @@ function MicroArchetypesChart(input) {
   }
 
   // Delete macro archetypes, as these plots are specifically about micro archetypes.
-  archSet.delete("aggro")
-  archSet.delete("midrange")
-  archSet.delete("control")
+  for (let m of input.parsed.macros) {
+    archSet.delete(m)
+  }
 
   // We want to fitler out any archtetypes that don't meet a minimum
   // criteria, in order to de-clutter the plots. Use aggregate data across all buckets
@@ -924,7 +928,7 @@ function MacroArchetypeDeltaChart(input) {
 
   let rows = []
   for (let arch of archetypes.values()) {
-    if (!macroArchetypes.has(arch.type)) {
+    if (!input.parsed.macros.includes(arch.type)) {
       continue
     }
     // Skip archetypes with no games so an empty bucket doesn't read as a -50% loser.
@@ -1004,7 +1008,7 @@ function MacroArchetypesChart(input) {
   }
 
   // Parse the buckets.
-  let archs = ["aggro", "midrange", "control"]
+  let archs = input.parsed.macros
   let datasets = new Map()
   for (let arch of archs) {
     datasets.set(arch, [])
@@ -1044,26 +1048,12 @@ function MacroArchetypesChart(input) {
     }
   }
 
-  let chartDataset = [
-      {
-        label: 'Aggro',
-        data: datasets.get("aggro"),
-        borderColor: Colors.get("R"),
-        backgroundColor: Colors.get("R"),
-      },
-      {
-        label: 'Midrange',
-        data: datasets.get("midrange"),
-        borderColor: Colors.get("G"),
-        backgroundColor: Colors.get("G"),
-      },
-      {
-        label: 'Control',
-        data: datasets.get("control"),
-        borderColor: Colors.get("U"),
-        backgroundColor: Colors.get("U"),
-      },
-  ]
+  let chartDataset = archs.map((arch) => ({
+    label: MacroLabel(arch),
+    data: datasets.get(arch),
+    borderColor: macroColor(arch),
+    backgroundColor: macroColor(arch),
+  }))
 
   let title = `Build rate (bucket size = ${input.bucketSize} drafts)`
   switch (input.dataset) {
@@ -1121,40 +1111,26 @@ function MacroArchetypesChart(input) {
 
 function MacroArchetypesPieChart(input) {
   let stats = input.parsed.archetypeData
+  let macros = input.parsed.macros
 
+  // A macro nobody has built yet has no entry in the server's stats.
   let title = `Decks`
-  let graphData = [
-    stats.get("aggro").count,
-    stats.get("midrange").count,
-    stats.get("control").count,
-  ]
+  let graphData = macros.map((m) => stats.has(m) ? stats.get(m).count : 0)
 
   switch (input.dataset) {
     case "wins":
       title = `Wins`
-      graphData = [
-        stats.get("aggro").wins,
-        stats.get("midrange").wins,
-        stats.get("control").wins,
-      ]
+      graphData = macros.map((m) => stats.has(m) ? stats.get(m).wins : 0)
   }
 
   let data = {
-    labels: ['Aggro', 'Midrange', 'Control'],
+    labels: macros.map(MacroLabel),
     datasets: [
       {
         label: title,
         data: graphData,
-        backgroundColor: [
-          'rgba(255, 99, 132, 0.2)',
-          'rgba(255, 206, 86, 0.2)',
-          'rgba(54, 162, 235, 0.2)',
-        ],
-        borderColor: [
-          'rgba(255, 99, 132, 1)',
-          'rgba(255, 206, 86, 1)',
-          'rgba(54, 162, 235, 1)',
-        ],
+        backgroundColor: macros.map(macroColor),
+        borderColor: macros.map(macroColor),
         borderWidth: 1,
       },
     ],
@@ -1192,13 +1168,17 @@ function MacroArchetypesPieChart(input) {
 
 function WinsByMatchup(input) {
   // For now, just use the first matchup.
-  let matchup = input.matchups.items[0]
-  for (let m of input.matchups.items) {
+  let items = (input.matchups && input.matchups.items) || []
+  let matchup = items[0]
+  for (let m of items) {
     if (m.name == input.focus) {
       matchup = m;
       break;
     }
   }
+  if (matchup == null) {
+    return null
+  }
 
   const options = {
     responsive: true,
@@ -1227,7 +1207,7 @@ function WinsByMatchup(input) {
     },
   };
 
-  // Sort the vs. by relative "speed" - i.e., aggro, midrange, control.
+  // Order the vs. by the cube's macro list - by default aggro, midrange, control.
   let versus = new Array()
   let find = function(t) {
     if (matchup.versus == null) {
@@ -1252,8 +1232,7 @@ function WinsByMatchup(input) {
     }
   }
 
-  let ats = ["aggro", "midrange", "control"]
-  for (let n of ats) {
+  for (let n of input.macros) {
     if (n == matchup.name) {
       continue;
     }
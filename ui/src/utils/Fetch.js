@@ -39,6 +39,18 @@ export async function LoadDecks(cube, onLoad, start, end, draftSize, playerMatch
   onLoad(list)
 }
 
+// DefaultMacros are the macro archetypes of a cube whose registry entry doesn't
+// configure any.
+export const DefaultMacros = ["aggro", "midrange", "control"]
+
+// LoadCubeMacros looks up the cube's macro archetypes in the cube registry.
+export async function LoadCubeMacros(cube, onLoad) {
+  const resp = await fetch('/api/cubes');
+  let d = await resp.json();
+  const entry = (d.cubes || []).find((c) => c.id === cube)
+  onLoad(entry && entry.macros && entry.macros.length ? entry.macros : DefaultMacros)
+}
+
 export async function LoadArchetypeData(cube, onLoad, start, end, draftSize, playerMatch, match) {
   const resp = await fetch(`/api/${cube}/archetypes?start=${start}&end=${end}&size=${draftSize}&player=${playerMatch}&match=${encodeURIComponent(match || "")}`);
   let d = await resp.json();
@@ -1,6 +1,3 @@
-import { CombineColors } from "../utils/Colors.js"
-
-
 export const QueryTerms = [
   "pow",
   "name",
@@ -8,6 +5,7 @@ export const QueryTerms = [
   "color",
   "t",
   "cmc",
+  "mv",
   "games",
   "mb",
   "sb",
@@ -24,6 +22,7 @@ export const QueryTermMetadata = [
   { term: "color", description: "Card color", operators: [":", "=", "!="], valueType: "color", example: "color:ug", values: ["w", "u", "b", "r", "g"] },
   { term: "dcolor", description: "Deck color", operators: [":", "=", "!="], valueType: "color", example: "dcolor:ug", values: ["w", "u", "b", "r", "g"], isDeckOnly: true },
   { term: "cmc", description: "Mana value", operators: ["<", ">", "="], valueType: "number", example: "cmc<3" },
+  { term: "mv", description: "Mana value", operators: ["<", ">", "="], valueType: "number", example: "mv<3" },
   { term: "t", description: "Card type", operators: [":"], valueType: "text", example: "t:creature", values: ["Artifact", "Creature", "Enchantment", "Instant", "Land", "Planeswalker", "Sorcery", "Basic", "Legendary", "Tribal", "World"] },
   { term: "o", description: "Oracle text", operators: [":"], valueType: "text", example: "o:flying" },
   { term: "name", description: "Card name", operators: [":"], valueType: "text", example: "name:bolt" },
@@ -31,7 +30,7 @@ export const QueryTermMetadata = [
   { term: "games", description: "Total games played", operators: ["<", ">"], valueType: "number", example: "games>5" },
   { term: "mb", description: "Mainboard count", operators: ["<", ">", "="], valueType: "number", example: "mb>0" },
   { term: "sb", description: "Sideboard count", operators: ["<", ">", "="], valueType: "number", example: "sb>0" },
-  { term: "players", description: "Number of drafters", operators: ["<", ">", "=", ":"], valueType: "number", example: "players>2" },
+  { term: "players", description: "Number of drafters", operators: ["<", ">", "="], valueType: "number", example: "players>2" },
   { term: "drafts", description: "Number of drafts", operators: ["<", ">", "="], valueType: "number", example: "drafts>3" },
   { term: "winpct", description: "Win percentage", operators: ["<", ">", "="], valueType: "number", example: "winpct>50" },
   { term: "arch", description: "Deck archetype", operators: [":"], valueType: "text", example: "arch:aggro", isDeckOnly: true },
@@ -41,870 +40,817 @@ export const QueryTermMetadata = [
   { term: "minCards", description: "Min matching cards", operators: [":"], valueType: "number", example: "minCards:3", isDeckOnly: true },
 ]
 
-export function CardMatches(card, matchStr, checkText) {
-  if (isTermQuery(matchStr)) {
-    // This is a fuzzy match. Split the string and check each term.
-    let splits = parseTerms(matchStr)
-    let matchedAnyCardTerm = false
-
-    // Check if any oracle terms match.
-    if (splits.some(term => term.startsWith("o:"))) {
-      matchedAnyCardTerm = true
-      // If there are oracle terms, we need to match at least one of them.
-      if (!oraclesMatch(splits, card)) {
-        return false
-      }
-    }
-
-    // Check if any power terms match.
-    if (splits.some(term => term.startsWith("pow"))) {
-      matchedAnyCardTerm = true
-      // If there are power terms, we need to match at least one of them.
-      if (!powersMatch(splits, card)) {
-        return false
-      }
-    }
-
-    // Check if any color terms match.
-    if (splits.some(term => isColorTerm(term))) {
-      matchedAnyCardTerm = true
-      // If there are color terms, we need to match at least one of them.
-      if (!colorsMatch(splits, card)) {
-        return false
-      }
-    }
-
-    // Check if any type terms match.
-    if (splits.some(term => isTypeTerm(term))) {
-      matchedAnyCardTerm = true
-      // If there are type terms, we need to match at least one of them.
-      if (!typesMatch(splits, card)) {
-        return false
-      }
-    }
-
-    // Check if any name terms match.
-    if (splits.some(term => isNameTerm(term))) {
-      matchedAnyCardTerm = true
-      // If there are name terms, we need to match at least one of them.
-      if (!namesMatch(splits, card)) {
-        return false
-      }
-    }
-
-    // Check if the cmc terms match.
-    if (splits.some(term => isCMCTerm(term))) {
-      matchedAnyCardTerm = true
-      if (!cmcsMatch(splits, card)) {
-        return false
-      }
-    }
-
-    // Check if the # games queries match.
-    if (splits.some(term => isGamesTerm(term))) {
-      matchedAnyCardTerm = true
-      if (!gamesMatch(splits, card)) {
-        return false
-      }
-    }
-
-    // Check if mainboard queries match.
-    if (splits.some(term => isMainboardTerm(term))) {
-      matchedAnyCardTerm = true
-      if (!mainboardsMatch(splits, card)) {
-        return false
-      }
-    }
-
-    // Check if sideboard queries match.
-    if (splits.some(term => isSideboardTerm(term))) {
-      matchedAnyCardTerm = true
-      if (!sideboardsMatch(splits, card)) {
-        return false
-      }
-    }
-
-
-    // Check if # drafts queries match.
-    if (splits.some(term => isDraftsTerm(term))) {
-      matchedAnyCardTerm = true
-      if (!draftsTermsMatch(splits, card)) {
-        return false
-      }
-    }
-
-    // Check if the # of players queries match.
-    if (splits.some(term => isPlayersTerm(term))) {
-      matchedAnyCardTerm = true
-      if (!playersTermsMatch(splits, card)) {
-        return false
-      }
-    }
-
-    // Win percentage queries.
-    if (splits.some(term => isWinPercentageTerm(term))) {
-      matchedAnyCardTerm = true
-      if (!winPercentagesMatch(splits, card)) {
-        return false
-      }
-    }
-
-    // If we only have deck-only terms (like arch:), we shouldn't highlight cards.
-    return matchedAnyCardTerm
+// The query language is the server's (see pkg/server/query): terms are ANDed,
+// OR and parentheses group them, and -, ! or NOT negates. Some pages filter
+// data they already hold rather than asking the server again, so what follows
+// is a port of the server's lexer, parser and matcher. Keep the two in step,
+// or the same search will give different results depending on the page.
+
+// CardMatches reports whether the card satisfies the query. Deck terms don't
+// restrict a lone card, and stat terms only apply to the per-card stats rows
+// from the cards endpoint. A query that doesn't parse matches nothing.
+export function CardMatches(card, matchStr) {
+  const q = ParseQuery(matchStr)
+  if (q.error) {
+    return false
   }
-
-  if (card.name.toLowerCase().match(matchStr.toLowerCase())) {
+  const stats = cardStats(card)
+  const root = stats ? q.stats : q.card
+  if (!root) {
     return true
   }
-  if (checkText && card.oracle_text) {
-    if (card.oracle_text.toLowerCase().match(matchStr.toLowerCase())) {
-      return true
-    }
-  }
-  return false
+  return matchCard(root, card, stats)
 }
 
-// Return the minimum required cards that mush match the given match string
-// for a deck to be considered a match.
-function minRequiredCardMatches(matchStr) {
-  let count = 0
-  let splits = parseTerms(matchStr)
-  for (let term of splits) {
-    if (term.startsWith("minCards:")) {
-      let val = parseInt(term.replace("minCards:", ""))
-      if (!isNaN(val)) {
-        count = val
-      }
-    }
+// CardHighlighted reports whether a card in a deck view should be picked out
+// for the query: it has to match, and the query has to say something about
+// cards, so a search like arch:aggro doesn't light up the whole deck.
+export function CardHighlighted(card, matchStr) {
+  const q = ParseQuery(matchStr)
+  if (q.error || !q.card) {
+    return false
   }
-  return count
+  return matchCard(q.card, card, null)
 }
 
-export function DeckMatches(deck, matchStr, mbsb) {
-  if (!matchStr) return true;
-
-  let splits = parseTerms(matchStr)
-  let isTerm = isTermQuery(matchStr)
-  
-  let deckTerms = []
-  let cardTerms = []
-  
-  if (isTerm) {
-    for (let term of splits) {
-      const meta = QueryTermMetadata.find(m => {
-        // Match term name followed by an operator.
-        const ops = ["!=", ":", "=", "<", ">"]
-        for (const op of ops) {
-          if (term.startsWith(m.term + op)) return true;
-        }
-        return false;
-      });
-
-      if (meta && meta.isDeckOnly) {
-        deckTerms.push(term)
-      } else {
-        cardTerms.push(term)
-      }
-    }
-  } else {
-    // Fuzzy search: check player name and labels first.
-    const fs = matchStr.toLowerCase()
-    if (deck.player.toLowerCase().includes(fs)) return true;
-    if (deck.metadata && deck.metadata.draft_id && deck.metadata.draft_id.toLowerCase().includes(fs)) return true;
-    if (deck.macro_archetype && deck.macro_archetype.toLowerCase().includes(fs)) return true;
-    for (let label of (deck.labels || [])) {
-      if (label.toLowerCase().includes(fs)) return true;
-    }
-    // If not matched, treat the fuzzy string as a card term.
-    cardTerms.push(matchStr)
-  }
-  
-  // 1. All deck terms must match.
-  if (deckTerms.length > 0) {
-    for (let term of deckTerms) {
-      if (term.startsWith("arch:") || term.startsWith("arch!=")) {
-        if (!deckTypeMatches(term, deck)) return false;
-      } else if (term.startsWith("player:")) {
-        const val = term.replace("player:", "").replace(/"/g, "").toLowerCase()
-        if (!deck.player.toLowerCase().includes(val)) return false;
-      } else if (term.startsWith("event:")) {
-        const val = term.replace("event:", "").replace(/"/g, "").toLowerCase()
-        const eid = (deck.metadata && deck.metadata.draft_id) || ""
-        if (!eid.toLowerCase().includes(val)) return false;
-      } else if (term.startsWith("dcolor")) {
-        // dcolor matches the deck's overall colors.
-        let deckColors = CombineColors(deck.colors).toLowerCase()
-        if (term.startsWith("dcolor!=")) {
-          let val = term.replace("dcolor!=", "").toLowerCase()
-          let query = CombineColors(val.split("")).toLowerCase()
-          if (query == deckColors) return false;
-        } else if (term.startsWith("dcolor=")) {
-          let val = term.replace("dcolor=", "").toLowerCase()
-          let query = CombineColors(val.split("")).toLowerCase()
-          if (query != deckColors) return false;
-        } else if (term.startsWith("dcolor:")) {
-          let query = term.replace("dcolor:", "").toLowerCase()
-          // True if all of the colors in the query are included in the deck's colors.
-          if (!query.split("").every(c => deckColors.includes(c))) return false;
-        }
-      } else if (term.startsWith("draftSize")) {
-        let val = 0
-        let matches = false
-        if (term.startsWith("draftSize<")) {
-          val = parseInt(term.replace("draftSize<", ""))
-          matches = deck.draft_size < val
-        } else if (term.startsWith("draftSize>")) {
-          val = parseInt(term.replace("draftSize>", ""))
-          matches = deck.draft_size > val
-        } else if (term.startsWith("draftSize=")) {
-          val = parseInt(term.replace("draftSize=", ""))
-          matches = deck.draft_size == val
-        }
-        if (!matches) return false
-      }
-    }
+// DeckMatches reports whether the deck satisfies the query, with card terms
+// matched against the named board ("Sideboard" or "Pool", defaulting to the
+// mainboard). A query that doesn't parse matches nothing.
+export function DeckMatches(deck, matchStr, board) {
+  const q = ParseQuery(matchStr)
+  if (q.error) {
+    return false
   }
-  
-  // 2. If there are card terms (or fuzzy search that didn't match metadata),
-  // then the deck must have at least minCards matching those terms.
-  if (cardTerms.length > 0) {
-    let minCards = minRequiredCardMatches(matchStr) || 1
-    
-    let cards = []
-    if (mbsb == "Mainboard") cards = deck.mainboard;
-    else if (mbsb == "Sideboard") cards = deck.sideboard;
-    else {
-      cards = (deck.mainboard || []).concat(deck.sideboard || []).concat(deck.pool || [])
-    }
-
-    // Every cardTerm must be satisfied by at least one card in the deck.
-    // (This allows queries like "name:bolt name:fire" to find decks with both).
-    for (let term of cardTerms) {
-      let matched = false;
-      for (let card of cards) {
-        if (CardMatches(card, term, true)) {
-          matched = true;
-          break;
-        }
-      }
-      if (!matched) return false;
-    }
-
-    // Additionally, if minCards is specified, we must ensure at least that many
-    // cards match the *entire* set of cardTerms (the old behavior for minCards).
-    if (minCards > 1) {
-      let matchCount = 0
-      for (let card of cards) {
-        if (CardMatches(card, cardTerms.join(" "), true)) {
-          matchCount++
-        }
-      }
-      if (matchCount < minCards) return false;
-    }
-
-    return true;
+  if (!q.deck) {
+    return true
   }
-
-  // If no card terms, the deck matched based on deck terms alone.
-  return true;
-}
-
-export function parseTerms(matchStr) {
-  // Split the string into terms. A term ends at a space, unless the space is inside quotes.
-  let terms = []
-  let currentTerm = ""
-  let inQuotes = false
-  for (let char of matchStr) {
-    if (char == '"') {
-      inQuotes = !inQuotes
-      currentTerm += char
-    } else if (char == " " && !inQuotes) {
-      if (currentTerm.length > 0) {
-        terms.push(currentTerm)
-        currentTerm = ""
-      }
+  return matchDeck(q.deck, deck, boardCards(deck, board))
+}
+
+// lastParsed caches the most recent parse, since a page filters every row
+// against the same query.
+let lastParsed = { str: null, query: null }
+
+// ParseQuery parses a query string. The result holds the parsed tree pruned for
+// each kind of match, or error describing the first problem found. An empty
+// query matches everything.
+export function ParseQuery(matchStr) {
+  matchStr = matchStr || ""
+  if (lastParsed.str !== matchStr) {
+    lastParsed = { str: matchStr, query: parseQuery(matchStr) }
+  }
+  return lastParsed.query
+}
+
+function parseQuery(matchStr) {
+  try {
+    const toks = lex(matchStr)
+    const p = new parser(toks)
+    if (p.peek().kind == tokEOF) {
+      return {}
+    }
+    const root = p.parseOr()
+    const tok = p.peek()
+    if (tok.kind == tokRParen) {
+      throw queryError(tok.pos, "unexpected )")
+    }
+    if (tok.kind != tokEOF) {
+      throw queryError(tok.pos, "unexpected token")
+    }
+    return {
+      root: root,
+      card: widen(root, cardTerm),
+      stats: widen(root, cardStatTerm),
+      deck: widen(root, deckTerm),
+    }
+  } catch (e) {
+    if (e.queryPos === undefined) {
+      throw e
+    }
+    return { error: e.message }
+  }
+}
+
+function queryError(pos, msg) {
+  const e = new Error(`${msg} (at position ${pos + 1})`)
+  e.queryPos = pos
+  return e
+}
+
+const tokEOF = "eof"
+const tokLParen = "("
+const tokRParen = ")"
+const tokAnd = "and"
+const tokOr = "or"
+const tokNot = "not"
+const tokTerm = "term"
+
+// operators are the comparisons a term may use, longest first so "<=" isn't
+// read as "<".
+const operators = ["!=", "<=", ">=", ":", "=", "<", ">"]
+
+function isSpace(ch) {
+  return ch == " " || ch == "\t" || ch == "\n"
+}
+
+// lex splits a query into tokens. Whitespace separates terms, parentheses are
+// always their own token, and a double-quoted value may contain either.
+function lex(s) {
+  let toks = []
+  let i = 0
+  while (i < s.length) {
+    const ch = s[i]
+    if (isSpace(ch)) {
+      i++
+    } else if (ch == "(") {
+      toks.push({ kind: tokLParen, pos: i })
+      i++
+    } else if (ch == ")") {
+      toks.push({ kind: tokRParen, pos: i })
+      i++
+    } else if ((ch == "-" || ch == "!") && i + 1 < s.length && !isSpace(s[i + 1])) {
+      // A leading - (Scryfall) or ! negates whatever follows. A lone one
+      // followed by space is just a word.
+      toks.push({ kind: tokNot, pos: i })
+      i++
     } else {
-      currentTerm += char
+      const [tok, next] = lexTerm(s, i)
+      toks.push(tok)
+      i = next
     }
   }
-  if (currentTerm.length > 0) {
-    terms.push(currentTerm)
-  }
-  return terms
+  toks.push({ kind: tokEOF, pos: s.length })
+  return toks
 }
 
-// returns true if this is a proper term query match, and false otherwise.
-export function isTermQuery(matchStr) {
-  if (!matchStr) return false;
-  // Split the string. If any of the criteria are query terms, return true.
-  let splits = parseTerms(matchStr)
-  for (let term of splits) {
-    for (let qt of QueryTerms) {
-      if (term.startsWith(qt) && (term.includes("<") || term.includes(">") || term.includes("=") || term.includes(":"))) {
-        // It's a term query.
-        return true
+// lexTerm reads one term starting at i: either field, operator and value, or
+// a bare word. It returns the token and the offset just past it.
+function lexTerm(s, i) {
+  const start = i
+
+  // A field name is a run of letters immediately followed by an operator.
+  let j = i
+  while (j < s.length && /[A-Za-z_]/.test(s[j])) {
+    j++
+  }
+  if (j > i) {
+    for (const op of operators) {
+      if (s.startsWith(op, j)) {
+        const [value, next] = lexValue(s, j + op.length)
+        if (value == "") {
+          throw queryError(start, `missing value for "${s.slice(i, j)}"`)
+        }
+        return [{ kind: tokTerm, pos: start, field: s.slice(i, j).toLowerCase(), op: op, value: value }, next]
       }
     }
   }
-  return false
-}
-
-function isGamesTerm(term) {
-  return term.startsWith("games")
-}
 
-function gamesMatch(terms, card) {
-  for (let term of terms) {
-    if (!isGamesTerm(term)) {
-      continue
-    }
-    if (!gameMatches(term, card)) {
-      return false
+  const [value, next] = lexValue(s, i)
+  if (s[i] != '"') {
+    switch (value.toUpperCase()) {
+      case "AND":
+        return [{ kind: tokAnd, pos: start }, next]
+      case "OR":
+        return [{ kind: tokOr, pos: start }, next]
+      case "NOT":
+        return [{ kind: tokNot, pos: start }, next]
     }
   }
-  return true
+  return [{ kind: tokTerm, pos: start, field: "", op: "", value: value }, next]
 }
 
-function gameMatches(term, card) {
-  if (!isGamesTerm(term)) {
-    return true
-  }
-
-  // If the card has no games, it can't match.
-  if (card.total_games === null) {
-    return false
-  }
-
-  // Handle gt and lt queries.
-  if (term.startsWith("games<")) {
-    let val = parseInt(term.replace("games<", ""))
-    if (card.total_games < val) {
-      return true
+// lexValue reads a value starting at i, either a double-quoted string or a
+// run of characters up to whitespace or a parenthesis.
+function lexValue(s, i) {
+  if (i < s.length && s[i] == '"') {
+    const end = s.indexOf('"', i + 1)
+    if (end < 0) {
+      throw queryError(i, "unterminated quote")
     }
+    return [s.slice(i + 1, end), end + 1]
   }
-  if (term.startsWith("games>")) {
-    let val = parseInt(term.replace("games>", ""))
-    if (card.total_games > val) {
-      return true
-    }
+  let j = i
+  while (j < s.length && !isSpace(s[j]) && s[j] != "(" && s[j] != ")") {
+    j++
   }
-  return false
-}
-
-function isMainboardTerm(term) {
-  return term.startsWith("mb")
+  return [s.slice(i, j), j]
 }
 
-function mainboardsMatch(terms, card) {
-  for (let term of terms) {
-    if (!isMainboardTerm(term)) {
-      continue
-    }
-    if (!mainboardMatches(term, card)) {
-      return false
-    }
+class parser {
+  constructor(toks) {
+    this.toks = toks
+    this.pos = 0
   }
-  return true
-}
 
-function mainboardMatches(term, card) {
-  if (!isMainboardTerm(term)) {
-    return true
-  }
-  if (card.mainboard === null) {
-    return false
+  peek() {
+    return this.toks[this.pos]
   }
 
-  if (term.startsWith("mb<")) {
-    let val = parseInt(term.replace("mb<", ""))
-    if (card.mainboard < val) {
-      return true
+  next() {
+    const tok = this.toks[this.pos]
+    if (tok.kind != tokEOF) {
+      this.pos++
     }
+    return tok
   }
-  if (term.startsWith("mb>")) {
-    let val = parseInt(term.replace("mb>", ""))
-    if (card.mainboard > val) {
-      return true
-    }
-  }
-  if (term.startsWith("mb=")) {
-    let val = parseInt(term.replace("mb=", ""))
-    return card.mainboard === val
-  }
-}
-
-function isSideboardTerm(term) {
-  return term.startsWith("sb")
-}
 
-function sideboardsMatch(terms, card) {
-  for (let term of terms) {
-    if (!isSideboardTerm(term)) {
-      continue
+  parseOr() {
+    const children = [this.parseAnd()]
+    while (this.peek().kind == tokOr) {
+      this.next()
+      children.push(this.parseAnd())
     }
-    if (!sideboardMatches(term, card)) {
-      return false
+    if (children.length == 1) {
+      return children[0]
     }
+    return { type: "or", children: children }
   }
-  return true
-}
 
-function sideboardMatches(term, card) {
-  if (!isSideboardTerm(term)) {
-    return true
-  }
-  if (card.sideboard == null) {
-    return false
-  }
-
-  if (term.startsWith("sb<")) {
-    let val = parseInt(term.replace("sb<", ""))
-    if (card.sideboard < val) {
-      return true
+  parseAnd() {
+    const children = [this.parseUnary()]
+    for (;;) {
+      switch (this.peek().kind) {
+        case tokEOF:
+        case tokRParen:
+        case tokOr:
+          if (children.length == 1) {
+            return children[0]
+          }
+          return { type: "and", children: children }
+        case tokAnd:
+          this.next()
+      }
+      children.push(this.parseUnary())
     }
   }
-  if (term.startsWith("sb>")) {
-    let val = parseInt(term.replace("sb>", ""))
-    if (card.sideboard > val) {
-      return true
+
+  parseUnary() {
+    if (this.peek().kind == tokNot) {
+      this.next()
+      return { type: "not", child: this.parseUnary() }
     }
+    return this.parsePrimary()
   }
-  if (term.startsWith("sb=")) {
-    let val = parseInt(term.replace("sb=", ""))
-    return card.sideboard === val
-  }
-}
 
-function isDraftsTerm(term) {
-  return term.startsWith("drafts")
-}
+  parsePrimary() {
+    const tok = this.next()
+    switch (tok.kind) {
+      case tokLParen: {
+        if (this.peek().kind == tokRParen) {
+          throw queryError(tok.pos, "empty parentheses")
+        }
+        const inner = this.parseOr()
+        if (this.next().kind != tokRParen) {
+          throw queryError(tok.pos, "missing closing )")
+        }
+        return inner
+      }
+      case tokTerm:
+        return newTerm(tok)
+    }
+    throw queryError(tok.pos, "expected a term")
+  }
+}
+
+// Field kinds say what a field's value is and what it is evaluated against.
+const cardText = "cardText"
+const cardColor = "cardColor"
+const cardNumber = "cardNumber"
+const cardKeyword = "cardKeyword"
+const deckText = "deckText"
+const deckColor = "deckColor"
+const deckNumber = "deckNumber"
+const statNumber = "statNumber"
+
+const cardKinds = [cardText, cardColor, cardNumber, cardKeyword]
+
+// fields maps every accepted field name, including aliases, to its canonical
+// name and kind.
+const fields = {
+  name: ["name", cardText],
+  n: ["name", cardText],
+  o: ["oracle", cardText],
+  oracle: ["oracle", cardText],
+  t: ["type", cardText],
+  type: ["type", cardText],
+  st: ["subtype", cardText],
+  subtype: ["subtype", cardText],
+  m: ["mana", cardText],
+  mana: ["mana", cardText],
+  tag: ["tag", cardText],
+  c: ["color", cardColor],
+  color: ["color", cardColor],
+  cmc: ["mv", cardNumber],
+  mv: ["mv", cardNumber],
+  pow: ["pow", cardNumber],
+  power: ["pow", cardNumber],
+  tou: ["tou", cardNumber],
+  toughness: ["tou", cardNumber],
+  is: ["is", cardKeyword],
+  arch: ["arch", deckText],
+  player: ["player", deckText],
+  event: ["event", deckText],
+  dcolor: ["dcolor", deckColor],
+  draftsize: ["draftsize", deckNumber],
+  mincards: ["mincards", deckNumber],
+  games: ["games", statNumber],
+  mb: ["mb", statNumber],
+  sb: ["sb", statNumber],
+  players: ["players", statNumber],
+  drafts: ["drafts", statNumber],
+  winpct: ["winpct", statNumber],
+}
+
+// keywords are the values is: accepts, mirroring the keyword checks and trait
+// names in the types package.
+const keywords = [
+  "creature", "land", "removal", "counterspell", "interaction", "handhate",
+  "deathtouch", "defender", "double_strike", "first_strike", "flash", "flying",
+  "haste", "hexproof", "indestructible", "lifelink", "menace", "prowess",
+  "reach", "trample", "vigilance", "ward", "cycling", "flashback", "kicker",
+  "cascade", "convoke", "equip",
+  "etb", "sac_outlet", "token_maker", "card_draw", "ramp", "recursion", "anthem",
+  "evasive", "card_advantage", "go_wide",
+]
 
-function draftsTermsMatch(terms, card) {
-  for (let term of terms) {
-    if (!isDraftsTerm(term)) {
-      continue
-    }
-    if (!draftTermMatches(term, card)) {
-      return false
-    }
+// newTerm validates a term token's field, operator and value.
+function newTerm(tok) {
+  let t = { type: "term", field: "", kind: "", op: tok.op, value: tok.value, colors: [], colorless: false }
+  if (tok.field == "") {
+    t.text = newPattern(tok.value)
+    return t
   }
-  return true
-}
 
-function draftTermMatches(term, card) {
-  if (!isDraftsTerm(term)) {
-    return true
-  }
-  if (card.drafts === null) {
-    return false
+  const f = fields[tok.field]
+  if (!f) {
+    throw queryError(tok.pos, `unknown field "${tok.field}"`)
   }
+  t.field = f[0]
+  t.kind = f[1]
 
-  if (term.startsWith("drafts<")) {
-    let val = parseInt(term.replace("drafts<", ""))
-    if (card.drafts < val) {
-      return true
+  switch (t.kind) {
+    case cardText:
+    case deckText:
+      if (t.op != ":" && t.op != "=" && t.op != "!=") {
+        throw queryError(tok.pos, `${tok.field} doesn't support "${t.op}"`)
+      }
+      // Labels are whole words, so arch: means "has this label".
+      if (t.field == "arch" && t.op == ":") {
+        t.op = "="
+      }
+      t.text = newPattern(t.value)
+      break
+    case cardKeyword:
+      if (t.op != ":" && t.op != "=" && t.op != "!=") {
+        throw queryError(tok.pos, `${tok.field} doesn't support "${t.op}"`)
+      }
+      // Keywords with spaces may be written either way: is:"first strike"
+      // or is:first_strike.
+      t.value = t.value.toLowerCase().replaceAll(" ", "_")
+      if (!keywords.includes(t.value)) {
+        throw queryError(tok.pos, `unknown is: keyword "${tok.value}"`)
+      }
+      break
+    case cardColor:
+    case deckColor: {
+      const v = t.value.toUpperCase()
+      if (v == "C" || v == "COLORLESS") {
+        t.colorless = true
+        break
+      }
+      for (const c of v) {
+        if (!"WUBRG".includes(c)) {
+          throw queryError(tok.pos, `invalid color "${c}" in "${tok.value}"`)
+        }
+        if (!t.colors.includes(c)) {
+          t.colors.push(c)
+        }
+      }
+      break
     }
-  }
-  if (term.startsWith("drafts>")) {
-    let val = parseInt(term.replace("drafts>", ""))
-    if (card.drafts > val) {
-      return true
+    default: {
+      const n = Number(t.value)
+      if (t.value.trim() == "" || isNaN(n)) {
+        throw queryError(tok.pos, `${tok.field} needs a number, got "${tok.value}"`)
+      }
+      if (t.field == "mincards" && t.op != ":" && t.op != "=") {
+        throw queryError(tok.pos, `${tok.field} doesn't support "${t.op}"`)
+      }
+      t.num = n
     }
   }
-  if (term.startsWith("drafts=")) {
-    let val = parseInt(term.replace("drafts=", ""))
-    return card.drafts === val
-  }
+  return t
 }
 
-function isPlayersTerm(term) {
-  return term.startsWith("players")
-}
-
-function playersTermsMatch(terms, card) {
-  for (let term of terms) {
-    if (!isPlayersTerm(term)) {
-      continue
-    }
-    if (!playerTermMatches(term, card)) {
-      return false
-    }
+// newPattern returns a case-insensitive text value in which * matches any run
+// of characters.
+function newPattern(v) {
+  let p = { lower: v.toLowerCase() }
+  if (p.lower.includes("*")) {
+    const re = p.lower.split("*").map(s => s.replace(/[.*+?^${}()|[\]\\]/g, "\\$&")).join(".*")
+    p.contains = new RegExp(re)
+    p.exact = new RegExp("^" + re + "$")
   }
-  return true
+  return p
 }
 
-function playerTermMatches(term, card) {
-  if (!isPlayersTerm(term)) {
-    return true
-  }
-  if (card.players == null) {
-    return false
-  }
-
-  let count = Object.entries(card.players).length
-
-  if (term.startsWith("players<")) {
-    let val = parseInt(term.replace("players<", ""))
-    if (count < val) {
-      return true
-    }
-  }
-  if (term.startsWith("players>")) {
-    let val = parseInt(term.replace("players>", ""))
-    if (count > val) {
-      return true
-    }
-  }
-  if (term.startsWith("players=")) {
-    let val = parseInt(term.replace("players=", ""))
-    return count === val
-  }
-
-  // A special ":" term for matching on a specific player name.
-  if (term.startsWith("players:")) {
-    let val = term.replace("players:", "")
-    if (card.players[val]) {
-      return true
-    }
+// patternIn reports whether s contains the pattern.
+function patternIn(p, s) {
+  s = (s || "").toLowerCase()
+  if (p.contains) {
+    return p.contains.test(s)
   }
-  return false
-}
-
-function isWinPercentageTerm(term) {
-  return term.startsWith("winpct")
+  return s.includes(p.lower)
 }
 
-function winPercentagesMatch(terms, card) {
-  for (let term of terms) {
-    if (!isWinPercentageTerm(term)) {
-      continue
-    }
-    if (!winPercentageMatches(term, card)) {
-      return false
-    }
+// patternIs reports whether s is the pattern in its entirety.
+function patternIs(p, s) {
+  s = (s || "").toLowerCase()
+  if (p.exact) {
+    return p.exact.test(s)
   }
-  return true
+  return s == p.lower
 }
 
-function winPercentageMatches(term, card) {
-  if (!isWinPercentageTerm(term)) {
-    return true
-  }
-  if (card.win_percent == null) {
-    return false
-  }
-
-  let cardVal = card.win_percent
+// isCard reports whether the term is about a single card.
+function isCard(t) {
+  return t.field != "" && cardKinds.includes(t.kind)
+}
 
-  if (term.startsWith("winpct<")) {
-    let val = parseInt(term.replace("winpct<", ""))
-    if (cardVal < val) {
-      return true
-    }
-  }
-  if (term.startsWith("winpct>")) {
-    let val = parseInt(term.replace("winpct>", ""))
-    if (cardVal > val) {
-      return true
-    }
+// cardStats returns the stats a stat term compares against, or null for a
+// card that isn't a stats row.
+function cardStats(card) {
+  if (card.total_games === undefined) {
+    return null
   }
-  if (term.startsWith("winpct=")) {
-    let val = parseInt(term.replace("winpct=", ""))
-    return cardVal === val
+  return {
+    games: card.total_games,
+    mb: card.mainboard,
+    sb: card.sideboard,
+    players: Object.keys(card.players || {}).length,
+    drafts: card.drafts || 0,
+    winpct: card.win_percent,
   }
-  return false
 }
 
-function isCMCTerm(term) {
-  return term.startsWith("cmc")
+// hasKeyword evaluates an is: keyword. The stats rows carry the interaction
+// checks as flags; otherwise fall back to the card's classified traits.
+function hasKeyword(card, kw) {
+  const roles = card.roles || []
+  switch (kw) {
+    case "creature":
+      return (card.types || []).includes("Creature")
+    case "land":
+      return (card.types || []).includes("Land")
+    case "removal":
+    case "counterspell":
+      return card[kw] !== undefined ? card[kw] : roles.includes(kw)
+    case "handhate":
+      return roles.includes(kw)
+    case "interaction":
+      if (card.interaction !== undefined) {
+        return card.interaction
+      }
+      return ["removal", "counterspell", "handhate"].some(r => roles.includes(r))
+  }
+  return (card.keywords || []).includes(kw) || (card.mechanics || []).includes(kw) || roles.includes(kw)
+}
+
+function matchCard(n, card, stats) {
+  switch (n.type) {
+    case "and":
+      return n.children.every(c => matchCard(c, card, stats))
+    case "or":
+      return n.children.some(c => matchCard(c, card, stats))
+    case "not":
+      return !matchCard(n.child, card, stats)
+  }
+  return matchCardTerm(n, card, stats)
+}
+
+function matchCardTerm(t, card, stats) {
+  if (t.field == "") {
+    return patternIn(t.text, card.name) || patternIn(t.text, card.oracle_text)
+  }
+  switch (t.field) {
+    case "name":
+      return matchText(t, [card.name])
+    case "oracle":
+      return matchText(t, [card.oracle_text])
+    case "type":
+      return matchText(t, (card.types || []).concat(card.sub_types || []))
+    case "subtype":
+      return matchText(t, card.sub_types || [])
+    case "mana":
+      return matchText(t, [card.mana_cost])
+    case "tag":
+      return matchText(t, card.tags || [])
+    case "color":
+      return matchColors(t, card.colors || [])
+    case "mv":
+      return matchNumber(t, card.cmc || 0)
+    case "pow":
+      return matchStat(t, card.power)
+    case "tou":
+      return matchStat(t, card.toughness)
+    case "is": {
+      const is = hasKeyword(card, t.value)
+      return t.op == "!=" ? !is : is
+    }
+  }
+  if (t.kind == statNumber && stats) {
+    return matchNumber(t, stats[t.field])
+  }
+  // Deck fields, and stat fields without stats, don't constrain a card.
+  return true
 }
 
-function cmcsMatch(terms, card) {
-  for (let term of terms) {
-    if (!isCMCTerm(term)) {
-      continue
-    }
-    if (!cmcMatches(term, card)) {
-      return false
-    }
-  }
+// matchDeckTerm evaluates a deck-level term, or a bare word, which matches the
+// deck's player, event or labels before falling back to its cards.
+function matchDeckTerm(t, deck, cards) {
+  switch (t.field) {
+    case "":
+      if (patternIn(t.text, deck.player) || patternIn(t.text, deckEvent(deck))) {
+        return true
+      }
+      if (deckLabels(deck).some(l => patternIn(t.text, l))) {
+        return true
+      }
+      return anyCard(t, cards)
+    case "arch":
+      return matchText(t, deckLabels(deck))
+    case "player":
+      return matchText(t, [deck.player])
+    case "event":
+      return matchText(t, [deckEvent(deck)])
+    case "dcolor":
+      return matchColors(t, deck.colors || [])
+    case "draftsize":
+      return matchNumber(t, deck.draft_size || 0)
+  }
+  // mincards is applied by the conjunction it belongs to; stat fields need
+  // per-card stats a deck doesn't have.
   return true
 }
 
-function cmcMatches(term, card) {
-  if (!isCMCTerm(term)) {
-    // Not a cmc query - always matches.
-    return true
+// deckLabels returns the deck's labels with its macro archetype, which arch:
+// and bare words match alike.
+function deckLabels(deck) {
+  const labels = deck.labels || []
+  if (deck.macro_archetype) {
+    return [deck.macro_archetype].concat(labels)
   }
-
-  // If the card has no cmc, it can't match.
-  if (card.cmc == null) {
-    return false
-  }
-
-  let cmc = parseInt(card.cmc)
-  if (isNaN(cmc)) {
-    return false
-  }
-
-  // If the term is a cmc query, we need to check if the card's cmc matches.
-  if (term.startsWith("cmc<")) {
-    let val = parseInt(term.replace("cmc<", ""))
-    if (cmc < val) {
-      return true
-    }
-  }
-  if (term.startsWith("cmc>")) {
-    let val = parseInt(term.replace("cmc>", ""))
-    if (cmc > val) {
-      return true
-    }
-  }
-  if (term.startsWith("cmc=")) {
-    let val = parseInt(term.replace("cmc=", ""))
-    if (cmc == val) {
-      return true
-    }
-  }
-  return false
+  return labels
 }
 
-function isNameTerm(term) {
-  return term.startsWith("name:")
+function deckEvent(deck) {
+  return (deck.metadata && deck.metadata.draft_id) || ""
 }
 
-function nameMatches(term, card) {
-  if (!isNameTerm(term)) {
-    // Not a name query - always matches.
-    return true
-  }
-
-  // Remove the name: prefix and any quotes.
-  let query = term.replace("name:", "").replace(/"/g, "").toLowerCase()
-  if (card.name.toLowerCase().match(query)) {
-    return true
+// matchText applies a text operator across vals: ":" matches when any value
+// contains the pattern, "=" when any equals it, and "!=" when none does.
+function matchText(t, vals) {
+  switch (t.op) {
+    case ":":
+      return vals.some(v => patternIn(t.text, v))
+    case "=":
+      return vals.some(v => patternIs(t.text, v))
+    case "!=":
+      return !vals.some(v => patternIs(t.text, v))
   }
   return false
 }
 
-function namesMatch(terms, card) {
-  for (let term of terms) {
-    if (!isNameTerm(term)) {
-      continue
-    }
-    if (nameMatches(term, card)) {
-      return true
-    }
-  }
-  return false
-}
+// matchColors compares a color set with the term's. ":" and ">=" mean "at
+// least these colors" (or, for c:c, exactly colorless), "<=" means "within
+// these colors", and "<" and ">" are the strict versions.
+function matchColors(t, have) {
+  const set = new Set(have.map(c => c.toUpperCase()))
+  const superset = t.colors.every(c => set.has(c))
+  const subset = [...set].every(c => t.colors.includes(c))
+  const equal = superset && subset
 
-function colorsMatch(terms, card) {
-  for (let term of terms) {
-    if (!isColorTerm(term)) {
-      continue
-    }
-    if (colorMatches(term, card)) {
-      return true
-    }
+  switch (t.op) {
+    case ":":
+      if (t.colorless) {
+        return set.size == 0
+      }
+      return superset
+    case ">=":
+      return superset
+    case "=":
+      return equal
+    case "!=":
+      return !equal
+    case "<=":
+      return subset
+    case "<":
+      return subset && !equal
+    case ">":
+      return superset && !equal
   }
   return false
 }
 
-function isColorTerm(term) {
-  return term.startsWith("color:") || term.startsWith("color=") || term.startsWith("color!=")
-}
-
-function colorMatches(term, card) {
-  if (!isColorTerm(term)) {
-    // Not a color query - always matches.
-    return true
-  }
-
-  // If the card has no colors, it can't match.
-  if (!card.colors || card.colors.length == 0) {
-    return false
-  }
-  let cardColors = CombineColors(card.colors).toLowerCase()
-
-  // "!=" means "not exactly the given colors".
-  // e.g., "color!=uw" means the card is not exactly blue and white.
-  if (term.includes("!=")) {
-    let val = term.replace("color!=", "").toLowerCase()
-    let query = CombineColors(val.split("")).toLowerCase()
-    if (query != cardColors) {
-      return true
-    }
-    return false
-  }
-
-  // "=" means "exactly the given colors".
-  // e.g., "color=uw" means the card is exactly blue and white.
-  if (term.includes("=")) {
-    let val = term.replace("color=", "").toLowerCase()
-    let query = CombineColors(val.split("")).toLowerCase()
-    if (query == cardColors) {
-      return true
-    }
-    return false
-  }
-
-  // ":" means "contains the given colors".
-  // e.g., "color:uw" means the card contains both blue and white.
-  // "color:u" means the card contains blue.
-  let query = term.replace("color:", "").toLowerCase()
-
-  // True if all of the colors are included in the card's colors.
-  if (query.split("").every(c => cardColors.includes(c))) {
-    return true
+function matchNumber(t, v) {
+  switch (t.op) {
+    case ":":
+    case "=":
+      return v == t.num
+    case "!=":
+      return v != t.num
+    case "<":
+      return v < t.num
+    case "<=":
+      return v <= t.num
+    case ">":
+      return v > t.num
+    case ">=":
+      return v >= t.num
   }
   return false
 }
 
-function powMatches(term, card) {
-  if (!term.startsWith("pow")) {
-    // Not a power query - always matches.
-    return true
-  }
-
-  // If the card has no power, it can't match.
-  if (card.power == null) {
+// matchStat compares a printed power or toughness. Non-numeric values like "*"
+// never match.
+function matchStat(t, s) {
+  if (!/^[+-]?\d+$/.test(s || "")) {
     return false
   }
-
-  // Parse the power string as an int.
-  let power = parseInt(card.power)
-  if (isNaN(power)) {
-    return false
-  }
-
-  // If the term is a power query, we need to check if the card's power matches.
-  if (term.startsWith("pow<")) {
-    let val = parseInt(term.replace("pow<", ""))
-    if (power < val) {
-      return true
-    }
-  }
-  if (term.startsWith("pow>")) {
-    let val = parseInt(term.replace("pow>", ""))
-    if (power > val) {
-      return true
-    }
-  }
-  if (term.startsWith("pow=")) {
-    let val = parseInt(term.replace("pow=", ""))
-    if (power == val) {
-      return true
-    }
-  }
-  return false
-}
-
-// Check if any of the oracle terms match, and return true if they do.
-function oraclesMatch(terms, card) {
-  for (let term of terms) {
-    if (!term.startsWith("o:")) {
-      continue
-    }
-
-    if (oracleMatches(term, card)) {
-      return true
-    }
-  }
-  return false
+  return matchNumber(t, parseInt(s))
 }
-
-function powersMatch(terms, card) {
-  for (let term of terms) {
-    if (!term.startsWith("pow")) {
-      continue
-    }
-    if (powMatches(term, card)) {
-      return true
+
+// cardOnly reports whether n only involves card terms, and so describes a
+// single card.
+function cardOnly(n) {
+  switch (n.type) {
+    case "term":
+      return isCard(n)
+    case "not":
+      return cardOnly(n.child)
+  }
+  return n.children.every(cardOnly)
+}
+
+// anyCard reports whether any of the cards satisfies n.
+function anyCard(n, cards) {
+  return cards.some(c => matchCard(n, c, null))
+}
+
+// A caller can't evaluate every term: a lone card has no deck, and a deck's
+// cards have no play stats. Such a term must not filter either way, so before
+// matching, the terms a caller can't evaluate are pruned out in a way that
+// only ever widens the result. widen returns a node matched by everything n
+// is, and narrow a node matching only what n does; under NOT one turns into
+// the other.
+
+// widen returns n with the terms keep rejects taken out so that the result
+// matches at least what n does, or null if it matches everything.
+function widen(n, keep) {
+  switch (n.type) {
+    case "term":
+      return keep(n) ? n : null
+    case "not": {
+      const child = narrow(n.child, keep)
+      return child ? { type: "not", child: child } : null
+    }
+    case "and": {
+      const children = n.children.map(c => widen(c, keep)).filter(c => c)
+      return children.length ? { type: "and", children: children } : null
+    }
+    case "or": {
+      const children = n.children.map(c => widen(c, keep))
+      return children.every(c => c) ? { type: "or", children: children } : null
+    }
+  }
+  return n
+}
+
+// narrow returns n with the terms keep rejects taken out so that the result
+// matches at most what n does, or null if it matches nothing.
+function narrow(n, keep) {
+  switch (n.type) {
+    case "term":
+      return keep(n) ? n : null
+    case "not": {
+      const child = widen(n.child, keep)
+      return child ? { type: "not", child: child } : null
+    }
+    case "and": {
+      const children = n.children.map(c => narrow(c, keep))
+      return children.every(c => c) ? { type: "and", children: children } : null
+    }
+    case "or": {
+      const children = n.children.map(c => narrow(c, keep)).filter(c => c)
+      return children.length ? { type: "or", children: children } : null
+    }
+  }
+  return n
+}
+
+// cardTerm, cardStatTerm and deckTerm say which terms can be evaluated
+// against a lone card, a card with its stats, and a deck.
+function cardTerm(t) { return t.field == "" || isCard(t) }
+function cardStatTerm(t) { return cardTerm(t) || t.kind == statNumber }
+function deckTerm(t) { return t.kind != statNumber }
+
+// boardCards returns the cards from the requested board. Card terms match
+// against the mainboard by default.
+function boardCards(deck, board) {
+  switch (board) {
+    case "Sideboard":
+      return deck.sideboard || []
+    case "Pool":
+      return deck.pool || []
+  }
+  return deck.mainboard || []
+}
+
+// matchDeck evaluates n against a deck whose relevant board is cards.
+//
+// Card terms that are ANDed together describe one card, so `t:creature mv<=2`
+// matches decks running a cheap creature rather than decks with any creature
+// and any cheap card. A conjunction's mincards term raises how many cards must
+// satisfy it. A negated card term with no positive card term alongside it
+// excludes decks running any matching card.
+function matchDeck(n, deck, cards) {
+  switch (n.type) {
+    case "not":
+      return !matchDeck(n.child, deck, cards)
+    case "or":
+      if (cardOnly(n)) {
+        return anyCard(n, cards)
+      }
+      return n.children.some(c => matchDeck(c, deck, cards))
+    case "and": {
+      let need = 1
+      let cardTerms = []
+      let positive = false
+      for (const child of n.children) {
+        if (child.type == "term" && child.field == "mincards") {
+          need = Math.max(need, Math.trunc(child.num))
+          continue
+        }
+        if (cardOnly(child)) {
+          cardTerms.push(child)
+          if (child.type != "not") {
+            positive = true
+          }
+          continue
+        }
+        if (!matchDeck(child, deck, cards)) {
+          return false
+        }
+      }
+      if (!positive) {
+        return cardTerms.every(c => matchDeck(c, deck, cards))
+      }
+      const card = { type: "and", children: cardTerms }
+      return cards.filter(c => matchCard(card, c, null)).length >= need
     }
   }
-  return false
-}
-
-function oracleMatches(term, card) {
-  if (!term.startsWith("o:")) {
-    // Not an oracle query - always matches.
-    return true
+  if (isCard(n)) {
+    return anyCard(n, cards)
   }
-
-  // Remove the o: prefix and any quotes.
-  let query = term.replace("o:", "").replace(/"/g, "").toLowerCase()
-  if (card.oracle_text.toLowerCase().match(query)) {
-    return true
-  }
-  return false
+  return matchDeckTerm(n, deck, cards)
 }
 
-function isDeckTypeTerm(term) {
-  return term.startsWith("arch:") || term.startsWith("arch!=")
-}
-
-function deckTypesMatch(terms, deck) {
-  for (let term of terms) {
-    if (!isDeckTypeTerm(term)) {
-      continue
-    }
-    if (deckTypeMatches(term, deck)) {
-      return true
+export function parseTerms(matchStr) {
+  // Split the string into terms. A term ends at a space, unless the space is inside quotes.
+  let terms = []
+  let currentTerm = ""
+  let inQuotes = false
+  for (let char of matchStr) {
+    if (char == '"') {
+      inQuotes = !inQuotes
+      currentTerm += char
+    } else if (char == " " && !inQuotes) {
+      if (currentTerm.length > 0) {
+        terms.push(currentTerm)
+        currentTerm = ""
+      }
+    } else {
+      currentTerm += char
     }
   }
-  return false
-}
-
-function deckTypeMatches(term, deck) {
-  if (!isDeckTypeTerm(term)) {
-    // Not a deck type query - always matches.
-    return true
-  }
-
-  // Remove the arch: prefix and any quotes.
-  let query = term.replace("arch:", "").replace(/"/g, "").toLowerCase()
-
-  // Return true if any of the deck's types match the query.
-  if (deck.macro_archetype && deck.macro_archetype.toLowerCase() == query) {
-    return true
-  }
-  if ((deck.labels || []).some(t => t.toLowerCase() == query)) {
-    return true
+  if (currentTerm.length > 0) {
+    terms.push(currentTerm)
   }
-
-  return false
-}
-
-function isTypeTerm(term) {
-  return term.startsWith("t:") || term.startsWith("t!=")
+  return terms
 }
 
-function typesMatch(terms, card) {
-  for (let term of terms) {
-    if (!isTypeTerm(term)) {
-      continue
-    }
-    if (typeMatches(term, card)) {
-      return true
+// returns true if this is a proper term query match, and false otherwise.
+export function isTermQuery(matchStr) {
+  if (!matchStr) return false;
+  // Split the string. If any of the criteria are query terms, return true.
+  let splits = parseTerms(matchStr)
+  for (let term of splits) {
+    for (let qt of QueryTerms) {
+      if (term.startsWith(qt) && (term.includes("<") || term.includes(">") || term.includes("=") || term.includes(":"))) {
+        // It's a term query.
+        return true
+      }
     }
   }
   return false
 }
-
-function typeMatches(term, card) {
-  if (!isTypeTerm(term)) {
-    // Not a type query - always matches.
-    return true
-  }
-
-  // Remove the t: prefix and any quotes.
-  let query = term.replace("t:", "").replace(/"/g, "").toLowerCase()
-  // Return true if any of the card's types match the query.
-
-  if (card.types.some(t => t.toLowerCase() == query)) {
-    return true
-  }
-
-  return false
-}
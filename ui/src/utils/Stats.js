@@ -12,6 +12,16 @@ export const WIN_CONFIDENCE_OPTS = [
   { label: "99%", value: "0.99" },
 ]
 
+// Bucket size counts drafts by default; the other modes group drafts into
+// calendar periods or cube versions and count those.
+export const BUCKET_BY_OPTS = [
+  { label: "Drafts", value: "drafts" },
+  { label: "Month", value: "month" },
+  { label: "Quarter", value: "quarter" },
+  { label: "Season", value: "season" },
+  { label: "Cube version", value: "cube_version" },
+]
+
 // Two-sided normal critical values for the offered levels. A lookup table keeps
 // us from pulling in an inverse-normal for four fixed values.
 const Z_BY_CONFIDENCE = {
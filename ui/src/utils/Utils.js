@@ -193,6 +193,11 @@ export function CardImageURL(card) {
   return `https://api.scryfall.com/cards/named?format=image&exact=${encodeURIComponent(card.name)}`
 }
 
+// MacroLabel capitalizes a macro archetype for display, e.g. "aggro" -> "Aggro".
+export function MacroLabel(macro) {
+  return macro.charAt(0).toUpperCase() + macro.slice(1)
+}
+
 export function SortFunc(a, b) {
   if (a.props.sort > b.props.sort) {
     return -1